@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"rentcamp/config"
+	"rentcamp/helper"
+	"rentcamp/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+func createOwner(am model.AdminModelInterface, username string, password string) error {
+	if username == "" || password == "" {
+		return errors.New("username and password are required")
+	}
+
+	hashpwd, err := helper.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	var owner = model.Admin{
+		Username: username,
+		Password: hashpwd,
+		Role:     helper.RoleOwner,
+	}
+	if am.Insert(owner) == nil {
+		return errors.New("cannot insert owner account")
+	}
+
+	return nil
+}
+
+// bootstrapOwner creates the first owner from ADMIN_USERNAME and
+// ADMIN_PASSWORD when the admins table is still empty. Every admin after
+// that has to be invited by an owner.
+func bootstrapOwner(am model.AdminModelInterface, cfg config.Config) {
+	if cfg.AdminUsername == "" || am.Count() > 0 {
+		return
+	}
+
+	if err := createOwner(am, cfg.AdminUsername, cfg.AdminPassword); err != nil {
+		logrus.Error("Bootstrap : cannot create owner account, ", err.Error())
+		return
+	}
+
+	logrus.Info("Bootstrap : owner account created for ", cfg.AdminUsername)
+}
+
+// runCreateOwner handles `app create-owner -username x -password y`. It is
+// refused while an active owner exists so it cannot be used to bypass
+// invitations.
+func runCreateOwner(am model.AdminModelInterface, args []string) {
+	var fs = flag.NewFlagSet("create-owner", flag.ExitOnError)
+	var username = fs.String("username", "", "owner username")
+	var password = fs.String("password", "", "owner password")
+	fs.Parse(args)
+
+	if am.CountActiveOwners() > 0 {
+		logrus.Fatal("Bootstrap : an active owner already exists, invite new admins instead")
+	}
+
+	if err := createOwner(am, *username, *password); err != nil {
+		logrus.Fatal("Bootstrap : cannot create owner account, ", err.Error())
+	}
+
+	logrus.Info("Bootstrap : owner account created for ", *username)
+}
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"net"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 	"github.com/sirupsen/logrus"
@@ -21,10 +23,92 @@ type Config struct {
 	CDN_API_Key     string
 	CDN_API_Secret  string
 	CDN_Folder_Name string
+	AdminUsername   string
+	AdminPassword   string
+	AdminInviteTTL  int
+
+	LoginMaxAttempts    int
+	LoginMaxIPAttempts  int
+	LoginBackoffSeconds int
+	LoginLockoutMinutes int
+
+	// TrustedProxies are the address ranges of the reverse proxies in front
+	// of the API. Client addresses are only read from X-Forwarded-For when
+	// the request comes through one of them.
+	TrustedProxies []*net.IPNet
+
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+
+	CheckoutHoldMinutes int
+	HoldSweepSeconds    int
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUser     string
+	SMTPPassword string
+	MailFrom     string
+	AppBaseURL   string
+
+	CartReminderHours int
+	CartArchiveDays   int
+	CartJobMinutes    int
+
+	// WishlistJobMinutes is how often wishlists are checked for price drops
+	// and restocks; 0 turns the notifications off.
+	WishlistJobMinutes int
+
+	WaitlistHoldMinutes int
+
+	// AffinityJobMinutes is how often "rented together" counts are rebuilt
+	// from order history; 0 only builds them at startup.
+	AffinityJobMinutes int
+
+	// SearchEngine is "mysql" (FULLTEXT) or "memory" (in-process index).
+	SearchEngine string
+
+	// SuggestMinSearchers is how many different visitors must have searched
+	// a query before it is suggested to everyone. Queries containing a word
+	// of SuggestBlocklist are never suggested.
+	SuggestMinSearchers int
+	SuggestBlocklist    []string
+
+	// SearchLogRetentionDays is how long searches are kept; 0 keeps them
+	// forever.
+	SearchLogRetentionDays int
+
+	// MainBranch* describe the branch created for a store that had no
+	// branches yet. Without a location it stays out of "near" searches
+	// until an admin sets one.
+	MainBranchName      string
+	MainBranchAddress   string
+	MainBranchLatitude  float64
+	MainBranchLongitude float64
 }
 
 func loadConfig() *Config {
 	var res = new(Config)
+	res.AdminInviteTTL = 72
+	res.LoginMaxAttempts = 5
+	res.LoginMaxIPAttempts = 20
+	res.LoginBackoffSeconds = 1
+	res.LoginLockoutMinutes = 15
+	res.CheckoutHoldMinutes = 15
+	res.HoldSweepSeconds = 60
+	res.SMTPPort = 587
+	res.CartReminderHours = 24
+	res.CartArchiveDays = 30
+	res.CartJobMinutes = 60
+	res.WishlistJobMinutes = 60
+	res.WaitlistHoldMinutes = 30
+	res.AffinityJobMinutes = 60
+	res.SearchEngine = "mysql"
+	res.SuggestMinSearchers = 3
+	res.SearchLogRetentionDays = 90
+	res.MainBranchName = "Main store"
+
 	var err = godotenv.Load(".ENV")
 	if err != nil {
 		logrus.Error("Config : Cannot load config file, ", err.Error())
@@ -83,6 +167,139 @@ func loadConfig() *Config {
 		res.CDN_Folder_Name = val
 	}
 
+	if val, found := os.LookupEnv("ADMIN_USERNAME"); found {
+		res.AdminUsername = val
+	}
+	if val, found := os.LookupEnv("ADMIN_PASSWORD"); found {
+		res.AdminPassword = val
+	}
+	if val, found := os.LookupEnv("ADMIN_INVITE_TTL"); found {
+		hours, err := strconv.Atoi(val)
+		if err != nil {
+			logrus.Error("Config : invalid admin invite ttl value, ", err.Error())
+			return nil
+		}
+		res.AdminInviteTTL = hours
+	}
+
+	if val, found := os.LookupEnv("OIDC_ISSUER"); found {
+		res.OIDCIssuer = val
+	}
+	if val, found := os.LookupEnv("OIDC_CLIENT_ID"); found {
+		res.OIDCClientID = val
+	}
+	if val, found := os.LookupEnv("OIDC_CLIENT_SECRET"); found {
+		res.OIDCClientSecret = val
+	}
+	if val, found := os.LookupEnv("OIDC_REDIRECT_URL"); found {
+		res.OIDCRedirectURL = val
+	}
+
+	if val, found := os.LookupEnv("SMTP_HOST"); found {
+		res.SMTPHost = val
+	}
+	if val, found := os.LookupEnv("SMTP_USER"); found {
+		res.SMTPUser = val
+	}
+	if val, found := os.LookupEnv("SMTP_PASSWORD"); found {
+		res.SMTPPassword = val
+	}
+	if val, found := os.LookupEnv("MAIL_FROM"); found {
+		res.MailFrom = val
+	}
+	if val, found := os.LookupEnv("APP_BASE_URL"); found {
+		res.AppBaseURL = val
+	}
+	if val, found := os.LookupEnv("SEARCH_ENGINE"); found {
+		res.SearchEngine = val
+	}
+	if val, found := os.LookupEnv("SUGGEST_BLOCKLIST"); found {
+		for _, word := range strings.Split(val, ",") {
+			if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+				res.SuggestBlocklist = append(res.SuggestBlocklist, word)
+			}
+		}
+	}
+
+	if val, found := os.LookupEnv("TRUSTED_PROXIES"); found {
+		for _, entry := range strings.Split(val, ",") {
+			if entry = strings.TrimSpace(entry); entry == "" {
+				continue
+			}
+			if !strings.Contains(entry, "/") {
+				if ip := net.ParseIP(entry); ip != nil && ip.To4() == nil {
+					entry += "/128"
+				} else {
+					entry += "/32"
+				}
+			}
+			_, ipRange, err := net.ParseCIDR(entry)
+			if err != nil {
+				logrus.Error("Config : invalid TRUSTED_PROXIES value, ", err.Error())
+				return nil
+			}
+			res.TrustedProxies = append(res.TrustedProxies, ipRange)
+		}
+	}
+
+	if val, found := os.LookupEnv("MAIN_BRANCH_NAME"); found {
+		res.MainBranchName = val
+	}
+	if val, found := os.LookupEnv("MAIN_BRANCH_ADDRESS"); found {
+		res.MainBranchAddress = val
+	}
+	for key, target := range map[string]*float64{
+		"MAIN_BRANCH_LATITUDE":  &res.MainBranchLatitude,
+		"MAIN_BRANCH_LONGITUDE": &res.MainBranchLongitude,
+	} {
+		if val, found := os.LookupEnv(key); found {
+			num, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				logrus.Error("Config : invalid ", key, " value, ", err.Error())
+				return nil
+			}
+			*target = num
+		}
+	}
+
+	for key, target := range map[string]*int{
+		"LOGIN_MAX_ATTEMPTS":        &res.LoginMaxAttempts,
+		"LOGIN_MAX_IP_ATTEMPTS":     &res.LoginMaxIPAttempts,
+		"LOGIN_BACKOFF_SECONDS":     &res.LoginBackoffSeconds,
+		"LOGIN_LOCKOUT_MINUTES":     &res.LoginLockoutMinutes,
+		"CHECKOUT_HOLD_MINUTES":     &res.CheckoutHoldMinutes,
+		"HOLD_SWEEP_SECONDS":        &res.HoldSweepSeconds,
+		"SMTP_PORT":                 &res.SMTPPort,
+		"CART_REMINDER_HOURS":       &res.CartReminderHours,
+		"CART_ARCHIVE_DAYS":         &res.CartArchiveDays,
+		"CART_JOB_MINUTES":          &res.CartJobMinutes,
+		"WISHLIST_JOB_MINUTES":      &res.WishlistJobMinutes,
+		"WAITLIST_HOLD_MINUTES":     &res.WaitlistHoldMinutes,
+		"AFFINITY_JOB_MINUTES":      &res.AffinityJobMinutes,
+		"SUGGEST_MIN_SEARCHERS":     &res.SuggestMinSearchers,
+		"SEARCH_LOG_RETENTION_DAYS": &res.SearchLogRetentionDays,
+	} {
+		if val, found := os.LookupEnv(key); found {
+			num, err := strconv.Atoi(val)
+			if err != nil {
+				logrus.Error("Config : invalid ", key, " value, ", err.Error())
+				return nil
+			}
+			*target = num
+		}
+	}
+
+	for key, val := range map[string]int{
+		"CHECKOUT_HOLD_MINUTES": res.CheckoutHoldMinutes,
+		"CART_REMINDER_HOURS":   res.CartReminderHours,
+		"CART_ARCHIVE_DAYS":     res.CartArchiveDays,
+	} {
+		if val <= 0 {
+			logrus.Error("Config : ", key, " must be greater than zero")
+			return nil
+		}
+	}
+
 	return res
 }
 
@@ -0,0 +1,28 @@
+package config
+
+import "testing"
+
+func TestLoadConfigRejectsNonPositiveDurations(t *testing.T) {
+	var tests = []struct {
+		key   string
+		value string
+		valid bool
+	}{
+		{"CHECKOUT_HOLD_MINUTES", "30", true},
+		{"CHECKOUT_HOLD_MINUTES", "0", false},
+		{"CHECKOUT_HOLD_MINUTES", "-5", false},
+		{"CART_REMINDER_HOURS", "12", true},
+		{"CART_REMINDER_HOURS", "0", false},
+		{"CART_ARCHIVE_DAYS", "7", true},
+		{"CART_ARCHIVE_DAYS", "-1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+			if got := loadConfig(); (got != nil) != tt.valid {
+				t.Fatalf("loadConfig() = %v, want valid %v", got, tt.valid)
+			}
+		})
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"rentcamp/config"
 	"rentcamp/helper"
 	"rentcamp/model"
+	"rentcamp/pagination"
+	"strconv"
+	"time"
 
 	"github.com/golang-jwt/jwt"
 	"github.com/labstack/echo/v4"
@@ -13,35 +16,210 @@ import (
 type AdminControllerInterface interface {
 	CreateUser() echo.HandlerFunc
 	Login() echo.HandlerFunc
+	CreateInvite() echo.HandlerFunc
+	GetAllAdmins() echo.HandlerFunc
+	DisableAdmin() echo.HandlerFunc
+	EnableAdmin() echo.HandlerFunc
+	DeleteAdmin() echo.HandlerFunc
+	UnlockAdmin() echo.HandlerFunc
+	LoginMFA() echo.HandlerFunc
+	EnrollMFAChallenge() echo.HandlerFunc
+	ActivateMFAChallenge() echo.HandlerFunc
+	EnrollTOTP() echo.HandlerFunc
+	VerifyTOTP() echo.HandlerFunc
+	DisableTOTP() echo.HandlerFunc
+	SetMFARequired() echo.HandlerFunc
 }
 
 type AdminController struct {
-	config config.Config
-	model  model.AdminModelInterface
+	config   config.Config
+	model    model.AdminModelInterface
+	settings model.SettingModelInterface
+	throttle *helper.LoginThrottle
 }
 
-func NewAdminControlInterface(m model.AdminModelInterface) AdminControllerInterface {
+func NewAdminControlInterface(m model.AdminModelInterface, s model.SettingModelInterface, cfg config.Config, throttle *helper.LoginThrottle) AdminControllerInterface {
 	return &AdminController{
-		model: m,
+		model:    m,
+		settings: s,
+		config:   cfg,
+		throttle: throttle,
 	}
 }
 
+// Revisi: admin baru hanya bisa dibuat lewat invite dari owner
 func (uc *AdminController) CreateUser() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		var input = model.Admin{}
+		var input = model.AdminRegister{}
 		if err := c.Bind(&input); err != nil {
 			return c.JSON(http.StatusBadRequest, helper.FormatResponse("invalid user input", nil))
 		}
 
-		var res = uc.model.Insert(input)
-		if res == nil {
+		if input.InviteToken == "" {
+			return c.JSON(http.StatusUnauthorized, helper.FormatResponse("invite token is required", nil))
+		}
+
+		if input.Username == "" || input.Password == "" {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("username and password are required", nil))
+		}
+
+		hashpwd, err := helper.HashPassword(input.Password)
+		if err != nil {
 			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Cannot process data, something happend", nil))
 		}
 
+		var newAdmin = model.Admin{
+			Username: input.Username,
+			Password: hashpwd,
+		}
+
+		res, err := uc.model.RedeemInvite(helper.HashToken(input.InviteToken), newAdmin)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Cannot create admin: "+err.Error(), nil))
+		}
+
 		return c.JSON(http.StatusCreated, helper.FormatResponse("success create user", res))
 	}
 }
 
+func (uc *AdminController) CreateInvite() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var input = model.AdminInvite{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("invalid invite input", nil))
+		}
+
+		if !helper.IsStaffRole(input.Role) {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("role must be one of owner, manager, warehouse or support", nil))
+		}
+
+		adminID, _, _ := helper.TokenUser(c)
+
+		token, err := helper.GenerateRandomToken(24)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Cannot process data, something happend", nil))
+		}
+
+		var invite = model.AdminInvite{
+			TokenHash: helper.HashToken(token),
+			Role:      helper.NormalizeRole(input.Role),
+			CreatedBy: adminID,
+			ExpiresAt: time.Now().Add(time.Duration(uc.config.AdminInviteTTL) * time.Hour),
+		}
+
+		var res = uc.model.CreateInvite(invite)
+		if res == nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Cannot process data, something happend", nil))
+		}
+
+		var data = map[string]any{}
+		data["invite_token"] = token
+		data["role"] = res.Role
+		data["expires_at"] = res.ExpiresAt
+
+		return c.JSON(http.StatusCreated, helper.FormatResponse("success create invite", data))
+	}
+}
+
+func (uc *AdminController) GetAllAdmins() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
+
+		var res = uc.model.SelectAll(page)
+		if res == nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get all admins", nil))
+		}
+
+		data, meta := pagination.KeysetResult(page, res, func(admin model.Admin) int { return admin.Id })
+		return c.JSON(http.StatusOK, pagination.Response("Success get all admins", data, meta))
+	}
+}
+
+func (uc *AdminController) DisableAdmin() echo.HandlerFunc {
+	return uc.setDisabled(true)
+}
+
+func (uc *AdminController) EnableAdmin() echo.HandlerFunc {
+	return uc.setDisabled(false)
+}
+
+func (uc *AdminController) setDisabled(disabled bool) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		if disabled {
+			if msg := uc.checkRemovable(c, id); msg != "" {
+				return c.JSON(http.StatusConflict, helper.FormatResponse(msg, nil))
+			}
+		}
+
+		var res = uc.model.SetDisabled(id, disabled)
+		if res == nil {
+			return c.JSON(http.StatusNotFound, helper.FormatResponse("Admin not found", nil))
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success update admin", res))
+	}
+}
+
+func (uc *AdminController) DeleteAdmin() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		if msg := uc.checkRemovable(c, id); msg != "" {
+			return c.JSON(http.StatusConflict, helper.FormatResponse(msg, nil))
+		}
+
+		if !uc.model.Delete(id) {
+			return c.JSON(http.StatusNotFound, helper.FormatResponse("Admin not found", nil))
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success delete admin", nil))
+	}
+}
+
+func (uc *AdminController) UnlockAdmin() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		var target = uc.model.SelectById(id)
+		if target == nil {
+			return c.JSON(http.StatusNotFound, helper.FormatResponse("Admin not found", nil))
+		}
+
+		uc.throttle.Unlock(helper.AccountKey("admin", target.Username))
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success unlock admin", nil))
+	}
+}
+
+// checkRemovable guards against owners locking themselves or everyone else
+// out of the admin panel.
+func (uc *AdminController) checkRemovable(c echo.Context, id int) string {
+	if callerID, _, _ := helper.TokenUser(c); callerID == id {
+		return "You cannot disable or delete your own account"
+	}
+
+	var target = uc.model.SelectById(id)
+	if target != nil && target.Role == helper.RoleOwner && !target.Disabled && uc.model.CountActiveOwners() <= 1 {
+		return "Cannot remove the last active owner"
+	}
+
+	return ""
+}
+
 func (uc *AdminController) Login() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		var input = model.Login{}
@@ -50,30 +228,52 @@ func (uc *AdminController) Login() echo.HandlerFunc {
 			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid user input", nil))
 		}
 
+		var account = helper.AccountKey("admin", input.Username)
+		if wait := uc.throttle.Check(account, c.RealIP()); wait > 0 {
+			return helper.TooManyAttempts(c, wait)
+		}
+
 		var res = uc.model.Login(input.Username, input.Password)
 
 		if res == nil {
-			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Cannot process data, something happend", nil))
+			uc.throttle.Failure(account, c.RealIP())
+			return c.JSON(http.StatusUnauthorized, helper.FormatResponse("Invalid username or password", nil))
 		}
+		uc.throttle.Success(account)
 
 		if res.Id == 0 {
 			return c.JSON(http.StatusNotFound, helper.FormatResponse("Data not found", nil))
 		}
 
-		var jwtToken = helper.GenerateJWT(uc.config.Secret, uc.config.RefreshSecret, res.Id, res.Username, res.Role)
+		if res.TOTPEnabled {
+			return uc.mfaChallenge(c, res.Id, helper.MFAPurposeLogin)
+		}
 
-		if jwtToken == nil {
-			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Cannot process data, something happend", nil))
+		if uc.settings.Bool(model.SettingAdmin2FARequired) {
+			return uc.mfaChallenge(c, res.Id, helper.MFAPurposeEnroll)
 		}
 
-		var info = map[string]any{}
-		info["username"] = res.Username
-		info["role"] = res.Role
+		return uc.loginSuccess(c, res, nil)
+	}
+}
 
-		jwtToken["info"] = info
+func (uc *AdminController) loginSuccess(c echo.Context, res *model.Admin, extra map[string]any) error {
+	var jwtToken = helper.GenerateJWT(uc.config.Secret, uc.config.RefreshSecret, res.Id, res.Username, res.Role)
 
-		return c.JSON(http.StatusOK, helper.FormatResponse("login success", jwtToken))
+	if jwtToken == nil {
+		return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Cannot process data, something happend", nil))
 	}
+
+	var info = map[string]any{}
+	info["username"] = res.Username
+	info["role"] = res.Role
+
+	jwtToken["info"] = info
+	for key, val := range extra {
+		jwtToken[key] = val
+	}
+
+	return c.JSON(http.StatusOK, helper.FormatResponse("login success", jwtToken))
 }
 
 func SomeSecureHandler(c echo.Context) error {
@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"rentcamp/helper"
+	"rentcamp/model"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// stubAdmins keeps admins in a map for the account management handlers.
+type stubAdmins struct {
+	model.AdminModelInterface
+	admins map[int]*model.Admin
+}
+
+func (s *stubAdmins) SelectById(id int) *model.Admin {
+	return s.admins[id]
+}
+
+func (s *stubAdmins) CountActiveOwners() int64 {
+	var total int64
+	for _, admin := range s.admins {
+		if admin.Role == helper.RoleOwner && !admin.Disabled {
+			total++
+		}
+	}
+	return total
+}
+
+func (s *stubAdmins) SetDisabled(id int, disabled bool) *model.Admin {
+	var admin = s.admins[id]
+	if admin != nil {
+		admin.Disabled = disabled
+	}
+	return admin
+}
+
+func (s *stubAdmins) Delete(id int) bool {
+	if s.admins[id] == nil {
+		return false
+	}
+	delete(s.admins, id)
+	return true
+}
+
+// newAuthContext returns a context for target carrying the token the JWT
+// middleware would have set for the caller.
+func newAuthContext(method string, target string, body string, id int, role string) (echo.Context, *httptest.ResponseRecorder) {
+	var req = httptest.NewRequest(method, target, nil)
+	if body != "" {
+		req = httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	var rec = httptest.NewRecorder()
+	var c = echo.New().NewContext(req, rec)
+	if role != "" {
+		c.Set("user", &jwt.Token{Claims: jwt.MapClaims{"id": float64(id), "role": role}})
+	}
+	return c, rec
+}
+
+func TestDeleteAndDisableAdmin(t *testing.T) {
+	var tests = []struct {
+		name       string
+		disable    bool
+		target     int
+		owners     int
+		wantStatus int
+	}{
+		{"delete another admin", false, 3, 1, http.StatusOK},
+		{"delete yourself", false, 1, 2, http.StatusConflict},
+		{"disable yourself", true, 1, 2, http.StatusConflict},
+		{"delete the last active owner", false, 2, 1, http.StatusConflict},
+		{"disable the last active owner", true, 2, 1, http.StatusConflict},
+		{"delete an owner when another is left", false, 2, 2, http.StatusOK},
+		{"unknown admin", false, 9, 1, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The caller (id 1) is a manager, so the owners are 2 and, when
+			// two are wanted, 4.
+			var admins = &stubAdmins{admins: map[int]*model.Admin{
+				1: {Id: 1, Role: helper.RoleManager},
+				2: {Id: 2, Role: helper.RoleOwner},
+				3: {Id: 3, Role: helper.RoleSupport},
+			}}
+			if tt.owners > 1 {
+				admins.admins[4] = &model.Admin{Id: 4, Role: helper.RoleOwner}
+			}
+			var ac = &AdminController{model: admins}
+
+			var c, rec = newAuthContext(http.MethodDelete, "/", "", 1, helper.RoleManager)
+			c.SetParamNames("id")
+			c.SetParamValues(strconv.Itoa(tt.target))
+
+			var handler = ac.DeleteAdmin()
+			if tt.disable {
+				handler = ac.DisableAdmin()
+			}
+			if err := handler(c); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
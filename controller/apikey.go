@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"net/http"
+	"rentcamp/helper"
+	"rentcamp/model"
+	"rentcamp/pagination"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ApiKeyControllerInterface interface {
+	CreateApiKey() echo.HandlerFunc
+	GetAllApiKeys() echo.HandlerFunc
+	RevokeApiKey() echo.HandlerFunc
+}
+
+type ApiKeyController struct {
+	model model.ApiKeyModelInterface
+}
+
+func NewApiKeyControllerInterface(m model.ApiKeyModelInterface) ApiKeyControllerInterface {
+	return &ApiKeyController{
+		model: m,
+	}
+}
+
+func (akc *ApiKeyController) CreateApiKey() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var input = model.ApiKey{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid api key input", nil))
+		}
+
+		if input.Name == "" || input.Partner == "" || input.UserId == 0 {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("name, partner and user_id are required", nil))
+		}
+
+		var scopes = []string{}
+		for _, scope := range strings.Split(input.Scopes, ",") {
+			scope = strings.TrimSpace(scope)
+			if scope == "" {
+				continue
+			}
+			if !helper.IsPartnerScope(scope) {
+				return c.JSON(http.StatusBadRequest, helper.FormatResponse("Unknown scope "+scope, nil))
+			}
+			scopes = append(scopes, scope)
+		}
+		if len(scopes) == 0 {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("At least one scope is required", nil))
+		}
+
+		if input.ExpiresAt != nil && input.ExpiresAt.Before(time.Now()) {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("expires_at must be in the future", nil))
+		}
+
+		key, prefix, err := helper.GenerateAPIKey()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Cannot process data, something happend", nil))
+		}
+
+		adminID, _, _ := helper.TokenUser(c)
+
+		var newKey = model.ApiKey{
+			Name:      input.Name,
+			Partner:   input.Partner,
+			Prefix:    prefix,
+			KeyHash:   helper.HashToken(key),
+			Scopes:    strings.Join(scopes, ","),
+			UserId:    input.UserId,
+			CreatedBy: adminID,
+			ExpiresAt: input.ExpiresAt,
+		}
+
+		res, err := akc.model.Insert(newKey)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Cannot create api key: "+err.Error(), nil))
+		}
+
+		var data = map[string]any{}
+		data["api_key"] = key
+		data["key"] = res
+
+		return c.JSON(http.StatusCreated, helper.FormatResponse("Success create api key, store it now as it will not be shown again", data))
+	}
+}
+
+func (akc *ApiKeyController) GetAllApiKeys() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
+
+		var res = akc.model.SelectAll(page)
+		if res == nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get all api keys", nil))
+		}
+
+		data, meta := pagination.KeysetResult(page, res, func(key model.ApiKey) int { return key.Id })
+		return c.JSON(http.StatusOK, pagination.Response("Success get all api keys", data, meta))
+	}
+}
+
+func (akc *ApiKeyController) RevokeApiKey() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		if !akc.model.Revoke(id) {
+			return c.JSON(http.StatusNotFound, helper.FormatResponse("Api key not found", nil))
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success revoke api key", nil))
+	}
+}
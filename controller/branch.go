@@ -0,0 +1,232 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"rentcamp/helper"
+	"rentcamp/model"
+	"rentcamp/pagination"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type BranchControllerInterface interface {
+	GetBranches() echo.HandlerFunc
+	GetBranchById() echo.HandlerFunc
+	CreateBranch() echo.HandlerFunc
+	UpdateBranch() echo.HandlerFunc
+	DeleteBranch() echo.HandlerFunc
+	GetBranchStock() echo.HandlerFunc
+	SetBranchStock() echo.HandlerFunc
+	GetProductAvailability() echo.HandlerFunc
+}
+
+type BranchController struct {
+	model model.BranchModelInterface
+}
+
+func NewBranchControllerInterface(m model.BranchModelInterface) BranchControllerInterface {
+	return &BranchController{
+		model: m,
+	}
+}
+
+func branchError(c echo.Context, err error, fallback string) error {
+	switch {
+	case errors.Is(err, model.ErrBranchNotFound), errors.Is(err, model.ErrProductNotFound):
+		return c.JSON(http.StatusNotFound, helper.FormatResponse(err.Error(), nil))
+	case errors.Is(err, model.ErrInvalidBranch), errors.Is(err, model.ErrInvalidStock), errors.Is(err, model.ErrInvalidDates):
+		return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+	case errors.Is(err, model.ErrBranchInUse):
+		return c.JSON(http.StatusConflict, helper.FormatResponse(err.Error(), nil))
+	}
+	return c.JSON(http.StatusInternalServerError, helper.FormatResponse(fallback, nil))
+}
+
+// GetBranches lists the branches. With ?near=lat,lng they are ordered by
+// distance from that point.
+func (bc *BranchController) GetBranches() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
+
+		if near := c.QueryParam("near"); near != "" {
+			lat, lng, err := helper.ParseLatLng(near)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+			}
+
+			var res = bc.model.Near(lat, lng)
+			if res == nil {
+				return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get branches", nil))
+			}
+
+			data, meta := pagination.Slice(page, res)
+			return c.JSON(http.StatusOK, pagination.Response("Success get branches", data, meta))
+		}
+
+		var res = bc.model.SelectAll(page)
+		if res == nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get branches", nil))
+		}
+
+		data, meta := pagination.KeysetResult(page, res, func(branch model.Branch) int { return branch.Id })
+		return c.JSON(http.StatusOK, pagination.Response("Success get branches", data, meta))
+	}
+}
+
+func (bc *BranchController) GetBranchById() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		res, err := bc.model.SelectById(id)
+		if err != nil {
+			return branchError(c, err, "Error get branch")
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success get branch", res))
+	}
+}
+
+func (bc *BranchController) CreateBranch() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var input = model.Branch{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid branch input", nil))
+		}
+
+		res, err := bc.model.Insert(input)
+		if err != nil {
+			return branchError(c, err, "Error create branch")
+		}
+
+		return c.JSON(http.StatusCreated, helper.FormatResponse("Success create branch", res))
+	}
+}
+
+func (bc *BranchController) UpdateBranch() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		var input = model.Branch{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid branch input", nil))
+		}
+		input.Id = id
+
+		res, err := bc.model.Update(input)
+		if err != nil {
+			return branchError(c, err, "Error update branch")
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success update branch", res))
+	}
+}
+
+func (bc *BranchController) DeleteBranch() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		if err := bc.model.Delete(id); err != nil {
+			return branchError(c, err, "Error delete branch")
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success delete branch", nil))
+	}
+}
+
+func (bc *BranchController) GetBranchStock() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
+
+		var res = bc.model.SelectStock(id, page)
+		if res == nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get branch stock", nil))
+		}
+
+		data, meta := pagination.KeysetResult(page, res, func(stock model.BranchStock) int { return stock.ProductId })
+		return c.JSON(http.StatusOK, pagination.Response("Success get branch stock", data, meta))
+	}
+}
+
+// SetBranchStock sets how many units of a product the branch owns, for
+// example after a stock count.
+func (bc *BranchController) SetBranchStock() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+		productID, err := strconv.Atoi(c.Param("product_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid product ID", nil))
+		}
+
+		var input = struct {
+			Stock *int `json:"stock" form:"stock"`
+		}{}
+		if err := c.Bind(&input); err != nil || input.Stock == nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid stock input", nil))
+		}
+
+		res, err := bc.model.SetStock(id, productID, *input.Stock)
+		if err != nil {
+			return branchError(c, err, "Error set branch stock")
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success set branch stock", res))
+	}
+}
+
+// GetProductAvailability shows how many units each branch can rent out
+// between ?start_date and ?end_date.
+func (bc *BranchController) GetProductAvailability() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		productID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid product ID", nil))
+		}
+
+		start, err := model.ParseDate(c.QueryParam("start_date"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(model.ErrInvalidDates.Error(), nil))
+		}
+		end, err := model.ParseDate(c.QueryParam("end_date"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(model.ErrInvalidDates.Error(), nil))
+		}
+
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
+
+		res, err := bc.model.Availability(productID, start, end)
+		if err != nil {
+			return branchError(c, err, "Error get availability")
+		}
+
+		data, meta := pagination.Slice(page, res)
+		return c.JSON(http.StatusOK, pagination.Response("Success get availability", data, meta))
+	}
+}
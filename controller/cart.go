@@ -1,12 +1,15 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
+	"rentcamp/config"
 	"rentcamp/helper"
 	"rentcamp/model"
+	"rentcamp/pagination"
 	"strconv"
+	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 )
 
@@ -15,52 +18,162 @@ type CartControllerInterface interface {
 	AddItemToCart() echo.HandlerFunc
 	UpdateCartItem() echo.HandlerFunc
 	RemoveCartItem() echo.HandlerFunc
+	SetCartBranch() echo.HandlerFunc
 	GetItemsInCart() echo.HandlerFunc
 	RemoveAllItemsFromCart() echo.HandlerFunc
 	GetTotalCartPrice() echo.HandlerFunc
 	CreateCart() echo.HandlerFunc
+	RequireCartOwner() echo.MiddlewareFunc
+	GetMyCart() echo.HandlerFunc
+	CreateGuestCart() echo.HandlerFunc
+	RequireGuestCart() echo.MiddlewareFunc
+	MergeGuestCart() echo.HandlerFunc
+	GetAbandonmentReport() echo.HandlerFunc
 }
 
 type CartController struct {
-	model model.CartModelInterface
+	config config.Config
+	model  model.CartModelInterface
+	audit  model.AuditModelInterface
 }
 
-func NewCartControllerInterface(m model.CartModelInterface) CartControllerInterface {
+func NewCartControllerInterface(m model.CartModelInterface, audit model.AuditModelInterface, cfg config.Config) CartControllerInterface {
 	return &CartController{
-		model: m,
+		config: cfg,
+		model:  m,
+		audit:  audit,
 	}
 }
 
+// RequireCartOwner resolves the cart in :cart_id and only lets its owner
+// through. Staff with cart:manage may act on any cart; every refusal is
+// written to the audit log.
+func (cc *CartController) RequireCartOwner() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			cartID, err := strconv.Atoi(c.Param("cart_id"))
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid cart ID", nil))
+			}
+
+			ownerID, err := cc.model.GetCartOwner(cartID)
+			if err != nil {
+				return c.JSON(http.StatusNotFound, helper.FormatResponse("Cart not found", nil))
+			}
+
+			if !cc.canActFor(c, ownerID) {
+				return cc.denyAccess(c, "cart", cartID)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func (cc *CartController) canActFor(c echo.Context, ownerID int) bool {
+	userID, role, ok := helper.TokenUser(c)
+	if !ok {
+		return false
+	}
+	if helper.HasPermission(role, helper.PermCartManage) {
+		return true
+	}
+	return (role == helper.RoleCustomer || role == helper.RolePartner) && userID == ownerID
+}
+
+func (cc *CartController) denyAccess(c echo.Context, resource string, resourceID int) error {
+	userID, role, _ := helper.TokenUser(c)
+	cc.audit.Record(model.AuditLog{
+		ActorId:    userID,
+		ActorRole:  role,
+		ApiKeyId:   helper.APIKeyId(c),
+		Action:     resource + ".access_denied",
+		Resource:   resource,
+		ResourceId: resourceID,
+		Ip:         c.RealIP(),
+		Detail:     c.Request().Method + " " + c.Request().URL.Path,
+	})
+
+	return c.JSON(http.StatusForbidden, helper.FormatResponse("You don't have access to this "+resource, nil))
+}
+
+// cartIDParam reads the cart from :cart_id, or from the guest cart token
+// already resolved by RequireGuestCart.
+func cartIDParam(c echo.Context) (int, error) {
+	if id, ok := c.Get(guestCartKey).(int); ok {
+		return id, nil
+	}
+	return strconv.Atoi(c.Param("cart_id"))
+}
+
+func cartItemError(c echo.Context, err error, fallback string) error {
+	switch {
+	case errors.Is(err, model.ErrInvalidQuantity), errors.Is(err, model.ErrInvalidDates):
+		return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+	case errors.Is(err, model.ErrProductNotFound), errors.Is(err, model.ErrCartItemNotFound), errors.Is(err, model.ErrBranchNotFound):
+		return c.JSON(http.StatusNotFound, helper.FormatResponse(err.Error(), nil))
+	case errors.Is(err, model.ErrInsufficientStock), errors.Is(err, model.ErrCartClosed), errors.Is(err, model.ErrCartCheckingOut):
+		return c.JSON(http.StatusConflict, helper.FormatResponse(err.Error(), nil))
+	}
+	return c.JSON(http.StatusInternalServerError, helper.FormatResponse(fallback, nil))
+}
+
 // revisi : update authorization
 func (cc *CartController) CreateCart() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		user := c.Get("user")
-		if user == nil {
-			return c.JSON(http.StatusUnauthorized, helper.FormatResponse("Unauthorized 1", nil))
+		userID, err := strconv.Atoi(c.Param("user_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid user ID", nil))
 		}
-		claims, ok := user.(*jwt.Token).Claims.(jwt.MapClaims)
-		if !ok {
-			return c.JSON(http.StatusUnauthorized, helper.FormatResponse("Unauthorized 2", nil))
+
+		if !cc.canActFor(c, userID) {
+			return cc.denyAccess(c, "user", userID)
 		}
-		userID, ok := claims["id"].(float64)
-		if !ok {
-			return c.JSON(http.StatusUnauthorized, helper.FormatResponse("Unauthorized 3", nil))
+
+		var input = model.Cart{UserID: userID}
+		if keyID := helper.APIKeyId(c); keyID != 0 {
+			input.ApiKeyID = &keyID
 		}
 
-		newCart, err := cc.model.CreateCart(int(userID))
+		newCart, created, err := cc.model.CreateCart(input)
 		if err != nil {
 			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error creating cart", nil))
 		}
 
+		if !created {
+			return c.JSON(http.StatusOK, helper.FormatResponse("User already has an active cart", newCart))
+		}
+
 		return c.JSON(http.StatusCreated, helper.FormatResponse("Cart created successfully", newCart))
 	}
 }
 
-func (cc *CartController) GetCartByCartId() echo.HandlerFunc {
+// GetMyCart returns the caller's active cart, creating it on first use so
+// clients never need to remember cart IDs.
+func (cc *CartController) GetMyCart() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		var paramCartID = c.Param("cart_id")
+		userID, role, ok := helper.TokenUser(c)
+		if !ok || (role != helper.RoleCustomer && role != helper.RolePartner) {
+			return c.JSON(http.StatusForbidden, helper.FormatResponse("Only customers have a cart", nil))
+		}
+
+		var input = model.Cart{UserID: userID}
+		if keyID := helper.APIKeyId(c); keyID != 0 {
+			input.ApiKeyID = &keyID
+		}
 
-		cartID, err := strconv.Atoi(paramCartID)
+		res, _, err := cc.model.CreateCart(input)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error fetching cart", nil))
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Cart retrieved successfully", res))
+	}
+}
+
+func (cc *CartController) GetCartByCartId() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		cartID, err := cartIDParam(c)
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid cart ID", nil))
 		}
@@ -80,9 +193,7 @@ func (cc *CartController) GetCartByCartId() echo.HandlerFunc {
 
 func (cc *CartController) AddItemToCart() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		var paramCartID = c.Param("cart_id")
-
-		cartID, err := strconv.Atoi(paramCartID)
+		cartID, err := cartIDParam(c)
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid cart ID", nil))
 		}
@@ -92,9 +203,9 @@ func (cc *CartController) AddItemToCart() echo.HandlerFunc {
 			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid cart item input", nil))
 		}
 
-		var res = cc.model.AddItemToCart(cartID, input)
-		if res == nil {
-			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error adding item to cart", nil))
+		res, err := cc.model.AddItemToCart(cartID, input)
+		if err != nil {
+			return cartItemError(c, err, "Error adding item to cart")
 		}
 
 		return c.JSON(http.StatusCreated, helper.FormatResponse("Item added to cart successfully", res))
@@ -103,10 +214,9 @@ func (cc *CartController) AddItemToCart() echo.HandlerFunc {
 
 func (cc *CartController) UpdateCartItem() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		var paramCartID = c.Param("cart_id")
 		var paramItemID = c.Param("item_id")
 
-		cartID, err := strconv.Atoi(paramCartID)
+		cartID, err := cartIDParam(c)
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid cart ID", nil))
 		}
@@ -123,21 +233,45 @@ func (cc *CartController) UpdateCartItem() echo.HandlerFunc {
 
 		input.ID = itemID
 
-		var res = cc.model.UpdateCartItem(cartID, itemID, input)
-		if res == nil {
-			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error updating cart item", nil))
+		res, err := cc.model.UpdateCartItem(cartID, itemID, input)
+		if err != nil {
+			return cartItemError(c, err, "Error updating cart item")
 		}
 
 		return c.JSON(http.StatusOK, helper.FormatResponse("Cart item updated successfully", res))
 	}
 }
 
+// SetCartBranch chooses the pickup branch whose stock the cart is checked
+// against.
+func (cc *CartController) SetCartBranch() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		cartID, err := cartIDParam(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid cart ID", nil))
+		}
+
+		var input = struct {
+			BranchId int `json:"branch_id" form:"branch_id"`
+		}{}
+		if err := c.Bind(&input); err != nil || input.BranchId < 1 {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("branch_id is required", nil))
+		}
+
+		res, err := cc.model.SetCartBranch(cartID, input.BranchId)
+		if err != nil {
+			return cartItemError(c, err, "Error choosing branch")
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Cart branch updated successfully", res))
+	}
+}
+
 func (cc *CartController) RemoveCartItem() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		var paramCartID = c.Param("cart_id")
 		var paramItemID = c.Param("item_id")
 
-		cartID, err := strconv.Atoi(paramCartID)
+		cartID, err := cartIDParam(c)
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid cart ID", nil))
 		}
@@ -147,9 +281,8 @@ func (cc *CartController) RemoveCartItem() echo.HandlerFunc {
 			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid item ID", nil))
 		}
 
-		success := cc.model.RemoveCartItem(cartID, itemID)
-		if !success {
-			return c.JSON(http.StatusNotFound, helper.FormatResponse("Cart item not found", nil))
+		if err := cc.model.RemoveCartItem(cartID, itemID); err != nil {
+			return cartItemError(c, err, "Error removing cart item")
 		}
 
 		return c.JSON(http.StatusOK, helper.FormatResponse("Cart item removed successfully", nil))
@@ -158,34 +291,35 @@ func (cc *CartController) RemoveCartItem() echo.HandlerFunc {
 
 func (cc *CartController) GetItemsInCart() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		var paramCartID = c.Param("cart_id")
-
-		cartID, err := strconv.Atoi(paramCartID)
+		cartID, err := cartIDParam(c)
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid cart ID", nil))
 		}
 
-		var res = cc.model.GetItemsInCart(cartID)
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
+
+		var res = cc.model.GetItemsInCart(cartID, page)
 		if res == nil {
 			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error fetching cart items", nil))
 		}
 
-		return c.JSON(http.StatusOK, helper.FormatResponse("Cart items retrieved successfully", res))
+		data, meta := pagination.KeysetResult(page, res, func(item model.CartItem) int { return item.ID })
+		return c.JSON(http.StatusOK, pagination.Response("Cart items retrieved successfully", data, meta))
 	}
 }
 
 func (cc *CartController) RemoveAllItemsFromCart() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		var paramCartID = c.Param("cart_id")
-
-		cartID, err := strconv.Atoi(paramCartID)
+		cartID, err := cartIDParam(c)
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid cart ID", nil))
 		}
 
-		success := cc.model.RemoveAllItemsFromCart(cartID)
-		if !success {
-			return c.JSON(http.StatusNotFound, helper.FormatResponse("No items found in the cart", nil))
+		if err := cc.model.RemoveAllItemsFromCart(cartID); err != nil {
+			return cartItemError(c, err, "Error removing cart items")
 		}
 
 		return c.JSON(http.StatusOK, helper.FormatResponse("All items removed from the cart", nil))
@@ -194,9 +328,7 @@ func (cc *CartController) RemoveAllItemsFromCart() echo.HandlerFunc {
 
 func (cc *CartController) GetTotalCartPrice() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		var paramCartID = c.Param("cart_id")
-
-		cartID, err := strconv.Atoi(paramCartID)
+		cartID, err := cartIDParam(c)
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid cart ID", nil))
 		}
@@ -209,3 +341,35 @@ func (cc *CartController) GetTotalCartPrice() echo.HandlerFunc {
 		return c.JSON(http.StatusOK, helper.FormatResponse("Total cart price calculated successfully", totalPrice))
 	}
 }
+
+// GetAbandonmentReport reports cart abandonment for carts created between
+// ?from and ?to (inclusive, YYYY-MM-DD), defaulting to the last 30 days.
+func (cc *CartController) GetAbandonmentReport() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var to = model.Today()
+		var from = model.NewDate(to.AddDate(0, 0, -30))
+
+		if val := c.QueryParam("from"); val != "" {
+			date, err := model.ParseDate(val)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid from date", nil))
+			}
+			from = date
+		}
+		if val := c.QueryParam("to"); val != "" {
+			date, err := model.ParseDate(val)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid to date", nil))
+			}
+			to = date
+		}
+
+		var inactiveSince = time.Now().Add(-time.Duration(cc.config.CartReminderHours) * time.Hour)
+		res, err := cc.model.AbandonmentReport(from.Time, to.AddDate(0, 0, 1), inactiveSince)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error building report", nil))
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success get abandonment report", res))
+	}
+}
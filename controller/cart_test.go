@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"rentcamp/helper"
+	"rentcamp/model"
+	"strconv"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// stubCarts knows the owner of each cart and records created carts.
+type stubCarts struct {
+	model.CartModelInterface
+	owners  map[int]int
+	created []model.Cart
+}
+
+func (s *stubCarts) GetCartOwner(cartID int) (int, error) {
+	owner, found := s.owners[cartID]
+	if !found {
+		return 0, errors.New("record not found")
+	}
+	return owner, nil
+}
+
+func (s *stubCarts) SetCartBranch(cartID int, branchID int) (*model.Cart, error) {
+	if branchID != 2 {
+		return nil, model.ErrBranchNotFound
+	}
+	return &model.Cart{ID: cartID, BranchId: branchID}, nil
+}
+
+func (s *stubCarts) CreateCart(newCart model.Cart) (*model.Cart, bool, error) {
+	s.created = append(s.created, newCart)
+	newCart.ID = 100 + len(s.created)
+	return &newCart, true, nil
+}
+
+type stubAudit struct {
+	entries []model.AuditLog
+}
+
+func (s *stubAudit) Record(entry model.AuditLog) {
+	s.entries = append(s.entries, entry)
+}
+
+func TestRequireCartOwner(t *testing.T) {
+	var tests = []struct {
+		name       string
+		callerId   int
+		role       string
+		cartId     string
+		wantStatus int
+		wantAudit  bool
+	}{
+		{"owner", 10, helper.RoleCustomer, "1", http.StatusOK, false},
+		{"another customer", 11, helper.RoleCustomer, "1", http.StatusForbidden, true},
+		{"partner acting for the owner", 10, helper.RolePartner, "1", http.StatusOK, false},
+		{"partner acting for someone else", 11, helper.RolePartner, "1", http.StatusForbidden, true},
+		{"support staff with cart:manage", 1, helper.RoleSupport, "1", http.StatusOK, false},
+		{"warehouse staff whose id matches", 10, helper.RoleWarehouse, "1", http.StatusForbidden, true},
+		{"unknown cart", 10, helper.RoleCustomer, "9", http.StatusNotFound, false},
+		{"invalid cart id", 10, helper.RoleCustomer, "abc", http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var audit = &stubAudit{}
+			var cc = &CartController{model: &stubCarts{owners: map[int]int{1: 10}}, audit: audit}
+
+			var c, rec = newAuthContext(http.MethodGet, "/carts/"+tt.cartId, "", tt.callerId, tt.role)
+			c.SetParamNames("cart_id")
+			c.SetParamValues(tt.cartId)
+
+			var handler = cc.RequireCartOwner()(func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})
+			if err := handler(c); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if (len(audit.entries) == 1) != tt.wantAudit {
+				t.Fatalf("audit entries = %+v, want one: %v", audit.entries, tt.wantAudit)
+			}
+			if tt.wantAudit && (audit.entries[0].ActorId != tt.callerId || audit.entries[0].Action != "cart.access_denied" || audit.entries[0].ResourceId != 1) {
+				t.Fatalf("audit entry = %+v", audit.entries[0])
+			}
+		})
+	}
+}
+
+func TestCreateCartUsesPathUser(t *testing.T) {
+	var tests = []struct {
+		name       string
+		callerId   int
+		role       string
+		userId     int
+		wantStatus int
+	}{
+		{"customer creating their own cart", 10, helper.RoleCustomer, 10, http.StatusCreated},
+		{"customer creating a cart for someone else", 10, helper.RoleCustomer, 11, http.StatusForbidden},
+		{"manager creating a cart for a customer", 1, helper.RoleManager, 11, http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var carts = &stubCarts{}
+			var cc = &CartController{model: carts, audit: &stubAudit{}}
+
+			var c, rec = newAuthContext(http.MethodPost, "/carts/"+strconv.Itoa(tt.userId), "", tt.callerId, tt.role)
+			c.SetParamNames("user_id")
+			c.SetParamValues(strconv.Itoa(tt.userId))
+
+			if err := cc.CreateCart()(c); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusCreated && (len(carts.created) != 1 || carts.created[0].UserID != tt.userId) {
+				t.Fatalf("created carts = %+v, want one for user %d", carts.created, tt.userId)
+			}
+		})
+	}
+}
+
+func TestSetCartBranch(t *testing.T) {
+	var tests = []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"known branch", `{"branch_id":2}`, http.StatusOK},
+		{"unknown branch", `{"branch_id":9}`, http.StatusNotFound},
+		{"missing branch", `{}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cc = &CartController{model: &stubCarts{}}
+
+			var c, rec = newAuthContext(http.MethodPut, "/carts/1/branch", tt.body, 10, helper.RoleCustomer)
+			c.SetParamNames("cart_id")
+			c.SetParamValues("1")
+
+			if err := cc.SetCartBranch()(c); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"rentcamp/helper"
+	"rentcamp/model"
+	"rentcamp/pagination"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type DeliveryControllerInterface interface {
+	GetZones() echo.HandlerFunc
+	QuoteDelivery() echo.HandlerFunc
+	CreateZone() echo.HandlerFunc
+	UpdateZone() echo.HandlerFunc
+	DeleteZone() echo.HandlerFunc
+	GetSlots() echo.HandlerFunc
+	CreateSlot() echo.HandlerFunc
+	UpdateSlot() echo.HandlerFunc
+	DeleteSlot() echo.HandlerFunc
+	GetRuns() echo.HandlerFunc
+	AssignDriver() echo.HandlerFunc
+}
+
+type DeliveryController struct {
+	model model.DeliveryModelInterface
+}
+
+func NewDeliveryControllerInterface(m model.DeliveryModelInterface) DeliveryControllerInterface {
+	return &DeliveryController{
+		model: m,
+	}
+}
+
+func deliveryError(c echo.Context, err error, fallback string) error {
+	switch {
+	case errors.Is(err, model.ErrDeliveryZoneNotFound), errors.Is(err, model.ErrDeliverySlotNotFound), errors.Is(err, model.ErrBranchNotFound),
+		errors.Is(err, model.ErrOrderNotFound), errors.Is(err, model.ErrDriverNotFound):
+		return c.JSON(http.StatusNotFound, helper.FormatResponse(err.Error(), nil))
+	case errors.Is(err, model.ErrInvalidDeliveryZone), errors.Is(err, model.ErrInvalidDeliverySlot), errors.Is(err, model.ErrInvalidRunKind),
+		errors.Is(err, model.ErrOutsideDeliveryArea):
+		return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+	case errors.Is(err, model.ErrDeliverySlotInUse), errors.Is(err, model.ErrNotDeliveryOrder):
+		return c.JSON(http.StatusConflict, helper.FormatResponse(err.Error(), nil))
+	}
+	return c.JSON(http.StatusInternalServerError, helper.FormatResponse(fallback, nil))
+}
+
+// queryDate reads an optional date query parameter, falling back to def.
+func queryDate(c echo.Context, name string, def model.Date) (model.Date, error) {
+	if c.QueryParam(name) == "" {
+		return def, nil
+	}
+	return model.ParseDate(c.QueryParam(name))
+}
+
+func (dc *DeliveryController) GetZones() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
+
+		branchID, _ := strconv.Atoi(c.QueryParam("branch_id"))
+
+		var res = dc.model.SelectZones(branchID, page)
+		if res == nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get delivery zones", nil))
+		}
+
+		data, meta := pagination.KeysetResult(page, res, func(zone model.DeliveryZone) int { return zone.Id })
+		return c.JSON(http.StatusOK, pagination.Response("Success get delivery zones", data, meta))
+	}
+}
+
+// QuoteDelivery prices delivery to ?at=lat,lng. Without ?branch_id the
+// cheapest branch that delivers there is quoted.
+func (dc *DeliveryController) QuoteDelivery() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		lat, lng, err := helper.ParseLatLng(c.QueryParam("at"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
+
+		branchID, _ := strconv.Atoi(c.QueryParam("branch_id"))
+
+		res, err := dc.model.Quote(branchID, lat, lng)
+		if err != nil {
+			return deliveryError(c, err, "Error quoting delivery")
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success quote delivery", res))
+	}
+}
+
+func (dc *DeliveryController) CreateZone() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var input = model.DeliveryZone{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid delivery zone input", nil))
+		}
+
+		res, err := dc.model.InsertZone(input)
+		if err != nil {
+			return deliveryError(c, err, "Error create delivery zone")
+		}
+
+		return c.JSON(http.StatusCreated, helper.FormatResponse("Success create delivery zone", res))
+	}
+}
+
+func (dc *DeliveryController) UpdateZone() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		var input = model.DeliveryZone{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid delivery zone input", nil))
+		}
+		input.Id = id
+
+		res, err := dc.model.UpdateZone(input)
+		if err != nil {
+			return deliveryError(c, err, "Error update delivery zone")
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success update delivery zone", res))
+	}
+}
+
+func (dc *DeliveryController) DeleteZone() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		if err := dc.model.DeleteZone(id); err != nil {
+			return deliveryError(c, err, "Error delete delivery zone")
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success delete delivery zone", nil))
+	}
+}
+
+// GetSlots lists delivery and collection slots with the room they have
+// left, from ?from (today by default) to ?to (two weeks later).
+func (dc *DeliveryController) GetSlots() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		from, err := queryDate(c, "from", model.Today())
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid from date", nil))
+		}
+		to, err := queryDate(c, "to", model.NewDate(from.AddDate(0, 0, 13)))
+		if err != nil || to.Before(from.Time) {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid to date", nil))
+		}
+
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
+
+		branchID, _ := strconv.Atoi(c.QueryParam("branch_id"))
+
+		var res = dc.model.SelectSlots(branchID, c.QueryParam("kind"), from, to, page)
+		if res == nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get delivery slots", nil))
+		}
+
+		data, meta := pagination.OffsetResult(page, res)
+		return c.JSON(http.StatusOK, pagination.Response("Success get delivery slots", data, meta))
+	}
+}
+
+func (dc *DeliveryController) CreateSlot() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var input = model.DeliverySlot{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid delivery slot input", nil))
+		}
+
+		res, err := dc.model.InsertSlot(input)
+		if err != nil {
+			return deliveryError(c, err, "Error create delivery slot")
+		}
+
+		return c.JSON(http.StatusCreated, helper.FormatResponse("Success create delivery slot", res))
+	}
+}
+
+func (dc *DeliveryController) UpdateSlot() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		var input = model.DeliverySlot{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid delivery slot input", nil))
+		}
+		input.Id = id
+
+		res, err := dc.model.UpdateSlot(input)
+		if err != nil {
+			return deliveryError(c, err, "Error update delivery slot")
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success update delivery slot", res))
+	}
+}
+
+func (dc *DeliveryController) DeleteSlot() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		if err := dc.model.DeleteSlot(id); err != nil {
+			return deliveryError(c, err, "Error delete delivery slot")
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success delete delivery slot", nil))
+	}
+}
+
+// GetRuns is the driver sheet for ?date (today by default), filtered by
+// ?branch_id, ?driver_id or ?unassigned=true. Drivers only see their own
+// runs.
+func (dc *DeliveryController) GetRuns() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		date, err := queryDate(c, "date", model.Today())
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid date", nil))
+		}
+
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
+
+		var filter = model.DeliveryRunFilter{Date: date}
+		filter.BranchId, _ = strconv.Atoi(c.QueryParam("branch_id"))
+		filter.DriverId, _ = strconv.Atoi(c.QueryParam("driver_id"))
+		filter.Unassigned, _ = strconv.ParseBool(c.QueryParam("unassigned"))
+
+		adminID, role, _ := helper.TokenUser(c)
+		if helper.NormalizeRole(role) == helper.RoleDriver {
+			filter.DriverId = adminID
+			filter.Unassigned = false
+		}
+
+		res, err := dc.model.Runs(filter)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get deliveries", nil))
+		}
+
+		data, meta := pagination.Slice(page, res)
+		return c.JSON(http.StatusOK, pagination.Response("Success get deliveries", data, meta))
+	}
+}
+
+// AssignDriver sets who drives the delivery or collection run of an order.
+func (dc *DeliveryController) AssignDriver() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("order_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid order ID", nil))
+		}
+
+		var input = struct {
+			Kind     string `json:"kind" form:"kind"`
+			DriverId int    `json:"driver_id" form:"driver_id"`
+		}{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid driver input", nil))
+		}
+
+		res, err := dc.model.AssignDriver(id, input.Kind, input.DriverId)
+		if err != nil {
+			return deliveryError(c, err, "Error assign driver")
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success assign driver", res))
+	}
+}
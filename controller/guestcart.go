@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"net/http"
+	"rentcamp/helper"
+	"rentcamp/model"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+const guestCartKey = "guest_cart_id"
+
+func (cc *CartController) CreateGuestCart() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		res, err := cc.model.CreateGuestCart()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error creating cart", nil))
+		}
+
+		var token = helper.GenerateCartToken(cc.config.Secret, res.ID)
+		if token == "" {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error creating cart", nil))
+		}
+
+		var data = map[string]any{}
+		data["cart_token"] = token
+		data["cart"] = res
+
+		return c.JSON(http.StatusCreated, helper.FormatResponse("Cart created successfully", data))
+	}
+}
+
+// RequireGuestCart resolves the X-Cart-Token header to an anonymous cart
+// that has not been merged into an account yet.
+func (cc *CartController) RequireGuestCart() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			cartID, err := helper.ParseCartToken(cc.config.Secret, c.Request().Header.Get(helper.CartTokenHeader))
+			if err != nil {
+				return c.JSON(http.StatusUnauthorized, helper.FormatResponse("Invalid or missing cart token", nil))
+			}
+
+			if !cc.model.IsGuestCart(cartID) {
+				return c.JSON(http.StatusNotFound, helper.FormatResponse("Cart not found", nil))
+			}
+
+			c.Set(guestCartKey, cartID)
+			return next(c)
+		}
+	}
+}
+
+// MergeGuestCart lets a signed-in customer claim a guest cart explicitly,
+// e.g. after a social login where the token could not be sent along.
+func (cc *CartController) MergeGuestCart() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userID, role, ok := helper.TokenUser(c)
+		if !ok || role != helper.RoleCustomer {
+			return c.JSON(http.StatusForbidden, helper.FormatResponse("Only customers have a cart", nil))
+		}
+
+		res, err := MergeGuestCartToken(cc.model, cc.config.Secret, c.Request().Header.Get(helper.CartTokenHeader), userID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Cannot merge cart: "+err.Error(), nil))
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Cart merged successfully", res))
+	}
+}
+
+// MergeGuestCartToken merges the guest cart behind a cart token into the
+// user's active cart. An empty token is not an error and returns nil.
+func MergeGuestCartToken(cm model.CartModelInterface, signKey string, token string, userID int) (*model.Cart, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	guestID, err := helper.ParseCartToken(signKey, token)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := cm.MergeGuestCart(guestID, userID)
+	if err != nil {
+		logrus.Error("Cart : cannot merge guest cart ", guestID, " into user ", userID, ", ", err.Error())
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,274 @@
+package controller
+
+import (
+	"net/http"
+	"rentcamp/helper"
+	"rentcamp/model"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const totpIssuer = "rentCamp"
+
+const msgInvalidTOTP = "Invalid two-factor code"
+
+// mfaAccount is the throttle key shared by every step that checks a
+// two-factor code of the admin, so failures at one step slow down the
+// others too.
+func mfaAccount(adminId int) string {
+	return helper.AccountKey("admin-mfa", strconv.Itoa(adminId))
+}
+
+// checkTOTP verifies a code and records its time step, so every code is
+// accepted only once.
+func (uc *AdminController) checkTOTP(admin *model.Admin, code string) bool {
+	counter, ok := helper.VerifyTOTP(admin.TOTPSecret, code, time.Now(), admin.TOTPLastCounter)
+	return ok && uc.model.UseTOTPCounter(admin.Id, counter)
+}
+
+func (uc *AdminController) mfaChallenge(c echo.Context, adminId int, purpose string) error {
+	var token = helper.GenerateMFAToken(uc.config.Secret, adminId, purpose)
+	if token == "" {
+		return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Cannot process data, something happend", nil))
+	}
+
+	var data = map[string]any{}
+	data["mfa_token"] = token
+	if purpose == helper.MFAPurposeEnroll {
+		data["mfa_enrollment_required"] = true
+		return c.JSON(http.StatusOK, helper.FormatResponse("two-factor enrollment required", data))
+	}
+
+	data["mfa_required"] = true
+	return c.JSON(http.StatusOK, helper.FormatResponse("two-factor code required", data))
+}
+
+// LoginMFA completes the second login step with either a TOTP code or one
+// of the recovery codes issued at enrollment.
+func (uc *AdminController) LoginMFA() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var input = model.MFAVerify{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid user input", nil))
+		}
+
+		adminId, err := helper.ParseMFAToken(uc.config.Secret, input.MFAToken, helper.MFAPurposeLogin)
+		if err != nil {
+			return c.JSON(http.StatusUnauthorized, helper.FormatResponse("Invalid or expired mfa token", nil))
+		}
+
+		var account = mfaAccount(adminId)
+		if wait := uc.throttle.Check(account, c.RealIP()); wait > 0 {
+			return helper.TooManyAttempts(c, wait)
+		}
+
+		var admin = uc.model.SelectById(adminId)
+		if admin == nil || admin.Disabled || !admin.TOTPEnabled {
+			return c.JSON(http.StatusUnauthorized, helper.FormatResponse("Invalid or expired mfa token", nil))
+		}
+
+		var valid bool
+		if input.RecoveryCode != "" {
+			valid = uc.model.UseRecoveryCode(admin.Id, helper.HashToken(helper.NormalizeRecoveryCode(input.RecoveryCode)))
+		} else {
+			valid = uc.checkTOTP(admin, input.Code)
+		}
+
+		if !valid {
+			uc.throttle.Failure(account, c.RealIP())
+			return c.JSON(http.StatusUnauthorized, helper.FormatResponse(msgInvalidTOTP, nil))
+		}
+		uc.throttle.Success(account)
+
+		return uc.loginSuccess(c, admin, nil)
+	}
+}
+
+// EnrollMFAChallenge starts enrollment for an admin who was stopped at login
+// because two-factor authentication is mandatory.
+func (uc *AdminController) EnrollMFAChallenge() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var input = model.MFAVerify{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid user input", nil))
+		}
+
+		adminId, err := helper.ParseMFAToken(uc.config.Secret, input.MFAToken, helper.MFAPurposeEnroll)
+		if err != nil {
+			return c.JSON(http.StatusUnauthorized, helper.FormatResponse("Invalid or expired mfa token", nil))
+		}
+
+		if wait := uc.throttle.Check(mfaAccount(adminId), c.RealIP()); wait > 0 {
+			return helper.TooManyAttempts(c, wait)
+		}
+
+		return uc.startEnrollment(c, adminId)
+	}
+}
+
+func (uc *AdminController) ActivateMFAChallenge() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var input = model.MFAVerify{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid user input", nil))
+		}
+
+		adminId, err := helper.ParseMFAToken(uc.config.Secret, input.MFAToken, helper.MFAPurposeEnroll)
+		if err != nil {
+			return c.JSON(http.StatusUnauthorized, helper.FormatResponse("Invalid or expired mfa token", nil))
+		}
+
+		var account = mfaAccount(adminId)
+		if wait := uc.throttle.Check(account, c.RealIP()); wait > 0 {
+			return helper.TooManyAttempts(c, wait)
+		}
+
+		admin, codes, msg := uc.activate(adminId, input.Code)
+		if admin == nil {
+			if msg == msgInvalidTOTP {
+				uc.throttle.Failure(account, c.RealIP())
+			}
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(msg, nil))
+		}
+		uc.throttle.Success(account)
+
+		return uc.loginSuccess(c, admin, map[string]any{"recovery_codes": codes})
+	}
+}
+
+func (uc *AdminController) EnrollTOTP() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		adminId, _, _ := helper.TokenUser(c)
+		return uc.startEnrollment(c, adminId)
+	}
+}
+
+func (uc *AdminController) VerifyTOTP() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var input = model.MFAVerify{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid user input", nil))
+		}
+
+		adminId, _, _ := helper.TokenUser(c)
+
+		admin, codes, msg := uc.activate(adminId, input.Code)
+		if admin == nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(msg, nil))
+		}
+
+		var data = map[string]any{}
+		data["recovery_codes"] = codes
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("two-factor authentication enabled", data))
+	}
+}
+
+func (uc *AdminController) DisableTOTP() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var input = model.MFAVerify{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid user input", nil))
+		}
+
+		if uc.settings.Bool(model.SettingAdmin2FARequired) {
+			return c.JSON(http.StatusForbidden, helper.FormatResponse("Two-factor authentication is mandatory for staff", nil))
+		}
+
+		adminId, _, _ := helper.TokenUser(c)
+		var admin = uc.model.SelectById(adminId)
+		if admin == nil || !admin.TOTPEnabled {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Two-factor authentication is not enabled", nil))
+		}
+
+		if !uc.checkTOTP(admin, input.Code) {
+			return c.JSON(http.StatusUnauthorized, helper.FormatResponse("Invalid two-factor code", nil))
+		}
+
+		if !uc.model.DisableTOTP(adminId) {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Cannot process data, something happend", nil))
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("two-factor authentication disabled", nil))
+	}
+}
+
+func (uc *AdminController) SetMFARequired() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var input = struct {
+			Required bool `json:"required" form:"required"`
+		}{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid user input", nil))
+		}
+
+		if !uc.settings.Set(model.SettingAdmin2FARequired, strconv.FormatBool(input.Required)) {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Cannot process data, something happend", nil))
+		}
+
+		var data = map[string]any{}
+		data["required"] = input.Required
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success update two-factor policy", data))
+	}
+}
+
+func (uc *AdminController) startEnrollment(c echo.Context, adminId int) error {
+	var admin = uc.model.SelectById(adminId)
+	if admin == nil || admin.Disabled {
+		return c.JSON(http.StatusNotFound, helper.FormatResponse("Admin not found", nil))
+	}
+
+	if admin.TOTPEnabled {
+		return c.JSON(http.StatusConflict, helper.FormatResponse("Two-factor authentication is already enabled", nil))
+	}
+
+	secret, err := helper.GenerateTOTPSecret()
+	if err != nil || !uc.model.SetTOTPSecret(admin.Id, secret) {
+		return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Cannot process data, something happend", nil))
+	}
+
+	var data = map[string]any{}
+	data["secret"] = secret
+	data["provisioning_uri"] = helper.TOTPProvisioningURI(totpIssuer, admin.Username, secret)
+
+	return c.JSON(http.StatusOK, helper.FormatResponse("scan the provisioning uri and confirm with a code", data))
+}
+
+func (uc *AdminController) activate(adminId int, code string) (*model.Admin, []string, string) {
+	var admin = uc.model.SelectById(adminId)
+	if admin == nil || admin.Disabled {
+		return nil, nil, "Admin not found"
+	}
+
+	if admin.TOTPEnabled {
+		return nil, nil, "Two-factor authentication is already enabled"
+	}
+
+	if admin.TOTPSecret == "" {
+		return nil, nil, "Start enrollment first"
+	}
+
+	if !uc.checkTOTP(admin, code) {
+		return nil, nil, msgInvalidTOTP
+	}
+
+	codes, err := helper.GenerateRecoveryCodes(10)
+	if err != nil {
+		return nil, nil, "Cannot generate recovery codes"
+	}
+
+	var hashes = []string{}
+	for _, rc := range codes {
+		hashes = append(hashes, helper.HashToken(rc))
+	}
+
+	if !uc.model.EnableTOTP(admin.Id, hashes) {
+		return nil, nil, "Cannot enable two-factor authentication"
+	}
+	admin.TOTPEnabled = true
+
+	return admin, codes, ""
+}
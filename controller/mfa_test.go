@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"rentcamp/config"
+	"rentcamp/helper"
+	"rentcamp/model"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestMFAEnrollmentIsThrottled(t *testing.T) {
+	var cfg = config.Config{Secret: "secret", LoginMaxAttempts: 3, LoginBackoffSeconds: 60, LoginLockoutMinutes: 15}
+	var admins = &stubAdmins{admins: map[int]*model.Admin{
+		1: {Id: 1, Username: "owner", Role: helper.RoleOwner, TOTPSecret: "JBSWY3DPEHPK3PXP"},
+	}}
+	var uc = &AdminController{config: cfg, model: admins, throttle: helper.NewLoginThrottle(cfg)}
+	var body = `{"mfa_token":"` + helper.GenerateMFAToken(cfg.Secret, 1, helper.MFAPurposeEnroll) + `","code":"abcdef"}`
+
+	var call = func(handler echo.HandlerFunc) int {
+		var req = httptest.NewRequest(http.MethodPost, "/admins/login/mfa", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		var rec = httptest.NewRecorder()
+		if err := handler(echo.New().NewContext(req, rec)); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code
+	}
+
+	if code := call(uc.ActivateMFAChallenge()); code != http.StatusBadRequest {
+		t.Fatalf("wrong code: status = %d, want %d", code, http.StatusBadRequest)
+	}
+	if code := call(uc.ActivateMFAChallenge()); code != http.StatusTooManyRequests {
+		t.Fatalf("guess right after a failure: status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+	if code := call(uc.EnrollMFAChallenge()); code != http.StatusTooManyRequests {
+		t.Fatalf("restarting enrollment while throttled: status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+}
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"rentcamp/helper"
+	"rentcamp/model"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+const oidcStateCookie = "oidc_state"
+
+func (uc *UserController) OIDCLogin() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !uc.oidc.Enabled() {
+			return c.JSON(http.StatusNotFound, helper.FormatResponse("Social login is not configured", nil))
+		}
+
+		state, errState := helper.GenerateRandomToken(16)
+		nonce, errNonce := helper.GenerateRandomToken(16)
+		verifier, challenge, errPKCE := helper.NewPKCE()
+		if errState != nil || errNonce != nil || errPKCE != nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Cannot process data, something happend", nil))
+		}
+
+		authURL, err := uc.oidc.AuthCodeURL(c.Request().Context(), state, nonce, challenge)
+		if err != nil {
+			logrus.Error("OIDC : cannot build authorization url, ", err.Error())
+			return c.JSON(http.StatusBadGateway, helper.FormatResponse("Identity provider is unavailable", nil))
+		}
+
+		c.SetCookie(&http.Cookie{
+			Name:     oidcStateCookie,
+			Value:    helper.GenerateOIDCState(uc.config.Secret, state, nonce, verifier),
+			Path:     "/customer/oidc",
+			Expires:  time.Now().Add(10 * time.Minute),
+			HttpOnly: true,
+			Secure:   c.Scheme() == "https",
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		return c.Redirect(http.StatusFound, authURL)
+	}
+}
+
+// OIDCCallback finishes the authorization code flow and signs in the customer
+// behind the identity. The response is the same token pair as Login.
+func (uc *UserController) OIDCCallback() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !uc.oidc.Enabled() {
+			return c.JSON(http.StatusNotFound, helper.FormatResponse("Social login is not configured", nil))
+		}
+
+		if errCode := c.QueryParam("error"); errCode != "" {
+			return c.JSON(http.StatusUnauthorized, helper.FormatResponse("Login was cancelled: "+errCode, nil))
+		}
+
+		cookie, err := c.Cookie(oidcStateCookie)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Login session expired, please try again", nil))
+		}
+		c.SetCookie(&http.Cookie{Name: oidcStateCookie, Path: "/customer/oidc", MaxAge: -1})
+
+		state, nonce, verifier, err := helper.ParseOIDCState(uc.config.Secret, cookie.Value)
+		if err != nil || state != c.QueryParam("state") {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid login state", nil))
+		}
+
+		identity, err := uc.oidc.Exchange(c.Request().Context(), c.QueryParam("code"), verifier, nonce)
+		if err != nil {
+			logrus.Error("OIDC : code exchange failed, ", err.Error())
+			return c.JSON(http.StatusUnauthorized, helper.FormatResponse("Cannot verify identity", nil))
+		}
+
+		res, err := uc.model.LoginWithIdentity(*identity)
+		if err != nil {
+			switch {
+			case errors.Is(err, model.ErrIdentityUnverified), errors.Is(err, model.ErrIdentityEmailTooLong):
+				return c.JSON(http.StatusForbidden, helper.FormatResponse(err.Error(), nil))
+			case errors.Is(err, model.ErrAccountDeleted):
+				return c.JSON(http.StatusForbidden, helper.FormatResponse("This account was deleted, please contact support", nil))
+			}
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Cannot process data, something happend", nil))
+		}
+
+		return uc.loginSuccess(c, res, nil)
+	}
+}
@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"rentcamp/config"
+	"rentcamp/helper"
+	"rentcamp/model"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// stubProvider is a minimal OpenID Connect issuer: discovery, a JWKS with
+// one RSA key and a token endpoint that signs whatever claims it holds.
+type stubProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	claims jwt.MapClaims
+}
+
+func newStubProvider(t *testing.T) *stubProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stub = &stubProvider{key: key}
+	var mux = http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 stub.server.URL,
+			"authorization_endpoint": stub.server.URL + "/authorize",
+			"token_endpoint":         stub.server.URL + "/token",
+			"jwks_uri":               stub.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kid": "test",
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("code") != "good-code" || r.PostFormValue("code_verifier") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var token = jwt.NewWithClaims(jwt.SigningMethodRS256, stub.claims)
+		token.Header["kid"] = "test"
+		signed, err := token.SignedString(key)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": signed})
+	})
+
+	stub.server = httptest.NewServer(mux)
+	t.Cleanup(stub.server.Close)
+	return stub
+}
+
+// stubUsers records the identity the callback signs in with.
+type stubUsers struct {
+	model.UserModelInterface
+	identity *helper.OIDCIdentity
+	err      error
+}
+
+func (s *stubUsers) LoginWithIdentity(identity helper.OIDCIdentity) (*model.User, error) {
+	s.identity = &identity
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &model.User{Id: 7, Name: "Budi", Username: "budi_abc123"}, nil
+}
+
+func TestOIDCCallback(t *testing.T) {
+	var tests = []struct {
+		name       string
+		code       string
+		badState   bool
+		noCookie   bool
+		badNonce   bool
+		verified   bool
+		modelErr   error
+		wantStatus int
+	}{
+		{"signs in a verified identity", "good-code", false, false, false, true, nil, http.StatusOK},
+		{"state does not match the cookie", "good-code", true, false, false, true, nil, http.StatusBadRequest},
+		{"missing state cookie", "good-code", false, true, false, true, nil, http.StatusBadRequest},
+		{"code rejected by the provider", "bad-code", false, false, false, true, nil, http.StatusUnauthorized},
+		{"id token with another nonce", "good-code", false, false, true, true, nil, http.StatusUnauthorized},
+		{"unverified email", "good-code", false, false, false, false, model.ErrIdentityUnverified, http.StatusForbidden},
+		{"deleted account", "good-code", false, false, false, true, model.ErrAccountDeleted, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stub = newStubProvider(t)
+			var cfg = config.Config{
+				Secret:          "secret",
+				RefreshSecret:   "refresh",
+				OIDCIssuer:      stub.server.URL,
+				OIDCClientID:    "rentcamp",
+				OIDCRedirectURL: "http://localhost/customer/oidc/callback",
+			}
+			var users = &stubUsers{err: tt.modelErr}
+			var uc = &UserController{config: cfg, model: users, oidc: helper.NewOIDCProvider(cfg)}
+			var e = echo.New()
+
+			var loginRec = httptest.NewRecorder()
+			if err := uc.OIDCLogin()(e.NewContext(httptest.NewRequest(http.MethodGet, "/customer/oidc/login", nil), loginRec)); err != nil {
+				t.Fatal(err)
+			}
+			if loginRec.Code != http.StatusFound {
+				t.Fatalf("login status = %d, want 302", loginRec.Code)
+			}
+			location, err := url.Parse(loginRec.Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var query = location.Query()
+			if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+				t.Fatalf("authorization url has no PKCE challenge: %s", location)
+			}
+
+			var nonce = query.Get("nonce")
+			if tt.badNonce {
+				nonce = "other"
+			}
+			stub.claims = jwt.MapClaims{
+				"iss":            stub.server.URL,
+				"aud":            "rentcamp",
+				"sub":            "subject-1",
+				"email":          "budi@example.com",
+				"email_verified": tt.verified,
+				"nonce":          nonce,
+				"exp":            time.Now().Add(time.Minute).Unix(),
+			}
+
+			var state = query.Get("state")
+			if tt.badState {
+				state = "forged"
+			}
+			var req = httptest.NewRequest(http.MethodGet, "/customer/oidc/callback?code="+tt.code+"&state="+url.QueryEscape(state), nil)
+			if !tt.noCookie {
+				for _, cookie := range loginRec.Result().Cookies() {
+					req.AddCookie(cookie)
+				}
+			}
+
+			var rec = httptest.NewRecorder()
+			if err := uc.OIDCCallback()(e.NewContext(req, rec)); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("callback status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+
+			if tt.wantStatus == http.StatusOK {
+				var body struct {
+					Data map[string]any `json:"data"`
+				}
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+					t.Fatal(err)
+				}
+				if body.Data["access_token"] == nil {
+					t.Fatalf("response has no access token: %s", rec.Body.String())
+				}
+				if users.identity == nil || users.identity.Subject != "subject-1" || users.identity.Issuer != stub.server.URL || !users.identity.EmailVerified {
+					t.Fatalf("model got identity %+v", users.identity)
+				}
+			}
+		})
+	}
+}
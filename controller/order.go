@@ -0,0 +1,297 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"rentcamp/config"
+	"rentcamp/helper"
+	"rentcamp/model"
+	"rentcamp/pagination"
+	"rentcamp/worker"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type OrderControllerInterface interface {
+	Checkout() echo.HandlerFunc
+	GetMyOrders() echo.HandlerFunc
+	GetMyOrderById() echo.HandlerFunc
+	GetAllOrders() echo.HandlerFunc
+	GetOrderById() echo.HandlerFunc
+	SetPaymentResult() echo.HandlerFunc
+	CancelMyOrder() echo.HandlerFunc
+	CancelOrder() echo.HandlerFunc
+	MarkPickedUp() echo.HandlerFunc
+	MarkReturned() echo.HandlerFunc
+}
+
+type OrderController struct {
+	config   config.Config
+	model    model.OrderModelInterface
+	carts    model.CartModelInterface
+	waitlist *worker.WaitlistOfferer
+}
+
+func NewOrderControllerInterface(m model.OrderModelInterface, carts model.CartModelInterface, waitlist *worker.WaitlistOfferer, cfg config.Config) OrderControllerInterface {
+	return &OrderController{
+		config:   cfg,
+		model:    m,
+		carts:    carts,
+		waitlist: waitlist,
+	}
+}
+
+func orderError(c echo.Context, err error, fallback string) error {
+	switch {
+	case errors.Is(err, model.ErrOrderNotFound), errors.Is(err, model.ErrBranchNotFound), errors.Is(err, model.ErrDeliverySlotNotFound):
+		return c.JSON(http.StatusNotFound, helper.FormatResponse(err.Error(), nil))
+	case errors.Is(err, model.ErrCartEmpty), errors.Is(err, model.ErrInvalidDates), errors.Is(err, model.ErrInvalidQuantity),
+		errors.Is(err, model.ErrBranchRequired), errors.Is(err, model.ErrInvalidFulfilment), errors.Is(err, model.ErrInvalidDeliveryAddress),
+		errors.Is(err, model.ErrOutsideDeliveryArea), errors.Is(err, model.ErrDeliverySlotMismatch):
+		return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+	case errors.Is(err, model.ErrNotDriverRun):
+		return c.JSON(http.StatusForbidden, helper.FormatResponse(err.Error(), nil))
+	case errors.Is(err, model.ErrOrderNotPending), errors.Is(err, model.ErrHoldExpired), errors.Is(err, model.ErrCartClosed),
+		errors.Is(err, model.ErrOrderNotAllowed), errors.Is(err, model.ErrDeliverySlotFull):
+		return c.JSON(http.StatusConflict, helper.FormatResponse(err.Error(), nil))
+	}
+	return cartItemError(c, err, fallback)
+}
+
+// Checkout places holds on everything in the caller's active cart at the
+// chosen branch and opens an order that must be paid before the holds run
+// out. With "fulfilment": "delivery" the gear is delivered to the given
+// address in the chosen slots and the delivery fee is added to the total.
+func (oc *OrderController) Checkout() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userID, role, ok := helper.TokenUser(c)
+		if !ok || role != helper.RoleCustomer {
+			return c.JSON(http.StatusForbidden, helper.FormatResponse("Only customers can checkout", nil))
+		}
+
+		var input = model.CheckoutRequest{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid checkout input", nil))
+		}
+
+		cart, _, err := oc.carts.CreateCart(model.Cart{UserID: userID})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error fetching cart", nil))
+		}
+
+		var holdFor = time.Duration(oc.config.CheckoutHoldMinutes) * time.Minute
+		res, err := oc.model.StartCheckout(cart.ID, userID, input, holdFor)
+		if err != nil {
+			return orderError(c, err, "Error during checkout")
+		}
+
+		return c.JSON(http.StatusCreated, helper.FormatResponse("Items are held until payment, please pay before the hold expires", res))
+	}
+}
+
+func (oc *OrderController) GetMyOrders() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userID, role, ok := helper.TokenUser(c)
+		if !ok || role != helper.RoleCustomer {
+			return c.JSON(http.StatusForbidden, helper.FormatResponse("Only customers have orders", nil))
+		}
+
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
+
+		var res = oc.model.SelectByUser(userID, page)
+		if res == nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get orders", nil))
+		}
+
+		data, meta := pagination.KeysetResult(page, res, func(order model.Order) int { return order.Id })
+		return c.JSON(http.StatusOK, pagination.Response("Success get orders", data, meta))
+	}
+}
+
+func (oc *OrderController) GetMyOrderById() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		userID, role, ok := helper.TokenUser(c)
+		if !ok || role != helper.RoleCustomer {
+			return c.JSON(http.StatusForbidden, helper.FormatResponse("Only customers have orders", nil))
+		}
+
+		var res = oc.model.SelectById(id)
+		if res == nil || res.UserId != userID {
+			return c.JSON(http.StatusNotFound, helper.FormatResponse("Order not found", nil))
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success get order", res))
+	}
+}
+
+func (oc *OrderController) GetAllOrders() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
+
+		branchID, _ := strconv.Atoi(c.QueryParam("branch_id"))
+
+		var res = oc.model.SelectAll(c.QueryParam("status"), branchID, page)
+		if res == nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get orders", nil))
+		}
+
+		data, meta := pagination.KeysetResult(page, res, func(order model.Order) int { return order.Id })
+		return c.JSON(http.StatusOK, pagination.Response("Success get orders", data, meta))
+	}
+}
+
+func (oc *OrderController) GetOrderById() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		var res = oc.model.SelectById(id)
+		if res == nil {
+			return c.JSON(http.StatusNotFound, helper.FormatResponse("Order not found", nil))
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success get order", res))
+	}
+}
+
+// SetPaymentResult records the outcome reported by the payment provider.
+// A failed payment releases the order's holds straight away.
+func (oc *OrderController) SetPaymentResult() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		var input = struct {
+			Paid bool `json:"paid"`
+		}{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid payment input", nil))
+		}
+
+		var res *model.Order
+		if input.Paid {
+			res, err = oc.model.ConfirmPayment(id)
+		} else {
+			res, err = oc.model.FailPayment(id)
+		}
+		if err != nil {
+			return orderError(c, err, "Error updating payment")
+		}
+		if !input.Paid {
+			oc.stockFreed(res)
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success update payment", res))
+	}
+}
+
+// stockFreed offers the units of a cancelled, returned or unpaid order to
+// the waitlist of each product.
+func (oc *OrderController) stockFreed(order *model.Order) {
+	if order == nil {
+		return
+	}
+
+	for _, item := range order.Items {
+		oc.waitlist.StockFreed(model.FreedStock{ProductId: item.ProductId, Quantity: item.Quantity, StartDate: item.StartDate, EndDate: item.EndDate})
+	}
+}
+
+func (oc *OrderController) CancelMyOrder() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		userID, role, ok := helper.TokenUser(c)
+		if !ok || role != helper.RoleCustomer {
+			return c.JSON(http.StatusForbidden, helper.FormatResponse("Only customers have orders", nil))
+		}
+
+		res, err := oc.model.Cancel(id, userID)
+		if err != nil {
+			return orderError(c, err, "Error cancelling order")
+		}
+		oc.stockFreed(res)
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Order cancelled", res))
+	}
+}
+
+func (oc *OrderController) CancelOrder() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		res, err := oc.model.Cancel(id, 0)
+		if err != nil {
+			return orderError(c, err, "Error cancelling order")
+		}
+		oc.stockFreed(res)
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Order cancelled", res))
+	}
+}
+
+// handoverDriver is the caller's id when the caller is a driver, who may
+// only hand over orders on their own runs, and 0 for other staff.
+func handoverDriver(c echo.Context) int {
+	adminID, role, _ := helper.TokenUser(c)
+	if helper.NormalizeRole(role) == helper.RoleDriver {
+		return adminID
+	}
+	return 0
+}
+
+func (oc *OrderController) MarkPickedUp() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		res, err := oc.model.MarkPickedUp(id, handoverDriver(c))
+		if err != nil {
+			return orderError(c, err, "Error updating order")
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Order picked up", res))
+	}
+}
+
+func (oc *OrderController) MarkReturned() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		res, err := oc.model.MarkReturned(id, handoverDriver(c))
+		if err != nil {
+			return orderError(c, err, "Error updating order")
+		}
+		oc.stockFreed(res)
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Order returned", res))
+	}
+}
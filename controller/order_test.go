@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"net/http"
+	"rentcamp/helper"
+	"rentcamp/model"
+	"rentcamp/pagination"
+	"testing"
+)
+
+// stubOrders holds one order, 5, placed by customer 10.
+type stubOrders struct {
+	model.OrderModelInterface
+}
+
+func (stubOrders) SelectById(orderID int) *model.Order {
+	if orderID != 5 {
+		return nil
+	}
+	return &model.Order{Id: 5, UserId: 10}
+}
+
+func (stubOrders) SelectByUser(userID int, page pagination.Page) []model.Order {
+	if userID != 10 {
+		return []model.Order{}
+	}
+	return []model.Order{{Id: 5, UserId: 10}}
+}
+
+func TestMyOrdersAreForCustomersOnly(t *testing.T) {
+	var tests = []struct {
+		name       string
+		callerId   int
+		role       string
+		wantStatus int
+	}{
+		{"owner of the order", 10, helper.RoleCustomer, http.StatusOK},
+		{"another customer", 11, helper.RoleCustomer, http.StatusNotFound},
+		{"staff whose id matches", 10, helper.RoleOwner, http.StatusForbidden},
+		{"partner whose acting user matches", 10, helper.RolePartner, http.StatusForbidden},
+		{"driver whose id matches", 10, helper.RoleDriver, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var oc = &OrderController{model: stubOrders{}}
+
+			var c, rec = newAuthContext(http.MethodGet, "/me/orders/5", "", tt.callerId, tt.role)
+			c.SetParamNames("id")
+			c.SetParamValues("5")
+			if err := oc.GetMyOrderById()(c); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("GetMyOrderById status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			var wantList = tt.wantStatus
+			if wantList == http.StatusNotFound {
+				wantList = http.StatusOK
+			}
+			c, rec = newAuthContext(http.MethodGet, "/me/orders", "", tt.callerId, tt.role)
+			if err := oc.GetMyOrders()(c); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != wantList {
+				t.Fatalf("GetMyOrders status = %d, want %d", rec.Code, wantList)
+			}
+		})
+	}
+}
+
+func (stubOrders) Cancel(orderID int, userID int) (*model.Order, error) {
+	if orderID != 5 || (userID != 0 && userID != 10) {
+		return nil, model.ErrOrderNotFound
+	}
+	return &model.Order{Id: 5, UserId: 10, Status: model.OrderStatusCancelled}, nil
+}
+
+func TestCancelMyOrderIsForCustomersOnly(t *testing.T) {
+	var tests = []struct {
+		name       string
+		callerId   int
+		role       string
+		wantStatus int
+	}{
+		{"owner of the order", 10, helper.RoleCustomer, http.StatusOK},
+		{"another customer", 11, helper.RoleCustomer, http.StatusNotFound},
+		{"staff whose id matches", 10, helper.RoleOwner, http.StatusForbidden},
+		{"partner whose acting user matches", 10, helper.RolePartner, http.StatusForbidden},
+		{"driver whose id matches", 10, helper.RoleDriver, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var oc = &OrderController{model: stubOrders{}}
+
+			var c, rec = newAuthContext(http.MethodPost, "/me/orders/5/cancel", "", tt.callerId, tt.role)
+			c.SetParamNames("id")
+			c.SetParamValues("5")
+			if err := oc.CancelMyOrder()(c); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("CancelMyOrder status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// stubHandover lets driver 7 hand over order 5 only.
+type stubHandover struct {
+	model.OrderModelInterface
+	driverIDs []int
+}
+
+func (s *stubHandover) MarkPickedUp(orderID int, driverID int) (*model.Order, error) {
+	s.driverIDs = append(s.driverIDs, driverID)
+	if driverID != 0 && driverID != 7 {
+		return nil, model.ErrNotDriverRun
+	}
+	return &model.Order{Id: orderID, Status: model.OrderStatusPickedUp}, nil
+}
+
+func TestMarkPickedUpLimitsDriversToTheirRuns(t *testing.T) {
+	var tests = []struct {
+		name       string
+		callerId   int
+		role       string
+		wantDriver int
+		wantStatus int
+	}{
+		{"driver of the run", 7, helper.RoleDriver, 7, http.StatusOK},
+		{"another driver", 8, helper.RoleDriver, 8, http.StatusForbidden},
+		{"branch staff", 3, helper.RoleWarehouse, 0, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var orders = &stubHandover{}
+			var oc = &OrderController{model: orders}
+
+			var c, rec = newAuthContext(http.MethodPost, "/admin/orders/5/pickup", "", tt.callerId, tt.role)
+			c.SetParamNames("id")
+			c.SetParamValues("5")
+
+			if err := oc.MarkPickedUp()(c); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if len(orders.driverIDs) != 1 || orders.driverIDs[0] != tt.wantDriver {
+				t.Fatalf("model got driver %v, want %d", orders.driverIDs, tt.wantDriver)
+			}
+		})
+	}
+}
@@ -1,16 +1,16 @@
 package controller
 
 import (
-	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"rentcamp/config"
 	"rentcamp/helper"
 	"rentcamp/model"
+	"rentcamp/pagination"
+	"rentcamp/search"
 	"strconv"
 
-	"github.com/cloudinary/cloudinary-go"
-	"github.com/cloudinary/cloudinary-go/api/uploader"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 )
 
@@ -20,36 +20,48 @@ type ProductControllerInterface interface {
 	GetProductById() echo.HandlerFunc
 	UpdateProduct() echo.HandlerFunc
 	DeleteProduct() echo.HandlerFunc
+	JoinWaitlist() echo.HandlerFunc
+	GetMyWaitlist() echo.HandlerFunc
+	LeaveWaitlist() echo.HandlerFunc
+	Suggest() echo.HandlerFunc
+	GetZeroResultQueries() echo.HandlerFunc
+	CreateTransfer() echo.HandlerFunc
+	GetTransfers() echo.HandlerFunc
+	GetTransferById() echo.HandlerFunc
+	DispatchTransfer() echo.HandlerFunc
+	ReceiveTransfer() echo.HandlerFunc
+	CancelTransfer() echo.HandlerFunc
 }
 
 type ProductController struct {
-	config config.Config
-	model  model.ProductModelInterface
+	config    config.Config
+	model     model.ProductModelInterface
+	waitlist  model.WaitlistModelInterface
+	searchLog model.SearchLogModelInterface
+	suggester *search.Suggester
+	transfers model.TransferModelInterface
 }
 
-func NewProductControllerInterface(m model.ProductModelInterface, cfg config.Config) ProductControllerInterface {
+func NewProductControllerInterface(m model.ProductModelInterface, waitlist model.WaitlistModelInterface, searchLog model.SearchLogModelInterface, suggester *search.Suggester, transfers model.TransferModelInterface, cfg config.Config) ProductControllerInterface {
 	return &ProductController{
-		model:  m,
-		config: cfg,
+		model:     m,
+		waitlist:  waitlist,
+		searchLog: searchLog,
+		suggester: suggester,
+		transfers: transfers,
+		config:    cfg,
 	}
 }
 
-// Revisi: Authorization admin only
+func uploadError(c echo.Context, err error) error {
+	if errors.Is(err, helper.ErrInvalidImage) {
+		return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+	}
+	return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Failed to upload image", nil))
+}
+
 func (cpc *ProductController) CreateProduct() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		userToken := c.Get("user").(*jwt.Token)
-		tokenData, ok := helper.ExtractToken(userToken).(map[string]interface{})
-		if !ok {
-			return c.JSON(http.StatusUnauthorized, helper.FormatResponse("Invalid Token", "Invalid or missing token"))
-		}
-		role, roleOk := tokenData["role"].(string)
-		if !roleOk {
-			return c.JSON(http.StatusUnauthorized, helper.FormatResponse("Role information missing in the token", nil))
-		}
-		if role != "admin" {
-			return c.JSON(http.StatusUnauthorized, helper.FormatResponse("You don't have permission", nil))
-		}
-
 		var input = model.Product{}
 		if err := c.Bind(&input); err != nil {
 			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid product input", nil))
@@ -60,31 +72,11 @@ func (cpc *ProductController) CreateProduct() echo.HandlerFunc {
 			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Error uploading image", nil))
 		}
 
-		cld, err := cloudinary.NewFromParams(
-			cpc.config.CDN_Cloud_Name,
-			cpc.config.CDN_API_Key,
-			cpc.config.CDN_API_Secret,
-		)
+		url, err := helper.UploadImage(cpc.config, image)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Failed to initialize Cloudinary", nil))
+			return uploadError(c, err)
 		}
-
-		uploadParams := uploader.UploadParams{
-			Folder: cpc.config.CDN_Folder_Name,
-		}
-
-		fileReader, err := image.Open()
-		if err != nil {
-			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Failed to read image file", nil))
-		}
-		defer fileReader.Close()
-
-		result, err := cld.Upload.Upload(context.TODO(), fileReader, uploadParams)
-		if err != nil {
-			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Failed to upload image to Cloudinary: "+err.Error(), nil))
-		}
-
-		input.Image = result.SecureURL
+		input.Image = url
 
 		createdProduct := cpc.model.InsertProduct(input)
 		if createdProduct == nil {
@@ -95,43 +87,57 @@ func (cpc *ProductController) CreateProduct() echo.HandlerFunc {
 	}
 }
 
-// Revisi: Penggabungan getall dengan search pagination
+// searcher identifies who ran a search: the signed-in user, or else the
+// client address.
+func searcher(c echo.Context) string {
+	if userID, role, ok := helper.TokenUser(c); ok {
+		return fmt.Sprintf("%s:%d", role, userID)
+	}
+	return "ip:" + c.RealIP()
+}
+
+// GetAllProduct lists the catalogue. With ?q (or the older ?name) it
+// returns search results in relevance order instead.
 func (cpc *ProductController) GetAllProduct() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		pageStr := c.QueryParam("page")
-		limitStr := c.QueryParam("limit")
 		search := c.QueryParam("name")
-
-		var page, limit int
-		if pageStr != "" {
-			page, _ = strconv.Atoi(pageStr)
-		}
-		if limitStr != "" {
-			limit, _ = strconv.Atoi(limitStr)
+		sort := c.QueryParam("sort")
+		if q := c.QueryParam("q"); q != "" {
+			search = q
 		}
 
-		if page == 0 || limit == 0 {
-			var res = cpc.model.SelectAll()
-
-			if res == nil {
-				return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get all users, ", nil))
-			}
-
-			return c.JSON(http.StatusOK, helper.FormatResponse("Success get all users, ", res))
-		} else {
-			res, totalCount, err := cpc.model.SelectAllWithPagination(page, limit, search)
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
 
+		if search != "" {
+			res, totalCount, err := cpc.model.Search(search, page)
 			if err != nil {
-				return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error fetching products", nil))
+				return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error searching products", nil))
 			}
-
-			response := map[string]interface{}{
-				"products":   res,
-				"total_data": totalCount,
+			if page.First() {
+				cpc.searchLog.Record(search, totalCount, searcher(c))
 			}
 
-			return c.JSON(http.StatusOK, helper.FormatResponse("Success fetching products", response))
+			data, meta := pagination.OffsetResult(page, res)
+			return c.JSON(http.StatusOK, pagination.Response("Success searching products", data, meta.WithTotal(int64(totalCount))))
+		}
+
+		res, totalCount, err := cpc.model.SelectPage(page, sort)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error fetching products", nil))
+		}
+
+		var data []model.Product
+		var meta pagination.Meta
+		if sort == model.ProductSortRating {
+			data, meta = pagination.OffsetResult(page, res)
+		} else {
+			data, meta = pagination.KeysetResult(page, res, func(product model.Product) int { return product.Id })
 		}
+
+		return c.JSON(http.StatusOK, pagination.Response("Success fetching products", data, meta.WithTotal(totalCount)))
 	}
 }
 
@@ -153,22 +159,8 @@ func (cpc *ProductController) GetProductById() echo.HandlerFunc {
 	}
 }
 
-// Revisi: Authorization admin only
 func (cpc *ProductController) UpdateProduct() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		userToken := c.Get("user").(*jwt.Token)
-		tokenData, ok := helper.ExtractToken(userToken).(map[string]interface{})
-		if !ok {
-			return c.JSON(http.StatusUnauthorized, helper.FormatResponse("Invalid Token", "Invalid or missing token"))
-		}
-		role, roleOk := tokenData["role"].(string)
-		if !roleOk {
-			return c.JSON(http.StatusUnauthorized, helper.FormatResponse("Role information missing in the token", nil))
-		}
-		if role != "admin" {
-			return c.JSON(http.StatusUnauthorized, helper.FormatResponse("You don't have permission", nil))
-		}
-
 		var paramId = c.Param("id")
 		cnv, err := strconv.Atoi(paramId)
 		if err != nil {
@@ -186,29 +178,11 @@ func (cpc *ProductController) UpdateProduct() echo.HandlerFunc {
 			}
 			input.Image = existingProduct.Image
 		} else {
-			cld, err := cloudinary.NewFromParams(
-				cpc.config.CDN_Cloud_Name,
-				cpc.config.CDN_API_Key,
-				cpc.config.CDN_API_Secret,
-			)
-			if err != nil {
-				return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Failed to initialize Cloudinary", nil))
-			}
-			uploadParams := uploader.UploadParams{
-				Folder: cpc.config.CDN_Folder_Name,
-			}
-
-			fileReader, err := image.Open()
+			url, err := helper.UploadImage(cpc.config, image)
 			if err != nil {
-				return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Failed to read image file", nil))
+				return uploadError(c, err)
 			}
-			defer fileReader.Close()
-
-			result, err := cld.Upload.Upload(context.TODO(), fileReader, uploadParams)
-			if err != nil {
-				return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Failed to upload image to Cloudinary", nil))
-			}
-			input.Image = result.SecureURL
+			input.Image = url
 		}
 
 		input.Id = cnv
@@ -222,21 +196,8 @@ func (cpc *ProductController) UpdateProduct() echo.HandlerFunc {
 	}
 }
 
-// Revisi: Authorization admin only
 func (cpc *ProductController) DeleteProduct() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		userToken := c.Get("user").(*jwt.Token)
-		tokenData, ok := helper.ExtractToken(userToken).(map[string]interface{})
-		if !ok {
-			return c.JSON(http.StatusUnauthorized, helper.FormatResponse("Invalid Token", "Invalid or missing token"))
-		}
-		role, roleOk := tokenData["role"].(string)
-		if !roleOk {
-			return c.JSON(http.StatusUnauthorized, helper.FormatResponse("Role information missing in the token", nil))
-		}
-		if role != "admin" {
-			return c.JSON(http.StatusUnauthorized, helper.FormatResponse("You don't have permission", nil))
-		}
 		var paramId = c.Param("id")
 
 		cnv, err := strconv.Atoi(paramId)
@@ -0,0 +1,221 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"rentcamp/config"
+	"rentcamp/helper"
+	"rentcamp/model"
+	"rentcamp/pagination"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+type QuestionControllerInterface interface {
+	AskQuestion() echo.HandlerFunc
+	GetProductQuestions() echo.HandlerFunc
+	GetQuestionsForStaff() echo.HandlerFunc
+	AnswerQuestion() echo.HandlerFunc
+	EditAnswer() echo.HandlerFunc
+	HideQuestion() echo.HandlerFunc
+	ShowQuestion() echo.HandlerFunc
+	DeleteQuestion() echo.HandlerFunc
+}
+
+type QuestionController struct {
+	config     config.Config
+	model      model.QuestionModelInterface
+	users      model.UserModelInterface
+	dispatcher *helper.Dispatcher
+}
+
+func NewQuestionControllerInterface(m model.QuestionModelInterface, users model.UserModelInterface, dispatcher *helper.Dispatcher, cfg config.Config) QuestionControllerInterface {
+	return &QuestionController{
+		config:     cfg,
+		model:      m,
+		users:      users,
+		dispatcher: dispatcher,
+	}
+}
+
+func questionError(c echo.Context, err error, fallback string) error {
+	switch {
+	case errors.Is(err, model.ErrEmptyQuestion):
+		return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+	case errors.Is(err, model.ErrQuestionNotFound), errors.Is(err, model.ErrProductNotFound):
+		return c.JSON(http.StatusNotFound, helper.FormatResponse(err.Error(), nil))
+	case errors.Is(err, model.ErrAlreadyAnswered), errors.Is(err, model.ErrNotAnswered):
+		return c.JSON(http.StatusConflict, helper.FormatResponse(err.Error(), nil))
+	}
+	return c.JSON(http.StatusInternalServerError, helper.FormatResponse(fallback, nil))
+}
+
+func (qc *QuestionController) AskQuestion() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		productID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid product ID", nil))
+		}
+
+		userID, role, ok := helper.TokenUser(c)
+		if !ok || role != helper.RoleCustomer {
+			return c.JSON(http.StatusForbidden, helper.FormatResponse("Only customers can ask questions", nil))
+		}
+
+		var input = model.ProductQuestion{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid question input", nil))
+		}
+		input.ProductId = productID
+		input.UserId = userID
+
+		res, err := qc.model.Ask(input)
+		if err != nil {
+			return questionError(c, err, "Error asking question")
+		}
+
+		return c.JSON(http.StatusCreated, helper.FormatResponse("Question sent, we will email you when it is answered", res))
+	}
+}
+
+func (qc *QuestionController) GetProductQuestions() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		productID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid product ID", nil))
+		}
+
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
+
+		var res = qc.model.SelectAnswered(productID, page)
+		if res == nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get questions", nil))
+		}
+
+		data, meta := pagination.KeysetResult(page, res, func(question model.ProductQuestion) int { return question.Id })
+		return c.JSON(http.StatusOK, pagination.Response("Success get questions", data, meta))
+	}
+}
+
+func (qc *QuestionController) GetQuestionsForStaff() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
+
+		var res = qc.model.SelectForStaff(c.QueryParam("unanswered") == "true", page)
+		if res == nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get questions", nil))
+		}
+
+		data, meta := pagination.KeysetResult(page, res, func(question model.ProductQuestion) int { return question.Id })
+		return c.JSON(http.StatusOK, pagination.Response("Success get questions", data, meta))
+	}
+}
+
+// AnswerQuestion stores the answer and emails the customer who asked. A
+// question that already has an answer is refused; use EditAnswer.
+func (qc *QuestionController) AnswerQuestion() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		res, ok, err := qc.answer(c, qc.model.Answer)
+		if !ok {
+			return err
+		}
+
+		if asker := qc.users.SelectById(res.UserId); asker != nil {
+			qc.dispatcher.Dispatch(helper.Notification{
+				To:      asker.Email,
+				Subject: "Your question has been answered",
+				Body: fmt.Sprintf("Hi %s,\n\nYou asked: %s\n\nOur answer: %s\n\nSee the product: %s/products/%d\n",
+					asker.Name, res.Body, res.Answer, qc.config.AppBaseURL, res.ProductId),
+			})
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success answer question", res))
+	}
+}
+
+// EditAnswer corrects an existing answer without emailing the customer
+// again.
+func (qc *QuestionController) EditAnswer() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		res, ok, err := qc.answer(c, qc.model.EditAnswer)
+		if !ok {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success edit answer", res))
+	}
+}
+
+// answer reads the answer from the request and stores it with save. When
+// ok is false the error response has been written and err is what the
+// handler returns.
+func (qc *QuestionController) answer(c echo.Context, save func(questionID int, adminID int, answer string) (*model.ProductQuestion, error)) (*model.ProductQuestion, bool, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, false, c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+	}
+
+	var input = struct {
+		Answer string `json:"answer" form:"answer"`
+	}{}
+	if err := c.Bind(&input); err != nil || strings.TrimSpace(input.Answer) == "" {
+		return nil, false, c.JSON(http.StatusBadRequest, helper.FormatResponse("answer is required", nil))
+	}
+
+	adminID, _, _ := helper.TokenUser(c)
+
+	res, err := save(id, adminID, strings.TrimSpace(input.Answer))
+	if err != nil {
+		return nil, false, questionError(c, err, "Error answering question")
+	}
+
+	return res, true, nil
+}
+
+func (qc *QuestionController) HideQuestion() echo.HandlerFunc {
+	return qc.setHidden(true)
+}
+
+func (qc *QuestionController) ShowQuestion() echo.HandlerFunc {
+	return qc.setHidden(false)
+}
+
+func (qc *QuestionController) setHidden(hidden bool) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		res, err := qc.model.SetHidden(id, hidden)
+		if err != nil {
+			return questionError(c, err, "Error updating question")
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success update question", res))
+	}
+}
+
+func (qc *QuestionController) DeleteQuestion() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		if !qc.model.Delete(id) {
+			return c.JSON(http.StatusNotFound, helper.FormatResponse(model.ErrQuestionNotFound.Error(), nil))
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success delete question", nil))
+	}
+}
@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"net/http"
+	"rentcamp/helper"
+	"rentcamp/model"
+	"testing"
+)
+
+// stubQuestions has question 1 answered and question 2 still open.
+type stubQuestions struct {
+	model.QuestionModelInterface
+}
+
+func (stubQuestions) Answer(questionID int, adminID int, answer string) (*model.ProductQuestion, error) {
+	if questionID == 1 {
+		return nil, model.ErrAlreadyAnswered
+	}
+	return &model.ProductQuestion{Id: questionID, UserId: 10, Answer: answer}, nil
+}
+
+func (stubQuestions) EditAnswer(questionID int, adminID int, answer string) (*model.ProductQuestion, error) {
+	if questionID != 1 {
+		return nil, model.ErrNotAnswered
+	}
+	return &model.ProductQuestion{Id: questionID, UserId: 10, Answer: answer}, nil
+}
+
+func TestAnswerAndEditAnswer(t *testing.T) {
+	var tests = []struct {
+		name       string
+		edit       bool
+		questionID string
+		wantStatus int
+	}{
+		{"answer an answered question", false, "1", http.StatusConflict},
+		{"edit an answered question", true, "1", http.StatusOK},
+		{"edit an open question", true, "2", http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// No users model or dispatcher: neither path may email the customer.
+			var qc = &QuestionController{model: stubQuestions{}}
+			var handler = qc.AnswerQuestion()
+			if tt.edit {
+				handler = qc.EditAnswer()
+			}
+
+			var c, rec = newAuthContext(http.MethodPost, "/admins/questions/"+tt.questionID+"/answer", `{"answer":"Yes"}`, 1, helper.RoleOwner)
+			c.SetParamNames("id")
+			c.SetParamValues(tt.questionID)
+			if err := handler(c); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"rentcamp/helper"
+	"rentcamp/model"
+	"rentcamp/pagination"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type RecommendationControllerInterface interface {
+	GetRelatedProducts() echo.HandlerFunc
+	GetMyRecommendations() echo.HandlerFunc
+}
+
+type RecommendationController struct {
+	model model.RecommendationModelInterface
+}
+
+func NewRecommendationControllerInterface(m model.RecommendationModelInterface) RecommendationControllerInterface {
+	return &RecommendationController{
+		model: m,
+	}
+}
+
+// GetRelatedProducts lists the products most often rented together with
+// the given one.
+func (rc *RecommendationController) GetRelatedProducts() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		productID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid product ID", nil))
+		}
+
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
+
+		limit, offset := page.Window()
+		res, err := rc.model.Related(productID, limit+offset)
+		if errors.Is(err, model.ErrProductNotFound) {
+			return c.JSON(http.StatusNotFound, helper.FormatResponse("Product not found", nil))
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get related products", nil))
+		}
+
+		data, meta := pagination.Slice(page, res)
+		return c.JSON(http.StatusOK, pagination.Response("Success get related products", data, meta))
+	}
+}
+
+// GetMyRecommendations suggests products based on the customer's past
+// rentals.
+func (rc *RecommendationController) GetMyRecommendations() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userID, role, ok := helper.TokenUser(c)
+		if !ok || role != helper.RoleCustomer {
+			return c.JSON(http.StatusForbidden, helper.FormatResponse("Only customers get recommendations", nil))
+		}
+
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
+
+		limit, offset := page.Window()
+		res, err := rc.model.ForUser(userID, limit+offset)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get recommendations", nil))
+		}
+
+		data, meta := pagination.Slice(page, res)
+		return c.JSON(http.StatusOK, pagination.Response("Success get recommendations", data, meta))
+	}
+}
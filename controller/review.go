@@ -0,0 +1,221 @@
+package controller
+
+import (
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"rentcamp/config"
+	"rentcamp/helper"
+	"rentcamp/model"
+	"rentcamp/pagination"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const maxReviewPhotos = 5
+
+type ReviewControllerInterface interface {
+	CreateReview() echo.HandlerFunc
+	GetProductReviews() echo.HandlerFunc
+	GetReviewsForModeration() echo.HandlerFunc
+	HideReview() echo.HandlerFunc
+	ShowReview() echo.HandlerFunc
+	ReplyReview() echo.HandlerFunc
+	FlagReview() echo.HandlerFunc
+}
+
+type ReviewController struct {
+	config config.Config
+	model  model.ReviewModelInterface
+}
+
+func NewReviewControllerInterface(m model.ReviewModelInterface, cfg config.Config) ReviewControllerInterface {
+	return &ReviewController{
+		config: cfg,
+		model:  m,
+	}
+}
+
+func reviewError(c echo.Context, err error, fallback string) error {
+	switch {
+	case errors.Is(err, model.ErrInvalidRating):
+		return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+	case errors.Is(err, model.ErrReviewNotAllowed):
+		return c.JSON(http.StatusForbidden, helper.FormatResponse(err.Error(), nil))
+	case errors.Is(err, model.ErrReviewNotFound):
+		return c.JSON(http.StatusNotFound, helper.FormatResponse(err.Error(), nil))
+	case errors.Is(err, model.ErrAlreadyReviewed):
+		return c.JSON(http.StatusConflict, helper.FormatResponse(err.Error(), nil))
+	}
+	return c.JSON(http.StatusInternalServerError, helper.FormatResponse(fallback, nil))
+}
+
+// CreateReview accepts a rating, text and up to five photos (multipart
+// field "photos") for a returned order line. The photos are only uploaded
+// once the review itself has been accepted.
+func (rc *ReviewController) CreateReview() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		productID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid product ID", nil))
+		}
+
+		userID, role, ok := helper.TokenUser(c)
+		if !ok || role != helper.RoleCustomer {
+			return c.JSON(http.StatusForbidden, helper.FormatResponse("Only customers can review products", nil))
+		}
+
+		var input = model.Review{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid review input", nil))
+		}
+		input.ProductId = productID
+		input.UserId = userID
+
+		if err := rc.model.CanReview(input); err != nil {
+			return reviewError(c, err, "Error creating review")
+		}
+
+		var files = []*multipart.FileHeader{}
+		if form, err := c.MultipartForm(); err == nil {
+			files = form.File["photos"]
+		}
+		if len(files) > maxReviewPhotos {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("A review can have at most 5 photos", nil))
+		}
+		for _, file := range files {
+			if err := helper.CheckImage(file); err != nil {
+				return uploadError(c, err)
+			}
+		}
+
+		var photos = []string{}
+		for _, file := range files {
+			url, err := helper.UploadImage(rc.config, file)
+			if err != nil {
+				return uploadError(c, err)
+			}
+			photos = append(photos, url)
+		}
+
+		res, err := rc.model.Insert(input, photos)
+		if err != nil {
+			return reviewError(c, err, "Error creating review")
+		}
+
+		return c.JSON(http.StatusCreated, helper.FormatResponse("Success create review", res))
+	}
+}
+
+func (rc *ReviewController) GetProductReviews() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		productID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid product ID", nil))
+		}
+
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
+
+		var res = rc.model.SelectByProduct(productID, page)
+		if res == nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get reviews", nil))
+		}
+
+		data, meta := pagination.KeysetResult(page, res, func(review model.Review) int { return review.Id })
+		return c.JSON(http.StatusOK, pagination.Response("Success get reviews", data, meta))
+	}
+}
+
+func (rc *ReviewController) GetReviewsForModeration() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
+
+		var res = rc.model.SelectForModeration(c.QueryParam("flagged") == "true", page)
+		if res == nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get reviews", nil))
+		}
+
+		data, meta := pagination.KeysetResult(page, res, func(review model.Review) int { return review.Id })
+		return c.JSON(http.StatusOK, pagination.Response("Success get reviews", data, meta))
+	}
+}
+
+func (rc *ReviewController) HideReview() echo.HandlerFunc {
+	return rc.setHidden(true)
+}
+
+func (rc *ReviewController) ShowReview() echo.HandlerFunc {
+	return rc.setHidden(false)
+}
+
+func (rc *ReviewController) setHidden(hidden bool) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		res, err := rc.model.SetHidden(id, hidden)
+		if err != nil {
+			return reviewError(c, err, "Error updating review")
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success update review", res))
+	}
+}
+
+func (rc *ReviewController) ReplyReview() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		var input = struct {
+			Reply string `json:"reply" form:"reply"`
+		}{}
+		if err := c.Bind(&input); err != nil || strings.TrimSpace(input.Reply) == "" {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("reply is required", nil))
+		}
+
+		adminID, _, _ := helper.TokenUser(c)
+
+		res, err := rc.model.Reply(id, adminID, strings.TrimSpace(input.Reply))
+		if err != nil {
+			return reviewError(c, err, "Error replying to review")
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success reply to review", res))
+	}
+}
+
+func (rc *ReviewController) FlagReview() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		var input = struct {
+			Reason string `json:"reason" form:"reason"`
+		}{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid flag input", nil))
+		}
+
+		res, err := rc.model.Flag(id, input.Reason)
+		if err != nil {
+			return reviewError(c, err, "Error flagging review")
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success flag review", res))
+	}
+}
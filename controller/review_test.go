@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"rentcamp/helper"
+	"rentcamp/model"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// stubReviews accepts reviews of order line 1 only.
+type stubReviews struct {
+	model.ReviewModelInterface
+	inserted bool
+}
+
+func (s *stubReviews) CanReview(review model.Review) error {
+	if review.Rating < 1 || review.Rating > 5 {
+		return model.ErrInvalidRating
+	}
+	if review.OrderItemId != 1 {
+		return model.ErrReviewNotAllowed
+	}
+	return nil
+}
+
+func (s *stubReviews) Insert(review model.Review, photos []string) (*model.Review, error) {
+	s.inserted = true
+	return &review, nil
+}
+
+func TestCreateReviewChecksBeforeUploading(t *testing.T) {
+	var tests = []struct {
+		name       string
+		fields     map[string]string
+		photos     [][]byte
+		wantStatus int
+	}{
+		{"review without photos", map[string]string{"rating": "5", "order_item_id": "1"}, nil, http.StatusCreated},
+		{"rating out of range", map[string]string{"rating": "9", "order_item_id": "1"}, [][]byte{[]byte("photo")}, http.StatusBadRequest},
+		{"not the renter", map[string]string{"rating": "5", "order_item_id": "2"}, [][]byte{[]byte("photo")}, http.StatusForbidden},
+		{"photo that is not an image", map[string]string{"rating": "5", "order_item_id": "1"}, [][]byte{[]byte("<html></html>")}, http.StatusBadRequest},
+		{"too many photos", map[string]string{"rating": "5", "order_item_id": "1"}, make([][]byte, 6), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body = &bytes.Buffer{}
+			var writer = multipart.NewWriter(body)
+			for key, value := range tt.fields {
+				writer.WriteField(key, value)
+			}
+			for _, photo := range tt.photos {
+				part, _ := writer.CreateFormFile("photos", "photo.png")
+				part.Write(photo)
+			}
+			writer.Close()
+
+			var reviews = &stubReviews{}
+			var rc = &ReviewController{model: reviews}
+			var c, rec = newAuthContext(http.MethodPost, "/products/7/reviews", body.String(), 10, helper.RoleCustomer)
+			c.Request().Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+			c.SetParamNames("id")
+			c.SetParamValues("7")
+			if err := rc.CreateReview()(c); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if reviews.inserted != (tt.wantStatus == http.StatusCreated) {
+				t.Fatalf("inserted = %v for status %d", reviews.inserted, rec.Code)
+			}
+		})
+	}
+}
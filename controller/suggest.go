@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"net/http"
+	"rentcamp/helper"
+	"rentcamp/pagination"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultSuggestLimit = 5
+	maxSuggestLimit     = 20
+)
+
+// Suggest powers the search box autocomplete from the in-memory prefix
+// index, so it never touches the database.
+func (cpc *ProductController) Suggest() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var limit = defaultSuggestLimit
+		if val, err := strconv.Atoi(c.QueryParam("limit")); err == nil && val > 0 {
+			limit = min(val, maxSuggestLimit)
+		}
+
+		var res = cpc.suggester.Suggest(c.QueryParam("q"), limit)
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success get suggestions", res))
+	}
+}
+
+// GetZeroResultQueries lists the searches of the last ?days (default 30)
+// that found no products.
+func (cpc *ProductController) GetZeroResultQueries() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var days = 30
+		if val, err := strconv.Atoi(c.QueryParam("days")); err == nil && val > 0 {
+			days = val
+		}
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
+
+		var res = cpc.searchLog.ZeroResults(time.Now().AddDate(0, 0, -days), page)
+		if res == nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get search queries", nil))
+		}
+
+		data, meta := pagination.OffsetResult(page, res)
+		return c.JSON(http.StatusOK, pagination.Response("Success get zero result queries", data, meta))
+	}
+}
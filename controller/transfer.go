@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"rentcamp/helper"
+	"rentcamp/model"
+	"rentcamp/pagination"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+func transferError(c echo.Context, err error, fallback string) error {
+	switch {
+	case errors.Is(err, model.ErrTransferNotFound), errors.Is(err, model.ErrProductNotFound), errors.Is(err, model.ErrBranchNotFound):
+		return c.JSON(http.StatusNotFound, helper.FormatResponse(err.Error(), nil))
+	case errors.Is(err, model.ErrInvalidTransfer):
+		return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+	case errors.Is(err, model.ErrTransferNotAllowed), errors.Is(err, model.ErrTransferUnavailable), errors.Is(err, model.ErrInvalidStock):
+		return c.JSON(http.StatusConflict, helper.FormatResponse(err.Error(), nil))
+	}
+	return c.JSON(http.StatusInternalServerError, helper.FormatResponse(fallback, nil))
+}
+
+func (cpc *ProductController) CreateTransfer() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var input = model.StockTransfer{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid transfer input", nil))
+		}
+		input.RequestedBy, _, _ = helper.TokenUser(c)
+
+		res, err := cpc.transfers.Request(input)
+		if err != nil {
+			return transferError(c, err, "Error create transfer")
+		}
+
+		return c.JSON(http.StatusCreated, helper.FormatResponse("Success create transfer", res))
+	}
+}
+
+// GetTransfers lists transfers, optionally filtered by ?status and by
+// ?branch_id on either end.
+func (cpc *ProductController) GetTransfers() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var branchID int
+		if param := c.QueryParam("branch_id"); param != "" {
+			id, err := strconv.Atoi(param)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid branch ID", nil))
+			}
+			branchID = id
+		}
+
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
+
+		var res = cpc.transfers.SelectAll(c.QueryParam("status"), branchID, page)
+		if res == nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get transfers", nil))
+		}
+
+		data, meta := pagination.KeysetResult(page, res, func(transfer model.StockTransfer) int { return transfer.Id })
+		return c.JSON(http.StatusOK, pagination.Response("Success get transfers", data, meta))
+	}
+}
+
+func (cpc *ProductController) GetTransferById() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		res, err := cpc.transfers.SelectById(id)
+		if err != nil {
+			return transferError(c, err, "Error get transfer")
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success get transfer", res))
+	}
+}
+
+func (cpc *ProductController) DispatchTransfer() echo.HandlerFunc {
+	return cpc.moveTransfer(cpc.transfers.Dispatch, "Success dispatch transfer", "Error dispatch transfer")
+}
+
+func (cpc *ProductController) ReceiveTransfer() echo.HandlerFunc {
+	return cpc.moveTransfer(cpc.transfers.Receive, "Success receive transfer", "Error receive transfer")
+}
+
+func (cpc *ProductController) CancelTransfer() echo.HandlerFunc {
+	return cpc.moveTransfer(cpc.transfers.Cancel, "Success cancel transfer", "Error cancel transfer")
+}
+
+// moveTransfer handles the status changes, which all take an optional note
+// that is kept in the transfer history.
+func (cpc *ProductController) moveTransfer(move func(transferID int, adminID int, note string) (*model.StockTransfer, error), success string, fallback string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		var input = struct {
+			Note string `json:"note" form:"note"`
+		}{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid transfer input", nil))
+		}
+
+		adminID, _, _ := helper.TokenUser(c)
+		res, err := move(id, adminID, input.Note)
+		if err != nil {
+			return transferError(c, err, fallback)
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse(success, res))
+	}
+}
@@ -0,0 +1,213 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"rentcamp/helper"
+	"rentcamp/model"
+	"rentcamp/pagination"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type TripControllerInterface interface {
+	GetTemplates() echo.HandlerFunc
+	GetTemplateById() echo.HandlerFunc
+	CreateTemplate() echo.HandlerFunc
+	UpdateTemplate() echo.HandlerFunc
+	DeleteTemplate() echo.HandlerFunc
+	PlanTrip() echo.HandlerFunc
+	AddPlanToCart() echo.HandlerFunc
+}
+
+type TripController struct {
+	model model.TripModelInterface
+	carts model.CartModelInterface
+}
+
+func NewTripControllerInterface(m model.TripModelInterface, carts model.CartModelInterface) TripControllerInterface {
+	return &TripController{
+		model: m,
+		carts: carts,
+	}
+}
+
+func tripError(c echo.Context, err error, fallback string) error {
+	switch {
+	case errors.Is(err, model.ErrTripTemplateNotFound):
+		return c.JSON(http.StatusNotFound, helper.FormatResponse(err.Error(), nil))
+	case errors.Is(err, model.ErrInvalidTripTemplate), errors.Is(err, model.ErrInvalidTripPeople), errors.Is(err, model.ErrInvalidDates):
+		return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+	}
+	return c.JSON(http.StatusInternalServerError, helper.FormatResponse(fallback, nil))
+}
+
+func (tc *TripController) GetTemplates() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
+
+		var res = tc.model.SelectTemplates(page)
+		if res == nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get trip templates", nil))
+		}
+
+		data, meta := pagination.KeysetResult(page, res, func(template model.TripTemplate) int { return template.Id })
+		return c.JSON(http.StatusOK, pagination.Response("Success get trip templates", data, meta))
+	}
+}
+
+func (tc *TripController) GetTemplateById() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		res, err := tc.model.SelectTemplate(id)
+		if err != nil {
+			return tripError(c, err, "Error get trip template")
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success get trip template", res))
+	}
+}
+
+func (tc *TripController) CreateTemplate() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var input = model.TripTemplate{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid trip template input", nil))
+		}
+
+		res, err := tc.model.InsertTemplate(input)
+		if err != nil {
+			return tripError(c, err, "Error create trip template")
+		}
+
+		return c.JSON(http.StatusCreated, helper.FormatResponse("Success create trip template", res))
+	}
+}
+
+func (tc *TripController) UpdateTemplate() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		var input = model.TripTemplate{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid trip template input", nil))
+		}
+		input.Id = id
+
+		res, err := tc.model.UpdateTemplate(input)
+		if err != nil {
+			return tripError(c, err, "Error update trip template")
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success update trip template", res))
+	}
+}
+
+func (tc *TripController) DeleteTemplate() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		if !tc.model.DeleteTemplate(id) {
+			return c.JSON(http.StatusNotFound, helper.FormatResponse("Trip template not found", nil))
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success delete trip template", nil))
+	}
+}
+
+// PlanTrip suggests the gear for a trip, with availability checked for the
+// requested dates. Nothing is reserved.
+func (tc *TripController) PlanTrip() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var input = model.TripPlanRequest{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid trip plan input", nil))
+		}
+
+		res, err := tc.model.Plan(input, 0)
+		if err != nil {
+			return tripError(c, err, "Error planning trip")
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success plan trip", res))
+	}
+}
+
+// AddPlanToCart plans the trip and puts every line that can be rented in
+// the customer's cart. Lines that cannot be added are returned as skipped.
+func (tc *TripController) AddPlanToCart() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userID, role, ok := helper.TokenUser(c)
+		if !ok || role != helper.RoleCustomer {
+			return c.JSON(http.StatusForbidden, helper.FormatResponse("Only customers have a cart", nil))
+		}
+
+		var input = model.TripPlanRequest{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid trip plan input", nil))
+		}
+
+		cart, _, err := tc.carts.CreateCart(model.Cart{UserID: userID})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error fetching cart", nil))
+		}
+
+		plan, err := tc.model.Plan(input, cart.ID)
+		if err != nil {
+			return tripError(c, err, "Error planning trip")
+		}
+
+		type skippedLine struct {
+			Category string `json:"category"`
+			Reason   string `json:"reason"`
+		}
+		var added = []model.CartItem{}
+		var skipped = []skippedLine{}
+
+		for _, line := range plan.Lines {
+			if line.Product == nil {
+				skipped = append(skipped, skippedLine{Category: line.Category, Reason: "no product in this category"})
+				continue
+			}
+			if !line.Sufficient {
+				skipped = append(skipped, skippedLine{Category: line.Category, Reason: model.ErrInsufficientStock.Error()})
+				continue
+			}
+
+			item, err := tc.carts.AddItemToCart(cart.ID, model.CartItem{
+				ProductID: line.Product.Id,
+				Quantity:  line.Quantity,
+				StartDate: plan.StartDate,
+				EndDate:   plan.EndDate,
+			})
+			if err != nil {
+				skipped = append(skipped, skippedLine{Category: line.Category, Reason: err.Error()})
+				continue
+			}
+			added = append(added, *item)
+		}
+
+		var res = map[string]any{
+			"cart_id": cart.ID,
+			"plan":    plan,
+			"added":   added,
+			"skipped": skipped,
+		}
+
+		return c.JSON(http.StatusCreated, helper.FormatResponse("Trip plan added to cart", res))
+	}
+}
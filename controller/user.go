@@ -6,11 +6,11 @@ import (
 	"rentcamp/config"
 	"rentcamp/helper"
 	"rentcamp/model"
+	"rentcamp/pagination"
 	"strconv"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/sirupsen/logrus"
 )
 
 type UserControllerInterface interface {
@@ -20,16 +20,27 @@ type UserControllerInterface interface {
 	GetUserById() echo.HandlerFunc
 	UpdateUser() echo.HandlerFunc
 	DeleteUser() echo.HandlerFunc
+	UnlockUser() echo.HandlerFunc
+	OIDCLogin() echo.HandlerFunc
+	OIDCCallback() echo.HandlerFunc
+	Unsubscribe() echo.HandlerFunc
 }
 
 type UserController struct {
-	config config.Config
-	model  model.UserModelInterface
+	config   config.Config
+	model    model.UserModelInterface
+	carts    model.CartModelInterface
+	throttle *helper.LoginThrottle
+	oidc     *helper.OIDCProvider
 }
 
-func NewUserControlInterface(m model.UserModelInterface) UserControllerInterface {
+func NewUserControlInterface(m model.UserModelInterface, carts model.CartModelInterface, cfg config.Config, throttle *helper.LoginThrottle, oidc *helper.OIDCProvider) UserControllerInterface {
 	return &UserController{
-		model: m,
+		model:    m,
+		carts:    carts,
+		config:   cfg,
+		throttle: throttle,
+		oidc:     oidc,
 	}
 }
 
@@ -57,7 +68,15 @@ func (uc *UserController) CreateUser() echo.HandlerFunc {
 			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Cannot process data, something happend", nil))
 		}
 
-		return c.JSON(http.StatusCreated, helper.FormatResponse("success create user", res))
+		var message = "success create user"
+		adjustments, err := uc.mergeGuestCart(c, res.Id)
+		if err != nil {
+			message += ", but the guest cart could not be merged: " + err.Error()
+		} else if len(adjustments) > 0 {
+			message += ", but some guest cart items did not fit the stock left"
+		}
+
+		return c.JSON(http.StatusCreated, helper.FormatResponse(message, res))
 	}
 }
 
@@ -69,68 +88,84 @@ func (uc *UserController) Login() echo.HandlerFunc {
 			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid user input", nil))
 		}
 
+		var account = helper.AccountKey("customer", input.Username)
+		if wait := uc.throttle.Check(account, c.RealIP()); wait > 0 {
+			return helper.TooManyAttempts(c, wait)
+		}
+
 		var res = uc.model.Login(input.Username, input.Password)
 
 		if res == nil {
-			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Cannot process data, something happend", nil))
+			uc.throttle.Failure(account, c.RealIP())
+			return c.JSON(http.StatusUnauthorized, helper.FormatResponse("Invalid username or password", nil))
 		}
+		uc.throttle.Success(account)
 
 		if res.Id == 0 {
 			return c.JSON(http.StatusNotFound, helper.FormatResponse("Data not found", nil))
 		}
-		var role = "user"
-		var jwtToken = helper.GenerateJWT(uc.config.Secret, uc.config.RefreshSecret, res.Id, res.Username, role)
 
-		if jwtToken == nil {
-			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Cannot process data, something happend", nil))
+		var extra = map[string]any{}
+		adjustments, err := uc.mergeGuestCart(c, res.Id)
+		if err != nil {
+			extra["cart_merge_error"] = err.Error()
+		} else if len(adjustments) > 0 {
+			extra["cart_merge_adjustments"] = adjustments
 		}
-		var info = map[string]any{}
-		info["id"] = res.Id
-		info["name"] = res.Name
-		info["username"] = res.Username
 
-		jwtToken["info"] = info
-
-		return c.JSON(http.StatusOK, helper.FormatResponse("login success", jwtToken))
+		return uc.loginSuccess(c, res, extra)
 	}
 }
 
-func (uc *UserController) GetAllUsers() echo.HandlerFunc {
-	return func(c echo.Context) error {
-		pageStr := c.QueryParam("page")
-		limitStr := c.QueryParam("limit")
-		search := c.QueryParam("name")
-
-		var page, limit int
-		if pageStr != "" {
-			page, _ = strconv.Atoi(pageStr)
-		}
-		if limitStr != "" {
-			limit, _ = strconv.Atoi(limitStr)
-		}
-
-		if page == 0 || limit == 0 {
-			var res = uc.model.SelectAll()
+// mergeGuestCart merges the guest cart sent along with a login or sign up.
+// A failed merge does not fail the login; the error is logged and returned
+// so the response can tell the client the guest cart was left as it was.
+// Lines that were capped or left out are returned as adjustments.
+func (uc *UserController) mergeGuestCart(c echo.Context, userID int) ([]model.MergeAdjustment, error) {
+	res, err := MergeGuestCartToken(uc.carts, uc.config.Secret, c.Request().Header.Get(helper.CartTokenHeader), userID)
+	if err != nil {
+		logrus.Error("Customer : cannot merge guest cart for user ", userID, ", ", err.Error())
+		return nil, err
+	}
+	if res == nil {
+		return nil, nil
+	}
+	return res.MergeAdjustments, nil
+}
 
-			if res == nil {
-				return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get all users, ", nil))
-			}
+func (uc *UserController) loginSuccess(c echo.Context, res *model.User, extra map[string]any) error {
+	var jwtToken = helper.GenerateJWT(uc.config.Secret, uc.config.RefreshSecret, res.Id, res.Username, helper.RoleCustomer)
 
-			return c.JSON(http.StatusOK, helper.FormatResponse("Success get all users, ", res))
-		} else {
-			res, totalCount, err := uc.model.SelectAllWithPagination(page, limit, search)
+	if jwtToken == nil {
+		return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Cannot process data, something happend", nil))
+	}
+	var info = map[string]any{}
+	info["id"] = res.Id
+	info["name"] = res.Name
+	info["username"] = res.Username
+
+	jwtToken["info"] = info
+	for key, val := range extra {
+		jwtToken[key] = val
+	}
 
-			if err != nil {
-				return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error fetching products", nil))
-			}
+	return c.JSON(http.StatusOK, helper.FormatResponse("login success", jwtToken))
+}
 
-			response := map[string]interface{}{
-				"products":   res,
-				"total_data": totalCount,
-			}
+func (uc *UserController) GetAllUsers() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
 
-			return c.JSON(http.StatusOK, helper.FormatResponse("Success fetching products", response))
+		res, totalCount, err := uc.model.SelectPage(page, c.QueryParam("name"))
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get all users", nil))
 		}
+
+		data, meta := pagination.KeysetResult(page, res, func(user model.User) int { return user.Id })
+		return c.JSON(http.StatusOK, pagination.Response("Success get all users", data, meta.WithTotal(totalCount)))
 	}
 }
 
@@ -154,32 +189,18 @@ func (uc *UserController) GetUserById() echo.HandlerFunc {
 
 func (uc *UserController) UpdateUser() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		user := c.Get("user").(*jwt.Token)
-		claims := user.Claims.(jwt.MapClaims)
-		role := claims["role"].(string)
-		userID := int(claims["id"].(float64))
-
 		paramID := c.Param("id")
 		id, err := strconv.Atoi(paramID)
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
 		}
 
-		if role != "admin" && userID != id {
-			return c.JSON(http.StatusForbidden, helper.FormatResponse("Permission denied. You don't have the required permissions.", nil))
-		}
-
 		var input = model.User{}
 		if err := c.Bind(&input); err != nil {
 			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid user input", nil))
 		}
 
 		input.Id = id
-		hashpwd, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
-		if err != nil {
-			return errors.New("Gagal mengenkripsi kata sandi: " + err.Error())
-		}
-		input.Password = string(hashpwd)
 
 		res, err := uc.model.Update(input)
 		if err != nil {
@@ -192,32 +213,52 @@ func (uc *UserController) UpdateUser() echo.HandlerFunc {
 
 func (uc *UserController) DeleteUser() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		user := c.Get("user").(*jwt.Token)
-		claims := user.Claims.(jwt.MapClaims)
-		role := claims["role"].(string)
-		userID := int(claims["id"].(float64))
+		var paramId = c.Param("id")
 
-		paramID := c.Param("id")
-		id, err := strconv.Atoi(paramID)
+		cnv, err := strconv.Atoi(paramId)
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
 		}
 
-		if role != "admin" && userID != id {
-			return c.JSON(http.StatusForbidden, helper.FormatResponse("Permission denied. You don't have the required permissions.", nil))
+		success := uc.model.Delete(cnv)
+		if !success {
+			return c.JSON(http.StatusNotFound, helper.FormatResponse("User not found", nil))
 		}
-		var paramId = c.Param("id")
 
-		cnv, err := strconv.Atoi(paramId)
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success delete user", nil))
+	}
+}
+
+func (uc *UserController) UnlockUser() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
 		}
 
-		success := uc.model.Delete(cnv)
-		if !success {
+		var target = uc.model.SelectById(id)
+		if target == nil {
 			return c.JSON(http.StatusNotFound, helper.FormatResponse("User not found", nil))
 		}
 
-		return c.JSON(http.StatusOK, helper.FormatResponse("Success delete user", nil))
+		uc.throttle.Unlock(helper.AccountKey("customer", target.Username))
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Success unlock user", nil))
+	}
+}
+
+// Unsubscribe handles the link at the bottom of reminder emails.
+func (uc *UserController) Unsubscribe() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := helper.ParseUnsubscribeToken(uc.config.Secret, c.QueryParam("token"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid unsubscribe link", nil))
+		}
+
+		if !uc.model.SetEmailOptOut(id, true) {
+			return c.JSON(http.StatusNotFound, helper.FormatResponse("User not found", nil))
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("You will no longer receive reminder emails", nil))
 	}
 }
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"rentcamp/config"
+	"rentcamp/helper"
+	"rentcamp/model"
+	"testing"
+)
+
+type loginUsers struct {
+	model.UserModelInterface
+}
+
+func (loginUsers) Login(username string, password string) *model.User {
+	if username == "budi" && password == "pass" {
+		return &model.User{Id: 10, Name: "Budi", Username: "budi"}
+	}
+	return nil
+}
+
+// mergeCarts answers MergeGuestCart with err or adjustments and records the
+// merged carts.
+type mergeCarts struct {
+	model.CartModelInterface
+	err         error
+	adjustments []model.MergeAdjustment
+	merged      []int
+}
+
+func (m *mergeCarts) MergeGuestCart(guestCartID, userID int) (*model.Cart, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	m.merged = append(m.merged, guestCartID)
+	return &model.Cart{ID: 1, UserID: userID, MergeAdjustments: m.adjustments}, nil
+}
+
+func TestLoginMergesGuestCart(t *testing.T) {
+	var cfg = config.Config{Secret: "secret", RefreshSecret: "refresh", LoginMaxAttempts: 5, LoginMaxIPAttempts: 20, LoginBackoffSeconds: 1, LoginLockoutMinutes: 15}
+
+	var tests = []struct {
+		name            string
+		cartToken       string
+		mergeErr        error
+		adjustments     []model.MergeAdjustment
+		wantMerged      bool
+		wantMergeErr    bool
+		wantAdjustments bool
+	}{
+		{"no guest cart", "", nil, nil, false, false, false},
+		{"guest cart merged", helper.GenerateCartToken("secret", 7), nil, nil, true, false, false},
+		{"guest cart merged with capped lines", helper.GenerateCartToken("secret", 7), nil, []model.MergeAdjustment{{ProductID: 3, Requested: 2, Merged: 1}}, true, false, true},
+		{"guest cart already merged", helper.GenerateCartToken("secret", 7), errors.New("guest cart already merged"), nil, false, true, false},
+		{"forged cart token", helper.GenerateCartToken("other", 7), nil, nil, false, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var carts = &mergeCarts{err: tt.mergeErr, adjustments: tt.adjustments}
+			var uc = &UserController{config: cfg, model: loginUsers{}, carts: carts, throttle: helper.NewLoginThrottle(cfg)}
+
+			var c, rec = newAuthContext(http.MethodPost, "/customer/login", `{"username":"budi","password":"pass"}`, 0, "")
+			if tt.cartToken != "" {
+				c.Request().Header.Set(helper.CartTokenHeader, tt.cartToken)
+			}
+
+			if err := uc.Login()(c); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != http.StatusOK {
+				t.Fatalf("a failed merge must not fail the login, status = %d", rec.Code)
+			}
+
+			var body struct {
+				Data map[string]any `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if _, found := body.Data["cart_merge_error"]; found != tt.wantMergeErr {
+				t.Fatalf("cart_merge_error present = %v, want %v: %s", found, tt.wantMergeErr, rec.Body.String())
+			}
+			if _, found := body.Data["cart_merge_adjustments"]; found != tt.wantAdjustments {
+				t.Fatalf("cart_merge_adjustments present = %v, want %v: %s", found, tt.wantAdjustments, rec.Body.String())
+			}
+			if (len(carts.merged) == 1) != tt.wantMerged {
+				t.Fatalf("merged carts = %v, want merged %v", carts.merged, tt.wantMerged)
+			}
+		})
+	}
+}
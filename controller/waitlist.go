@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"rentcamp/helper"
+	"rentcamp/model"
+	"rentcamp/pagination"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// JoinWaitlist queues the caller for a product that is fully booked for the
+// requested dates.
+func (cpc *ProductController) JoinWaitlist() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		productID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid product ID", nil))
+		}
+
+		userID, role, ok := helper.TokenUser(c)
+		if !ok || role != helper.RoleCustomer {
+			return c.JSON(http.StatusForbidden, helper.FormatResponse("Only customers can join a waitlist", nil))
+		}
+
+		var input = model.WaitlistEntry{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid waitlist input", nil))
+		}
+		if input.Quantity == 0 {
+			input.Quantity = 1
+		}
+		input.ProductId = productID
+		input.UserId = userID
+
+		res, err := cpc.waitlist.Join(input)
+		if err != nil {
+			switch {
+			case errors.Is(err, model.ErrWaitlistInvalidInput):
+				return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+			case errors.Is(err, model.ErrProductNotFound):
+				return c.JSON(http.StatusNotFound, helper.FormatResponse(err.Error(), nil))
+			case errors.Is(err, model.ErrStillAvailable), errors.Is(err, model.ErrAlreadyOnWaitlist):
+				return c.JSON(http.StatusConflict, helper.FormatResponse(err.Error(), nil))
+			}
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error joining waitlist", nil))
+		}
+
+		return c.JSON(http.StatusCreated, helper.FormatResponse("You are on the waitlist, we will email you when a unit frees up", res))
+	}
+}
+
+func (cpc *ProductController) GetMyWaitlist() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userID, _, _ := helper.TokenUser(c)
+
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
+
+		var res = cpc.waitlist.SelectByUser(userID, page)
+		if res == nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get waitlist", nil))
+		}
+
+		data, meta := pagination.KeysetResult(page, res, func(entry model.WaitlistEntry) int { return entry.Id })
+		return c.JSON(http.StatusOK, pagination.Response("Success get waitlist", data, meta))
+	}
+}
+
+func (cpc *ProductController) LeaveWaitlist() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid id", nil))
+		}
+
+		userID, _, _ := helper.TokenUser(c)
+
+		if !cpc.waitlist.Leave(userID, id) {
+			return c.JSON(http.StatusNotFound, helper.FormatResponse(model.ErrWaitlistNotFound.Error(), nil))
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("You left the waitlist", nil))
+	}
+}
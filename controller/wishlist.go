@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"rentcamp/helper"
+	"rentcamp/model"
+	"rentcamp/pagination"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type WishlistControllerInterface interface {
+	GetWishlist() echo.HandlerFunc
+	AddToWishlist() echo.HandlerFunc
+	RemoveFromWishlist() echo.HandlerFunc
+	MoveToCart() echo.HandlerFunc
+	RequireCustomer() echo.MiddlewareFunc
+}
+
+type WishlistController struct {
+	model model.WishlistModelInterface
+	carts model.CartModelInterface
+}
+
+func NewWishlistControllerInterface(m model.WishlistModelInterface, carts model.CartModelInterface) WishlistControllerInterface {
+	return &WishlistController{
+		model: m,
+		carts: carts,
+	}
+}
+
+func (wc *WishlistController) RequireCustomer() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if _, role, ok := helper.TokenUser(c); !ok || role != helper.RoleCustomer {
+				return c.JSON(http.StatusForbidden, helper.FormatResponse("Only customers have a wishlist", nil))
+			}
+			return next(c)
+		}
+	}
+}
+
+func (wc *WishlistController) GetWishlist() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userID, _, _ := helper.TokenUser(c)
+
+		page, err := pagination.Parse(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse(err.Error(), nil))
+		}
+
+		var res = wc.model.List(userID, page)
+		if res == nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error get wishlist", nil))
+		}
+
+		data, meta := pagination.KeysetResult(page, res, func(item model.WishlistItem) int { return item.Id })
+		return c.JSON(http.StatusOK, pagination.Response("Success get wishlist", data, meta))
+	}
+}
+
+func (wc *WishlistController) AddToWishlist() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var input = struct {
+			ProductId int `json:"product_id" form:"product_id"`
+		}{}
+		if err := c.Bind(&input); err != nil || input.ProductId == 0 {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("product_id is required", nil))
+		}
+
+		userID, _, _ := helper.TokenUser(c)
+
+		res, err := wc.model.Add(userID, input.ProductId)
+		if err != nil {
+			if errors.Is(err, model.ErrProductNotFound) {
+				return c.JSON(http.StatusNotFound, helper.FormatResponse(err.Error(), nil))
+			}
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error adding to wishlist", nil))
+		}
+
+		return c.JSON(http.StatusCreated, helper.FormatResponse("Product saved to wishlist", res))
+	}
+}
+
+func (wc *WishlistController) RemoveFromWishlist() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		productID, err := strconv.Atoi(c.Param("product_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid product ID", nil))
+		}
+
+		userID, _, _ := helper.TokenUser(c)
+
+		if !wc.model.Remove(userID, productID) {
+			return c.JSON(http.StatusNotFound, helper.FormatResponse("Product is not in your wishlist", nil))
+		}
+
+		return c.JSON(http.StatusOK, helper.FormatResponse("Product removed from wishlist", nil))
+	}
+}
+
+// MoveToCart adds a wishlisted product to the active cart for the given
+// dates and takes it off the wishlist.
+func (wc *WishlistController) MoveToCart() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		productID, err := strconv.Atoi(c.Param("product_id"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid product ID", nil))
+		}
+
+		var input = model.CartItem{}
+		if err := c.Bind(&input); err != nil {
+			return c.JSON(http.StatusBadRequest, helper.FormatResponse("Invalid cart item input", nil))
+		}
+		if input.Quantity == 0 {
+			input.Quantity = 1
+		}
+		input.ProductID = productID
+
+		userID, _, _ := helper.TokenUser(c)
+
+		if !wc.model.Has(userID, productID) {
+			return c.JSON(http.StatusNotFound, helper.FormatResponse("Product is not in your wishlist", nil))
+		}
+
+		cart, _, err := wc.carts.CreateCart(model.Cart{UserID: userID})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, helper.FormatResponse("Error fetching cart", nil))
+		}
+
+		res, err := wc.carts.AddItemToCart(cart.ID, input)
+		if err != nil {
+			return cartItemError(c, err, "Error adding item to cart")
+		}
+
+		wc.model.Remove(userID, productID)
+
+		return c.JSON(http.StatusCreated, helper.FormatResponse("Product moved to cart", res))
+	}
+}
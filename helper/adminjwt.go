@@ -1,6 +1,7 @@
 package helper
 
 import (
+	"errors"
 	"rentcamp/config"
 	"time"
 
@@ -127,10 +128,111 @@ func ExtractToken(token *jwt.Token) any {
 	return nil
 }
 
-func Middleware() echo.MiddlewareFunc {
-	cfg := config.Config{}
-	return echojwt.WithConfig(echojwt.Config{
-		SigningKey:    []byte(cfg.Secret),
-		SigningMethod: "HS256",
+// AccountChecker reports whether the account a token was issued for may
+// still be used, so disabling an account revokes its outstanding tokens.
+type AccountChecker interface {
+	IsAccountActive(id int, role string) bool
+}
+
+var accountChecker AccountChecker
+
+func UseAccountChecker(checker AccountChecker) {
+	accountChecker = checker
+}
+
+func ParseAccessToken(signKey string, auth string) (*jwt.Token, error) {
+	token, err := jwt.Parse(auth, func(t *jwt.Token) (interface{}, error) {
+		return []byte(signKey), nil
+	}, jwt.WithValidMethods([]string{"HS256"}))
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+
+	if accountChecker != nil {
+		id, _ := claims["id"].(float64)
+		role, _ := claims["role"].(string)
+		if !accountChecker.IsAccountActive(int(id), NormalizeRole(role)) {
+			return nil, errors.New("account is disabled")
+		}
+	}
+
+	return token, nil
+}
+
+// Middleware authenticates a request either with a bearer JWT or, for
+// partner integrations, with an X-API-Key header.
+func Middleware(cfg config.Config) echo.MiddlewareFunc {
+	var jwtMiddleware = echojwt.WithConfig(echojwt.Config{
+		ParseTokenFunc: func(c echo.Context, auth string) (interface{}, error) {
+			return ParseAccessToken(cfg.Secret, auth)
+		},
 	})
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		var withJWT = jwtMiddleware(next)
+		return func(c echo.Context) error {
+			if c.Request().Header.Get(APIKeyHeader) != "" {
+				return apiKeyAuth(c, next)
+			}
+			return withJWT(c)
+		}
+	}
+}
+
+const (
+	MFAPurposeLogin  = "login"
+	MFAPurposeEnroll = "enroll"
+)
+
+// MFA challenge tokens are signed with a derived key so they can never be
+// accepted by Middleware as an access token.
+func mfaKey(signKey string) []byte {
+	return []byte(signKey + ":mfa")
+}
+
+func GenerateMFAToken(signKey string, adminId int, purpose string) string {
+	var claims = jwt.MapClaims{}
+	claims["id"] = adminId
+	claims["mfa"] = purpose
+	claims["iat"] = time.Now().Unix()
+	claims["exp"] = time.Now().Add(time.Minute * 5).Unix()
+
+	var sign = jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+
+	token, err := sign.SignedString(mfaKey(signKey))
+	if err != nil {
+		logrus.Error("JWT : cannot sign mfa token, ", err.Error())
+		return ""
+	}
+
+	return token
+}
+
+func ParseMFAToken(signKey string, auth string, purpose string) (int, error) {
+	token, err := jwt.Parse(auth, func(t *jwt.Token) (interface{}, error) {
+		return mfaKey(signKey), nil
+	}, jwt.WithValidMethods([]string{"HS256"}))
+	if err != nil {
+		return 0, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid || claims["mfa"] != purpose {
+		return 0, errors.New("invalid mfa token")
+	}
+
+	id, ok := claims["id"].(float64)
+	if !ok {
+		return 0, errors.New("invalid mfa token")
+	}
+
+	return int(id), nil
 }
@@ -0,0 +1,35 @@
+package helper
+
+import "testing"
+
+type stubAccounts map[int]bool
+
+func (s stubAccounts) IsAccountActive(id int, role string) bool {
+	return !IsStaffRole(role) || s[id]
+}
+
+func TestParseAccessTokenRefusesDisabledAccounts(t *testing.T) {
+	UseAccountChecker(stubAccounts{1: true, 2: false})
+	t.Cleanup(func() { UseAccountChecker(nil) })
+
+	var tests = []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"active staff", generateToken("secret", 1, "owner", RoleOwner), false},
+		{"disabled staff", generateToken("secret", 2, "staff", RoleWarehouse), true},
+		{"legacy admin role is checked too", generateToken("secret", 2, "staff", "admin"), true},
+		{"customer", generateToken("secret", 2, "budi", RoleCustomer), false},
+		{"other signing key", generateToken("other", 1, "owner", RoleOwner), true},
+		{"mfa token", GenerateMFAToken("secret", 1, MFAPurposeLogin), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseAccessToken("secret", tt.token); (err != nil) != tt.wantErr {
+				t.Errorf("ParseAccessToken err = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
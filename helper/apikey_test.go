@@ -0,0 +1,95 @@
+package helper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"rentcamp/config"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+type stubKeys map[string]*APIKeyIdentity
+
+func (s stubKeys) ResolveAPIKey(key string) *APIKeyIdentity {
+	return s[key]
+}
+
+// newScopeServer registers routes the way route.RouteCart and
+// route.RouteProduct do: authenticated routes with and without a declared
+// scope, and a public catalogue route.
+func newScopeServer(t *testing.T) *echo.Echo {
+	UseAPIKeyResolver(stubKeys{
+		"cart-key":    {Id: 1, UserId: 10, Partner: "Kemah Tour", Scopes: []string{ScopeCartRead}},
+		"catalog-key": {Id: 2, UserId: 11, Partner: "Bukit Camp", Scopes: []string{ScopeCatalogRead}},
+	})
+	t.Cleanup(func() { UseAPIKeyResolver(nil) })
+
+	var whoami = func(c echo.Context) error {
+		_, role, ok := TokenUser(c)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, FormatResponse("Invalid or missing token", nil))
+		}
+		return c.String(http.StatusOK, role)
+	}
+
+	var e = echo.New()
+	var cfg = config.Config{Secret: "secret"}
+	var me = e.Group("/me")
+	me.Use(Middleware(cfg))
+	me.GET("/cart", whoami, RequireScope(ScopeCartRead))
+	me.GET("/orders", whoami)
+
+	e.GET("/products", func(c echo.Context) error {
+		return c.String(http.StatusOK, "catalogue")
+	}, RequireScope(ScopeCatalogRead))
+
+	return e
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	var customerToken = generateToken("secret", 10, "budi", RoleCustomer)
+
+	var tests = []struct {
+		name       string
+		path       string
+		apiKey     string
+		bearer     string
+		wantStatus int
+		wantBody   string
+	}{
+		{"key with the declared scope", "/me/cart", "cart-key", "", http.StatusOK, RolePartner},
+		{"key without the declared scope", "/me/cart", "catalog-key", "", http.StatusForbidden, ""},
+		{"key on a route without a scope", "/me/orders", "cart-key", "", http.StatusUnauthorized, ""},
+		{"unknown key", "/me/cart", "nope", "", http.StatusUnauthorized, ""},
+		{"customer on a scoped route", "/me/cart", "", customerToken, http.StatusOK, RoleCustomer},
+		{"customer on a route without a scope", "/me/orders", "", customerToken, http.StatusOK, RoleCustomer},
+		{"anonymous catalogue", "/products", "", "", http.StatusOK, "catalogue"},
+		{"catalogue with catalog:read", "/products", "catalog-key", "", http.StatusOK, "catalogue"},
+		{"catalogue without catalog:read", "/products", "cart-key", "", http.StatusForbidden, ""},
+		{"catalogue with an unknown key", "/products", "nope", "", http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e = newScopeServer(t)
+			var req = httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.apiKey != "" {
+				req.Header.Set(APIKeyHeader, tt.apiKey)
+			}
+			if tt.bearer != "" {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+tt.bearer)
+			}
+
+			var rec = httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Fatalf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
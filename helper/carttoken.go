@@ -0,0 +1,49 @@
+package helper
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+)
+
+const CartTokenHeader = "X-Cart-Token"
+
+func cartTokenKey(signKey string) []byte {
+	return []byte(signKey + ":cart")
+}
+
+// GenerateCartToken signs the id of an anonymous cart so a visitor can keep
+// using it without an account.
+func GenerateCartToken(signKey string, cartID int) string {
+	var claims = jwt.MapClaims{}
+	claims["cart_id"] = cartID
+	claims["iat"] = time.Now().Unix()
+	claims["exp"] = time.Now().Add(time.Hour * 24 * 30).Unix()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(cartTokenKey(signKey))
+	if err != nil {
+		logrus.Error("JWT : cannot sign cart token, ", err.Error())
+		return ""
+	}
+
+	return token
+}
+
+func ParseCartToken(signKey string, raw string) (int, error) {
+	var claims = jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		return cartTokenKey(signKey), nil
+	}, jwt.WithValidMethods([]string{"HS256"}))
+	if err != nil {
+		return 0, err
+	}
+
+	id, ok := claims["cart_id"].(float64)
+	if !ok || id <= 0 {
+		return 0, errors.New("invalid cart token")
+	}
+
+	return int(id), nil
+}
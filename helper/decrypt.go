@@ -1,6 +1,10 @@
 package helper
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/subtle"
+
+	"golang.org/x/crypto/bcrypt"
+)
 
 func HashPassword(password string) (string, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -14,3 +18,28 @@ func VerifyPassword(inputPassword, hashedPassword string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(inputPassword))
 	return err == nil
 }
+
+func IsHashedPassword(password string) bool {
+	_, err := bcrypt.Cost([]byte(password))
+	return err == nil
+}
+
+// CheckCredential verifies a login password against the stored one. Accounts
+// created before hashing was introduced still hold plain text; those match by
+// direct comparison and are reported through needsRehash so the caller can
+// replace the stored value with a bcrypt hash.
+func CheckCredential(inputPassword, storedPassword string) (valid bool, needsRehash bool) {
+	if inputPassword == "" || storedPassword == "" {
+		return false, false
+	}
+
+	if IsHashedPassword(storedPassword) {
+		return VerifyPassword(inputPassword, storedPassword), false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(inputPassword), []byte(storedPassword)) == 1 {
+		return true, true
+	}
+
+	return false, false
+}
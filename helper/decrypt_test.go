@@ -0,0 +1,35 @@
+package helper
+
+import "testing"
+
+func TestCheckCredential(t *testing.T) {
+	hashed, err := HashPassword("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name       string
+		input      string
+		stored     string
+		wantValid  bool
+		wantRehash bool
+	}{
+		{"bcrypt match", "s3cret", hashed, true, false},
+		{"bcrypt mismatch", "wrong", hashed, false, false},
+		{"legacy plain text match", "s3cret", "s3cret", true, true},
+		{"legacy plain text mismatch", "wrong", "s3cret", false, false},
+		{"empty input", "", hashed, false, false},
+		{"empty stored password", "s3cret", "", false, false},
+		{"hash used as the password", hashed, hashed, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, rehash := CheckCredential(tt.input, tt.stored)
+			if valid != tt.wantValid || rehash != tt.wantRehash {
+				t.Errorf("CheckCredential = (%v, %v), want (%v, %v)", valid, rehash, tt.wantValid, tt.wantRehash)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package helper
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const earthRadiusKm = 6371.0
+
+var ErrInvalidCoordinates = errors.New("coordinates must be given as lat,lng")
+
+// DistanceKm is the great-circle (haversine) distance between two points.
+func DistanceKm(lat1, lng1, lat2, lng2 float64) float64 {
+	var toRad = func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	var dLat = toRad(lat2 - lat1)
+	var dLng = toRad(lng2 - lng1)
+	var a = math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// ParseLatLng reads a "lat,lng" query value.
+func ParseLatLng(raw string) (float64, float64, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) != 2 {
+		return 0, 0, ErrInvalidCoordinates
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, ErrInvalidCoordinates
+	}
+	lng, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lng < -180 || lng > 180 {
+		return 0, 0, ErrInvalidCoordinates
+	}
+
+	return lat, lng, nil
+}
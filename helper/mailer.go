@@ -0,0 +1,94 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/mail"
+	"net/smtp"
+	"rentcamp/config"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Mailer sends plain-text emails to customers.
+type Mailer interface {
+	Send(to string, subject string, body string) error
+}
+
+// NewMailer returns an SMTP mailer when SMTP_HOST is configured, and a
+// mailer that only logs messages otherwise so development needs no server.
+func NewMailer(cfg config.Config) Mailer {
+	if cfg.SMTPHost == "" {
+		return &LogMailer{}
+	}
+
+	return &SMTPMailer{
+		addr: fmt.Sprintf("%s:%d", cfg.SMTPHost, cfg.SMTPPort),
+		host: cfg.SMTPHost,
+		user: cfg.SMTPUser,
+		pass: cfg.SMTPPassword,
+		from: cfg.MailFrom,
+	}
+}
+
+type SMTPMailer struct {
+	addr string
+	host string
+	user string
+	pass string
+	from string
+}
+
+var ErrInvalidMailHeader = errors.New("mail recipient or subject contains invalid characters")
+
+func (sm *SMTPMailer) Send(to string, subject string, body string) error {
+	var auth smtp.Auth
+	if sm.user != "" {
+		auth = smtp.PlainAuth("", sm.user, sm.pass, sm.host)
+	}
+
+	msg, err := buildMessage(sm.from, to, subject, body)
+	if err != nil {
+		logrus.Error("Mailer : refusing to send mail, ", err.Error())
+		return err
+	}
+
+	if err := smtp.SendMail(sm.addr, auth, sm.from, []string{to}, []byte(msg)); err != nil {
+		logrus.Error("Mailer : cannot send mail to ", to, ", ", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// buildMessage refuses recipients and subjects with line breaks, which
+// would otherwise let a value such as a customer's email address add its
+// own headers or recipients to the message.
+func buildMessage(from string, to string, subject string, body string) (string, error) {
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return "", ErrInvalidMailHeader
+	}
+	addr, err := mail.ParseAddress(to)
+	if err != nil || addr.Address != to {
+		return "", ErrInvalidMailHeader
+	}
+
+	var msg strings.Builder
+	msg.WriteString("From: " + from + "\r\n")
+	msg.WriteString("To: " + to + "\r\n")
+	msg.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n\r\n")
+	msg.WriteString(body)
+
+	return msg.String(), nil
+}
+
+type LogMailer struct{}
+
+func (lm *LogMailer) Send(to string, subject string, body string) error {
+	logrus.Info("Mailer : to=", to, " subject=", subject, "\n", body)
+	return nil
+}
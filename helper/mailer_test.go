@@ -0,0 +1,45 @@
+package helper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildMessage(t *testing.T) {
+	var tests = []struct {
+		name    string
+		to      string
+		subject string
+		wantErr bool
+	}{
+		{"plain message", "budi@example.com", "You left something in your cart", false},
+		{"recipient with an injected header", "budi@example.com\r\nBcc: all@example.com", "Hello", true},
+		{"recipient with a bare newline", "budi@example.com\nBcc: all@example.com", "Hello", true},
+		{"subject with an injected header", "budi@example.com", "Hello\r\nBcc: all@example.com", true},
+		{"recipient list", "budi@example.com, sari@example.com", "Hello", true},
+		{"display name", "Budi <budi@example.com>", "Hello", true},
+		{"not an address", "budi", "Hello", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := buildMessage("shop@example.com", tt.to, tt.subject, "body")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildMessage err = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !strings.HasPrefix(msg, "From: shop@example.com\r\nTo: "+tt.to+"\r\nSubject: "+tt.subject+"\r\n") {
+				t.Fatalf("unexpected message:\n%s", msg)
+			}
+		})
+	}
+}
+
+func TestBuildMessageEncodesSubject(t *testing.T) {
+	msg, err := buildMessage("shop@example.com", "budi@example.com", "Tenda sudah tersedia – ayo sewa", "body")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(msg, "Subject: =?utf-8?q?") {
+		t.Fatalf("non-ASCII subject should be encoded:\n%s", msg)
+	}
+}
@@ -0,0 +1,51 @@
+package helper
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// Notification is a message for a single customer.
+type Notification struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Dispatcher delivers notifications in the background so request handlers
+// never wait on the mail server.
+type Dispatcher struct {
+	mailer Mailer
+	queue  chan Notification
+}
+
+func NewDispatcher(mailer Mailer, size int) *Dispatcher {
+	var d = &Dispatcher{
+		mailer: mailer,
+		queue:  make(chan Notification, size),
+	}
+	go d.loop()
+
+	return d
+}
+
+// Dispatch queues the notification. When the queue is full the
+// notification is dropped and logged rather than blocking the caller.
+func (d *Dispatcher) Dispatch(n Notification) {
+	if n.To == "" {
+		return
+	}
+
+	select {
+	case d.queue <- n:
+	default:
+		logrus.Warn("Notifier : queue full, dropping notification to ", n.To, " (", n.Subject, ")")
+	}
+}
+
+func (d *Dispatcher) loop() {
+	for n := range d.queue {
+		if err := d.mailer.Send(n.To, n.Subject, n.Body); err != nil {
+			logrus.Error("Notifier : cannot deliver notification to ", n.To, ", ", err.Error())
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package helper
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	RoleOwner     = "owner"
+	RoleManager   = "manager"
+	RoleWarehouse = "warehouse"
+	RoleSupport   = "support"
+	RoleCustomer  = "customer"
+	RolePartner   = "partner"
+	RoleDriver    = "driver"
+)
+
+const (
+	PermProductWrite   = "product:write"
+	PermOrderRead      = "order:read"
+	PermOrderHandover  = "order:handover"
+	PermOrderWrite     = "order:write"
+	PermUserRead       = "user:read"
+	PermUserWrite      = "user:write"
+	PermCartManage     = "cart:manage"
+	PermAdminManage    = "admin:manage"
+	PermAPIKeyManage   = "apikey:manage"
+	PermReportRead     = "report:read"
+	PermReviewModerate = "review:moderate"
+	PermQuestionAnswer = "question:answer"
+	PermBranchManage   = "branch:manage"
+	PermInventoryWrite = "inventory:write"
+	PermDeliveryManage = "delivery:manage"
+	PermDeliveryRead   = "delivery:read"
+)
+
+var rolePermissions = map[string][]string{
+	RoleOwner: {
+		PermProductWrite, PermOrderRead, PermOrderHandover, PermOrderWrite,
+		PermUserRead, PermUserWrite, PermCartManage, PermAdminManage,
+		PermAPIKeyManage, PermReportRead, PermReviewModerate,
+		PermQuestionAnswer, PermBranchManage, PermInventoryWrite,
+		PermDeliveryManage, PermDeliveryRead,
+	},
+	RoleManager: {
+		PermProductWrite, PermOrderRead, PermOrderHandover, PermOrderWrite,
+		PermUserRead, PermUserWrite, PermCartManage, PermAPIKeyManage,
+		PermReportRead, PermReviewModerate,
+		PermQuestionAnswer, PermBranchManage, PermInventoryWrite,
+		PermDeliveryManage, PermDeliveryRead,
+	},
+	RoleWarehouse: {
+		PermOrderRead, PermOrderHandover, PermInventoryWrite,
+		PermDeliveryRead,
+	},
+	RoleSupport: {
+		PermOrderRead, PermUserRead, PermCartManage, PermReviewModerate,
+		PermQuestionAnswer,
+	},
+	RoleDriver: {
+		PermOrderHandover, PermDeliveryRead,
+	},
+	RoleCustomer: {},
+	RolePartner:  {},
+}
+
+// NormalizeRole maps role names issued before staff roles existed onto the
+// current ones, so tokens signed with "admin" or "user" keep working.
+func NormalizeRole(role string) string {
+	switch role {
+	case "admin":
+		return RoleOwner
+	case "user":
+		return RoleCustomer
+	}
+	return role
+}
+
+func IsStaffRole(role string) bool {
+	role = NormalizeRole(role)
+	_, found := rolePermissions[role]
+	return found && role != RoleCustomer && role != RolePartner
+}
+
+func HasPermission(role string, permission string) bool {
+	for _, p := range rolePermissions[NormalizeRole(role)] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func TokenClaims(c echo.Context) (jwt.MapClaims, bool) {
+	token, ok := c.Get("user").(*jwt.Token)
+	if !ok || token == nil {
+		return nil, false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	return claims, ok
+}
+
+func TokenUser(c echo.Context) (id int, role string, ok bool) {
+	claims, ok := TokenClaims(c)
+	if !ok {
+		return 0, "", false
+	}
+	fid, idOk := claims["id"].(float64)
+	role, roleOk := claims["role"].(string)
+	if !idOk || !roleOk {
+		return 0, "", false
+	}
+	return int(fid), NormalizeRole(role), true
+}
+
+func RequirePermission(permissions ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			_, role, ok := TokenUser(c)
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, FormatResponse("Invalid or missing token", nil))
+			}
+			for _, p := range permissions {
+				if !HasPermission(role, p) {
+					return c.JSON(http.StatusForbidden, FormatResponse("You don't have permission", nil))
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+func RequireStaff() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			_, role, ok := TokenUser(c)
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, FormatResponse("Invalid or missing token", nil))
+			}
+			if !IsStaffRole(role) {
+				return c.JSON(http.StatusForbidden, FormatResponse("You don't have permission", nil))
+			}
+			return next(c)
+		}
+	}
+}
+
+// RequireSelfOrPermission lets a caller through when the path parameter
+// matches their own id, otherwise the listed permissions are required.
+func RequireSelfOrPermission(param string, permissions ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			id, role, ok := TokenUser(c)
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, FormatResponse("Invalid or missing token", nil))
+			}
+			target, err := strconv.Atoi(c.Param(param))
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, FormatResponse("Invalid id", nil))
+			}
+			if role == RoleCustomer && id == target {
+				return next(c)
+			}
+			for _, p := range permissions {
+				if !HasPermission(role, p) {
+					return c.JSON(http.StatusForbidden, FormatResponse("Permission denied. You don't have the required permissions.", nil))
+				}
+			}
+			return next(c)
+		}
+	}
+}
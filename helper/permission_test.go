@@ -0,0 +1,130 @@
+package helper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// newTokenContext returns a context carrying the token the JWT middleware
+// would have set for the given account.
+func newTokenContext(id int, role string) (echo.Context, *httptest.ResponseRecorder) {
+	var e = echo.New()
+	var rec = httptest.NewRecorder()
+	var c = e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	if role != "" {
+		c.Set("user", &jwt.Token{Claims: jwt.MapClaims{"id": float64(id), "role": role}})
+	}
+	return c, rec
+}
+
+func okHandler(c echo.Context) error {
+	return c.NoContent(http.StatusOK)
+}
+
+func TestHasPermission(t *testing.T) {
+	var tests = []struct {
+		role       string
+		permission string
+		want       bool
+	}{
+		{RoleOwner, PermAdminManage, true},
+		{RoleManager, PermAdminManage, false},
+		{RoleManager, PermProductWrite, true},
+		{RoleWarehouse, PermOrderHandover, true},
+		{RoleWarehouse, PermProductWrite, false},
+		{RoleSupport, PermUserRead, true},
+		{RoleSupport, PermUserWrite, false},
+		{RoleCustomer, PermOrderRead, false},
+		{RolePartner, PermOrderRead, false},
+		{"admin", PermAdminManage, true},
+		{"unknown", PermOrderRead, false},
+	}
+
+	for _, tt := range tests {
+		if got := HasPermission(tt.role, tt.permission); got != tt.want {
+			t.Errorf("HasPermission(%q, %q) = %v, want %v", tt.role, tt.permission, got, tt.want)
+		}
+	}
+}
+
+func TestIsStaffRole(t *testing.T) {
+	var tests = map[string]bool{
+		RoleOwner:     true,
+		RoleManager:   true,
+		RoleWarehouse: true,
+		RoleSupport:   true,
+		RoleDriver:    true,
+		"admin":       true,
+		RoleCustomer:  false,
+		"user":        false,
+		RolePartner:   false,
+		"unknown":     false,
+	}
+
+	for role, want := range tests {
+		if got := IsStaffRole(role); got != want {
+			t.Errorf("IsStaffRole(%q) = %v, want %v", role, got, want)
+		}
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	var tests = []struct {
+		name       string
+		role       string
+		perms      []string
+		wantStatus int
+	}{
+		{"no token", "", []string{PermProductWrite}, http.StatusUnauthorized},
+		{"role has the permission", RoleManager, []string{PermProductWrite}, http.StatusOK},
+		{"role lacks the permission", RoleSupport, []string{PermProductWrite}, http.StatusForbidden},
+		{"every permission is required", RoleWarehouse, []string{PermOrderRead, PermOrderWrite}, http.StatusForbidden},
+		{"customer", RoleCustomer, []string{PermOrderRead}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c, rec = newTokenContext(1, tt.role)
+			if err := RequirePermission(tt.perms...)(okHandler)(c); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRequireSelfOrPermission(t *testing.T) {
+	var tests = []struct {
+		name       string
+		id         int
+		role       string
+		param      string
+		wantStatus int
+	}{
+		{"customer reading themselves", 5, RoleCustomer, "5", http.StatusOK},
+		{"customer reading someone else", 5, RoleCustomer, "6", http.StatusForbidden},
+		{"staff with the permission", 5, RoleSupport, "6", http.StatusOK},
+		{"staff id matching is not enough", 6, RoleWarehouse, "6", http.StatusForbidden},
+		{"invalid id", 5, RoleCustomer, "abc", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c, rec = newTokenContext(tt.id, tt.role)
+			c.SetParamNames("id")
+			c.SetParamValues(tt.param)
+			if err := RequireSelfOrPermission("id", PermUserRead)(okHandler)(c); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
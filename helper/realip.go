@@ -0,0 +1,28 @@
+package helper
+
+import (
+	"rentcamp/config"
+
+	"github.com/labstack/echo/v4"
+)
+
+// IPExtractor decides where c.RealIP() takes the client address from. By
+// default it is the address of the connection, and X-Forwarded-For and
+// X-Real-IP are ignored so clients cannot choose the IP the login throttle
+// counts against. Requests arriving through one of the trusted proxies use
+// the last address in X-Forwarded-For that is not a trusted proxy.
+func IPExtractor(cfg config.Config) echo.IPExtractor {
+	if len(cfg.TrustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	var options = []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, ipRange := range cfg.TrustedProxies {
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
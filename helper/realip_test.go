@@ -0,0 +1,47 @@
+package helper
+
+import (
+	"net"
+	"net/http/httptest"
+	"rentcamp/config"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestIPExtractor(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+
+	var tests = []struct {
+		name       string
+		trusted    bool
+		remoteAddr string
+		header     string
+		want       string
+	}{
+		{"no proxy ignores the header", false, "203.0.113.7:4000", "198.51.100.1", "203.0.113.7"},
+		{"trusted proxy forwards the client", true, "10.0.0.2:4000", "198.51.100.1, 10.0.0.3", "198.51.100.1"},
+		{"untrusted peer cannot forward", true, "203.0.113.7:4000", "198.51.100.1", "203.0.113.7"},
+		{"spoofed entries before the client are skipped", true, "10.0.0.2:4000", "192.0.2.9, 198.51.100.1", "198.51.100.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg = config.Config{}
+			if tt.trusted {
+				cfg.TrustedProxies = []*net.IPNet{proxies}
+			}
+			var e = echo.New()
+			e.IPExtractor = IPExtractor(cfg)
+
+			var req = httptest.NewRequest("POST", "/login", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set(echo.HeaderXForwardedFor, tt.header)
+			req.Header.Set(echo.HeaderXRealIP, tt.header)
+
+			if got := e.NewContext(req, httptest.NewRecorder()).RealIP(); got != tt.want {
+				t.Fatalf("RealIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
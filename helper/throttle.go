@@ -0,0 +1,192 @@
+package helper
+
+import (
+	"math"
+	"net/http"
+	"rentcamp/config"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// maxRecords bounds how many accounts and IPs the throttle remembers, and
+// maxRecordIPs how many addresses one record keeps for Unlock.
+const (
+	maxRecords   = 10000
+	maxRecordIPs = 50
+)
+
+type loginRecord struct {
+	failures    int
+	lastFailure time.Time
+	nextAllowed time.Time
+	lockedUntil time.Time
+	// ips are the addresses the failures came from, so unlocking an
+	// account can clear them too.
+	ips map[string]bool
+}
+
+// LoginThrottle keeps failed login attempts per account and per client IP.
+// Every failure doubles the wait before the next attempt, and reaching the
+// configured limit locks the key for the lockout period.
+type LoginThrottle struct {
+	mu            sync.Mutex
+	records       map[string]*loginRecord
+	maxAttempts   int
+	maxIPAttempts int
+	backoff       time.Duration
+	lockout       time.Duration
+}
+
+func NewLoginThrottle(cfg config.Config) *LoginThrottle {
+	return &LoginThrottle{
+		records:       map[string]*loginRecord{},
+		maxAttempts:   cfg.LoginMaxAttempts,
+		maxIPAttempts: cfg.LoginMaxIPAttempts,
+		backoff:       time.Duration(cfg.LoginBackoffSeconds) * time.Second,
+		lockout:       time.Duration(cfg.LoginLockoutMinutes) * time.Minute,
+	}
+}
+
+func AccountKey(kind string, username string) string {
+	return kind + ":" + strings.ToLower(username)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check returns how long the caller has to wait before the next attempt for
+// this account and IP is accepted. Zero means the attempt may proceed.
+func (t *LoginThrottle) Check(account string, ip string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var now = time.Now()
+	var wait time.Duration
+	for _, key := range []string{account, ipKey(ip)} {
+		var rec = t.current(key, now)
+		if rec == nil {
+			continue
+		}
+		for _, until := range []time.Time{rec.lockedUntil, rec.nextAllowed} {
+			if d := until.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+
+	return wait
+}
+
+func (t *LoginThrottle) Failure(account string, ip string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var now = time.Now()
+	t.fail(account, t.maxAttempts, now, ip)
+	t.fail(ipKey(ip), t.maxIPAttempts, now, ip)
+
+	if len(t.records) > maxRecords {
+		t.prune(now)
+	}
+}
+
+func (t *LoginThrottle) Success(account string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.records, account)
+}
+
+// Unlock clears the account and the IPs it failed from, so the user can
+// log in again from the same address.
+func (t *LoginThrottle) Unlock(account string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var rec, found = t.records[account]
+	delete(t.records, account)
+	if found {
+		for ip := range rec.ips {
+			delete(t.records, ipKey(ip))
+		}
+		logrus.Info("Login : account unlocked by admin, ", account)
+	}
+
+	return found
+}
+
+func (t *LoginThrottle) current(key string, now time.Time) *loginRecord {
+	var rec = t.records[key]
+	if rec == nil {
+		return nil
+	}
+	if now.Sub(rec.lastFailure) > t.lockout && now.After(rec.lockedUntil) {
+		delete(t.records, key)
+		return nil
+	}
+	return rec
+}
+
+func (t *LoginThrottle) fail(key string, limit int, now time.Time, ip string) {
+	var rec = t.current(key, now)
+	if rec == nil {
+		rec = &loginRecord{ips: map[string]bool{}}
+		t.records[key] = rec
+	}
+
+	rec.failures++
+	if len(rec.ips) < maxRecordIPs {
+		rec.ips[ip] = true
+	}
+	rec.lastFailure = now
+
+	var delay = t.lockout
+	if rec.failures <= 30 {
+		if d := t.backoff << (rec.failures - 1); d > 0 && d < t.lockout {
+			delay = d
+		}
+	}
+	rec.nextAllowed = now.Add(delay)
+
+	if limit > 0 && rec.failures >= limit {
+		rec.lockedUntil = now.Add(t.lockout)
+		rec.failures = 0
+		logrus.Warn("Login : ", key, " locked until ", rec.lockedUntil.Format(time.RFC3339), " after repeated failures, last ip ", ip)
+	}
+}
+
+// prune drops expired records. When failures keep coming from many
+// accounts or addresses within the lockout period that is not enough, so
+// the records idle the longest are dropped until a tenth of the room is
+// free again.
+func (t *LoginThrottle) prune(now time.Time) {
+	for key := range t.records {
+		t.current(key, now)
+	}
+	if len(t.records) <= maxRecords {
+		return
+	}
+
+	var keys = make([]string, 0, len(t.records))
+	for key := range t.records {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return t.records[keys[i]].lastFailure.Before(t.records[keys[j]].lastFailure)
+	})
+	for _, key := range keys[:len(keys)-maxRecords*9/10] {
+		delete(t.records, key)
+	}
+}
+
+func TooManyAttempts(c echo.Context, wait time.Duration) error {
+	c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	return c.JSON(http.StatusTooManyRequests, FormatResponse("Too many failed login attempts, try again later", nil))
+}
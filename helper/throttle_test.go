@@ -0,0 +1,140 @@
+package helper
+
+import (
+	"rentcamp/config"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newTestThrottle() *LoginThrottle {
+	return NewLoginThrottle(config.Config{
+		LoginMaxAttempts:    3,
+		LoginMaxIPAttempts:  5,
+		LoginBackoffSeconds: 1,
+		LoginLockoutMinutes: 15,
+	})
+}
+
+func TestLoginThrottleBackoff(t *testing.T) {
+	var throttle = newTestThrottle()
+	var account = AccountKey("customer", "Budi")
+
+	if wait := throttle.Check(account, "10.0.0.1"); wait != 0 {
+		t.Fatalf("first attempt should not wait, got %v", wait)
+	}
+
+	throttle.Failure(account, "10.0.0.1")
+	var first = throttle.Check(account, "10.0.0.1")
+	throttle.Failure(account, "10.0.0.1")
+	var second = throttle.Check(account, "10.0.0.1")
+
+	if first <= 0 || first > time.Second {
+		t.Fatalf("first failure should wait up to the backoff, got %v", first)
+	}
+	if second <= first {
+		t.Fatalf("second failure should wait longer than the first, got %v then %v", first, second)
+	}
+}
+
+func TestLoginThrottleLockout(t *testing.T) {
+	var throttle = newTestThrottle()
+	var account = AccountKey("customer", "budi")
+
+	for i := 0; i < 3; i++ {
+		throttle.Failure(account, "10.0.0.1")
+	}
+
+	if wait := throttle.Check(account, "10.0.0.2"); wait < 14*time.Minute {
+		t.Fatalf("account should be locked from any IP, got %v", wait)
+	}
+	if wait := throttle.Check(AccountKey("customer", "other"), "10.0.0.3"); wait != 0 {
+		t.Fatalf("other accounts on other IPs should not wait, got %v", wait)
+	}
+}
+
+func TestLoginThrottleIPLimit(t *testing.T) {
+	var throttle = newTestThrottle()
+
+	for i := 0; i < 5; i++ {
+		throttle.Failure(AccountKey("customer", string(rune('a'+i))), "10.0.0.9")
+	}
+
+	if wait := throttle.Check(AccountKey("customer", "fresh"), "10.0.0.9"); wait < 14*time.Minute {
+		t.Fatalf("IP should be locked after spraying accounts, got %v", wait)
+	}
+}
+
+func TestLoginThrottleSuccessClearsAccount(t *testing.T) {
+	var throttle = newTestThrottle()
+	var account = AccountKey("admin", "owner")
+
+	throttle.Failure(account, "10.0.0.1")
+	throttle.Success(account)
+
+	if wait := throttle.Check(account, "10.0.0.2"); wait != 0 {
+		t.Fatalf("success should clear the account, got %v", wait)
+	}
+}
+
+func TestLoginThrottleUnlockClearsAccountAndIP(t *testing.T) {
+	var throttle = newTestThrottle()
+	var account = AccountKey("customer", "budi")
+
+	for i := 0; i < 5; i++ {
+		throttle.Failure(account, "10.0.0.1")
+	}
+	if wait := throttle.Check(account, "10.0.0.1"); wait == 0 {
+		t.Fatal("account should be locked before unlocking")
+	}
+
+	if !throttle.Unlock(account) {
+		t.Fatal("Unlock should report the account was locked")
+	}
+	if wait := throttle.Check(account, "10.0.0.1"); wait != 0 {
+		t.Fatalf("unlocked account should log in from the same IP, got %v", wait)
+	}
+	if throttle.Unlock(account) {
+		t.Fatal("second Unlock should find nothing")
+	}
+}
+
+func TestLoginThrottleBoundsRecords(t *testing.T) {
+	var throttle = newTestThrottle()
+	var start = time.Now().Add(-time.Minute)
+
+	for i := 0; i < maxRecords; i++ {
+		throttle.records[AccountKey("customer", strconv.Itoa(i))] = &loginRecord{
+			failures:    1,
+			lastFailure: start.Add(time.Duration(i) * time.Millisecond),
+			ips:         map[string]bool{},
+		}
+	}
+	var account = AccountKey("customer", "budi")
+	throttle.Failure(account, "10.0.0.1")
+
+	if len(throttle.records) != maxRecords*9/10 {
+		t.Fatalf("throttle keeps %d records, want %d", len(throttle.records), maxRecords*9/10)
+	}
+	if _, found := throttle.records[AccountKey("customer", "0")]; found {
+		t.Fatal("the record idle the longest should be dropped")
+	}
+	if _, found := throttle.records[AccountKey("customer", strconv.Itoa(maxRecords-1))]; !found {
+		t.Fatal("the most recent record should be kept")
+	}
+	if wait := throttle.Check(account, "10.0.0.1"); wait == 0 {
+		t.Fatal("the failure that triggered pruning should still count")
+	}
+}
+
+func TestLoginThrottleBoundsIPsPerRecord(t *testing.T) {
+	var throttle = NewLoginThrottle(config.Config{LoginBackoffSeconds: 1, LoginLockoutMinutes: 15})
+	var account = AccountKey("customer", "budi")
+
+	for i := 0; i < maxRecordIPs+10; i++ {
+		throttle.Failure(account, "10.0.1."+strconv.Itoa(i))
+	}
+	if got := len(throttle.records[account].ips); got != maxRecordIPs {
+		t.Fatalf("record keeps %d addresses, want %d", got, maxRecordIPs)
+	}
+}
@@ -0,0 +1,93 @@
+package helper
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the RFC 6238 SHA1 test key "12345678901234567890".
+var rfcSecret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeMatchesRFC6238(t *testing.T) {
+	var tests = []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := TOTPCode(rfcSecret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	var now = time.Unix(1234567890, 0)
+	var step = now.Unix() / totpPeriod
+	var current, _ = TOTPCode(rfcSecret, now)
+	var previous, _ = TOTPCode(rfcSecret, now.Add(-totpPeriod*time.Second))
+	var tooOld, _ = TOTPCode(rfcSecret, now.Add(-2*totpPeriod*time.Second))
+
+	var tests = []struct {
+		name        string
+		code        string
+		lastCounter int64
+		wantStep    int64
+		wantOK      bool
+	}{
+		{"current code", current, 0, step, true},
+		{"code with spaces", current[:3] + " " + current[3:], 0, step, true},
+		{"previous step within skew", previous, 0, step - 1, true},
+		{"outside skew", tooOld, 0, 0, false},
+		{"wrong length", current[:5], 0, 0, false},
+		{"replayed code", current, step, 0, false},
+		{"older step after a newer one was used", previous, step, 0, false},
+		{"after an older step was used", current, step - 1, step, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, gotOK := VerifyTOTP(rfcSecret, tt.code, now, tt.lastCounter)
+			if gotOK != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("VerifyTOTP = (%d, %v), want (%d, %v)", gotStep, gotOK, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestVerifyTOTPRejectsBadSecret(t *testing.T) {
+	if _, ok := VerifyTOTP("not base32!", "123456", time.Now(), 0); ok {
+		t.Fatal("an invalid secret must not verify")
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seen = map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("recovery code %q is not formatted as xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("recovery code %q repeated", code)
+		}
+		seen[code] = true
+	}
+	if len(codes) != 10 {
+		t.Fatalf("got %d codes, want 10", len(codes))
+	}
+}
@@ -0,0 +1,46 @@
+package helper
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+)
+
+func unsubscribeKey(signKey string) []byte {
+	return []byte(signKey + ":unsubscribe")
+}
+
+// GenerateUnsubscribeToken signs the link placed in marketing emails so a
+// customer can opt out without logging in.
+func GenerateUnsubscribeToken(signKey string, userID int) string {
+	var claims = jwt.MapClaims{}
+	claims["id"] = userID
+	claims["iat"] = time.Now().Unix()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(unsubscribeKey(signKey))
+	if err != nil {
+		logrus.Error("JWT : cannot sign unsubscribe token, ", err.Error())
+		return ""
+	}
+
+	return token
+}
+
+func ParseUnsubscribeToken(signKey string, raw string) (int, error) {
+	var claims = jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		return unsubscribeKey(signKey), nil
+	}, jwt.WithValidMethods([]string{"HS256"}))
+	if err != nil {
+		return 0, err
+	}
+
+	id, ok := claims["id"].(float64)
+	if !ok || id <= 0 {
+		return 0, errors.New("invalid unsubscribe token")
+	}
+
+	return int(id), nil
+}
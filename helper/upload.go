@@ -0,0 +1,76 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"rentcamp/config"
+
+	"github.com/cloudinary/cloudinary-go"
+	"github.com/cloudinary/cloudinary-go/api/uploader"
+)
+
+// MaxImageSize is the largest image accepted for upload, in bytes.
+const MaxImageSize = 5 << 20
+
+var ErrInvalidImage = errors.New("images must be JPEG, PNG or WebP files of at most 5 MB")
+
+var imageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// CheckImage refuses files that are too large or whose content is not a
+// supported image, whatever their name or declared type says.
+func CheckImage(file *multipart.FileHeader) error {
+	if file.Size <= 0 || file.Size > MaxImageSize {
+		return ErrInvalidImage
+	}
+
+	fileReader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer fileReader.Close()
+
+	var head = make([]byte, 512)
+	n, err := io.ReadFull(fileReader, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrInvalidImage
+	}
+	if !imageTypes[http.DetectContentType(head[:n])] {
+		return ErrInvalidImage
+	}
+
+	return nil
+}
+
+// UploadImage stores an uploaded image on the CDN and returns its public URL.
+func UploadImage(cfg config.Config, file *multipart.FileHeader) (string, error) {
+	if err := CheckImage(file); err != nil {
+		return "", err
+	}
+
+	cld, err := cloudinary.NewFromParams(cfg.CDN_Cloud_Name, cfg.CDN_API_Key, cfg.CDN_API_Secret)
+	if err != nil {
+		return "", err
+	}
+
+	fileReader, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer fileReader.Close()
+
+	result, err := cld.Upload.Upload(context.TODO(), fileReader, uploader.UploadParams{
+		Folder: cfg.CDN_Folder_Name,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return result.SecureURL, nil
+}
@@ -0,0 +1,54 @@
+package helper
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http/httptest"
+	"testing"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// newFileHeader parses content back from a multipart form, as Echo would.
+func newFileHeader(t *testing.T, content []byte) *multipart.FileHeader {
+	t.Helper()
+	var body = &bytes.Buffer{}
+	var writer = multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("image", "photo.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	writer.Close()
+
+	var req = httptest.NewRequest("POST", "/", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	_, file, err := req.FormFile("image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestCheckImage(t *testing.T) {
+	var tests = []struct {
+		name    string
+		content []byte
+		wantErr bool
+	}{
+		{"png", pngHeader, false},
+		{"jpeg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF"), false},
+		{"html named as png", []byte("<html><script>alert(1)</script></html>"), true},
+		{"empty file", []byte{}, true},
+		{"too large", append(pngHeader, make([]byte, MaxImageSize)...), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err = CheckImage(newFileHeader(t, tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckImage error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
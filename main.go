@@ -2,10 +2,15 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"rentcamp/config"
 	"rentcamp/controller"
+	"rentcamp/helper"
 	"rentcamp/model"
 	route "rentcamp/routes"
+	"rentcamp/search"
+	"rentcamp/worker"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -16,18 +21,84 @@ func main() {
 	var config = config.InitConfig()
 
 	db := model.InitModel(*config)
-	model.Migrate(db)
+	model.Migrate(db, *config)
 
 	adminModel := model.NewAdminsModel(db)
 	ProductModel := model.NewProductsModel(db)
 	userModel := model.NewUsersModel(db)
 	cartModel := model.NewCartModel(db)
+	settingModel := model.NewSettingsModel(db)
+	apiKeyModel := model.NewApiKeysModel(db)
+	auditModel := model.NewAuditModel(db)
+	orderModel := model.NewOrdersModel(db)
+	wishlistModel := model.NewWishlistModel(db)
+	waitlistModel := model.NewWaitlistModel(db)
+	reviewModel := model.NewReviewModel(db)
+	questionModel := model.NewQuestionModel(db)
+	searchLogModel := model.NewSearchLogModel(db)
+	recommendationModel := model.NewRecommendationModel(db)
+	tripModel := model.NewTripModel(db)
+	branchModel := model.NewBranchModel(db)
+	transferModel := model.NewTransferModel(db)
+	deliveryModel := model.NewDeliveryModel(db)
 
-	adminController := controller.NewAdminControlInterface(adminModel)
-	ProductController := controller.NewProductControllerInterface(ProductModel, *config)
-	userController := controller.NewUserControlInterface(userModel)
-	cartController := controller.NewCartControllerInterface(cartModel)
+	if len(os.Args) > 1 && os.Args[1] == "create-owner" {
+		runCreateOwner(adminModel, os.Args[2:])
+		return
+	}
+	bootstrapOwner(adminModel, *config)
+	helper.UseAccountChecker(adminModel)
+	helper.UseAPIKeyResolver(apiKeyModel)
 
+	loginThrottle := helper.NewLoginThrottle(*config)
+
+	ProductModel.UseSearch(search.New(config.SearchEngine, db))
+
+	suggester := search.NewSuggester()
+	suggestIndexer := worker.NewSuggestIndexer(ProductModel, searchLogModel, suggester, *config)
+	suggestIndexer.Run()
+	ProductModel.Listen(suggestIndexer.ProductChanged)
+	go suggestIndexer.Watch()
+
+	mailer := helper.NewMailer(*config)
+	dispatcher := helper.NewDispatcher(mailer, 100)
+	waitlistOfferer := worker.NewWaitlistOfferer(waitlistModel, dispatcher, *config)
+
+	if config.HoldSweepSeconds > 0 {
+		worker.Every(time.Duration(config.HoldSweepSeconds)*time.Second, "hold sweeper", waitlistOfferer.ReleaseHolds(orderModel.ReleaseExpiredHolds))
+		worker.Every(time.Duration(config.HoldSweepSeconds)*time.Second, "waitlist offers", waitlistOfferer.Run)
+	}
+	worker.Every(10*time.Minute, "suggestion index", suggestIndexer.Run)
+	if config.SearchLogRetentionDays > 0 {
+		worker.Every(24*time.Hour, "search log retention", suggestIndexer.PruneLog)
+	}
+	if config.CartJobMinutes > 0 {
+		worker.Every(time.Duration(config.CartJobMinutes)*time.Minute, "abandoned cart reminder", worker.NewCartReminder(cartModel, mailer, *config).Run)
+	}
+	affinityBuilder := worker.NewAffinityBuilder(recommendationModel)
+	go affinityBuilder.Run()
+	if config.AffinityJobMinutes > 0 {
+		worker.Every(time.Duration(config.AffinityJobMinutes)*time.Minute, "product affinity", affinityBuilder.Run)
+	}
+	if config.WishlistJobMinutes > 0 {
+		worker.Every(time.Duration(config.WishlistJobMinutes)*time.Minute, "wishlist notifier", worker.NewWishlistNotifier(wishlistModel, mailer, *config).Run)
+	}
+
+	adminController := controller.NewAdminControlInterface(adminModel, settingModel, *config, loginThrottle)
+	ProductController := controller.NewProductControllerInterface(ProductModel, waitlistModel, searchLogModel, suggester, transferModel, *config)
+	userController := controller.NewUserControlInterface(userModel, cartModel, *config, loginThrottle, helper.NewOIDCProvider(*config))
+	cartController := controller.NewCartControllerInterface(cartModel, auditModel, *config)
+	apiKeyController := controller.NewApiKeyControllerInterface(apiKeyModel)
+	orderController := controller.NewOrderControllerInterface(orderModel, cartModel, waitlistOfferer, *config)
+	wishlistController := controller.NewWishlistControllerInterface(wishlistModel, cartModel)
+	reviewController := controller.NewReviewControllerInterface(reviewModel, *config)
+	questionController := controller.NewQuestionControllerInterface(questionModel, userModel, dispatcher, *config)
+	recommendationController := controller.NewRecommendationControllerInterface(recommendationModel)
+	tripController := controller.NewTripControllerInterface(tripModel, cartModel)
+	branchController := controller.NewBranchControllerInterface(branchModel)
+	deliveryController := controller.NewDeliveryControllerInterface(deliveryModel)
+
+	e.IPExtractor = helper.IPExtractor(*config)
 	e.Pre(middleware.RemoveTrailingSlash())
 
 	e.Use(middleware.CORS())
@@ -40,6 +111,15 @@ func main() {
 	route.RouteProduct(e, ProductController, *config)
 	route.RouteUser(e, userController, *config)
 	route.RouteCart(e, cartController, *config)
+	route.RouteApiKey(e, apiKeyController, *config)
+	route.RouteOrder(e, orderController, *config)
+	route.RouteWishlist(e, wishlistController, *config)
+	route.RouteReview(e, reviewController, *config)
+	route.RouteQuestion(e, questionController, *config)
+	route.RouteRecommendation(e, recommendationController, *config)
+	route.RouteTrip(e, tripController, *config)
+	route.RouteBranch(e, branchController, *config)
+	route.RouteDelivery(e, deliveryController, *config)
 
 	e.Logger.Fatal(e.Start(fmt.Sprintf(":%d", config.ServerPort)).Error())
 }
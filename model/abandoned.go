@@ -0,0 +1,114 @@
+package model
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// AbandonedCart is an active cart with items that nobody touched for a
+// while, together with the contact details needed to send a reminder.
+type AbandonedCart struct {
+	CartID         int       `json:"cart_id"`
+	UserID         int       `json:"user_id"`
+	Name           string    `json:"name"`
+	Email          string    `json:"email"`
+	LastActivityAt time.Time `json:"last_activity_at"`
+	Items          int       `json:"items"`
+}
+
+type AbandonmentReport struct {
+	From            time.Time `json:"from"`
+	To              time.Time `json:"to"`
+	Carts           int64     `json:"carts"`
+	Ordered         int64     `json:"ordered"`
+	Abandoned       int64     `json:"abandoned"`
+	Reminded        int64     `json:"reminded"`
+	Recovered       int64     `json:"recovered"`
+	AbandonmentRate float64   `json:"abandonment_rate"`
+	RecoveryRate    float64   `json:"recovery_rate"`
+}
+
+const cartHasItems = "EXISTS (SELECT 1 FROM cart_items WHERE cart_items.cart_id = carts.id AND cart_items.deleted_at IS NULL)"
+
+// FindAbandonedCarts returns the active carts inactive since before
+// inactiveSince that were not reminded yet, skipping customers who opted
+// out of emails.
+func (cm *CartModel) FindAbandonedCarts(inactiveSince time.Time) []AbandonedCart {
+	var res = []AbandonedCart{}
+	if err := cm.db.Model(&Cart{}).
+		Select("carts.id AS cart_id, carts.user_id, users.name, users.email, carts.last_activity_at, "+
+			"(SELECT COUNT(*) FROM cart_items WHERE cart_items.cart_id = carts.id AND cart_items.deleted_at IS NULL) AS items").
+		Joins("JOIN users ON users.id = carts.user_id AND users.deleted_at IS NULL").
+		Where("carts.status = ? AND carts.reminder_sent_at IS NULL AND carts.last_activity_at < ?", CartStatusActive, inactiveSince).
+		Where("users.email_opt_out = ? AND users.email <> ''", false).
+		Where(cartHasItems).
+		Scan(&res).Error; err != nil {
+		logrus.Error("Cart Model: Error finding abandoned carts, ", err.Error())
+		return nil
+	}
+
+	return res
+}
+
+func (cm *CartModel) MarkReminderSent(cartID int) bool {
+	if err := cm.db.Model(&Cart{}).Where("id = ?", cartID).UpdateColumn("reminder_sent_at", time.Now()).Error; err != nil {
+		logrus.Error("Cart Model: Error marking reminder sent, ", err.Error())
+		return false
+	}
+	return true
+}
+
+// ArchiveStaleCarts archives active and guest carts untouched since before
+// inactiveSince. Their owners get a fresh cart on their next visit.
+func (cm *CartModel) ArchiveStaleCarts(inactiveSince time.Time) int64 {
+	var qry = cm.db.Model(&Cart{}).
+		Where("status IN ? AND last_activity_at < ?", []string{CartStatusActive, CartStatusGuest}, inactiveSince).
+		Updates(map[string]any{"status": CartStatusArchived, "active_user_id": nil})
+	if qry.Error != nil {
+		logrus.Error("Cart Model: Error archiving stale carts, ", qry.Error.Error())
+		return 0
+	}
+	return qry.RowsAffected
+}
+
+// AbandonmentReport summarises the customer carts with items created in
+// [from, to). A cart counts as abandoned when it was not ordered and has
+// been inactive since before inactiveSince.
+func (cm *CartModel) AbandonmentReport(from time.Time, to time.Time, inactiveSince time.Time) (*AbandonmentReport, error) {
+	var res = AbandonmentReport{From: from, To: to}
+
+	var base = func() *gorm.DB {
+		return cm.db.Model(&Cart{}).
+			Where("carts.created_at >= ? AND carts.created_at < ?", from, to).
+			Where("carts.status <> ? AND carts.user_id <> 0", CartStatusMerged)
+	}
+
+	var counts = []struct {
+		target *int64
+		where  string
+		args   []any
+	}{
+		{&res.Carts, cartHasItems, nil},
+		{&res.Ordered, "carts.status = ?", []any{CartStatusOrdered}},
+		{&res.Abandoned, cartHasItems + " AND carts.status <> ? AND carts.last_activity_at < ?", []any{CartStatusOrdered, inactiveSince}},
+		{&res.Reminded, "carts.reminder_sent_at IS NOT NULL", nil},
+		{&res.Recovered, "carts.reminder_sent_at IS NOT NULL AND carts.status = ?", []any{CartStatusOrdered}},
+	}
+	for _, count := range counts {
+		if err := base().Where(count.where, count.args...).Count(count.target).Error; err != nil {
+			logrus.Error("Cart Model: Error building abandonment report, ", err.Error())
+			return nil, err
+		}
+	}
+
+	if res.Carts > 0 {
+		res.AbandonmentRate = float64(res.Abandoned) / float64(res.Carts)
+	}
+	if res.Reminded > 0 {
+		res.RecoveryRate = float64(res.Recovered) / float64(res.Reminded)
+	}
+
+	return &res, nil
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewCartsRecordActivity(t *testing.T) {
+	var db = newTestDB(t)
+	var carts = NewCartModel(db)
+
+	cart, _, err := carts.CreateCart(Cart{UserID: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	guest, err := carts.CreateGuestCart()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []int{cart.ID, guest.ID} {
+		var stored = Cart{}
+		db.Where("id = ?", id).First(&stored)
+		if time.Since(stored.LastActivityAt) > time.Minute {
+			t.Fatalf("cart %d last activity = %v, want now", id, stored.LastActivityAt)
+		}
+	}
+
+	if archived := carts.ArchiveStaleCarts(time.Now().Add(-time.Hour)); archived != 0 {
+		t.Fatalf("fresh carts must not be archived, archived %d", archived)
+	}
+}
+
+func TestFindAbandonedCarts(t *testing.T) {
+	var db = newTestDB(t)
+	var carts = NewCartModel(db)
+	var product = newTestProduct(t, db, 5, 100)
+
+	var customers = []User{
+		{Name: "Budi", Username: "budi", Password: "x", Email: "budi@example.com", Gender: "m"},
+		{Name: "Sari", Username: "sari", Password: "x", Email: "sari@example.com", Gender: "f", EmailOptOut: true},
+		{Name: "Dewi", Username: "dewi", Password: "x", Email: "dewi@example.com", Gender: "f"},
+		{Name: "Agus", Username: "agus", Password: "x", Email: "agus@example.com", Gender: "m"},
+	}
+	var cartIDs = []int{}
+	for i := range customers {
+		mustCreate(t, db, &customers[i])
+		cart, _, err := carts.CreateCart(Cart{UserID: customers[i].Id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cartIDs = append(cartIDs, cart.ID)
+	}
+
+	// Budi, Sari and Agus left items two days ago, Dewi's cart is empty.
+	for _, i := range []int{0, 1, 3} {
+		if _, err := carts.AddItemToCart(cartIDs[i], CartItem{ProductID: product.Id, Quantity: 1, StartDate: days(10), EndDate: days(11)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Model(&Cart{}).Where("id IN ?", cartIDs).UpdateColumn("last_activity_at", time.Now().Add(-48*time.Hour))
+	carts.MarkReminderSent(cartIDs[3])
+
+	var found = carts.FindAbandonedCarts(time.Now().Add(-24 * time.Hour))
+	if len(found) != 1 || found[0].CartID != cartIDs[0] || found[0].Items != 1 || found[0].Email != "budi@example.com" {
+		t.Fatalf("abandoned carts = %+v, want only Budi's", found)
+	}
+
+	if found := carts.FindAbandonedCarts(time.Now().Add(-72 * time.Hour)); len(found) != 0 {
+		t.Fatalf("carts active within the period are not abandoned, got %+v", found)
+	}
+
+	if archived := carts.ArchiveStaleCarts(time.Now().Add(-24 * time.Hour)); archived != 4 {
+		t.Fatalf("archived %d carts, want 4", archived)
+	}
+	fresh, created, err := carts.CreateCart(Cart{UserID: customers[0].Id})
+	if err != nil || !created || fresh.ID == cartIDs[0] {
+		t.Fatalf("an archived cart's owner should get a new cart, got %+v, %v, %v", fresh, created, err)
+	}
+}
@@ -1,6 +1,9 @@
 package model
 
 import (
+	"errors"
+	"rentcamp/helper"
+	"rentcamp/pagination"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -8,14 +11,37 @@ import (
 )
 
 type Admin struct {
-	Id        int            `gorm:"primaryKey;type:smallint" json:"id" form:"id"`
-	Username  string         `gorm:"type:varchar(25);not null" json:"username" form:"username"`
-	Password  string         `gorm:"type:varchar(25);not null" json:"password" form:"password"`
-	Role      string         `gorm:"type:ENUM('admin');not null" json:"role" form:"role"`
-	CreatedAt time.Time      `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"created_at" form:"created_at"`
-	UpdatedAt time.Time      `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"updated_at" form:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
-	Products  []Product      `json:"products"`
+	Id       int    `gorm:"primaryKey;type:smallint" json:"id" form:"id"`
+	Username string `gorm:"type:varchar(25);not null" json:"username" form:"username"`
+	Password string `gorm:"type:varchar(255);not null" json:"-" form:"password"`
+	Role     string `gorm:"type:varchar(20);not null" json:"role" form:"role"`
+	Disabled bool   `gorm:"not null;default:false" json:"disabled"`
+
+	TOTPSecret      string         `gorm:"type:varchar(64)" json:"-"`
+	TOTPEnabled     bool           `gorm:"not null;default:false" json:"totp_enabled"`
+	TOTPLastCounter int64          `gorm:"not null;default:0" json:"-"`
+	CreatedAt       time.Time      `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"created_at" form:"created_at"`
+	UpdatedAt       time.Time      `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"updated_at" form:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+	Products        []Product      `json:"products"`
+}
+
+type AdminInvite struct {
+	Id        int        `gorm:"primaryKey" json:"id"`
+	TokenHash string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	Role      string     `gorm:"type:varchar(20);not null" json:"role"`
+	CreatedBy int        `json:"created_by"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+type AdminRecoveryCode struct {
+	Id        int    `gorm:"primaryKey"`
+	AdminId   int    `gorm:"index;not null"`
+	CodeHash  string `gorm:"type:varchar(64);not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP"`
 }
 
 type Login struct {
@@ -23,9 +49,35 @@ type Login struct {
 	Password string `json:"password" form:"password"`
 }
 
+type AdminRegister struct {
+	InviteToken string `json:"invite_token" form:"invite_token"`
+	Username    string `json:"username" form:"username"`
+	Password    string `json:"password" form:"password"`
+}
+
+type MFAVerify struct {
+	MFAToken     string `json:"mfa_token" form:"mfa_token"`
+	Code         string `json:"code" form:"code"`
+	RecoveryCode string `json:"recovery_code" form:"recovery_code"`
+}
+
 type AdminModelInterface interface {
 	Login(username string, password string) *Admin
 	Insert(newItem Admin) *Admin
+	Count() int64
+	CountActiveOwners() int64
+	SelectAll(page pagination.Page) []Admin
+	SelectById(adminId int) *Admin
+	SetDisabled(adminId int, disabled bool) *Admin
+	Delete(adminId int) bool
+	CreateInvite(newInvite AdminInvite) *AdminInvite
+	RedeemInvite(tokenHash string, newAdmin Admin) (*Admin, error)
+	IsAccountActive(id int, role string) bool
+	SetTOTPSecret(adminId int, secret string) bool
+	EnableTOTP(adminId int, recoveryHashes []string) bool
+	DisableTOTP(adminId int) bool
+	UseTOTPCounter(adminId int, counter int64) bool
+	UseRecoveryCode(adminId int, codeHash string) bool
 }
 
 type AdminsModel struct {
@@ -58,5 +110,234 @@ func (um *AdminsModel) Login(username string, password string) *Admin {
 		return nil
 	}
 
+	if data.Disabled {
+		logrus.Error("Model : Login data error, ", "account disabled")
+		return nil
+	}
+
+	valid, needsRehash := helper.CheckCredential(password, data.Password)
+	if !valid {
+		logrus.Error("Model : Login data error, ", "invalid credential")
+		return nil
+	}
+
+	if needsRehash {
+		hashed, err := helper.HashPassword(password)
+		if err != nil {
+			logrus.Error("Model : Cannot hash legacy password, ", err.Error())
+			return &data
+		}
+		if err := um.db.Model(&data).Update("password", hashed).Error; err != nil {
+			logrus.Error("Model : Cannot migrate legacy password, ", err.Error())
+			return &data
+		}
+		logrus.Info("Model : Legacy admin password migrated to bcrypt, admin id ", data.Id)
+	}
+
+	return &data
+}
+
+func (um *AdminsModel) Count() int64 {
+	var total int64
+	if err := um.db.Model(&Admin{}).Count(&total).Error; err != nil {
+		logrus.Error("Model : Cannot count admins, ", err.Error())
+		return 0
+	}
+
+	return total
+}
+
+func (um *AdminsModel) CountActiveOwners() int64 {
+	var total int64
+	if err := um.db.Model(&Admin{}).Where("role = ? AND disabled = ?", helper.RoleOwner, false).Count(&total).Error; err != nil {
+		logrus.Error("Model : Cannot count owners, ", err.Error())
+		return 0
+	}
+
+	return total
+}
+
+func (um *AdminsModel) SelectAll(page pagination.Page) []Admin {
+	var data = []Admin{}
+	if err := page.Keyset(um.db, "id", false).Find(&data).Error; err != nil {
+		logrus.Error("Model : Cannot get all admins, ", err.Error())
+		return nil
+	}
+
+	return data
+}
+
+func (um *AdminsModel) SelectById(adminId int) *Admin {
+	var data = Admin{}
+	if err := um.db.Where("id = ?", adminId).First(&data).Error; err != nil {
+		logrus.Error("Model : Data with that ID was not found, ", err.Error())
+		return nil
+	}
+
 	return &data
 }
+
+func (um *AdminsModel) SetDisabled(adminId int, disabled bool) *Admin {
+	if err := um.db.Model(&Admin{}).Where("id = ?", adminId).Update("disabled", disabled).Error; err != nil {
+		logrus.Error("Model : Update error, ", err.Error())
+		return nil
+	}
+
+	return um.SelectById(adminId)
+}
+
+func (um *AdminsModel) Delete(adminId int) bool {
+	var data = Admin{}
+	if err := um.db.Where("id = ?", adminId).First(&data).Error; err != nil {
+		logrus.Error("Model: Error finding data to delete, ", err.Error())
+		return false
+	}
+
+	if err := um.db.Delete(&data).Error; err != nil {
+		logrus.Error("Model : Error delete data, ", err.Error())
+		return false
+	}
+
+	return true
+}
+
+func (um *AdminsModel) CreateInvite(newInvite AdminInvite) *AdminInvite {
+	if err := um.db.Create(&newInvite).Error; err != nil {
+		logrus.Error("Model : Insert invite error, ", err.Error())
+		return nil
+	}
+
+	return &newInvite
+}
+
+func (um *AdminsModel) RedeemInvite(tokenHash string, newAdmin Admin) (*Admin, error) {
+	err := um.db.Transaction(func(tx *gorm.DB) error {
+		var invite = AdminInvite{}
+		if err := tx.Where("token_hash = ?", tokenHash).First(&invite).Error; err != nil {
+			return errors.New("invite not found")
+		}
+		if invite.UsedAt != nil {
+			return errors.New("invite already used")
+		}
+		if time.Now().After(invite.ExpiresAt) {
+			return errors.New("invite expired")
+		}
+
+		var exists int64
+		if err := tx.Model(&Admin{}).Where("username = ?", newAdmin.Username).Count(&exists).Error; err != nil {
+			return err
+		}
+		if exists > 0 {
+			return errors.New("username already taken")
+		}
+
+		newAdmin.Role = invite.Role
+		if err := tx.Create(&newAdmin).Error; err != nil {
+			return err
+		}
+
+		var now = time.Now()
+		var qry = tx.Model(&AdminInvite{}).Where("id = ? AND used_at IS NULL", invite.Id).Update("used_at", &now)
+		if qry.Error != nil {
+			return qry.Error
+		}
+		if qry.RowsAffected < 1 {
+			return errors.New("invite already used")
+		}
+
+		return nil
+	})
+	if err != nil {
+		logrus.Error("Model : Redeem invite error, ", err.Error())
+		return nil, err
+	}
+
+	return &newAdmin, nil
+}
+
+func (um *AdminsModel) IsAccountActive(id int, role string) bool {
+	if !helper.IsStaffRole(role) {
+		return true
+	}
+
+	var data = Admin{}
+	if err := um.db.Select("id", "disabled").Where("id = ?", id).First(&data).Error; err != nil {
+		return false
+	}
+
+	return !data.Disabled
+}
+
+func (um *AdminsModel) SetTOTPSecret(adminId int, secret string) bool {
+	var qry = um.db.Model(&Admin{}).Where("id = ? AND totp_enabled = ?", adminId, false).
+		Updates(map[string]any{"totp_secret": secret, "totp_last_counter": 0})
+	if err := qry.Error; err != nil {
+		logrus.Error("Model : Cannot save totp secret, ", err.Error())
+		return false
+	}
+
+	return qry.RowsAffected > 0
+}
+
+func (um *AdminsModel) EnableTOTP(adminId int, recoveryHashes []string) bool {
+	err := um.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Admin{}).Where("id = ?", adminId).Update("totp_enabled", true).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("admin_id = ?", adminId).Delete(&AdminRecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		var codes = []AdminRecoveryCode{}
+		for _, hash := range recoveryHashes {
+			codes = append(codes, AdminRecoveryCode{AdminId: adminId, CodeHash: hash})
+		}
+		return tx.Create(&codes).Error
+	})
+	if err != nil {
+		logrus.Error("Model : Cannot enable totp, ", err.Error())
+		return false
+	}
+
+	return true
+}
+
+func (um *AdminsModel) DisableTOTP(adminId int) bool {
+	err := um.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Admin{}).Where("id = ?", adminId).Updates(map[string]any{"totp_enabled": false, "totp_secret": "", "totp_last_counter": 0}).Error; err != nil {
+			return err
+		}
+		return tx.Where("admin_id = ?", adminId).Delete(&AdminRecoveryCode{}).Error
+	})
+	if err != nil {
+		logrus.Error("Model : Cannot disable totp, ", err.Error())
+		return false
+	}
+
+	return true
+}
+
+// UseTOTPCounter records the time step of an accepted code. It fails when
+// that step or a later one was already used, which also stops two
+// requests racing with the same code.
+func (um *AdminsModel) UseTOTPCounter(adminId int, counter int64) bool {
+	var qry = um.db.Model(&Admin{}).Where("id = ? AND totp_last_counter < ?", adminId, counter).Update("totp_last_counter", counter)
+	if err := qry.Error; err != nil {
+		logrus.Error("Model : Cannot save totp counter, ", err.Error())
+		return false
+	}
+
+	return qry.RowsAffected == 1
+}
+
+func (um *AdminsModel) UseRecoveryCode(adminId int, codeHash string) bool {
+	var qry = um.db.Model(&AdminRecoveryCode{}).
+		Where("admin_id = ? AND code_hash = ? AND used_at IS NULL", adminId, codeHash).
+		Update("used_at", time.Now())
+	if err := qry.Error; err != nil {
+		logrus.Error("Model : Cannot use recovery code, ", err.Error())
+		return false
+	}
+
+	return qry.RowsAffected == 1
+}
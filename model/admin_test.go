@@ -0,0 +1,151 @@
+package model
+
+import (
+	"rentcamp/helper"
+	"testing"
+	"time"
+)
+
+func TestAdminLoginMigratesPlainTextPassword(t *testing.T) {
+	var db = newTestDB(t)
+	var admins = NewAdminsModel(db)
+
+	var legacy = Admin{Username: "owner", Password: "plain", Role: helper.RoleOwner}
+	mustCreate(t, db, &legacy)
+
+	if got := admins.Login("owner", "wrong"); got != nil {
+		t.Fatal("a wrong password must not log in")
+	}
+	if got := admins.Login("owner", "plain"); got == nil {
+		t.Fatal("the legacy password should still log in once")
+	}
+
+	var stored = admins.SelectById(legacy.Id)
+	if !helper.IsHashedPassword(stored.Password) {
+		t.Fatalf("password should be stored as bcrypt after login, got %q", stored.Password)
+	}
+	if got := admins.Login("owner", "plain"); got == nil {
+		t.Fatal("the migrated password should log in")
+	}
+	if got := admins.Login("owner", stored.Password); got != nil {
+		t.Fatal("the stored hash must not work as a password")
+	}
+}
+
+func TestAdminLoginRefusesDisabledAccount(t *testing.T) {
+	var db = newTestDB(t)
+	var admins = NewAdminsModel(db)
+
+	hashed, _ := helper.HashPassword("pass")
+	var admin = Admin{Username: "staff", Password: hashed, Role: helper.RoleWarehouse}
+	mustCreate(t, db, &admin)
+	admins.SetDisabled(admin.Id, true)
+
+	if got := admins.Login("staff", "pass"); got != nil {
+		t.Fatal("a disabled admin must not log in")
+	}
+}
+
+func TestRedeemInvite(t *testing.T) {
+	var db = newTestDB(t)
+	var admins = NewAdminsModel(db)
+
+	mustCreate(t, db, &Admin{Username: "owner", Password: "x", Role: helper.RoleOwner})
+	mustCreate(t, db, &AdminInvite{TokenHash: "valid", Role: helper.RoleWarehouse, ExpiresAt: time.Now().Add(time.Hour)})
+	mustCreate(t, db, &AdminInvite{TokenHash: "taken", Role: helper.RoleSupport, ExpiresAt: time.Now().Add(time.Hour)})
+	mustCreate(t, db, &AdminInvite{TokenHash: "expired", Role: helper.RoleSupport, ExpiresAt: time.Now().Add(-time.Minute)})
+
+	got, err := admins.RedeemInvite("valid", Admin{Username: "gudang", Password: "x", Role: helper.RoleOwner})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Role != helper.RoleWarehouse {
+		t.Fatalf("role should come from the invite, got %q", got.Role)
+	}
+
+	var tests = []struct {
+		name     string
+		hash     string
+		username string
+	}{
+		{"invite used twice", "valid", "gudang2"},
+		{"expired invite", "expired", "late"},
+		{"unknown invite", "missing", "nobody"},
+		{"username already taken", "taken", "owner"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := admins.RedeemInvite(tt.hash, Admin{Username: tt.username, Password: "x"}); err == nil {
+				t.Fatal("RedeemInvite should fail")
+			}
+		})
+	}
+
+	if total := admins.Count(); total != 2 {
+		t.Fatalf("only the valid invite should create an admin, have %d admins", total)
+	}
+
+	var taken = AdminInvite{}
+	db.Where("token_hash = ?", "taken").First(&taken)
+	if taken.UsedAt != nil {
+		t.Fatal("a failed redeem must leave the invite unused")
+	}
+}
+
+func TestIsAccountActive(t *testing.T) {
+	var db = newTestDB(t)
+	var admins = NewAdminsModel(db)
+
+	var active = Admin{Username: "active", Password: "x", Role: helper.RoleManager}
+	var disabled = Admin{Username: "disabled", Password: "x", Role: helper.RoleManager}
+	mustCreate(t, db, &active)
+	mustCreate(t, db, &disabled)
+	admins.SetDisabled(disabled.Id, true)
+
+	var tests = []struct {
+		name string
+		id   int
+		role string
+		want bool
+	}{
+		{"active admin", active.Id, helper.RoleManager, true},
+		{"disabled admin", disabled.Id, helper.RoleManager, false},
+		{"deleted or unknown admin", 999, helper.RoleOwner, false},
+		{"customers are not admins", disabled.Id, helper.RoleCustomer, true},
+	}
+	for _, tt := range tests {
+		if got := admins.IsAccountActive(tt.id, tt.role); got != tt.want {
+			t.Errorf("%s: IsAccountActive = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUseTOTPCounterRefusesReplay(t *testing.T) {
+	var db = newTestDB(t)
+	var admins = NewAdminsModel(db)
+
+	var admin = Admin{Username: "owner", Password: "x", Role: "owner", TOTPSecret: "SECRET", TOTPEnabled: true}
+	mustCreate(t, db, &admin)
+
+	var steps = []struct {
+		counter int64
+		want    bool
+	}{
+		{100, true},
+		{100, false},
+		{99, false},
+		{101, true},
+	}
+	for _, step := range steps {
+		if got := admins.UseTOTPCounter(admin.Id, step.counter); got != step.want {
+			t.Errorf("UseTOTPCounter(%d) = %v, want %v", step.counter, got, step.want)
+		}
+	}
+
+	if !admins.DisableTOTP(admin.Id) {
+		t.Fatal("DisableTOTP failed")
+	}
+	if got := admins.SelectById(admin.Id); got == nil || got.TOTPLastCounter != 0 {
+		t.Fatalf("disabling should reset the counter, got %+v", got)
+	}
+}
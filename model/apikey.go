@@ -0,0 +1,107 @@
+package model
+
+import (
+	"errors"
+	"rentcamp/helper"
+	"rentcamp/pagination"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type ApiKey struct {
+	Id         int        `gorm:"primaryKey" json:"id"`
+	Name       string     `gorm:"type:varchar(100);not null" json:"name" form:"name"`
+	Partner    string     `gorm:"type:varchar(100);not null" json:"partner" form:"partner"`
+	Prefix     string     `gorm:"type:varchar(16);index;not null" json:"prefix"`
+	KeyHash    string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	Scopes     string     `gorm:"type:varchar(255);not null" json:"scopes" form:"scopes"`
+	UserId     int        `gorm:"not null" json:"user_id" form:"user_id"`
+	CreatedBy  int        `json:"created_by"`
+	ExpiresAt  *time.Time `json:"expires_at" form:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+type ApiKeyModelInterface interface {
+	Insert(newKey ApiKey) (*ApiKey, error)
+	SelectAll(page pagination.Page) []ApiKey
+	Revoke(keyId int) bool
+	ResolveAPIKey(key string) *helper.APIKeyIdentity
+}
+
+type ApiKeysModel struct {
+	db *gorm.DB
+}
+
+func NewApiKeysModel(db *gorm.DB) ApiKeyModelInterface {
+	return &ApiKeysModel{
+		db: db,
+	}
+}
+
+func (akm *ApiKeysModel) Insert(newKey ApiKey) (*ApiKey, error) {
+	var users int64
+	if err := akm.db.Model(&User{}).Where("id = ?", newKey.UserId).Count(&users).Error; err != nil {
+		logrus.Error("Model : Insert api key error, ", err.Error())
+		return nil, err
+	}
+	if users == 0 {
+		return nil, errors.New("partner account not found")
+	}
+
+	if err := akm.db.Create(&newKey).Error; err != nil {
+		logrus.Error("Model : Insert api key error, ", err.Error())
+		return nil, err
+	}
+
+	return &newKey, nil
+}
+
+func (akm *ApiKeysModel) SelectAll(page pagination.Page) []ApiKey {
+	var data = []ApiKey{}
+	if err := page.Keyset(akm.db, "id", true).Find(&data).Error; err != nil {
+		logrus.Error("Model : Cannot get all api keys, ", err.Error())
+		return nil
+	}
+
+	return data
+}
+
+func (akm *ApiKeysModel) Revoke(keyId int) bool {
+	var qry = akm.db.Model(&ApiKey{}).Where("id = ? AND revoked_at IS NULL", keyId).Update("revoked_at", time.Now())
+	if err := qry.Error; err != nil {
+		logrus.Error("Model : Revoke api key error, ", err.Error())
+		return false
+	}
+
+	return qry.RowsAffected > 0
+}
+
+func (akm *ApiKeysModel) ResolveAPIKey(key string) *helper.APIKeyIdentity {
+	var data = ApiKey{}
+	if err := akm.db.Where("key_hash = ? AND revoked_at IS NULL", helper.HashToken(key)).Limit(1).Find(&data).Error; err != nil {
+		logrus.Error("Model : Resolve api key error, ", err.Error())
+		return nil
+	}
+	if data.Id == 0 {
+		return nil
+	}
+	if data.ExpiresAt != nil && time.Now().After(*data.ExpiresAt) {
+		return nil
+	}
+
+	if err := akm.db.Model(&ApiKey{}).Where("id = ?", data.Id).UpdateColumn("last_used_at", time.Now()).Error; err != nil {
+		logrus.Error("Model : Cannot track api key usage, ", err.Error())
+	}
+
+	return &helper.APIKeyIdentity{
+		Id:      data.Id,
+		UserId:  data.UserId,
+		Partner: data.Partner,
+		Scopes:  strings.Split(data.Scopes, ","),
+	}
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type AuditLog struct {
+	Id         int       `gorm:"primaryKey" json:"id"`
+	ActorId    int       `gorm:"index" json:"actor_id"`
+	ActorRole  string    `gorm:"type:varchar(20)" json:"actor_role"`
+	ApiKeyId   int       `json:"api_key_id"`
+	Action     string    `gorm:"type:varchar(100);index;not null" json:"action"`
+	Resource   string    `gorm:"type:varchar(50)" json:"resource"`
+	ResourceId int       `json:"resource_id"`
+	Ip         string    `gorm:"type:varchar(45)" json:"ip"`
+	Detail     string    `gorm:"type:text" json:"detail"`
+	CreatedAt  time.Time `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+type AuditModelInterface interface {
+	Record(entry AuditLog)
+}
+
+type AuditModel struct {
+	db *gorm.DB
+}
+
+func NewAuditModel(db *gorm.DB) AuditModelInterface {
+	return &AuditModel{
+		db: db,
+	}
+}
+
+func (am *AuditModel) Record(entry AuditLog) {
+	logrus.Warn("Audit : ", entry.Action, " actor=", entry.ActorRole, ":", entry.ActorId, " ", entry.Resource, "=", entry.ResourceId, " ip=", entry.Ip)
+
+	if err := am.db.Create(&entry).Error; err != nil {
+		logrus.Error("Model : Cannot write audit log, ", err.Error())
+	}
+}
@@ -0,0 +1,132 @@
+package model
+
+import (
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// bookedStatuses are the order states whose lines keep units out of stock.
+var bookedStatuses = []string{OrderStatusConfirmed, OrderStatusPickedUp}
+
+// availableStock returns how many units of a product are free for every day
+// of the period. It is the single place availability is computed, so new
+// kinds of reservations only need to be subtracted here.
+//
+// Holds and booked lines that overlap the period are subtracted in full,
+// which errs on the side of refusing a rental rather than overbooking.
+// Waitlist holds offered to holderID are left out, since those units are
+// kept for that customer.
+//
+// With a branchID only that branch's stock, holds and orders count, and the
+// result never exceeds what is free across all branches, so holds not tied
+// to a branch are still respected.
+func availableStock(db *gorm.DB, productID int, branchID int, start Date, end Date, holderID int) (int, error) {
+	var product = Product{}
+	if err := db.Select("id", "stock").Where("id = ?", productID).First(&product).Error; err != nil {
+		return 0, err
+	}
+
+	var stock = product.Stock
+	if branchID != 0 {
+		stock = 0
+		if err := db.Model(&BranchStock{}).Where("branch_id = ? AND product_id = ?", branchID, productID).
+			Select("COALESCE(SUM(stock), 0)").Row().Scan(&stock); err != nil {
+			return 0, err
+		}
+	}
+
+	var held int
+	var holds = db.Model(&InventoryHold{}).
+		Where("product_id = ? AND released_at IS NULL AND expires_at > ?", productID, time.Now()).
+		Where("start_date <= ? AND end_date >= ?", end, start).
+		Where("NOT (order_id = 0 AND user_id = ?)", holderID)
+	if branchID != 0 {
+		holds = holds.Where("branch_id = ?", branchID)
+	}
+	if err := holds.Select("COALESCE(SUM(quantity), 0)").Row().Scan(&held); err != nil {
+		return 0, err
+	}
+
+	var booked int
+	var bookings = db.Model(&OrderItem{}).
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("order_items.product_id = ? AND orders.status IN ?", productID, bookedStatuses).
+		Where("order_items.start_date <= ? AND order_items.end_date >= ?", end, start)
+	if branchID != 0 {
+		bookings = bookings.Where("orders.branch_id = ?", branchID)
+	}
+	if err := bookings.Select("COALESCE(SUM(order_items.quantity), 0)").Row().Scan(&booked); err != nil {
+		return 0, err
+	}
+
+	var available = stock - held - booked
+	if branchID != 0 {
+		total, err := availableStock(db, productID, 0, start, end, holderID)
+		if err != nil {
+			return 0, err
+		}
+		available = min(available, total)
+	}
+
+	return available, nil
+}
+
+// peakInUse returns the most units of a product held or booked on any one
+// day from since on. Unlike availableStock it does not add up rentals that
+// never overlap, so it suits checks over a long window. With a branchID
+// only that branch's holds and orders count.
+func peakInUse(db *gorm.DB, productID int, branchID int, since Date) (int, error) {
+	type rental struct {
+		StartDate Date
+		EndDate   Date
+		Quantity  int
+	}
+
+	var held = []rental{}
+	var holds = db.Model(&InventoryHold{}).
+		Where("product_id = ? AND released_at IS NULL AND expires_at > ? AND end_date >= ?", productID, time.Now(), since)
+	if branchID != 0 {
+		holds = holds.Where("branch_id = ?", branchID)
+	}
+	if err := holds.Select("start_date, end_date, quantity").Scan(&held).Error; err != nil {
+		return 0, err
+	}
+
+	var booked = []rental{}
+	var bookings = db.Model(&OrderItem{}).
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("order_items.product_id = ? AND orders.status IN ? AND order_items.end_date >= ?", productID, bookedStatuses, since)
+	if branchID != 0 {
+		bookings = bookings.Where("orders.branch_id = ?", branchID)
+	}
+	if err := bookings.Select("order_items.start_date, order_items.end_date, order_items.quantity").Scan(&booked).Error; err != nil {
+		return 0, err
+	}
+
+	// A rental takes its units on its first day and gives them back the day
+	// after its last, so walking the changes in date order gives the units
+	// in use on every day. Units given back go before units taken that day.
+	type change struct {
+		day   time.Time
+		delta int
+	}
+	var changes = []change{}
+	for _, r := range append(held, booked...) {
+		changes = append(changes, change{r.StartDate.Time, r.Quantity}, change{r.EndDate.AddDate(0, 0, 1), -r.Quantity})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if !changes[i].day.Equal(changes[j].day) {
+			return changes[i].day.Before(changes[j].day)
+		}
+		return changes[i].delta < changes[j].delta
+	})
+
+	var inUse, peak int
+	for _, c := range changes {
+		inUse += c.delta
+		peak = max(peak, inUse)
+	}
+	return peak, nil
+}
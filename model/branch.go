@@ -0,0 +1,385 @@
+package model
+
+import (
+	"errors"
+	"rentcamp/helper"
+	"rentcamp/pagination"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrBranchNotFound = errors.New("branch not found")
+	ErrBranchRequired = errors.New("branch_id is required, please choose a pickup branch")
+	ErrInvalidBranch  = errors.New("a branch needs a name and valid coordinates")
+	ErrBranchInUse    = errors.New("branch still has stock, open orders or transfers, or is the last branch")
+	ErrInvalidStock   = errors.New("stock cannot be negative")
+)
+
+// Branch is a store where rentals are picked up and returned.
+type Branch struct {
+	Id           int            `gorm:"primaryKey" json:"id"`
+	Name         string         `gorm:"type:varchar(100);not null" json:"name"`
+	Address      string         `gorm:"type:varchar(255);not null" json:"address"`
+	Phone        string         `gorm:"type:varchar(15)" json:"phone"`
+	OpeningHours string         `gorm:"type:varchar(255)" json:"opening_hours"`
+	Latitude     float64        `gorm:"type:decimal(9,6);not null" json:"latitude"`
+	Longitude    float64        `gorm:"type:decimal(9,6);not null" json:"longitude"`
+	CreatedAt    time.Time      `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt    time.Time      `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+}
+
+// BranchStock is how many units of a product a branch owns. The product's
+// own Stock is kept equal to the sum over all branches.
+type BranchStock struct {
+	BranchId  int             `gorm:"primaryKey;autoIncrement:false" json:"branch_id"`
+	ProductId int             `gorm:"primaryKey;autoIncrement:false" json:"product_id"`
+	Stock     int             `gorm:"not null" json:"stock"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Product   ProductResponse `gorm:"foreignKey:ProductId" json:"product"`
+}
+
+type BranchDistance struct {
+	Branch
+	DistanceKm float64 `json:"distance_km"`
+}
+
+type BranchAvailability struct {
+	Branch    Branch `json:"branch"`
+	Available int    `json:"available"`
+}
+
+type BranchModelInterface interface {
+	Insert(branch Branch) (*Branch, error)
+	Update(branch Branch) (*Branch, error)
+	Delete(branchID int) error
+	SelectAll(page pagination.Page) []Branch
+	SelectById(branchID int) (*Branch, error)
+	Near(lat float64, lng float64) []BranchDistance
+	SetStock(branchID int, productID int, stock int) (*BranchStock, error)
+	SelectStock(branchID int, page pagination.Page) []BranchStock
+	Availability(productID int, start Date, end Date) ([]BranchAvailability, error)
+}
+
+type BranchModel struct {
+	db *gorm.DB
+}
+
+func NewBranchModel(db *gorm.DB) BranchModelInterface {
+	return &BranchModel{
+		db: db,
+	}
+}
+
+// HasLocation reports whether the branch has real coordinates. A branch
+// created by the migration without a configured location sits at 0,0.
+func (branch Branch) HasLocation() bool {
+	return branch.Latitude != 0 || branch.Longitude != 0
+}
+
+func validBranch(branch Branch) bool {
+	return strings.TrimSpace(branch.Name) != "" && branch.HasLocation() &&
+		branch.Latitude >= -90 && branch.Latitude <= 90 &&
+		branch.Longitude >= -180 && branch.Longitude <= 180
+}
+
+func (bm *BranchModel) Insert(branch Branch) (*Branch, error) {
+	if !validBranch(branch) {
+		return nil, ErrInvalidBranch
+	}
+
+	branch.Id = 0
+	if err := bm.db.Create(&branch).Error; err != nil {
+		logrus.Error("Branch Model: Error creating branch, ", err.Error())
+		return nil, err
+	}
+
+	return &branch, nil
+}
+
+func (bm *BranchModel) Update(branch Branch) (*Branch, error) {
+	if !validBranch(branch) {
+		return nil, ErrInvalidBranch
+	}
+	if _, err := bm.SelectById(branch.Id); err != nil {
+		return nil, err
+	}
+
+	if err := bm.db.Model(&Branch{}).Where("id = ?", branch.Id).Updates(map[string]any{
+		"name":          branch.Name,
+		"address":       branch.Address,
+		"phone":         branch.Phone,
+		"opening_hours": branch.OpeningHours,
+		"latitude":      branch.Latitude,
+		"longitude":     branch.Longitude,
+	}).Error; err != nil {
+		logrus.Error("Branch Model: Error updating branch, ", err.Error())
+		return nil, err
+	}
+
+	return bm.SelectById(branch.Id)
+}
+
+// Delete removes a branch that no longer owns stock and has no open
+// orders or transfers. The last branch cannot be deleted.
+func (bm *BranchModel) Delete(branchID int) error {
+	if _, err := bm.SelectById(branchID); err != nil {
+		return err
+	}
+
+	var branches, stock, orders, transfers int64
+	if err := bm.db.Model(&Branch{}).Count(&branches).Error; err != nil {
+		return err
+	}
+	if err := bm.db.Model(&BranchStock{}).Where("branch_id = ? AND stock > 0", branchID).Count(&stock).Error; err != nil {
+		return err
+	}
+	if err := bm.db.Model(&Order{}).Where("branch_id = ? AND status IN ?", branchID,
+		[]string{OrderStatusPendingPayment, OrderStatusConfirmed, OrderStatusPickedUp}).Count(&orders).Error; err != nil {
+		return err
+	}
+	if err := bm.db.Model(&StockTransfer{}).Where("(from_branch_id = ? OR to_branch_id = ?) AND status IN ?", branchID, branchID,
+		[]string{TransferStatusRequested, TransferStatusInTransit}).Count(&transfers).Error; err != nil {
+		return err
+	}
+	if branches <= 1 || stock > 0 || orders > 0 || transfers > 0 {
+		return ErrBranchInUse
+	}
+
+	if err := bm.db.Where("id = ?", branchID).Delete(&Branch{}).Error; err != nil {
+		logrus.Error("Branch Model: Error deleting branch, ", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (bm *BranchModel) SelectAll(page pagination.Page) []Branch {
+	var res = []Branch{}
+	if err := page.Keyset(bm.db, "id", false).Find(&res).Error; err != nil {
+		logrus.Error("Branch Model: Error listing branches, ", err.Error())
+		return nil
+	}
+	return res
+}
+
+func (bm *BranchModel) SelectById(branchID int) (*Branch, error) {
+	var res = Branch{}
+	if err := bm.db.Where("id = ?", branchID).First(&res).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBranchNotFound
+		}
+		logrus.Error("Branch Model: Error finding branch, ", err.Error())
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Near lists every branch with a location ordered by distance from the
+// given point. There are only a handful of branches, so distances are
+// computed here rather than in SQL.
+func (bm *BranchModel) Near(lat float64, lng float64) []BranchDistance {
+	var branches = []Branch{}
+	if err := bm.db.Find(&branches).Error; err != nil {
+		logrus.Error("Branch Model: Error listing branches, ", err.Error())
+		return nil
+	}
+
+	var res = []BranchDistance{}
+	for _, branch := range branches {
+		if !branch.HasLocation() {
+			continue
+		}
+		res = append(res, BranchDistance{
+			Branch:     branch,
+			DistanceKm: helper.DistanceKm(lat, lng, branch.Latitude, branch.Longitude),
+		})
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].DistanceKm < res[j].DistanceKm
+	})
+
+	return res
+}
+
+// SetStock sets how many units of a product a branch owns and updates the
+// product total.
+func (bm *BranchModel) SetStock(branchID int, productID int, stock int) (*BranchStock, error) {
+	if stock < 0 {
+		return nil, ErrInvalidStock
+	}
+	if _, err := bm.SelectById(branchID); err != nil {
+		return nil, err
+	}
+
+	err := bm.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", productID).First(&Product{}).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrProductNotFound
+			}
+			return err
+		}
+		return setBranchStock(tx, branchID, productID, stock)
+	})
+	if err != nil {
+		logrus.Error("Branch Model: Error setting stock, ", err.Error())
+		return nil, err
+	}
+
+	var res = BranchStock{}
+	if err := bm.db.Preload("Product").Where("branch_id = ? AND product_id = ?", branchID, productID).First(&res).Error; err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (bm *BranchModel) SelectStock(branchID int, page pagination.Page) []BranchStock {
+	var res = []BranchStock{}
+	if err := page.Keyset(bm.db.Preload("Product").Where("branch_id = ?", branchID), "product_id", false).Find(&res).Error; err != nil {
+		logrus.Error("Branch Model: Error listing stock, ", err.Error())
+		return nil
+	}
+	return res
+}
+
+// Availability lists how many units of the product each branch can rent
+// out for the whole period.
+func (bm *BranchModel) Availability(productID int, start Date, end Date) ([]BranchAvailability, error) {
+	if start.IsZero() || end.IsZero() || end.Before(start.Time) {
+		return nil, ErrInvalidDates
+	}
+	if err := bm.db.Where("id = ?", productID).First(&Product{}).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+
+	var branches = []Branch{}
+	if err := bm.db.Order("id").Find(&branches).Error; err != nil {
+		return nil, err
+	}
+
+	var res = []BranchAvailability{}
+	for _, branch := range branches {
+		available, err := availableStock(bm.db, productID, branch.Id, start, end, 0)
+		if err != nil {
+			logrus.Error("Branch Model: Error checking availability, ", err.Error())
+			return nil, err
+		}
+		res = append(res, BranchAvailability{Branch: branch, Available: max(available, 0)})
+	}
+
+	return res, nil
+}
+
+// setBranchStock writes the stock of one branch and recomputes the product
+// total from all branches.
+func setBranchStock(tx *gorm.DB, branchID int, productID int, stock int) error {
+	if err := tx.Clauses(clause.OnConflict{DoUpdates: clause.AssignmentColumns([]string{"stock", "updated_at"})}).
+		Create(&BranchStock{BranchId: branchID, ProductId: productID, Stock: stock}).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&Product{}).Where("id = ?", productID).
+		Update("stock", tx.Model(&BranchStock{}).Select("COALESCE(SUM(stock), 0)").Where("product_id = ?", productID)).Error
+}
+
+// adjustBranchStock adds delta units to a branch. The branch cannot end up
+// with negative stock.
+func adjustBranchStock(tx *gorm.DB, branchID int, productID int, delta int) error {
+	var stock int
+	if err := tx.Model(&BranchStock{}).Where("branch_id = ? AND product_id = ?", branchID, productID).
+		Select("COALESCE(SUM(stock), 0)").Row().Scan(&stock); err != nil {
+		return err
+	}
+	if stock+delta < 0 {
+		return ErrInvalidStock
+	}
+
+	return setBranchStock(tx, branchID, productID, stock+delta)
+}
+
+// defaultBranch is where stock entered on the product itself is kept.
+func defaultBranch(tx *gorm.DB) (int, error) {
+	var branch = Branch{}
+	if err := tx.Order("id").Limit(1).Find(&branch).Error; err != nil {
+		return 0, err
+	}
+	return branch.Id, nil
+}
+
+// assignProductStock makes a stock total set on the product agree with the
+// branches by giving the difference to the default branch.
+func assignProductStock(tx *gorm.DB, productID int, total int) error {
+	branchID, err := defaultBranch(tx)
+	if err != nil || branchID == 0 {
+		return err
+	}
+
+	var others int
+	if err := tx.Model(&BranchStock{}).Where("product_id = ? AND branch_id <> ?", productID, branchID).
+		Select("COALESCE(SUM(stock), 0)").Row().Scan(&others); err != nil {
+		return err
+	}
+
+	return setBranchStock(tx, branchID, productID, max(total-others, 0))
+}
+
+// pickupBranch checks the branch chosen at checkout. Without a choice the
+// only branch is used.
+func pickupBranch(tx *gorm.DB, branchID int) (int, error) {
+	if branchID != 0 {
+		if err := tx.Where("id = ?", branchID).First(&Branch{}).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return 0, ErrBranchNotFound
+			}
+			return 0, err
+		}
+		return branchID, nil
+	}
+
+	var ids = []int{}
+	if err := tx.Model(&Branch{}).Limit(2).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) != 1 {
+		return 0, ErrBranchRequired
+	}
+	return ids[0], nil
+}
+
+// migrateBranches creates the first branch for a store that kept its stock
+// on the products and moves that stock and the existing orders onto it.
+func migrateBranches(db *gorm.DB, branch Branch) {
+	var count int64
+	if err := db.Unscoped().Model(&Branch{}).Count(&count).Error; err != nil || count > 0 {
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if strings.TrimSpace(branch.Name) == "" {
+			branch.Name = "Main store"
+		}
+		if err := tx.Create(&branch).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("INSERT INTO branch_stocks (branch_id, product_id, stock, updated_at) "+
+			"SELECT ?, id, stock, ? FROM products WHERE deleted_at IS NULL", branch.Id, time.Now()).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Order{}).Where("branch_id = 0").Update("branch_id", branch.Id).Error; err != nil {
+			return err
+		}
+		return tx.Model(&InventoryHold{}).Where("branch_id = 0 AND order_id <> 0").Update("branch_id", branch.Id).Error
+	})
+	if err != nil {
+		logrus.Error("Model : cannot create the first branch, ", err.Error())
+	}
+}
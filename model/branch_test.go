@@ -0,0 +1,82 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSetCartBranchFollowsBranchStock(t *testing.T) {
+	var db = newTestDB(t)
+	var carts = NewCartModel(db)
+	var product = newTestProduct(t, db, 5, 100)
+
+	var north = Branch{Name: "North", Address: "Jl. Utara 1", Latitude: -6.1, Longitude: 106.8}
+	mustCreate(t, db, &north)
+	mustCreate(t, db, &BranchStock{BranchId: north.Id, ProductId: product.Id, Stock: 1})
+
+	cart, _, err := carts.CreateCart(Cart{UserID: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := carts.SetCartBranch(cart.ID, 99); !errors.Is(err, ErrBranchNotFound) {
+		t.Fatalf("unknown branch: err = %v, want ErrBranchNotFound", err)
+	}
+	updated, err := carts.SetCartBranch(cart.ID, north.Id)
+	if err != nil || updated.BranchId != north.Id {
+		t.Fatalf("SetCartBranch = %+v, %v, want branch %d", updated, err, north.Id)
+	}
+
+	var line = CartItem{ProductID: product.Id, Quantity: 2, StartDate: days(3), EndDate: days(5)}
+	if _, err := carts.AddItemToCart(cart.ID, line); !errors.Is(err, ErrInsufficientStock) {
+		t.Fatalf("2 units at a branch with 1: err = %v, want ErrInsufficientStock", err)
+	}
+	line.Quantity = 1
+	if _, err := carts.AddItemToCart(cart.ID, line); err != nil {
+		t.Fatal(err)
+	}
+
+	order, err := NewOrdersModel(db).StartCheckout(cart.ID, 10, CheckoutRequest{}, 15*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.BranchId != north.Id {
+		t.Fatalf("checkout without a branch picked branch %d, want the cart's branch %d", order.BranchId, north.Id)
+	}
+}
+
+func TestNearSkipsBranchesWithoutLocation(t *testing.T) {
+	var db = newTestDB(t)
+	var branches = NewBranchModel(db)
+
+	mustCreate(t, db, &Branch{Name: "Unset", Address: "-"})
+	var far = Branch{Name: "Bandung", Address: "Jl. Braga 1", Latitude: -6.9, Longitude: 107.6}
+	mustCreate(t, db, &far)
+
+	var res = branches.Near(-6.2, 106.8)
+	if len(res) != 2 {
+		t.Fatalf("Near returned %d branches, want 2: %+v", len(res), res)
+	}
+	if res[0].Id != mainBranch || res[1].Id != far.Id {
+		t.Fatalf("Near order = %d, %d, want %d, %d", res[0].Id, res[1].Id, mainBranch, far.Id)
+	}
+
+	if _, err := branches.Insert(Branch{Name: "Nowhere"}); !errors.Is(err, ErrInvalidBranch) {
+		t.Fatalf("branch without a location: err = %v, want ErrInvalidBranch", err)
+	}
+}
+
+func TestMigrateBranchesUsesSeed(t *testing.T) {
+	var db = newTestDB(t)
+	db.Exec("DELETE FROM branches")
+
+	migrateBranches(db, Branch{Address: "Jl. Sudirman 1", Latitude: -6.2, Longitude: 106.8})
+
+	var branch = Branch{}
+	if err := db.First(&branch).Error; err != nil {
+		t.Fatal(err)
+	}
+	if branch.Name != "Main store" || branch.Address != "Jl. Sudirman 1" || !branch.HasLocation() {
+		t.Fatalf("seeded branch = %+v, want the configured address and location", branch)
+	}
+}
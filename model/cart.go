@@ -1,19 +1,52 @@
 package model
 
 import (
+	"errors"
+	"rentcamp/pagination"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
+const (
+	CartStatusActive   = "active"
+	CartStatusArchived = "archived"
+	CartStatusGuest    = "guest"
+	CartStatusMerged   = "merged"
+)
+
 type Cart struct {
-	ID        int            `gorm:"primaryKey" json:"id" form:"id"`
-	UserID    int            `json:"user_id" form:"user_id"`
-	CreatedAt time.Time      `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"created_at" form:"created_at"`
-	UpdatedAt time.Time      `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"updated_at" form:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at" form:"deleted_at"`
-	CartItem  []CartItem
+	ID       int    `gorm:"primaryKey" json:"id" form:"id"`
+	UserID   int    `json:"user_id" form:"user_id"`
+	ApiKeyID *int   `json:"api_key_id"`
+	Status   string `gorm:"type:varchar(20);not null;default:'active';index" json:"status"`
+	// BranchId is the pickup branch the customer chose, 0 until they do.
+	// Cart lines are checked against that branch's stock.
+	BranchId int `gorm:"index;not null;default:0" json:"branch_id" form:"-"`
+	// ActiveUserID mirrors UserID while the cart is the user's active one and
+	// is NULL otherwise; its unique index enforces one active cart per user.
+	ActiveUserID   *int           `gorm:"uniqueIndex" json:"-"`
+	LastActivityAt time.Time      `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP;index" json:"last_activity_at"`
+	ReminderSentAt *time.Time     `json:"reminder_sent_at"`
+	CreatedAt      time.Time      `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"created_at" form:"created_at"`
+	UpdatedAt      time.Time      `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"updated_at" form:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"deleted_at" form:"deleted_at"`
+	CartItem       []CartItem
+
+	// MergeAdjustments is filled by MergeGuestCart.
+	MergeAdjustments []MergeAdjustment `gorm:"-" json:"merge_adjustments,omitempty"`
+}
+
+// MergeAdjustment reports a guest cart line that did not fit the user's
+// cart: Merged of the Requested units were added.
+type MergeAdjustment struct {
+	ProductID int    `json:"product_id"`
+	StartDate Date   `json:"start_date"`
+	EndDate   Date   `json:"end_date"`
+	Requested int    `json:"requested"`
+	Merged    int    `json:"merged"`
+	Reason    string `json:"reason"`
 }
 
 type CartItem struct {
@@ -21,12 +54,31 @@ type CartItem struct {
 	CartID    int            `json:"cart_id" form:"cart_id"`
 	ProductID int            `json:"product_id" form:"product_id"`
 	Quantity  int            `json:"quantity" form:"quantity"`
+	StartDate Date           `json:"start_date" form:"start_date"`
+	EndDate   Date           `json:"end_date" form:"end_date"`
+	UnitPrice int            `json:"unit_price"`
 	CreatedAt time.Time      `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"created_at" form:"created_at"`
 	UpdatedAt time.Time      `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"updated_at" form:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at" form:"deleted_at"`
 	Product   ProductResponse
+
+	// Filled by GetItemsInCart so clients can warn before checkout.
+	CurrentPrice      int  `gorm:"-" json:"current_price"`
+	PriceChanged      bool `gorm:"-" json:"price_changed"`
+	AvailableQuantity int  `gorm:"-" json:"available_quantity"`
+	Available         bool `gorm:"-" json:"available"`
 }
 
+var (
+	ErrProductNotFound   = errors.New("product not found")
+	ErrInvalidQuantity   = errors.New("quantity must be at least 1")
+	ErrInvalidDates      = errors.New("start_date and end_date are required, start_date cannot be in the past and end_date cannot be before start_date")
+	ErrInsufficientStock = errors.New("not enough stock available for the requested dates")
+	ErrCartItemNotFound  = errors.New("cart item not found")
+	ErrCartClosed        = errors.New("cart has already been checked out or archived")
+	ErrCartCheckingOut   = errors.New("cart has an order waiting for payment")
+)
+
 type ProductResponse struct {
 	ID          string `json:"id"`
 	Name        string `json:"name"`
@@ -43,13 +95,22 @@ func (ProductResponse) TableName() string {
 
 type CartModelInterface interface {
 	GetCartByCartId(cartID int) (*Cart, error)
-	AddItemToCart(cartID int, newItem CartItem) *CartItem
-	UpdateCartItem(cartID, itemID int, updatedItem CartItem) *CartItem
-	RemoveCartItem(cartID, itemID int) bool
-	GetItemsInCart(cartID int) []CartItem
-	RemoveAllItemsFromCart(cartID int) bool
+	AddItemToCart(cartID int, newItem CartItem) (*CartItem, error)
+	UpdateCartItem(cartID, itemID int, updatedItem CartItem) (*CartItem, error)
+	RemoveCartItem(cartID, itemID int) error
+	SetCartBranch(cartID int, branchID int) (*Cart, error)
+	GetItemsInCart(cartID int, page pagination.Page) []CartItem
+	RemoveAllItemsFromCart(cartID int) error
 	GetTotalCartPrice(cartID int) int
-	CreateCart(userID int) (*Cart, error)
+	CreateCart(newCart Cart) (*Cart, bool, error)
+	GetCartOwner(cartID int) (int, error)
+	CreateGuestCart() (*Cart, error)
+	IsGuestCart(cartID int) bool
+	MergeGuestCart(guestCartID, userID int) (*Cart, error)
+	FindAbandonedCarts(inactiveSince time.Time) []AbandonedCart
+	MarkReminderSent(cartID int) bool
+	ArchiveStaleCarts(inactiveSince time.Time) int64
+	AbandonmentReport(from time.Time, to time.Time, inactiveSince time.Time) (*AbandonmentReport, error)
 }
 
 type CartModel struct {
@@ -62,17 +123,36 @@ func NewCartModel(db *gorm.DB) CartModelInterface {
 	}
 }
 
-func (cm *CartModel) CreateCart(userID int) (*Cart, error) {
-	newCart := Cart{
-		UserID: userID,
+// CreateCart returns the user's active cart, creating it when the user has
+// none. The boolean reports whether a new cart was created.
+func (cm *CartModel) CreateCart(newCart Cart) (*Cart, bool, error) {
+	if cart, err := cm.findActiveCart(newCart.UserID); err == nil {
+		return cart, false, nil
 	}
 
+	var userID = newCart.UserID
+	newCart.Status = CartStatusActive
+	newCart.ActiveUserID = &userID
+	newCart.LastActivityAt = time.Now()
+
 	if err := cm.db.Create(&newCart).Error; err != nil {
+		// A concurrent request may have created the cart first.
+		if cart, findErr := cm.findActiveCart(newCart.UserID); findErr == nil {
+			return cart, false, nil
+		}
 		logrus.Error("Cart Model: Error creating cart, ", err.Error())
-		return nil, err
+		return nil, false, err
 	}
 
-	return &newCart, nil
+	return &newCart, true, nil
+}
+
+func (cm *CartModel) findActiveCart(userID int) (*Cart, error) {
+	var cart = Cart{}
+	if err := cm.db.Preload("CartItem.Product").Where("active_user_id = ?", userID).First(&cart).Error; err != nil {
+		return nil, err
+	}
+	return &cart, nil
 }
 
 func (cm *CartModel) GetCartByCartId(cartID int) (*Cart, error) {
@@ -84,46 +164,336 @@ func (cm *CartModel) GetCartByCartId(cartID int) (*Cart, error) {
 	return &cart, nil
 }
 
-func (cm *CartModel) AddItemToCart(cartID int, newItem CartItem) *CartItem {
+// sameLine matches the line of cartID holding the same product for the same
+// rental dates as item.
+func sameLine(tx *gorm.DB, cartID int, item CartItem) *gorm.DB {
+	var qry = tx.Where("cart_id = ? AND product_id = ?", cartID, item.ProductID)
+	if item.StartDate.IsZero() {
+		qry = qry.Where("start_date IS NULL AND end_date IS NULL")
+	} else {
+		qry = qry.Where("start_date = ? AND end_date = ?", item.StartDate, item.EndDate)
+	}
+	return qry.Limit(1)
+}
+
+// touchCart records customer activity on the cart, which the abandoned cart
+// job uses to decide when to send a reminder.
+func touchCart(tx *gorm.DB, cartID int) error {
+	return tx.Model(&Cart{}).Where("id = ?", cartID).UpdateColumn("last_activity_at", time.Now()).Error
+}
+
+// openCart refuses changes to carts that were ordered, archived or merged,
+// and to carts with an order waiting for payment: that order was priced and
+// held from the lines as they were at checkout.
+func openCart(tx *gorm.DB, cartID int) error {
+	var count int64
+	if err := tx.Model(&Cart{}).Where("id = ? AND status IN ?", cartID, []string{CartStatusActive, CartStatusGuest}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrCartClosed
+	}
+
+	if err := tx.Model(&Order{}).
+		Where("cart_id = ? AND status = ? AND hold_expires_at > ?", cartID, OrderStatusPendingPayment, time.Now()).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCartCheckingOut
+	}
+	return nil
+}
+
+// validateLine checks a cart line against the product and its availability
+// for the line's dates. Other lines of the same cart that overlap the period
+// count against the available stock; excludeItemID leaves out the line being
+// replaced. A branchID checks the stock of that branch only.
+func validateLine(tx *gorm.DB, cartID int, item CartItem, excludeItemID int, branchID int) (*Product, error) {
+	if item.Quantity < 1 {
+		return nil, ErrInvalidQuantity
+	}
+	if item.StartDate.IsZero() || item.EndDate.IsZero() || item.StartDate.Before(Today().Time) || item.EndDate.Before(item.StartDate.Time) {
+		return nil, ErrInvalidDates
+	}
+
+	var product = Product{}
+	if err := tx.Where("id = ?", item.ProductID).First(&product).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+
+	room, err := lineRoom(tx, cartID, item, excludeItemID, branchID)
+	if err != nil {
+		return nil, err
+	}
+	if item.Quantity > room {
+		return nil, ErrInsufficientStock
+	}
+
+	return &product, nil
+}
+
+// lineRoom is how many units of the item's product the cart can hold for
+// the item's dates, leaving out the line excludeItemID.
+func lineRoom(tx *gorm.DB, cartID int, item CartItem, excludeItemID int, branchID int) (int, error) {
+	var holderID int
+	if err := tx.Model(&Cart{}).Where("id = ?", cartID).Select("user_id").Row().Scan(&holderID); err != nil {
+		return 0, err
+	}
+
+	available, err := availableStock(tx, item.ProductID, branchID, item.StartDate, item.EndDate, holderID)
+	if err != nil {
+		return 0, err
+	}
+
+	var inCart int
+	if err := tx.Model(&CartItem{}).
+		Where("cart_id = ? AND product_id = ? AND id <> ?", cartID, item.ProductID, excludeItemID).
+		Where("start_date <= ? AND end_date >= ?", item.EndDate, item.StartDate).
+		Select("COALESCE(SUM(quantity), 0)").Row().Scan(&inCart); err != nil {
+		return 0, err
+	}
+
+	return available - inCart, nil
+}
+
+// AddItemToCart merges the item into an existing line for the same product
+// and rental dates instead of adding a duplicate row.
+func (cm *CartModel) AddItemToCart(cartID int, newItem CartItem) (*CartItem, error) {
 	newItem.CartID = cartID
-	if err := cm.db.Create(&newItem).Error; err != nil {
+	newItem.ID = 0
+
+	if newItem.Quantity < 1 {
+		return nil, ErrInvalidQuantity
+	}
+
+	err := cm.db.Transaction(func(tx *gorm.DB) error {
+		if err := openCart(tx, cartID); err != nil {
+			return err
+		}
+
+		var existing = CartItem{}
+		if err := sameLine(tx, cartID, newItem).Find(&existing).Error; err != nil {
+			return err
+		}
+
+		var line = newItem
+		line.Quantity = newItem.Quantity + existing.Quantity
+
+		branchID, err := cartBranch(tx, cartID)
+		if err != nil {
+			return err
+		}
+		product, err := validateLine(tx, cartID, line, existing.ID, branchID)
+		if err != nil {
+			return err
+		}
+
+		if err := touchCart(tx, cartID); err != nil {
+			return err
+		}
+
+		if existing.ID == 0 {
+			newItem.UnitPrice = product.Price
+			return tx.Create(&newItem).Error
+		}
+
+		if err := tx.Model(&existing).Updates(map[string]any{"quantity": line.Quantity, "unit_price": product.Price}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", existing.ID).First(&newItem).Error
+	})
+	if err != nil {
 		logrus.Error("Cart Model: Error adding item to cart, ", err.Error())
-		return nil
+		return nil, err
 	}
-	return &newItem
+	return &newItem, nil
 }
 
-func (cm *CartModel) UpdateCartItem(cartID, itemID int, updatedItem CartItem) *CartItem {
-	if err := cm.db.Where("cart_id = ? AND id = ?", cartID, itemID).Updates(&updatedItem).Error; err != nil {
+func (cm *CartModel) UpdateCartItem(cartID, itemID int, updatedItem CartItem) (*CartItem, error) {
+	var res = CartItem{}
+
+	err := cm.db.Transaction(func(tx *gorm.DB) error {
+		if err := openCart(tx, cartID); err != nil {
+			return err
+		}
+
+		if err := tx.Where("cart_id = ? AND id = ?", cartID, itemID).First(&res).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCartItemNotFound
+			}
+			return err
+		}
+
+		if updatedItem.ProductID != 0 {
+			res.ProductID = updatedItem.ProductID
+		}
+		if updatedItem.Quantity != 0 {
+			res.Quantity = updatedItem.Quantity
+		}
+		if !updatedItem.StartDate.IsZero() {
+			res.StartDate = updatedItem.StartDate
+		}
+		if !updatedItem.EndDate.IsZero() {
+			res.EndDate = updatedItem.EndDate
+		}
+
+		branchID, err := cartBranch(tx, cartID)
+		if err != nil {
+			return err
+		}
+		product, err := validateLine(tx, cartID, res, res.ID, branchID)
+		if err != nil {
+			return err
+		}
+		res.UnitPrice = product.Price
+
+		if err := touchCart(tx, cartID); err != nil {
+			return err
+		}
+
+		return tx.Model(&CartItem{}).Where("id = ?", res.ID).Updates(map[string]any{
+			"product_id": res.ProductID,
+			"quantity":   res.Quantity,
+			"start_date": res.StartDate,
+			"end_date":   res.EndDate,
+			"unit_price": res.UnitPrice,
+		}).Error
+	})
+	if err != nil {
 		logrus.Error("Cart Model: Error updating cart item, ", err.Error())
-		return nil
+		return nil, err
 	}
-	return &updatedItem
+	return &res, nil
 }
 
-func (cm *CartModel) RemoveCartItem(cartID, itemID int) bool {
-	if err := cm.db.Where("cart_id = ? AND id = ?", cartID, itemID).Delete(&CartItem{}).Error; err != nil {
+func (cm *CartModel) RemoveCartItem(cartID, itemID int) error {
+	err := cm.db.Transaction(func(tx *gorm.DB) error {
+		if err := openCart(tx, cartID); err != nil {
+			return err
+		}
+
+		var qry = tx.Where("cart_id = ? AND id = ?", cartID, itemID).Delete(&CartItem{})
+		if qry.Error != nil {
+			return qry.Error
+		}
+		if qry.RowsAffected == 0 {
+			return ErrCartItemNotFound
+		}
+
+		return touchCart(tx, cartID)
+	})
+	if err != nil {
 		logrus.Error("Cart Model: Error removing cart item, ", err.Error())
-		return false
 	}
-	return true
+	return err
 }
 
-func (cm *CartModel) GetItemsInCart(cartID int) []CartItem {
+// SetCartBranch chooses the branch the cart will be picked up from. Lines
+// that the branch cannot supply are flagged by GetItemsInCart.
+func (cm *CartModel) SetCartBranch(cartID int, branchID int) (*Cart, error) {
+	err := cm.db.Transaction(func(tx *gorm.DB) error {
+		if err := openCart(tx, cartID); err != nil {
+			return err
+		}
+		if err := tx.Where("id = ?", branchID).First(&Branch{}).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBranchNotFound
+			}
+			return err
+		}
+		if err := tx.Model(&Cart{}).Where("id = ?", cartID).Update("branch_id", branchID).Error; err != nil {
+			return err
+		}
+		return touchCart(tx, cartID)
+	})
+	if err != nil {
+		logrus.Error("Cart Model: Error choosing cart branch, ", err.Error())
+		return nil, err
+	}
+
+	return cm.GetCartByCartId(cartID)
+}
+
+// cartBranch is the branch whose stock the cart is checked against: the
+// one the customer chose, or the only branch. With several branches and no
+// choice yet, 0 checks the stock of all branches together.
+func cartBranch(tx *gorm.DB, cartID int) (int, error) {
+	var branchID int
+	if err := tx.Model(&Cart{}).Where("id = ?", cartID).Select("branch_id").Row().Scan(&branchID); err != nil {
+		return 0, err
+	}
+	if branchID != 0 {
+		return branchID, nil
+	}
+
+	var ids = []int{}
+	if err := tx.Model(&Branch{}).Limit(2).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 1 {
+		return ids[0], nil
+	}
+	return 0, nil
+}
+
+// GetItemsInCart returns the lines with their current price and
+// availability, flagging lines that changed since they were added.
+func (cm *CartModel) GetItemsInCart(cartID int, page pagination.Page) []CartItem {
 	var items = []CartItem{}
-	if err := cm.db.Preload("Product").Where("cart_id = ?", cartID).Find(&items).Error; err != nil {
+	if err := page.Keyset(cm.db.Preload("Product").Where("cart_id = ?", cartID), "id", false).Find(&items).Error; err != nil {
 		logrus.Error("Cart Model: Error fetching cart items, ", err.Error())
 		return nil
 	}
+
+	holderID, _ := cm.GetCartOwner(cartID)
+	branchID, err := cartBranch(cm.db, cartID)
+	if err != nil {
+		logrus.Error("Cart Model: Error finding cart branch, ", err.Error())
+		return nil
+	}
+
+	for i := range items {
+		var item = &items[i]
+		if item.Product.ID == "" {
+			continue
+		}
+
+		item.CurrentPrice = item.Product.Price
+		item.PriceChanged = item.UnitPrice != 0 && item.UnitPrice != item.CurrentPrice
+
+		if item.StartDate.IsZero() || item.EndDate.IsZero() {
+			continue
+		}
+		available, err := availableStock(cm.db, item.ProductID, branchID, item.StartDate, item.EndDate, holderID)
+		if err != nil {
+			logrus.Error("Cart Model: Error checking availability, ", err.Error())
+			continue
+		}
+		item.AvailableQuantity = available
+		item.Available = !item.StartDate.Before(Today().Time) && item.Quantity <= available
+	}
+
 	return items
 }
 
-func (cm *CartModel) RemoveAllItemsFromCart(cartID int) bool {
-	if err := cm.db.Where("cart_id = ?", cartID).Delete(&CartItem{}).Error; err != nil {
+func (cm *CartModel) RemoveAllItemsFromCart(cartID int) error {
+	err := cm.db.Transaction(func(tx *gorm.DB) error {
+		if err := openCart(tx, cartID); err != nil {
+			return err
+		}
+		if err := tx.Where("cart_id = ?", cartID).Delete(&CartItem{}).Error; err != nil {
+			return err
+		}
+		return touchCart(tx, cartID)
+	})
+	if err != nil {
 		logrus.Error("Cart Model: Error removing all cart items, ", err.Error())
-		return false
 	}
-	return true
+	return err
 }
 
 func (cm *CartModel) GetTotalCartPrice(cartID int) int {
@@ -134,3 +504,138 @@ func (cm *CartModel) GetTotalCartPrice(cartID int) int {
 	}
 	return totalPrice
 }
+
+func (cm *CartModel) GetCartOwner(cartID int) (int, error) {
+	var cart = Cart{}
+	if err := cm.db.Select("id", "user_id").Where("id = ?", cartID).First(&cart).Error; err != nil {
+		return 0, err
+	}
+	return cart.UserID, nil
+}
+
+func (cm *CartModel) CreateGuestCart() (*Cart, error) {
+	var newCart = Cart{Status: CartStatusGuest, LastActivityAt: time.Now()}
+	if err := cm.db.Create(&newCart).Error; err != nil {
+		logrus.Error("Cart Model: Error creating guest cart, ", err.Error())
+		return nil, err
+	}
+	return &newCart, nil
+}
+
+func (cm *CartModel) IsGuestCart(cartID int) bool {
+	var count int64
+	if err := cm.db.Model(&Cart{}).Where("id = ? AND status = ?", cartID, CartStatusGuest).Count(&count).Error; err != nil {
+		logrus.Error("Cart Model: Error fetching guest cart, ", err.Error())
+		return false
+	}
+	return count > 0
+}
+
+// MergeGuestCart moves the lines of a guest cart into the user's active
+// cart. Merge rules:
+//   - same product and same rental dates: quantities are added together
+//   - same product with different dates: kept as separate lines, since they
+//     are different rentals
+//   - a line that no longer fits the stock is capped to what is left, or
+//     left out when nothing is; the cart lists these in MergeAdjustments
+//   - the guest cart is marked merged and can no longer be used
+func (cm *CartModel) MergeGuestCart(guestCartID, userID int) (*Cart, error) {
+	if !cm.IsGuestCart(guestCartID) {
+		return nil, errors.New("guest cart not found or already merged")
+	}
+
+	target, _, err := cm.CreateCart(Cart{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	var adjustments = []MergeAdjustment{}
+	err = cm.db.Transaction(func(tx *gorm.DB) error {
+		var qry = tx.Model(&Cart{}).Where("id = ? AND status = ?", guestCartID, CartStatusGuest).Update("status", CartStatusMerged)
+		if qry.Error != nil {
+			return qry.Error
+		}
+		if qry.RowsAffected == 0 {
+			return errors.New("guest cart already merged")
+		}
+
+		if err := openCart(tx, target.ID); err != nil {
+			return err
+		}
+
+		// A branch chosen while browsing as a guest carries over unless the
+		// user's cart already has one.
+		if err := tx.Model(&Cart{}).Where("id = ? AND branch_id = 0", target.ID).
+			Update("branch_id", tx.Model(&Cart{}).Select("branch_id").Where("id = ?", guestCartID)).Error; err != nil {
+			return err
+		}
+
+		var items = []CartItem{}
+		if err := tx.Where("cart_id = ?", guestCartID).Find(&items).Error; err != nil {
+			return err
+		}
+
+		for _, item := range items {
+			var existing = CartItem{}
+			if err := sameLine(tx, target.ID, item).Find(&existing).Error; err != nil {
+				return err
+			}
+
+			var line = item
+			line.Quantity = existing.Quantity + item.Quantity
+			if _, err := validateLine(tx, target.ID, line, existing.ID, 0); err != nil {
+				if !errors.Is(err, ErrInsufficientStock) && !errors.Is(err, ErrInvalidDates) && !errors.Is(err, ErrProductNotFound) {
+					return err
+				}
+
+				var merged int
+				if errors.Is(err, ErrInsufficientStock) {
+					room, roomErr := lineRoom(tx, target.ID, line, existing.ID, 0)
+					if roomErr != nil {
+						return roomErr
+					}
+					merged = min(max(room-existing.Quantity, 0), item.Quantity)
+				}
+				adjustments = append(adjustments, MergeAdjustment{
+					ProductID: item.ProductID,
+					StartDate: item.StartDate,
+					EndDate:   item.EndDate,
+					Requested: item.Quantity,
+					Merged:    merged,
+					Reason:    err.Error(),
+				})
+				if merged == 0 {
+					continue
+				}
+				item.Quantity = merged
+			}
+
+			if existing.ID != 0 {
+				if err := tx.Model(&existing).UpdateColumn("quantity", gorm.Expr("quantity + ?", item.Quantity)).Error; err != nil {
+					return err
+				}
+				if err := tx.Delete(&item).Error; err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := tx.Model(&item).Updates(map[string]any{"cart_id": target.ID, "quantity": item.Quantity}).Error; err != nil {
+				return err
+			}
+		}
+
+		return touchCart(tx, target.ID)
+	})
+	if err != nil {
+		logrus.Error("Cart Model: Error merging guest cart, ", err.Error())
+		return nil, err
+	}
+
+	res, err := cm.findActiveCart(userID)
+	if err != nil {
+		return nil, err
+	}
+	res.MergeAdjustments = adjustments
+	return res, nil
+}
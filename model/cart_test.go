@@ -0,0 +1,390 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"rentcamp/pagination"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// newTestProduct creates a product with all its stock at the main branch,
+// as InsertProduct does.
+func newTestProduct(t *testing.T, db *gorm.DB, stock int, price int) Product {
+	t.Helper()
+	var product = Product{Name: "Tenda Dome", Description: "Tenda untuk 4 orang", Price: price, Stock: stock, AdminId: catalogAdmin(t, db)}
+	mustCreate(t, db, &product)
+	mustCreate(t, db, &BranchStock{BranchId: mainBranch, ProductId: product.Id, Stock: stock})
+	return product
+}
+
+func TestCreateCartKeepsOneActiveCart(t *testing.T) {
+	var db = newTestDB(t)
+	var carts = NewCartModel(db)
+
+	first, created, err := carts.CreateCart(Cart{UserID: 10})
+	if err != nil || !created {
+		t.Fatalf("first CreateCart = %v, %v, want a new cart", created, err)
+	}
+	again, created, err := carts.CreateCart(Cart{UserID: 10})
+	if err != nil || created || again.ID != first.ID {
+		t.Fatalf("second CreateCart = %+v, %v, %v, want cart %d", again, created, err, first.ID)
+	}
+	other, created, err := carts.CreateCart(Cart{UserID: 11})
+	if err != nil || !created || other.ID == first.ID {
+		t.Fatalf("another user should get their own cart, got %+v, %v, %v", other, created, err)
+	}
+
+	var active int64
+	db.Model(&Cart{}).Where("user_id = ? AND status = ?", 10, CartStatusActive).Count(&active)
+	if active != 1 {
+		t.Fatalf("user has %d active carts, want 1", active)
+	}
+}
+
+func TestAddItemToCartMergesSameLine(t *testing.T) {
+	var db = newTestDB(t)
+	var carts = NewCartModel(db)
+	var product = newTestProduct(t, db, 5, 100)
+
+	cart, _, err := carts.CreateCart(Cart{UserID: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var line = CartItem{ProductID: product.Id, Quantity: 1, StartDate: days(3), EndDate: days(5)}
+	first, err := carts.AddItemToCart(cart.ID, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line.Quantity = 2
+	merged, err := carts.AddItemToCart(cart.ID, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.ID != first.ID || merged.Quantity != 3 {
+		t.Fatalf("same product and dates should merge into line %d with quantity 3, got %+v", first.ID, merged)
+	}
+
+	var otherDates = CartItem{ProductID: product.Id, Quantity: 1, StartDate: days(10), EndDate: days(11)}
+	separate, err := carts.AddItemToCart(cart.ID, otherDates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if separate.ID == first.ID {
+		t.Fatal("different dates should be a separate line")
+	}
+
+	var lines int64
+	db.Model(&CartItem{}).Where("cart_id = ?", cart.ID).Count(&lines)
+	if lines != 2 {
+		t.Fatalf("cart has %d lines, want 2", lines)
+	}
+}
+
+func TestMergeGuestCart(t *testing.T) {
+	var db = newTestDB(t)
+	var carts = NewCartModel(db)
+	var tent = newTestProduct(t, db, 10, 100)
+	var stove = newTestProduct(t, db, 10, 50)
+
+	account, _, err := carts.CreateCart(Cart{UserID: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := carts.AddItemToCart(account.ID, CartItem{ProductID: tent.Id, Quantity: 1, StartDate: days(3), EndDate: days(5)}); err != nil {
+		t.Fatal(err)
+	}
+
+	guest, err := carts.CreateGuestCart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range []CartItem{
+		{ProductID: tent.Id, Quantity: 2, StartDate: days(3), EndDate: days(5)},
+		{ProductID: tent.Id, Quantity: 1, StartDate: days(8), EndDate: days(9)},
+		{ProductID: stove.Id, Quantity: 1, StartDate: days(3), EndDate: days(5)},
+	} {
+		if _, err := carts.AddItemToCart(guest.ID, item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	merged, err := carts.MergeGuestCart(guest.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.ID != account.ID {
+		t.Fatalf("guest items should land in the account cart %d, got %d", account.ID, merged.ID)
+	}
+
+	var quantities = map[string]int{}
+	for _, item := range merged.CartItem {
+		quantities[fmt.Sprint(item.ProductID, " ", item.StartDate)] = item.Quantity
+	}
+	var want = map[string]int{
+		fmt.Sprint(tent.Id, " ", days(3)):  3,
+		fmt.Sprint(tent.Id, " ", days(8)):  1,
+		fmt.Sprint(stove.Id, " ", days(3)): 1,
+	}
+	if !reflect.DeepEqual(quantities, want) {
+		t.Fatalf("account cart lines = %v, want %v", quantities, want)
+	}
+
+	if carts.IsGuestCart(guest.ID) {
+		t.Fatal("the guest cart should be closed after merging")
+	}
+	if _, err := carts.MergeGuestCart(guest.ID, 10); err == nil {
+		t.Fatal("merging the same guest cart twice should fail")
+	}
+	if _, err := carts.AddItemToCart(guest.ID, CartItem{ProductID: stove.Id, Quantity: 1, StartDate: days(3), EndDate: days(5)}); err != ErrCartClosed {
+		t.Fatalf("adding to a merged guest cart = %v, want ErrCartClosed", err)
+	}
+}
+
+func TestMergeGuestCartCapsLinesToStock(t *testing.T) {
+	var db = newTestDB(t)
+	var carts = NewCartModel(db)
+	var tent = newTestProduct(t, db, 3, 100)
+	var stove = newTestProduct(t, db, 1, 50)
+
+	account, _, err := carts.CreateCart(Cart{UserID: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range []CartItem{
+		{ProductID: tent.Id, Quantity: 2, StartDate: days(3), EndDate: days(5)},
+		{ProductID: stove.Id, Quantity: 1, StartDate: days(3), EndDate: days(5)},
+	} {
+		if _, err := carts.AddItemToCart(account.ID, item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	guest, err := carts.CreateGuestCart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range []CartItem{
+		{ProductID: tent.Id, Quantity: 3, StartDate: days(3), EndDate: days(5)},
+		{ProductID: stove.Id, Quantity: 1, StartDate: days(4), EndDate: days(6)},
+	} {
+		if _, err := carts.AddItemToCart(guest.ID, item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	merged, err := carts.MergeGuestCart(guest.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var quantities = map[string]int{}
+	for _, item := range merged.CartItem {
+		quantities[fmt.Sprint(item.ProductID, " ", item.StartDate)] = item.Quantity
+	}
+	var want = map[string]int{
+		fmt.Sprint(tent.Id, " ", days(3)):  3,
+		fmt.Sprint(stove.Id, " ", days(3)): 1,
+	}
+	if !reflect.DeepEqual(quantities, want) {
+		t.Fatalf("account cart lines = %v, want %v", quantities, want)
+	}
+
+	var got = map[int][2]int{}
+	for _, adj := range merged.MergeAdjustments {
+		got[adj.ProductID] = [2]int{adj.Requested, adj.Merged}
+	}
+	var wantAdj = map[int][2]int{tent.Id: {3, 1}, stove.Id: {1, 0}}
+	if !reflect.DeepEqual(got, wantAdj) {
+		t.Fatalf("merge adjustments = %v, want %v", got, wantAdj)
+	}
+}
+
+func TestMigrateActiveCartsRunsOnce(t *testing.T) {
+	var db = newTestDB(t)
+
+	var older, newer, guest = Cart{UserID: 10}, Cart{UserID: 10}, Cart{UserID: 0}
+	for _, cart := range []*Cart{&older, &newer, &guest} {
+		mustCreate(t, db, cart)
+	}
+
+	migrateActiveCarts(db)
+
+	var byID = map[int]Cart{}
+	var carts = []Cart{}
+	db.Find(&carts)
+	for _, cart := range carts {
+		byID[cart.ID] = cart
+	}
+	if got := byID[newer.ID]; got.ActiveUserID == nil || *got.ActiveUserID != 10 || got.Status != CartStatusActive {
+		t.Fatalf("newest cart = %+v, want the active cart of user 10", got)
+	}
+	if got := byID[older.ID]; got.ActiveUserID != nil || got.Status != CartStatusArchived {
+		t.Fatalf("older cart = %+v, want archived", got)
+	}
+	if got := byID[guest.ID]; got.ActiveUserID != nil || got.Status != CartStatusActive {
+		t.Fatalf("guest cart = %+v, want left alone", got)
+	}
+
+	var later = Cart{UserID: 11}
+	mustCreate(t, db, &later)
+	migrateActiveCarts(db)
+
+	var again = Cart{}
+	db.First(&again, later.ID)
+	if again.ActiveUserID != nil || again.Status != CartStatusActive {
+		t.Fatalf("second run changed cart %+v, want the migration to run once", again)
+	}
+}
+
+func TestCreateGuestCart(t *testing.T) {
+	var db = newTestDB(t)
+	var carts = NewCartModel(db)
+	var product = newTestProduct(t, db, 2, 100)
+
+	guest, err := carts.CreateGuestCart()
+	if err != nil {
+		t.Fatalf("CreateGuestCart: %v", err)
+	}
+	if guest.UserID != 0 || !carts.IsGuestCart(guest.ID) {
+		t.Fatalf("guest cart = %+v, want a cart without a user", guest)
+	}
+	if _, err := carts.AddItemToCart(guest.ID, CartItem{ProductID: product.Id, Quantity: 1, StartDate: days(3), EndDate: days(5)}); err != nil {
+		t.Fatalf("adding to the guest cart: %v", err)
+	}
+	if db.Migrator().HasConstraint(&Cart{}, "fk_users_carts") {
+		t.Fatal("carts.user_id must not reference users")
+	}
+}
+
+func TestAddItemToCartValidatesLine(t *testing.T) {
+	var db = newTestDB(t)
+	var carts = NewCartModel(db)
+	var product = newTestProduct(t, db, 3, 100)
+
+	cart, _, err := carts.CreateCart(Cart{UserID: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := carts.AddItemToCart(cart.ID, CartItem{ProductID: product.Id, Quantity: 2, StartDate: days(3), EndDate: days(5)}); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name string
+		item CartItem
+		want error
+	}{
+		{"unknown product", CartItem{ProductID: 999, Quantity: 1, StartDate: days(3), EndDate: days(5)}, ErrProductNotFound},
+		{"zero quantity", CartItem{ProductID: product.Id, Quantity: 0, StartDate: days(3), EndDate: days(5)}, ErrInvalidQuantity},
+		{"negative quantity", CartItem{ProductID: product.Id, Quantity: -1, StartDate: days(3), EndDate: days(5)}, ErrInvalidQuantity},
+		{"no dates", CartItem{ProductID: product.Id, Quantity: 1}, ErrInvalidDates},
+		{"start in the past", CartItem{ProductID: product.Id, Quantity: 1, StartDate: days(-1), EndDate: days(5)}, ErrInvalidDates},
+		{"end before start", CartItem{ProductID: product.Id, Quantity: 1, StartDate: days(5), EndDate: days(3)}, ErrInvalidDates},
+		{"more than the stock", CartItem{ProductID: product.Id, Quantity: 4, StartDate: days(10), EndDate: days(11)}, ErrInsufficientStock},
+		{"overlapping line already takes the stock", CartItem{ProductID: product.Id, Quantity: 2, StartDate: days(4), EndDate: days(6)}, ErrInsufficientStock},
+		{"fits next to the overlapping line", CartItem{ProductID: product.Id, Quantity: 1, StartDate: days(4), EndDate: days(6)}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := carts.AddItemToCart(cart.ID, tt.item); !errors.Is(err, tt.want) {
+				t.Fatalf("AddItemToCart err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateCartItemValidatesLine(t *testing.T) {
+	var db = newTestDB(t)
+	var carts = NewCartModel(db)
+	var product = newTestProduct(t, db, 3, 100)
+
+	cart, _, _ := carts.CreateCart(Cart{UserID: 10})
+	item, err := carts.AddItemToCart(cart.ID, CartItem{ProductID: product.Id, Quantity: 1, StartDate: days(3), EndDate: days(5)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := carts.UpdateCartItem(cart.ID, item.ID, CartItem{Quantity: 4}); !errors.Is(err, ErrInsufficientStock) {
+		t.Fatalf("raising past the stock = %v, want ErrInsufficientStock", err)
+	}
+	if _, err := carts.UpdateCartItem(cart.ID, item.ID, CartItem{Quantity: 3}); err != nil {
+		t.Fatalf("the line itself must not count against its new quantity: %v", err)
+	}
+	if _, err := carts.UpdateCartItem(cart.ID, 999, CartItem{Quantity: 1}); !errors.Is(err, ErrCartItemNotFound) {
+		t.Fatalf("unknown item = %v, want ErrCartItemNotFound", err)
+	}
+}
+
+func TestGetItemsInCartFlagsChanges(t *testing.T) {
+	var db = newTestDB(t)
+	var carts = NewCartModel(db)
+	var tent = newTestProduct(t, db, 2, 100)
+	var stove = newTestProduct(t, db, 2, 50)
+
+	cart, _, _ := carts.CreateCart(Cart{UserID: 10})
+	carts.AddItemToCart(cart.ID, CartItem{ProductID: tent.Id, Quantity: 2, StartDate: days(3), EndDate: days(5)})
+	carts.AddItemToCart(cart.ID, CartItem{ProductID: stove.Id, Quantity: 1, StartDate: days(3), EndDate: days(5)})
+
+	db.Model(&Product{}).Where("id = ?", tent.Id).Update("price", 120)
+	db.Model(&Product{}).Where("id = ?", tent.Id).Update("stock", 1)
+
+	var items = carts.GetItemsInCart(cart.ID, pagination.Page{Limit: 10})
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+	for _, item := range items {
+		switch item.ProductID {
+		case tent.Id:
+			if !item.PriceChanged || item.CurrentPrice != 120 || item.UnitPrice != 100 {
+				t.Errorf("tent price change not flagged: %+v", item)
+			}
+			if item.Available || item.AvailableQuantity != 1 {
+				t.Errorf("tent should be short of stock: %+v", item)
+			}
+		case stove.Id:
+			if item.PriceChanged || !item.Available {
+				t.Errorf("stove did not change: %+v", item)
+			}
+		}
+	}
+}
+
+func TestRemoveCartItems(t *testing.T) {
+	var db = newTestDB(t)
+	var carts = NewCartModel(db)
+	var product = newTestProduct(t, db, 5, 100)
+
+	cart, _, err := carts.CreateCart(Cart{UserID: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := carts.AddItemToCart(cart.ID, CartItem{ProductID: product.Id, Quantity: 1, StartDate: days(3), EndDate: days(5)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := carts.RemoveCartItem(cart.ID, item.ID+1); !errors.Is(err, ErrCartItemNotFound) {
+		t.Fatalf("removing a missing line = %v, want ErrCartItemNotFound", err)
+	}
+
+	db.Model(&Cart{}).Where("id = ?", cart.ID).Update("status", CartStatusArchived)
+	if err := carts.RemoveCartItem(cart.ID, item.ID); !errors.Is(err, ErrCartClosed) {
+		t.Fatalf("removing a line from an archived cart = %v, want ErrCartClosed", err)
+	}
+	if err := carts.RemoveAllItemsFromCart(cart.ID); !errors.Is(err, ErrCartClosed) {
+		t.Fatalf("emptying an archived cart = %v, want ErrCartClosed", err)
+	}
+
+	db.Model(&Cart{}).Where("id = ?", cart.ID).Update("status", CartStatusActive)
+	if err := carts.RemoveCartItem(cart.ID, item.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := carts.RemoveCartItem(cart.ID, item.ID); !errors.Is(err, ErrCartItemNotFound) {
+		t.Fatalf("removing a line twice = %v, want ErrCartItemNotFound", err)
+	}
+}
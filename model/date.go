@@ -0,0 +1,98 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const DateLayout = "2006-01-02"
+
+// Date is a calendar day used for rental periods. It reads and writes
+// "2006-01-02" in JSON, form values and the database.
+type Date struct {
+	time.Time
+}
+
+func NewDate(t time.Time) Date {
+	var y, m, d = t.Date()
+	return Date{time.Date(y, m, d, 0, 0, 0, 0, time.Local)}
+}
+
+func ParseDate(value string) (Date, error) {
+	t, err := time.ParseInLocation(DateLayout, strings.TrimSpace(value), time.Local)
+	if err != nil {
+		return Date{}, err
+	}
+	return Date{t}, nil
+}
+
+func Today() Date {
+	return NewDate(time.Now())
+}
+
+func (d Date) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.Format(DateLayout)
+}
+
+// Days returns the inclusive number of rental days from d to end.
+func (d Date) Days(end Date) int {
+	return int(end.Sub(d.Time).Hours()/24) + 1
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(d.String())
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var raw = strings.Trim(string(data), `"`)
+	if raw == "" || raw == "null" {
+		*d = Date{}
+		return nil
+	}
+	parsed, err := ParseDate(raw)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func (d *Date) UnmarshalParam(param string) error {
+	return d.UnmarshalJSON([]byte(param))
+}
+
+func (d Date) Value() (driver.Value, error) {
+	if d.IsZero() {
+		return nil, nil
+	}
+	return d.String(), nil
+}
+
+func (d *Date) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*d = Date{}
+	case time.Time:
+		*d = NewDate(v)
+	case []byte:
+		return d.UnmarshalJSON(v)
+	case string:
+		return d.UnmarshalJSON([]byte(v))
+	default:
+		return errors.New("unsupported date value")
+	}
+	return nil
+}
+
+func (Date) GormDataType() string {
+	return "date"
+}
@@ -0,0 +1,719 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"rentcamp/helper"
+	"rentcamp/pagination"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	FulfilmentPickup   = "pickup"
+	FulfilmentDelivery = "delivery"
+)
+
+const (
+	SlotKindDelivery   = "delivery"
+	SlotKindCollection = "collection"
+)
+
+const slotTimeLayout = "15:04"
+
+var (
+	ErrDeliveryZoneNotFound   = errors.New("delivery zone not found")
+	ErrInvalidDeliveryZone    = errors.New("a delivery zone needs a branch, a name, a radius above 0 and fees that are not negative")
+	ErrDeliverySlotNotFound   = errors.New("delivery slot not found")
+	ErrInvalidDeliverySlot    = errors.New("a delivery slot needs a branch, a kind of delivery or collection, a date, a start and end time as HH:MM and a capacity of at least 1")
+	ErrDeliverySlotInUse      = errors.New("delivery slot already has bookings")
+	ErrDeliverySlotFull       = errors.New("delivery slot is full, please choose another time")
+	ErrDeliverySlotMismatch   = errors.New("the delivery slot must be on the first rental day and the collection slot on the last, both at the order's branch")
+	ErrInvalidFulfilment      = errors.New("fulfilment must be pickup or delivery")
+	ErrInvalidDeliveryAddress = errors.New("delivery needs an address, valid coordinates, a delivery slot and a collection slot")
+	ErrOutsideDeliveryArea    = errors.New("we do not deliver to that location")
+	ErrNotDeliveryOrder       = errors.New("order is not for delivery")
+	ErrInvalidRunKind         = errors.New("kind must be delivery or collection")
+	ErrDriverNotFound         = errors.New("driver not found")
+	ErrNotDriverRun           = errors.New("order is not on one of your runs")
+)
+
+// deliveryBookedStatuses are the order statuses that take up room in a
+// delivery or collection slot.
+var deliveryBookedStatuses = []string{OrderStatusPendingPayment, OrderStatusConfirmed, OrderStatusPickedUp, OrderStatusReturned}
+
+// DeliveryZone is the area around a branch within RadiusKm. The fee is
+// BaseFee plus FeePerKm for every kilometre from the branch, so a zone can
+// charge a flat fee, a distance based fee or both. Where zones overlap the
+// smallest one applies.
+type DeliveryZone struct {
+	Id        int            `gorm:"primaryKey" json:"id"`
+	BranchId  int            `gorm:"index;not null" json:"branch_id"`
+	Name      string         `gorm:"type:varchar(100);not null" json:"name"`
+	RadiusKm  float64        `gorm:"type:decimal(6,2);not null" json:"radius_km"`
+	BaseFee   int            `gorm:"not null" json:"base_fee"`
+	FeePerKm  int            `gorm:"not null" json:"fee_per_km"`
+	CreatedAt time.Time      `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+}
+
+// DeliverySlot is a time window in which a branch delivers gear to
+// campsites or collects it again. Capacity is the number of orders it can
+// serve.
+type DeliverySlot struct {
+	Id        int       `gorm:"primaryKey" json:"id"`
+	BranchId  int       `gorm:"index;not null" json:"branch_id"`
+	Kind      string    `gorm:"type:varchar(20);not null" json:"kind"`
+	Date      Date      `gorm:"index;not null" json:"date"`
+	StartTime string    `gorm:"type:varchar(5);not null" json:"start_time"`
+	EndTime   string    `gorm:"type:varchar(5);not null" json:"end_time"`
+	Capacity  int       `gorm:"not null" json:"capacity"`
+	Booked    int       `gorm:"-" json:"booked"`
+	Remaining int       `gorm:"-" json:"remaining"`
+	CreatedAt time.Time `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// OrderDelivery is where and when a delivery order is dropped off and
+// collected, and which drivers do each run.
+type OrderDelivery struct {
+	OrderId            int           `gorm:"primaryKey;autoIncrement:false" json:"order_id"`
+	ZoneId             int           `gorm:"index" json:"zone_id"`
+	Address            string        `gorm:"type:varchar(255);not null" json:"address"`
+	Latitude           float64       `gorm:"type:decimal(9,6);not null" json:"latitude"`
+	Longitude          float64       `gorm:"type:decimal(9,6);not null" json:"longitude"`
+	Note               string        `gorm:"type:varchar(255)" json:"note"`
+	DistanceKm         float64       `gorm:"type:decimal(7,2)" json:"distance_km"`
+	Fee                int           `json:"fee"`
+	DeliverySlotId     int           `gorm:"index;not null" json:"delivery_slot_id"`
+	CollectionSlotId   int           `gorm:"index;not null" json:"collection_slot_id"`
+	DeliveryDriverId   int           `gorm:"index" json:"delivery_driver_id"`
+	CollectionDriverId int           `gorm:"index" json:"collection_driver_id"`
+	CreatedAt          time.Time     `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt          time.Time     `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"updated_at"`
+	DeliverySlot       *DeliverySlot `gorm:"foreignKey:DeliverySlotId" json:"delivery_slot,omitempty"`
+	CollectionSlot     *DeliverySlot `gorm:"foreignKey:CollectionSlotId" json:"collection_slot,omitempty"`
+}
+
+// DeliveryAddress is what the customer enters at checkout to have the
+// order delivered.
+type DeliveryAddress struct {
+	Address          string  `json:"address" form:"address"`
+	Latitude         float64 `json:"latitude" form:"latitude"`
+	Longitude        float64 `json:"longitude" form:"longitude"`
+	Note             string  `json:"note" form:"note"`
+	DeliverySlotId   int     `json:"delivery_slot_id" form:"delivery_slot_id"`
+	CollectionSlotId int     `json:"collection_slot_id" form:"collection_slot_id"`
+}
+
+type DeliveryQuote struct {
+	Branch     Branch       `json:"branch"`
+	Zone       DeliveryZone `json:"zone"`
+	DistanceKm float64      `json:"distance_km"`
+	Fee        int          `json:"fee"`
+}
+
+// DeliveryRun is one stop on a driver's sheet: dropping off or collecting
+// the gear of one order.
+type DeliveryRun struct {
+	Kind        string       `json:"kind"`
+	Slot        DeliverySlot `json:"slot"`
+	OrderId     int          `json:"order_id"`
+	OrderStatus string       `json:"order_status"`
+	UserId      int          `json:"user_id"`
+	BranchId    int          `json:"branch_id"`
+	Address     string       `json:"address"`
+	Latitude    float64      `json:"latitude"`
+	Longitude   float64      `json:"longitude"`
+	Note        string       `json:"note"`
+	DriverId    int          `json:"driver_id"`
+	Done        bool         `json:"done"`
+	Items       []OrderItem  `json:"items"`
+}
+
+type DeliveryRunFilter struct {
+	Date       Date
+	BranchId   int
+	DriverId   int
+	Unassigned bool
+}
+
+type DeliveryModelInterface interface {
+	InsertZone(zone DeliveryZone) (*DeliveryZone, error)
+	UpdateZone(zone DeliveryZone) (*DeliveryZone, error)
+	DeleteZone(zoneID int) error
+	SelectZones(branchID int, page pagination.Page) []DeliveryZone
+	Quote(branchID int, lat float64, lng float64) (*DeliveryQuote, error)
+	InsertSlot(slot DeliverySlot) (*DeliverySlot, error)
+	UpdateSlot(slot DeliverySlot) (*DeliverySlot, error)
+	DeleteSlot(slotID int) error
+	SelectSlots(branchID int, kind string, from Date, to Date, page pagination.Page) []DeliverySlot
+	Runs(filter DeliveryRunFilter) ([]DeliveryRun, error)
+	AssignDriver(orderID int, kind string, driverID int) (*OrderDelivery, error)
+}
+
+type DeliveryModel struct {
+	db *gorm.DB
+}
+
+func NewDeliveryModel(db *gorm.DB) DeliveryModelInterface {
+	return &DeliveryModel{
+		db: db,
+	}
+}
+
+func validZone(zone DeliveryZone) bool {
+	return zone.BranchId != 0 && strings.TrimSpace(zone.Name) != "" &&
+		zone.RadiusKm > 0 && zone.BaseFee >= 0 && zone.FeePerKm >= 0
+}
+
+func (dm *DeliveryModel) InsertZone(zone DeliveryZone) (*DeliveryZone, error) {
+	if !validZone(zone) {
+		return nil, ErrInvalidDeliveryZone
+	}
+	if _, err := pickupBranch(dm.db, zone.BranchId); err != nil {
+		return nil, err
+	}
+
+	zone.Id = 0
+	if err := dm.db.Create(&zone).Error; err != nil {
+		logrus.Error("Delivery Model: Error creating zone, ", err.Error())
+		return nil, err
+	}
+
+	return &zone, nil
+}
+
+func (dm *DeliveryModel) UpdateZone(zone DeliveryZone) (*DeliveryZone, error) {
+	if !validZone(zone) {
+		return nil, ErrInvalidDeliveryZone
+	}
+	if _, err := dm.selectZone(zone.Id); err != nil {
+		return nil, err
+	}
+	if _, err := pickupBranch(dm.db, zone.BranchId); err != nil {
+		return nil, err
+	}
+
+	if err := dm.db.Model(&DeliveryZone{}).Where("id = ?", zone.Id).Updates(map[string]any{
+		"branch_id":  zone.BranchId,
+		"name":       zone.Name,
+		"radius_km":  zone.RadiusKm,
+		"base_fee":   zone.BaseFee,
+		"fee_per_km": zone.FeePerKm,
+	}).Error; err != nil {
+		logrus.Error("Delivery Model: Error updating zone, ", err.Error())
+		return nil, err
+	}
+
+	return dm.selectZone(zone.Id)
+}
+
+// DeleteZone only stops new orders from using the zone. Orders delivered
+// to it keep their fee.
+func (dm *DeliveryModel) DeleteZone(zoneID int) error {
+	if _, err := dm.selectZone(zoneID); err != nil {
+		return err
+	}
+
+	if err := dm.db.Where("id = ?", zoneID).Delete(&DeliveryZone{}).Error; err != nil {
+		logrus.Error("Delivery Model: Error deleting zone, ", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (dm *DeliveryModel) selectZone(zoneID int) (*DeliveryZone, error) {
+	var res = DeliveryZone{}
+	if err := dm.db.Where("id = ?", zoneID).First(&res).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeliveryZoneNotFound
+		}
+		logrus.Error("Delivery Model: Error finding zone, ", err.Error())
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (dm *DeliveryModel) SelectZones(branchID int, page pagination.Page) []DeliveryZone {
+	var res = []DeliveryZone{}
+	var qry = dm.db
+	if branchID != 0 {
+		qry = qry.Where("branch_id = ?", branchID)
+	}
+	if err := page.Keyset(qry, "id", false).Find(&res).Error; err != nil {
+		logrus.Error("Delivery Model: Error listing zones, ", err.Error())
+		return nil
+	}
+	return res
+}
+
+// Quote prices delivery to a point from the given branch. Without a branch
+// the cheapest branch that delivers there is used.
+func (dm *DeliveryModel) Quote(branchID int, lat float64, lng float64) (*DeliveryQuote, error) {
+	res, err := quoteDelivery(dm.db, branchID, lat, lng)
+	if err != nil && !errors.Is(err, ErrOutsideDeliveryArea) && !errors.Is(err, ErrBranchNotFound) {
+		logrus.Error("Delivery Model: Error quoting delivery, ", err.Error())
+	}
+	return res, err
+}
+
+func quoteDelivery(tx *gorm.DB, branchID int, lat float64, lng float64) (*DeliveryQuote, error) {
+	var branches = []Branch{}
+	var qry = tx
+	if branchID != 0 {
+		qry = qry.Where("id = ?", branchID)
+	}
+	if err := qry.Find(&branches).Error; err != nil {
+		return nil, err
+	}
+	if branchID != 0 && len(branches) == 0 {
+		return nil, ErrBranchNotFound
+	}
+
+	var zones = []DeliveryZone{}
+	var ids = []int{}
+	for _, branch := range branches {
+		ids = append(ids, branch.Id)
+	}
+	if err := tx.Where("branch_id IN ?", ids).Order("radius_km, id").Find(&zones).Error; err != nil {
+		return nil, err
+	}
+
+	var best *DeliveryQuote
+	for _, branch := range branches {
+		var distance = helper.DistanceKm(lat, lng, branch.Latitude, branch.Longitude)
+		for _, zone := range zones {
+			if zone.BranchId != branch.Id || distance > zone.RadiusKm {
+				continue
+			}
+
+			var quote = DeliveryQuote{
+				Branch:     branch,
+				Zone:       zone,
+				DistanceKm: math.Round(distance*100) / 100,
+				Fee:        zone.BaseFee + int(math.Round(distance*float64(zone.FeePerKm))),
+			}
+			if best == nil || quote.Fee < best.Fee || (quote.Fee == best.Fee && quote.DistanceKm < best.DistanceKm) {
+				best = &quote
+			}
+			// Zones are ordered by radius, so the first match is the
+			// smallest zone of this branch.
+			break
+		}
+	}
+	if best == nil {
+		return nil, ErrOutsideDeliveryArea
+	}
+
+	return best, nil
+}
+
+func validSlot(slot DeliverySlot) bool {
+	if slot.BranchId == 0 || slot.Date.IsZero() || slot.Capacity < 1 {
+		return false
+	}
+	if slot.Kind != SlotKindDelivery && slot.Kind != SlotKindCollection {
+		return false
+	}
+	start, err := time.Parse(slotTimeLayout, slot.StartTime)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(slotTimeLayout, slot.EndTime)
+	if err != nil {
+		return false
+	}
+	return end.After(start)
+}
+
+func (dm *DeliveryModel) InsertSlot(slot DeliverySlot) (*DeliverySlot, error) {
+	if !validSlot(slot) {
+		return nil, ErrInvalidDeliverySlot
+	}
+	if _, err := pickupBranch(dm.db, slot.BranchId); err != nil {
+		return nil, err
+	}
+
+	slot.Id = 0
+	if err := dm.db.Create(&slot).Error; err != nil {
+		logrus.Error("Delivery Model: Error creating slot, ", err.Error())
+		return nil, err
+	}
+
+	return dm.selectSlot(slot.Id)
+}
+
+// UpdateSlot changes a slot. Once orders are booked into it only the
+// capacity may change, and not below the number of bookings.
+func (dm *DeliveryModel) UpdateSlot(slot DeliverySlot) (*DeliverySlot, error) {
+	if !validSlot(slot) {
+		return nil, ErrInvalidDeliverySlot
+	}
+	current, err := dm.selectSlot(slot.Id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := pickupBranch(dm.db, slot.BranchId); err != nil {
+		return nil, err
+	}
+	if current.Booked > 0 && (slot.Capacity < current.Booked || slot.BranchId != current.BranchId || slot.Kind != current.Kind ||
+		slot.Date.String() != current.Date.String() || slot.StartTime != current.StartTime || slot.EndTime != current.EndTime) {
+		return nil, ErrDeliverySlotInUse
+	}
+
+	if err := dm.db.Model(&DeliverySlot{}).Where("id = ?", slot.Id).Updates(map[string]any{
+		"branch_id":  slot.BranchId,
+		"kind":       slot.Kind,
+		"date":       slot.Date,
+		"start_time": slot.StartTime,
+		"end_time":   slot.EndTime,
+		"capacity":   slot.Capacity,
+	}).Error; err != nil {
+		logrus.Error("Delivery Model: Error updating slot, ", err.Error())
+		return nil, err
+	}
+
+	return dm.selectSlot(slot.Id)
+}
+
+// DeleteSlot removes a slot no order was ever booked into. Cancelled
+// orders still point at their slots.
+func (dm *DeliveryModel) DeleteSlot(slotID int) error {
+	if _, err := dm.selectSlot(slotID); err != nil {
+		return err
+	}
+
+	var orders int64
+	if err := dm.db.Model(&OrderDelivery{}).Where("delivery_slot_id = ? OR collection_slot_id = ?", slotID, slotID).Count(&orders).Error; err != nil {
+		return err
+	}
+	if orders > 0 {
+		return ErrDeliverySlotInUse
+	}
+
+	if err := dm.db.Where("id = ?", slotID).Delete(&DeliverySlot{}).Error; err != nil {
+		logrus.Error("Delivery Model: Error deleting slot, ", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (dm *DeliveryModel) selectSlot(slotID int) (*DeliverySlot, error) {
+	var res = DeliverySlot{}
+	if err := dm.db.Where("id = ?", slotID).First(&res).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeliverySlotNotFound
+		}
+		logrus.Error("Delivery Model: Error finding slot, ", err.Error())
+		return nil, err
+	}
+
+	var slots = []DeliverySlot{res}
+	if err := countBookings(dm.db, slots); err != nil {
+		logrus.Error("Delivery Model: Error counting slot bookings, ", err.Error())
+		return nil, err
+	}
+	return &slots[0], nil
+}
+
+// SelectSlots lists slots from one date to another in time order, with
+// how much room each has left.
+func (dm *DeliveryModel) SelectSlots(branchID int, kind string, from Date, to Date, page pagination.Page) []DeliverySlot {
+	var res = []DeliverySlot{}
+	var qry = dm.db.Where("date BETWEEN ? AND ?", from, to)
+	if branchID != 0 {
+		qry = qry.Where("branch_id = ?", branchID)
+	}
+	if kind != "" {
+		qry = qry.Where("kind = ?", kind)
+	}
+
+	limit, offset := page.Window()
+	if err := qry.Order("date, start_time, id").Limit(limit).Offset(offset).Find(&res).Error; err != nil {
+		logrus.Error("Delivery Model: Error listing slots, ", err.Error())
+		return nil
+	}
+	if err := countBookings(dm.db, res); err != nil {
+		logrus.Error("Delivery Model: Error counting slot bookings, ", err.Error())
+		return nil
+	}
+
+	return res
+}
+
+// countBookings fills in Booked and Remaining for the slots.
+func countBookings(tx *gorm.DB, slots []DeliverySlot) error {
+	if len(slots) == 0 {
+		return nil
+	}
+
+	var ids = []int{}
+	for _, slot := range slots {
+		ids = append(ids, slot.Id)
+	}
+
+	var booked = map[int]int{}
+	for _, column := range []string{"delivery_slot_id", "collection_slot_id"} {
+		var counts = []struct {
+			SlotId int
+			Booked int
+		}{}
+		if err := tx.Model(&OrderDelivery{}).
+			Joins("JOIN orders ON orders.id = order_deliveries.order_id").
+			Where("order_deliveries."+column+" IN ? AND orders.status IN ?", ids, deliveryBookedStatuses).
+			Select("order_deliveries." + column + " AS slot_id, COUNT(*) AS booked").
+			Group("order_deliveries." + column).Scan(&counts).Error; err != nil {
+			return err
+		}
+		for _, count := range counts {
+			booked[count.SlotId] += count.Booked
+		}
+	}
+
+	for i := range slots {
+		slots[i].Booked = booked[slots[i].Id]
+		slots[i].Remaining = max(slots[i].Capacity-slots[i].Booked, 0)
+	}
+	return nil
+}
+
+// deliveryBranch is the branch a delivery order is served from when the
+// customer did not choose one: the branch of the delivery slot.
+func deliveryBranch(tx *gorm.DB, address *DeliveryAddress) (int, error) {
+	if address == nil || address.DeliverySlotId == 0 {
+		return 0, ErrInvalidDeliveryAddress
+	}
+
+	var slot = DeliverySlot{}
+	if err := tx.Where("id = ?", address.DeliverySlotId).First(&slot).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrDeliverySlotNotFound
+		}
+		return 0, err
+	}
+	return slot.BranchId, nil
+}
+
+// bookDelivery prices the delivery of an order from the branch and takes a
+// place in both slots. The delivery slot has to be on the first rental day
+// and the collection slot on the last. Slot rows are locked so two
+// checkouts cannot both take the last place.
+func bookDelivery(tx *gorm.DB, branchID int, address *DeliveryAddress, first Date, last Date) (*OrderDelivery, error) {
+	if address == nil || strings.TrimSpace(address.Address) == "" ||
+		address.Latitude < -90 || address.Latitude > 90 || address.Longitude < -180 || address.Longitude > 180 ||
+		address.DeliverySlotId == 0 || address.CollectionSlotId == 0 {
+		return nil, ErrInvalidDeliveryAddress
+	}
+
+	quote, err := quoteDelivery(tx, branchID, address.Latitude, address.Longitude)
+	if err != nil {
+		return nil, err
+	}
+
+	var slots = []DeliverySlot{}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", []int{address.DeliverySlotId, address.CollectionSlotId}).Find(&slots).Error; err != nil {
+		return nil, err
+	}
+	if err := countBookings(tx, slots); err != nil {
+		return nil, err
+	}
+
+	var wanted = []struct {
+		id   int
+		kind string
+		date Date
+	}{
+		{address.DeliverySlotId, SlotKindDelivery, first},
+		{address.CollectionSlotId, SlotKindCollection, last},
+	}
+	for _, want := range wanted {
+		var slot *DeliverySlot
+		for i := range slots {
+			if slots[i].Id == want.id {
+				slot = &slots[i]
+			}
+		}
+		if slot == nil {
+			return nil, ErrDeliverySlotNotFound
+		}
+		if slot.Kind != want.kind || slot.BranchId != branchID || slot.Date.String() != want.date.String() {
+			return nil, ErrDeliverySlotMismatch
+		}
+		if slot.Remaining < 1 {
+			return nil, ErrDeliverySlotFull
+		}
+	}
+
+	return &OrderDelivery{
+		ZoneId:           quote.Zone.Id,
+		Address:          strings.TrimSpace(address.Address),
+		Latitude:         address.Latitude,
+		Longitude:        address.Longitude,
+		Note:             address.Note,
+		DistanceKm:       quote.DistanceKm,
+		Fee:              quote.Fee,
+		DeliverySlotId:   address.DeliverySlotId,
+		CollectionSlotId: address.CollectionSlotId,
+	}, nil
+}
+
+// Runs builds the driver sheet for a day: every paid delivery order that is
+// dropped off or collected that day, in slot order.
+func (dm *DeliveryModel) Runs(filter DeliveryRunFilter) ([]DeliveryRun, error) {
+	var slots = []DeliverySlot{}
+	var qry = dm.db.Where("date = ?", filter.Date)
+	if filter.BranchId != 0 {
+		qry = qry.Where("branch_id = ?", filter.BranchId)
+	}
+	if err := qry.Find(&slots).Error; err != nil {
+		logrus.Error("Delivery Model: Error listing slots, ", err.Error())
+		return nil, err
+	}
+
+	var res = []DeliveryRun{}
+	if len(slots) == 0 {
+		return res, nil
+	}
+
+	var bySlot = map[int]DeliverySlot{}
+	var ids = []int{}
+	for _, slot := range slots {
+		bySlot[slot.Id] = slot
+		ids = append(ids, slot.Id)
+	}
+
+	var deliveries = []OrderDelivery{}
+	if err := dm.db.Where("delivery_slot_id IN ? OR collection_slot_id IN ?", ids, ids).Find(&deliveries).Error; err != nil {
+		logrus.Error("Delivery Model: Error listing deliveries, ", err.Error())
+		return nil, err
+	}
+
+	var orderIDs = []int{}
+	for _, delivery := range deliveries {
+		orderIDs = append(orderIDs, delivery.OrderId)
+	}
+	var orders = []Order{}
+	if len(orderIDs) > 0 {
+		if err := dm.db.Preload("Items.Product").Where("id IN ? AND status IN ?", orderIDs,
+			[]string{OrderStatusConfirmed, OrderStatusPickedUp, OrderStatusReturned}).Find(&orders).Error; err != nil {
+			logrus.Error("Delivery Model: Error listing delivery orders, ", err.Error())
+			return nil, err
+		}
+	}
+	var byOrder = map[int]Order{}
+	for _, order := range orders {
+		byOrder[order.Id] = order
+	}
+
+	for _, delivery := range deliveries {
+		order, found := byOrder[delivery.OrderId]
+		if !found {
+			continue
+		}
+
+		var legs = []struct {
+			kind     string
+			slotID   int
+			driverID int
+			done     bool
+		}{
+			{SlotKindDelivery, delivery.DeliverySlotId, delivery.DeliveryDriverId, order.Status != OrderStatusConfirmed},
+			{SlotKindCollection, delivery.CollectionSlotId, delivery.CollectionDriverId, order.Status == OrderStatusReturned},
+		}
+		for _, leg := range legs {
+			slot, found := bySlot[leg.slotID]
+			if !found {
+				continue
+			}
+			if filter.DriverId != 0 && leg.driverID != filter.DriverId {
+				continue
+			}
+			if filter.Unassigned && leg.driverID != 0 {
+				continue
+			}
+
+			res = append(res, DeliveryRun{
+				Kind:        leg.kind,
+				Slot:        slot,
+				OrderId:     order.Id,
+				OrderStatus: order.Status,
+				UserId:      order.UserId,
+				BranchId:    order.BranchId,
+				Address:     delivery.Address,
+				Latitude:    delivery.Latitude,
+				Longitude:   delivery.Longitude,
+				Note:        delivery.Note,
+				DriverId:    leg.driverID,
+				Done:        leg.done,
+				Items:       order.Items,
+			})
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Slot.StartTime != res[j].Slot.StartTime {
+			return res[i].Slot.StartTime < res[j].Slot.StartTime
+		}
+		return res[i].OrderId < res[j].OrderId
+	})
+
+	return res, nil
+}
+
+// AssignDriver sets the driver of the delivery or collection run of an
+// order. Only active staff with the driver role can be assigned; a
+// driverID of 0 leaves the run unassigned.
+func (dm *DeliveryModel) AssignDriver(orderID int, kind string, driverID int) (*OrderDelivery, error) {
+	var column string
+	switch kind {
+	case SlotKindDelivery:
+		column = "delivery_driver_id"
+	case SlotKindCollection:
+		column = "collection_driver_id"
+	default:
+		return nil, ErrInvalidRunKind
+	}
+
+	if err := dm.db.Where("id = ?", orderID).First(&Order{}).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, err
+	}
+	if err := dm.db.Where("order_id = ?", orderID).First(&OrderDelivery{}).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotDeliveryOrder
+		}
+		return nil, err
+	}
+	if driverID != 0 {
+		if err := dm.db.Where("id = ? AND role = ? AND disabled = ?", driverID, helper.RoleDriver, false).First(&Admin{}).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrDriverNotFound
+			}
+			return nil, err
+		}
+	}
+
+	if err := dm.db.Model(&OrderDelivery{}).Where("order_id = ?", orderID).Update(column, driverID).Error; err != nil {
+		logrus.Error("Delivery Model: Error assigning driver, ", err.Error())
+		return nil, err
+	}
+
+	var res = OrderDelivery{}
+	if err := dm.db.Preload("DeliverySlot").Preload("CollectionSlot").Where("order_id = ?", orderID).First(&res).Error; err != nil {
+		logrus.Error("Delivery Model: Error finding delivery, ", err.Error())
+		return nil, err
+	}
+	return &res, nil
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"errors"
+	"rentcamp/helper"
+	"testing"
+)
+
+// newDeliveryOrder creates a confirmed delivery order whose delivery and
+// collection runs are driven by the given drivers.
+func newDeliveryOrder(t *testing.T, db *DeliveryModel, deliveryDriver int, collectionDriver int) Order {
+	t.Helper()
+	if err := db.db.Where("id = ?", 10).FirstOrCreate(&User{Id: 10, Name: "Camper", Email: "camper@example.com"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	var delivery = DeliverySlot{BranchId: mainBranch, Kind: SlotKindDelivery, Date: days(3), StartTime: "08:00", EndTime: "10:00", Capacity: 5}
+	var collection = DeliverySlot{BranchId: mainBranch, Kind: SlotKindCollection, Date: days(5), StartTime: "16:00", EndTime: "18:00", Capacity: 5}
+	mustCreate(t, db.db, &delivery)
+	mustCreate(t, db.db, &collection)
+	var order = Order{UserId: 10, Status: OrderStatusConfirmed, BranchId: mainBranch, Fulfilment: FulfilmentDelivery}
+	mustCreate(t, db.db, &order)
+	mustCreate(t, db.db, &OrderDelivery{OrderId: order.Id, Address: "Camp Ground", DeliverySlotId: delivery.Id, CollectionSlotId: collection.Id,
+		DeliveryDriverId: deliveryDriver, CollectionDriverId: collectionDriver})
+	return order
+}
+
+func TestAssignDriverRequiresDriverRole(t *testing.T) {
+	var db = newTestDB(t)
+	var deliveries = NewDeliveryModel(db).(*DeliveryModel)
+
+	var driver = Admin{Username: "dina", Password: "-", Role: helper.RoleDriver}
+	var owner = Admin{Username: "olga", Password: "-", Role: helper.RoleOwner}
+	var disabled = Admin{Username: "dodi", Password: "-", Role: helper.RoleDriver, Disabled: true}
+	for _, admin := range []*Admin{&driver, &owner, &disabled} {
+		mustCreate(t, db, admin)
+	}
+	var order = newDeliveryOrder(t, deliveries, 0, 0)
+
+	res, err := deliveries.AssignDriver(order.Id, SlotKindDelivery, driver.Id)
+	if err != nil || res.DeliveryDriverId != driver.Id {
+		t.Fatalf("AssignDriver = %+v, %v, want driver %d on the delivery run", res, err, driver.Id)
+	}
+	for _, admin := range []Admin{owner, disabled} {
+		if _, err := deliveries.AssignDriver(order.Id, SlotKindCollection, admin.Id); !errors.Is(err, ErrDriverNotFound) {
+			t.Fatalf("assigning %s: err = %v, want ErrDriverNotFound", admin.Username, err)
+		}
+	}
+}
+
+func TestHandoverByDriverIsLimitedToTheirRuns(t *testing.T) {
+	var db = newTestDB(t)
+	var deliveries = NewDeliveryModel(db).(*DeliveryModel)
+	var orders = NewOrdersModel(db)
+
+	var mine = newDeliveryOrder(t, deliveries, 7, 8)
+	mustCreate(t, db, &User{Id: 11, Name: "Hiker", Email: "hiker@example.com"})
+	var pickup = Order{UserId: 11, Status: OrderStatusConfirmed, BranchId: mainBranch}
+	mustCreate(t, db, &pickup)
+
+	if _, err := orders.MarkPickedUp(pickup.Id, 7); !errors.Is(err, ErrNotDriverRun) {
+		t.Fatalf("driver handing over a branch pickup: err = %v, want ErrNotDriverRun", err)
+	}
+	if _, err := orders.MarkPickedUp(mine.Id, 8); !errors.Is(err, ErrNotDriverRun) {
+		t.Fatalf("collection driver marking delivery: err = %v, want ErrNotDriverRun", err)
+	}
+	if res, err := orders.MarkPickedUp(mine.Id, 7); err != nil || res.Status != OrderStatusPickedUp {
+		t.Fatalf("delivery driver MarkPickedUp = %+v, %v, want picked up", res, err)
+	}
+
+	if _, err := orders.MarkReturned(mine.Id, 7); !errors.Is(err, ErrNotDriverRun) {
+		t.Fatalf("delivery driver marking return: err = %v, want ErrNotDriverRun", err)
+	}
+	if res, err := orders.MarkReturned(mine.Id, 8); err != nil || res.Status != OrderStatusReturned {
+		t.Fatalf("collection driver MarkReturned = %+v, %v, want returned", res, err)
+	}
+	if _, err := orders.MarkReturned(mine.Id, 8); !errors.Is(err, ErrOrderNotAllowed) {
+		t.Fatalf("returning twice: err = %v, want ErrOrderNotAllowed", err)
+	}
+
+	if res, err := orders.MarkPickedUp(pickup.Id, 0); err != nil || res.Status != OrderStatusPickedUp {
+		t.Fatalf("staff MarkPickedUp = %+v, %v, want picked up", res, err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func InitModel(config config.Config) *gorm.DB {
@@ -19,10 +20,99 @@ func InitModel(config config.Config) *gorm.DB {
 	return db
 }
 
-func Migrate(db *gorm.DB) {
-	db.AutoMigrate(&Admin{})
-	db.AutoMigrate(&Product{})
-	db.AutoMigrate(&User{})
-	db.AutoMigrate(&CartItem{})
-	db.AutoMigrate(&Cart{})
+// tables lists the models Migrate creates, in order.
+var tables = []any{
+	&Admin{},
+	&AdminInvite{},
+	&AdminRecoveryCode{},
+	&Setting{},
+	&Product{},
+	&User{},
+	&UserIdentity{},
+	&CartItem{},
+	&Cart{},
+	&ApiKey{},
+	&AuditLog{},
+	&Order{},
+	&OrderItem{},
+	&InventoryHold{},
+	&WishlistItem{},
+	&WaitlistEntry{},
+	&Review{},
+	&ReviewPhoto{},
+	&ProductQuestion{},
+	&SearchQuery{},
+	&ProductAffinity{},
+	&TripTemplate{},
+	&TripTemplateItem{},
+	&Branch{},
+	&BranchStock{},
+	&StockTransfer{},
+	&StockTransferEvent{},
+	&DeliveryZone{},
+	&DeliverySlot{},
+	&OrderDelivery{},
+}
+
+func Migrate(db *gorm.DB, config config.Config) {
+	for _, table := range tables {
+		db.AutoMigrate(table)
+	}
+
+	// Guest carts belong to no user, so carts.user_id cannot reference
+	// users. Older schemas have the foreign key AutoMigrate created for it.
+	if db.Migrator().HasConstraint(&Cart{}, "fk_users_carts") {
+		if err := db.Migrator().DropConstraint(&Cart{}, "fk_users_carts"); err != nil {
+			logrus.Error("Model : cannot drop the user foreign key of carts, ", err.Error())
+		}
+	}
+
+	if err := db.Model(&Admin{}).Where("role = ?", "admin").Update("role", "owner").Error; err != nil {
+		logrus.Error("Model : cannot migrate legacy admin role, ", err.Error())
+	}
+
+	migrateActiveCarts(db)
+	migrateBranches(db, Branch{
+		Name:      config.MainBranchName,
+		Address:   config.MainBranchAddress,
+		Latitude:  config.MainBranchLatitude,
+		Longitude: config.MainBranchLongitude,
+	})
+}
+
+// migrateActiveCarts picks the newest cart of users that had several before
+// the one-active-cart rule as their active cart and archives the rest. It
+// runs once; the setting records that it is done.
+func migrateActiveCarts(db *gorm.DB) {
+	if NewSettingsModel(db).Bool(SettingActiveCartsMigrated) {
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var latest = []int{}
+		if err := tx.Model(&Cart{}).Select("MAX(id)").
+			Where("status = ? AND user_id <> 0", CartStatusActive).
+			Group("user_id").Having("SUM(CASE WHEN active_user_id IS NULL THEN 0 ELSE 1 END) = 0").
+			Scan(&latest).Error; err != nil {
+			return err
+		}
+		if len(latest) > 0 {
+			if err := tx.Model(&Cart{}).Where("id IN ?", latest).
+				Update("active_user_id", gorm.Expr("user_id")).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&Cart{}).
+			Where("status = ? AND user_id <> 0 AND active_user_id IS NULL", CartStatusActive).
+			Update("status", CartStatusArchived).Error; err != nil {
+			return err
+		}
+
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).
+			Create(&Setting{Key: SettingActiveCartsMigrated, Value: "true"}).Error
+	})
+	if err != nil {
+		logrus.Error("Model : cannot migrate active carts, ", err.Error())
+	}
 }
@@ -0,0 +1,93 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/migrator"
+	"gorm.io/gorm/schema"
+)
+
+// testDialector runs the models on an in-memory SQLite database. It maps
+// the MySQL-only column types the models declare onto types SQLite accepts.
+type testDialector struct {
+	sqlite.Dialector
+}
+
+func (d testDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return sqlite.Migrator{Migrator: migrator.Migrator{Config: migrator.Config{
+		DB:                          db,
+		Dialector:                   d,
+		CreateIndexAfterCreateTable: true,
+	}}}
+}
+
+func (d testDialector) DataTypeOf(field *schema.Field) string {
+	if field.PrimaryKey && field.AutoIncrement {
+		return "integer PRIMARY KEY AUTOINCREMENT"
+	}
+	if strings.HasPrefix(strings.ToUpper(string(field.DataType)), "ENUM") {
+		return "text"
+	}
+	return d.Dialector.DataTypeOf(field)
+}
+
+// newTestDB returns an empty database with every table Migrate creates and
+// the first branch in place.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	logrus.SetLevel(logrus.FatalLevel)
+
+	var dialector = sqlite.Open("file::memory:?_pragma=foreign_keys(1)").(*sqlite.Dialector)
+	db, err := gorm.Open(testDialector{*dialector}, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One connection keeps every query on the same in-memory database.
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	for _, table := range tables {
+		if err := db.Migrator().CreateTable(table); err != nil {
+			t.Fatalf("create table for %T: %v", table, err)
+		}
+	}
+	migrateBranches(db, Branch{Name: "Main store", Latitude: -6.2, Longitude: 106.8})
+
+	return db
+}
+
+// catalogAdmin returns the id of the admin that owns test products, as the
+// products foreign key requires one.
+func catalogAdmin(t *testing.T, db *gorm.DB) int {
+	t.Helper()
+	var admin = Admin{Username: "catalog", Password: "-", Role: "manager"}
+	if err := db.Where("username = ?", admin.Username).FirstOrCreate(&admin).Error; err != nil {
+		t.Fatalf("create catalog admin: %v", err)
+	}
+	return admin.Id
+}
+
+func mustCreate(t *testing.T, db *gorm.DB, value any) {
+	t.Helper()
+	if err := db.Create(value).Error; err != nil {
+		t.Fatalf("create %T: %v", value, err)
+	}
+}
+
+// days returns the date n days from today.
+func days(n int) Date {
+	return NewDate(time.Now().AddDate(0, 0, n))
+}
@@ -0,0 +1,493 @@
+package model
+
+import (
+	"errors"
+	"rentcamp/pagination"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	OrderStatusPendingPayment = "pending_payment"
+	OrderStatusConfirmed      = "confirmed"
+	OrderStatusPaymentFailed  = "payment_failed"
+	OrderStatusExpired        = "expired"
+	OrderStatusCancelled      = "cancelled"
+	OrderStatusPickedUp       = "picked_up"
+	OrderStatusReturned       = "returned"
+)
+
+const CartStatusOrdered = "ordered"
+
+var (
+	ErrCartEmpty       = errors.New("cart is empty")
+	ErrOrderNotFound   = errors.New("order not found")
+	ErrOrderNotPending = errors.New("order is not waiting for payment")
+	ErrHoldExpired     = errors.New("inventory hold has expired, please checkout again")
+	ErrOrderNotAllowed = errors.New("order cannot change to that status")
+)
+
+// Order is a checked out cart. Fulfilment says whether the customer picks
+// the gear up at the branch or has it delivered; a delivery order has a
+// Delivery and its fee is part of Total. ApiKeyId is the partner key the
+// cart was built through, so partner orders can be told apart from the
+// linked customer's own.
+type Order struct {
+	Id            int            `gorm:"primaryKey" json:"id"`
+	UserId        int            `gorm:"index;not null" json:"user_id"`
+	CartId        int            `gorm:"index" json:"cart_id"`
+	ApiKeyId      *int           `gorm:"index" json:"api_key_id"`
+	Status        string         `gorm:"type:varchar(20);index;not null" json:"status"`
+	BranchId      int            `gorm:"index" json:"branch_id"`
+	Fulfilment    string         `gorm:"type:varchar(10);not null;default:'pickup'" json:"fulfilment"`
+	DeliveryFee   int            `gorm:"not null;default:0" json:"delivery_fee"`
+	Total         int            `json:"total"`
+	HoldExpiresAt time.Time      `json:"hold_expires_at"`
+	PaidAt        *time.Time     `json:"paid_at"`
+	CancelledAt   *time.Time     `json:"cancelled_at"`
+	PickedUpAt    *time.Time     `json:"picked_up_at"`
+	ReturnedAt    *time.Time     `json:"returned_at"`
+	CreatedAt     time.Time      `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt     time.Time      `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"updated_at"`
+	Items         []OrderItem    `json:"items"`
+	Delivery      *OrderDelivery `gorm:"foreignKey:OrderId" json:"delivery,omitempty"`
+}
+
+type OrderItem struct {
+	Id        int             `gorm:"primaryKey" json:"id"`
+	OrderId   int             `gorm:"index;not null" json:"order_id"`
+	ProductId int             `gorm:"index;not null" json:"product_id"`
+	Quantity  int             `json:"quantity"`
+	StartDate Date            `json:"start_date"`
+	EndDate   Date            `json:"end_date"`
+	UnitPrice int             `json:"unit_price"`
+	Subtotal  int             `json:"subtotal"`
+	Product   ProductResponse `gorm:"foreignKey:ProductId" json:"product"`
+}
+
+// InventoryHold keeps units of a product aside for an order while the
+// customer pays, or for a waitlisted customer (OrderId 0) while they decide.
+// A hold counts against availability until it is released or expires.
+type InventoryHold struct {
+	Id         int        `gorm:"primaryKey" json:"id"`
+	OrderId    int        `gorm:"index" json:"order_id"`
+	UserId     int        `gorm:"index" json:"user_id"`
+	BranchId   int        `gorm:"index" json:"branch_id"`
+	ProductId  int        `gorm:"index;not null" json:"product_id"`
+	Quantity   int        `json:"quantity"`
+	StartDate  Date       `json:"start_date"`
+	EndDate    Date       `json:"end_date"`
+	ExpiresAt  time.Time  `gorm:"index" json:"expires_at"`
+	ReleasedAt *time.Time `gorm:"index" json:"released_at"`
+	CreatedAt  time.Time  `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// CheckoutRequest is how the customer wants the order fulfilled. Delivery
+// is only read when Fulfilment is delivery. Without a BranchId a pickup
+// order uses the branch chosen on the cart.
+type CheckoutRequest struct {
+	BranchId   int              `json:"branch_id" form:"branch_id"`
+	Fulfilment string           `json:"fulfilment" form:"fulfilment"`
+	Delivery   *DeliveryAddress `json:"delivery" form:"delivery"`
+}
+
+type OrderModelInterface interface {
+	StartCheckout(cartID int, userID int, req CheckoutRequest, holdFor time.Duration) (*Order, error)
+	ConfirmPayment(orderID int) (*Order, error)
+	FailPayment(orderID int) (*Order, error)
+	SelectById(orderID int) *Order
+	SelectByUser(userID int, page pagination.Page) []Order
+	SelectAll(status string, branchID int, page pagination.Page) []Order
+	ReleaseExpiredHolds() []FreedStock
+	Cancel(orderID int, userID int) (*Order, error)
+	MarkPickedUp(orderID int, driverID int) (*Order, error)
+	MarkReturned(orderID int, driverID int) (*Order, error)
+}
+
+type OrdersModel struct {
+	db *gorm.DB
+}
+
+func NewOrdersModel(db *gorm.DB) OrderModelInterface {
+	return &OrdersModel{
+		db: db,
+	}
+}
+
+// StartCheckout turns the cart into an order waiting for payment and holds
+// every line's units at the pickup branch until holdFor has passed. Product
+// rows are locked so two checkouts cannot both take the last unit. For
+// delivery the fee is added to the total and both delivery slots are
+// booked.
+func (om *OrdersModel) StartCheckout(cartID int, userID int, req CheckoutRequest, holdFor time.Duration) (*Order, error) {
+	var order = Order{}
+
+	switch req.Fulfilment {
+	case "":
+		req.Fulfilment = FulfilmentPickup
+	case FulfilmentPickup, FulfilmentDelivery:
+	default:
+		return nil, ErrInvalidFulfilment
+	}
+
+	err := om.db.Transaction(func(tx *gorm.DB) error {
+		// Checking out again replaces an earlier attempt, whose lines may no
+		// longer match the cart.
+		var pending = []int{}
+		if err := tx.Model(&Order{}).Where("cart_id = ? AND status = ?", cartID, OrderStatusPendingPayment).Pluck("id", &pending).Error; err != nil {
+			return err
+		}
+		for _, id := range pending {
+			if err := tx.Model(&Order{}).Where("id = ?", id).Update("status", OrderStatusExpired).Error; err != nil {
+				return err
+			}
+			if err := releaseHolds(tx, id); err != nil {
+				return err
+			}
+		}
+
+		var cart = Cart{}
+		if err := tx.Where("id = ? AND status = ?", cartID, CartStatusActive).First(&cart).Error; err != nil {
+			return ErrCartClosed
+		}
+
+		var branchID = req.BranchId
+		if req.Fulfilment == FulfilmentPickup && branchID == 0 {
+			branchID = cart.BranchId
+		}
+		if req.Fulfilment == FulfilmentDelivery && branchID == 0 {
+			id, err := deliveryBranch(tx, req.Delivery)
+			if err != nil {
+				return err
+			}
+			branchID = id
+		}
+		branchID, err := pickupBranch(tx, branchID)
+		if err != nil {
+			return err
+		}
+
+		var items = []CartItem{}
+		if err := tx.Where("cart_id = ?", cartID).Find(&items).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return ErrCartEmpty
+		}
+
+		var productIDs = []int{}
+		for _, item := range items {
+			productIDs = append(productIDs, item.ProductID)
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&Product{}).
+			Where("id IN ?", productIDs).Select("id").Find(&[]Product{}).Error; err != nil {
+			return err
+		}
+
+		var expiresAt = time.Now().Add(holdFor)
+		order = Order{
+			UserId:        userID,
+			CartId:        cartID,
+			ApiKeyId:      cart.ApiKeyID,
+			BranchId:      branchID,
+			Fulfilment:    req.Fulfilment,
+			Status:        OrderStatusPendingPayment,
+			HoldExpiresAt: expiresAt,
+		}
+
+		var first, last Date
+		for _, item := range items {
+			product, err := validateLine(tx, cartID, item, item.ID, branchID)
+			if err != nil {
+				return err
+			}
+
+			var line = OrderItem{
+				ProductId: item.ProductID,
+				Quantity:  item.Quantity,
+				StartDate: item.StartDate,
+				EndDate:   item.EndDate,
+				UnitPrice: product.Price,
+				Subtotal:  product.Price * item.Quantity,
+			}
+			order.Items = append(order.Items, line)
+			order.Total += line.Subtotal
+
+			if first.IsZero() || line.StartDate.Before(first.Time) {
+				first = line.StartDate
+			}
+			if line.EndDate.After(last.Time) {
+				last = line.EndDate
+			}
+		}
+
+		var delivery *OrderDelivery
+		if req.Fulfilment == FulfilmentDelivery {
+			delivery, err = bookDelivery(tx, branchID, req.Delivery, first, last)
+			if err != nil {
+				return err
+			}
+			order.DeliveryFee = delivery.Fee
+			order.Total += delivery.Fee
+		}
+
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		if delivery != nil {
+			delivery.OrderId = order.Id
+			if err := tx.Create(delivery).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, line := range order.Items {
+			var hold = InventoryHold{
+				OrderId:   order.Id,
+				UserId:    userID,
+				BranchId:  branchID,
+				ProductId: line.ProductId,
+				Quantity:  line.Quantity,
+				StartDate: line.StartDate,
+				EndDate:   line.EndDate,
+				ExpiresAt: expiresAt,
+			}
+			if err := tx.Create(&hold).Error; err != nil {
+				return err
+			}
+		}
+
+		// Units offered from the waitlist are now covered by the order holds.
+		return fulfilWaitlist(tx, userID, order.Items)
+	})
+	if err != nil {
+		logrus.Error("Order Model: Error starting checkout, ", err.Error())
+		return nil, err
+	}
+
+	return om.SelectById(order.Id), nil
+}
+
+// ConfirmPayment books the order. Its holds are released because the
+// confirmed order lines now count as booked stock themselves.
+func (om *OrdersModel) ConfirmPayment(orderID int) (*Order, error) {
+	err := om.db.Transaction(func(tx *gorm.DB) error {
+		var order = Order{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", orderID).First(&order).Error; err != nil {
+			return ErrOrderNotFound
+		}
+		if order.Status != OrderStatusPendingPayment {
+			return ErrOrderNotPending
+		}
+		if time.Now().After(order.HoldExpiresAt) {
+			return ErrHoldExpired
+		}
+
+		var now = time.Now()
+		if err := tx.Model(&order).Updates(map[string]any{"status": OrderStatusConfirmed, "paid_at": &now}).Error; err != nil {
+			return err
+		}
+		if err := releaseHolds(tx, orderID); err != nil {
+			return err
+		}
+
+		return tx.Model(&Cart{}).Where("id = ?", order.CartId).
+			Updates(map[string]any{"status": CartStatusOrdered, "active_user_id": nil}).Error
+	})
+	if err != nil {
+		logrus.Error("Order Model: Error confirming payment, ", err.Error())
+		return nil, err
+	}
+
+	return om.SelectById(orderID), nil
+}
+
+func (om *OrdersModel) FailPayment(orderID int) (*Order, error) {
+	err := om.db.Transaction(func(tx *gorm.DB) error {
+		var qry = tx.Model(&Order{}).Where("id = ? AND status = ?", orderID, OrderStatusPendingPayment).Update("status", OrderStatusPaymentFailed)
+		if qry.Error != nil {
+			return qry.Error
+		}
+		if qry.RowsAffected == 0 {
+			return ErrOrderNotPending
+		}
+		return releaseHolds(tx, orderID)
+	})
+	if err != nil {
+		logrus.Error("Order Model: Error failing payment, ", err.Error())
+		return nil, err
+	}
+
+	return om.SelectById(orderID), nil
+}
+
+func releaseHolds(tx *gorm.DB, orderID int) error {
+	return tx.Model(&InventoryHold{}).Where("order_id = ? AND released_at IS NULL", orderID).Update("released_at", time.Now()).Error
+}
+
+func (om *OrdersModel) SelectById(orderID int) *Order {
+	var data = Order{}
+	if err := om.db.Preload("Items.Product").Preload("Delivery.DeliverySlot").Preload("Delivery.CollectionSlot").
+		Where("id = ?", orderID).First(&data).Error; err != nil {
+		logrus.Error("Model : Data with that ID was not found, ", err.Error())
+		return nil
+	}
+
+	return &data
+}
+
+func (om *OrdersModel) SelectByUser(userID int, page pagination.Page) []Order {
+	var data = []Order{}
+	if err := page.Keyset(om.db.Preload("Items").Preload("Delivery").Where("user_id = ?", userID), "id", true).Find(&data).Error; err != nil {
+		logrus.Error("Model : Cannot get orders, ", err.Error())
+		return nil
+	}
+
+	return data
+}
+
+func (om *OrdersModel) SelectAll(status string, branchID int, page pagination.Page) []Order {
+	var data = []Order{}
+	var qry = om.db.Preload("Items").Preload("Delivery")
+	if status != "" {
+		qry = qry.Where("status = ?", status)
+	}
+	if branchID != 0 {
+		qry = qry.Where("branch_id = ?", branchID)
+	}
+	if err := page.Keyset(qry, "id", true).Find(&data).Error; err != nil {
+		logrus.Error("Model : Cannot get orders, ", err.Error())
+		return nil
+	}
+
+	return data
+}
+
+// ReleaseExpiredHolds is run by the background sweeper. It frees holds
+// whose time ran out and expires the orders that were waiting on them. The
+// units of released checkout holds are returned so they can be offered to
+// the waitlist; waitlist holds are passed on by WaitlistModel.ExpireOffers.
+func (om *OrdersModel) ReleaseExpiredHolds() []FreedStock {
+	var now = time.Now()
+	var freed = []FreedStock{}
+	var expired int64
+
+	err := om.db.Transaction(func(tx *gorm.DB) error {
+		var qry = tx.Model(&Order{}).
+			Where("status = ? AND hold_expires_at <= ?", OrderStatusPendingPayment, now).
+			Update("status", OrderStatusExpired)
+		if qry.Error != nil {
+			return qry.Error
+		}
+		expired = qry.RowsAffected
+
+		var holds = []InventoryHold{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("released_at IS NULL AND expires_at <= ?", now).Find(&holds).Error; err != nil {
+			return err
+		}
+		if len(holds) == 0 {
+			return nil
+		}
+
+		var ids = []int{}
+		for _, hold := range holds {
+			ids = append(ids, hold.Id)
+			if hold.OrderId != 0 {
+				freed = append(freed, FreedStock{ProductId: hold.ProductId, Quantity: hold.Quantity, StartDate: hold.StartDate, EndDate: hold.EndDate})
+			}
+		}
+		if err := tx.Model(&InventoryHold{}).Where("id IN ?", ids).Update("released_at", now).Error; err != nil {
+			return err
+		}
+
+		logrus.Info("Order Model: expired ", expired, " orders and released ", len(holds), " holds")
+		return nil
+	})
+	if err != nil {
+		logrus.Error("Order Model: Error releasing expired holds, ", err.Error())
+		return nil
+	}
+
+	return freed
+}
+
+// Cancel cancels an order that was not picked up yet and frees its units.
+// Customers (userID other than 0) may only cancel their own orders, and only
+// before the rental starts.
+func (om *OrdersModel) Cancel(orderID int, userID int) (*Order, error) {
+	err := om.db.Transaction(func(tx *gorm.DB) error {
+		var order = Order{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").Where("id = ?", orderID).First(&order).Error; err != nil {
+			return ErrOrderNotFound
+		}
+		if userID != 0 && order.UserId != userID {
+			return ErrOrderNotFound
+		}
+		if order.Status != OrderStatusPendingPayment && order.Status != OrderStatusConfirmed {
+			return ErrOrderNotAllowed
+		}
+		if userID != 0 {
+			for _, item := range order.Items {
+				if !item.StartDate.After(Today().Time) {
+					return ErrOrderNotAllowed
+				}
+			}
+		}
+
+		if err := tx.Model(&order).Updates(map[string]any{"status": OrderStatusCancelled, "cancelled_at": time.Now()}).Error; err != nil {
+			return err
+		}
+		return releaseHolds(tx, orderID)
+	})
+	if err != nil {
+		logrus.Error("Order Model: Error cancelling order, ", err.Error())
+		return nil, err
+	}
+
+	return om.SelectById(orderID), nil
+}
+
+// MarkPickedUp hands an order over to the customer. A driverID other than 0
+// can only hand over orders on that driver's delivery run.
+func (om *OrdersModel) MarkPickedUp(orderID int, driverID int) (*Order, error) {
+	return om.transition(orderID, OrderStatusConfirmed, OrderStatusPickedUp, "picked_up_at", "delivery_driver_id", driverID)
+}
+
+// MarkReturned takes an order back. A driverID other than 0 can only take
+// back orders on that driver's collection run.
+func (om *OrdersModel) MarkReturned(orderID int, driverID int) (*Order, error) {
+	return om.transition(orderID, OrderStatusPickedUp, OrderStatusReturned, "returned_at", "collection_driver_id", driverID)
+}
+
+func (om *OrdersModel) transition(orderID int, from string, to string, stampColumn string, driverColumn string, driverID int) (*Order, error) {
+	var qry = om.db.Model(&Order{}).Where("id = ? AND status = ?", orderID, from)
+	if driverID != 0 {
+		qry = qry.Where("id IN (?)", om.db.Model(&OrderDelivery{}).Select("order_id").Where(driverColumn+" = ?", driverID))
+	}
+	qry = qry.Updates(map[string]any{"status": to, stampColumn: time.Now()})
+	if qry.Error != nil {
+		logrus.Error("Order Model: Error updating order status, ", qry.Error.Error())
+		return nil, qry.Error
+	}
+	if qry.RowsAffected == 0 {
+		if om.SelectById(orderID) == nil {
+			return nil, ErrOrderNotFound
+		}
+		if driverID != 0 {
+			var onRun int64
+			if err := om.db.Model(&OrderDelivery{}).Where("order_id = ? AND "+driverColumn+" = ?", orderID, driverID).Count(&onRun).Error; err != nil {
+				return nil, err
+			}
+			if onRun == 0 {
+				return nil, ErrNotDriverRun
+			}
+		}
+		return nil, ErrOrderNotAllowed
+	}
+
+	return om.SelectById(orderID), nil
+}
@@ -0,0 +1,171 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// mainBranch is the branch newTestDB creates.
+const mainBranch = 1
+
+// checkout puts quantity units of product in the user's cart for the given
+// dates and starts checkout at the main branch.
+func checkout(t *testing.T, db *gorm.DB, userID int, product Product, quantity int, start Date, end Date) *Order {
+	t.Helper()
+	var carts = NewCartModel(db)
+	cart, _, err := carts.CreateCart(Cart{UserID: userID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := carts.AddItemToCart(cart.ID, CartItem{ProductID: product.Id, Quantity: quantity, StartDate: start, EndDate: end}); err != nil {
+		t.Fatal(err)
+	}
+	order, err := NewOrdersModel(db).StartCheckout(cart.ID, userID, CheckoutRequest{BranchId: mainBranch}, 15*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return order
+}
+
+func TestCheckoutHoldsStock(t *testing.T) {
+	var db = newTestDB(t)
+	var product = newTestProduct(t, db, 3, 100)
+
+	var order = checkout(t, db, 10, product, 2, days(3), days(5))
+	if order.Status != OrderStatusPendingPayment || order.Total != 200 {
+		t.Fatalf("order = %+v, want pending payment with total 200", order)
+	}
+
+	var tests = []struct {
+		name     string
+		start    Date
+		end      Date
+		holderID int
+		want     int
+	}{
+		{"overlapping period for another customer", days(4), days(6), 11, 1},
+		{"the customer holding the units", days(4), days(6), 10, 1},
+		{"period after the hold", days(6), days(7), 11, 3},
+	}
+	for _, tt := range tests {
+		got, err := availableStock(db, product.Id, mainBranch, tt.start, tt.end, tt.holderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: available = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	var carts = NewCartModel(db)
+	other, _, _ := carts.CreateCart(Cart{UserID: 11})
+	if _, err := carts.AddItemToCart(other.ID, CartItem{ProductID: product.Id, Quantity: 2, StartDate: days(4), EndDate: days(4)}); err != ErrInsufficientStock {
+		t.Fatalf("held units must not be added to another cart, got %v", err)
+	}
+}
+
+func TestReleaseExpiredHolds(t *testing.T) {
+	var db = newTestDB(t)
+	var orders = NewOrdersModel(db)
+	var product = newTestProduct(t, db, 2, 100)
+
+	var expired = checkout(t, db, 10, product, 1, days(3), days(5))
+	var live = checkout(t, db, 11, product, 1, days(3), days(5))
+	db.Model(&Order{}).Where("id = ?", expired.Id).Update("hold_expires_at", time.Now().Add(-time.Minute))
+	db.Model(&InventoryHold{}).Where("order_id = ?", expired.Id).Update("expires_at", time.Now().Add(-time.Minute))
+
+	var freed = orders.ReleaseExpiredHolds()
+	var want = FreedStock{ProductId: product.Id, Quantity: 1, StartDate: days(3), EndDate: days(5)}
+	if len(freed) != 1 || freed[0].ProductId != want.ProductId || freed[0].Quantity != want.Quantity ||
+		!freed[0].StartDate.Equal(want.StartDate.Time) || !freed[0].EndDate.Equal(want.EndDate.Time) {
+		t.Fatalf("freed = %+v, want the unit of the expired order", freed)
+	}
+	if again := orders.ReleaseExpiredHolds(); len(again) != 0 {
+		t.Fatalf("released holds must only be reported once, got %+v", again)
+	}
+
+	if got := orders.SelectById(expired.Id); got.Status != OrderStatusExpired {
+		t.Fatalf("expired order status = %q, want expired", got.Status)
+	}
+	if got := orders.SelectById(live.Id); got.Status != OrderStatusPendingPayment {
+		t.Fatalf("live order status = %q, want pending payment", got.Status)
+	}
+
+	available, err := availableStock(db, product.Id, mainBranch, days(3), days(5), 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if available != 1 {
+		t.Fatalf("available = %d, want the expired unit back", available)
+	}
+
+	if _, err := orders.ConfirmPayment(expired.Id); err == nil {
+		t.Fatal("an expired order must not be paid")
+	}
+	if _, err := orders.ConfirmPayment(live.Id); err != nil {
+		t.Fatalf("the live order should still be payable: %v", err)
+	}
+}
+
+func TestStartCheckoutRecordsAPIKey(t *testing.T) {
+	var db = newTestDB(t)
+	var carts = NewCartModel(db)
+	var orders = NewOrdersModel(db)
+	var product = newTestProduct(t, db, 2, 100)
+
+	var keyID = 5
+	for userID, want := range map[int]*int{10: &keyID, 11: nil} {
+		cart, _, err := carts.CreateCart(Cart{UserID: userID, ApiKeyID: want})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := carts.AddItemToCart(cart.ID, CartItem{ProductID: product.Id, Quantity: 1, StartDate: days(3), EndDate: days(5)}); err != nil {
+			t.Fatal(err)
+		}
+
+		order, err := orders.StartCheckout(cart.ID, userID, CheckoutRequest{BranchId: mainBranch}, 15*time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (order.ApiKeyId == nil) != (want == nil) || (want != nil && *order.ApiKeyId != *want) {
+			t.Fatalf("order of user %d has api key %v, want %v", userID, order.ApiKeyId, want)
+		}
+	}
+}
+
+func TestCartLockedWhileOrderPending(t *testing.T) {
+	var db = newTestDB(t)
+	var carts = NewCartModel(db)
+	var orders = NewOrdersModel(db)
+	var product = newTestProduct(t, db, 5, 100)
+
+	var order = checkout(t, db, 10, product, 1, days(3), days(5))
+	var line = order.Items[0]
+	var item = CartItem{}
+	if err := db.Where("cart_id = ?", order.CartId).First(&item).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := carts.AddItemToCart(order.CartId, CartItem{ProductID: product.Id, Quantity: 1, StartDate: days(8), EndDate: days(9)}); !errors.Is(err, ErrCartCheckingOut) {
+		t.Fatalf("adding to a cart being paid = %v, want ErrCartCheckingOut", err)
+	}
+	if _, err := carts.UpdateCartItem(order.CartId, item.ID, CartItem{Quantity: 3}); !errors.Is(err, ErrCartCheckingOut) {
+		t.Fatalf("updating a cart being paid = %v, want ErrCartCheckingOut", err)
+	}
+	if err := carts.RemoveCartItem(order.CartId, item.ID); !errors.Is(err, ErrCartCheckingOut) {
+		t.Fatalf("removing from a cart being paid = %v, want ErrCartCheckingOut", err)
+	}
+	if got := orders.SelectById(order.Id); got.Items[0].Quantity != line.Quantity {
+		t.Fatalf("order line quantity = %d, want %d", got.Items[0].Quantity, line.Quantity)
+	}
+
+	if _, err := orders.FailPayment(order.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := carts.UpdateCartItem(order.CartId, item.ID, CartItem{Quantity: 3}); err != nil {
+		t.Fatalf("the cart should be editable once payment failed: %v", err)
+	}
+}
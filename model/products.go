@@ -1,6 +1,8 @@
 package model
 
 import (
+	"rentcamp/pagination"
+	"rentcamp/search"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -18,19 +20,41 @@ type Product struct {
 	UpdatedAt   time.Time      `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"updated_at" form:"updated_at"`
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at" form:"deleted_at"`
 	AdminId     int            `json:"admin_id" form:"admin_id"`
+	Category    string         `gorm:"type:varchar(50);index" json:"category" form:"category"`
+	Tags        string         `gorm:"type:varchar(255)" json:"tags" form:"tags"` // comma separated
+	// AvgRating and ReviewCount summarise the visible reviews and are kept
+	// up to date by the review model.
+	AvgRating   float64 `gorm:"type:decimal(3,2);not null;default:0" json:"avg_rating" form:"-"`
+	ReviewCount int     `gorm:"not null;default:0" json:"review_count" form:"-"`
+}
+
+const ProductSortRating = "rating"
+
+// productOrder turns the ?sort query value into an ORDER BY clause.
+func productOrder(sort string) string {
+	switch sort {
+	case ProductSortRating:
+		return "avg_rating desc, review_count desc, id"
+	}
+	return "id"
 }
 
 type ProductModelInterface interface {
 	InsertProduct(newProduct Product) *Product
-	SelectAll() []Product
-	SelectAllWithPagination(page, limit int, search string) ([]Product, int64, error)
+	SelectAll(sort string) []Product
+	SelectPage(page pagination.Page, sort string) ([]Product, int64, error)
 	SelectById(ProductId int) *Product
 	Update(updatedData Product) *Product
 	Delete(ProductId int) bool
+	Listen(listener ProductListener)
+	UseSearch(engine search.Engine)
+	Search(text string, page pagination.Page) ([]ProductSearchHit, int, error)
 }
 
 type ProductsModel struct {
-	db *gorm.DB
+	db        *gorm.DB
+	listeners []ProductListener
+	search    search.Engine
 }
 
 func NewProductsModel(db *gorm.DB) ProductModelInterface {
@@ -40,17 +64,25 @@ func NewProductsModel(db *gorm.DB) ProductModelInterface {
 }
 
 func (cpm *ProductsModel) InsertProduct(newProduct Product) *Product {
-	if err := cpm.db.Create(&newProduct).Error; err != nil {
+	err := cpm.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newProduct).Error; err != nil {
+			return err
+		}
+		return assignProductStock(tx, newProduct.Id, newProduct.Stock)
+	})
+	if err != nil {
 		logrus.Error("Model : Insert data error, ", err.Error())
 		return nil
 	}
 
+	cpm.notify(newProduct, false)
+
 	return &newProduct
 }
 
-func (cpm *ProductsModel) SelectAll() []Product {
+func (cpm *ProductsModel) SelectAll(sort string) []Product {
 	var data = []Product{}
-	if err := cpm.db.Find(&data).Error; err != nil {
+	if err := cpm.db.Order(productOrder(sort)).Find(&data).Error; err != nil {
 		logrus.Error("Model : Cannot get all category product, ", err.Error())
 		return nil
 	}
@@ -68,50 +100,28 @@ func (cpm *ProductsModel) SelectById(ProductId int) *Product {
 	return &data
 }
 
-func (cpm *ProductsModel) SelectAllWithPagination(page, limit int, search string) ([]Product, int64, error) {
-	var products []Product
+// SelectPage lists the catalogue in the given sort order together with the
+// number of products. The default id order is walked by keyset, the rating
+// order by offset.
+func (cpm *ProductsModel) SelectPage(page pagination.Page, sort string) ([]Product, int64, error) {
+	var products = []Product{}
 	var totalCount int64
 
-	if page < 1 {
-		page = 1
-	}
-
-	if limit < 1 {
-		limit = 10
+	if err := cpm.db.Model(&Product{}).Count(&totalCount).Error; err != nil {
+		logrus.Error("Model : Cannot count products, ", err.Error())
+		return nil, 0, err
 	}
 
-	if search != "" {
-		if err := cpm.db.Model(&Product{}).
-			Where("name LIKE ?", "%"+search+"%").
-			Count(&totalCount).Error; err != nil {
-			return nil, 0, err
-		}
+	var qry = cpm.db
+	if sort == ProductSortRating {
+		limit, offset := page.Window()
+		qry = qry.Order(productOrder(sort)).Limit(limit).Offset(offset)
 	} else {
-		if err := cpm.db.Model(&Product{}).
-			Count(&totalCount).Error; err != nil {
-			return nil, 0, err
-		}
+		qry = page.Keyset(qry, "id", false)
 	}
-
-	offset := (page - 1) * limit
-
-	if offset < 0 {
-		offset = 0
-	}
-
-	if search != "" {
-		if err := cpm.db.Where("name LIKE ?", "%"+search+"%").
-			Offset(offset).
-			Limit(limit).
-			Find(&products).Error; err != nil {
-			return nil, 0, err
-		}
-	} else {
-		if err := cpm.db.Offset(offset).
-			Limit(limit).
-			Find(&products).Error; err != nil {
-			return nil, 0, err
-		}
+	if err := qry.Find(&products).Error; err != nil {
+		logrus.Error("Model : Cannot get products, ", err.Error())
+		return nil, 0, err
 	}
 
 	return products, totalCount, nil
@@ -138,14 +148,28 @@ func (cpm *ProductsModel) Update(updatedData Product) *Product {
 	if updatedData.AdminId != 0 {
 		data["admin_id"] = updatedData.AdminId
 	}
-	var qry = cpm.db.Table("products").Where("id = ?", updatedData.Id).Updates(data)
-	if err := qry.Error; err != nil {
-		logrus.Error("Model : update error, ", err.Error())
-		return nil
+	if updatedData.Category != "" {
+		data["category"] = updatedData.Category
+	}
+	if updatedData.Tags != "" {
+		data["tags"] = updatedData.Tags
 	}
+	err := cpm.db.Transaction(func(tx *gorm.DB) error {
+		var qry = tx.Table("products").Where("id = ?", updatedData.Id).Updates(data)
+		if err := qry.Error; err != nil {
+			return err
+		}
+		if qry.RowsAffected < 1 {
+			return gorm.ErrRecordNotFound
+		}
 
-	if dataCount := qry.RowsAffected; dataCount < 1 {
-		logrus.Error("Model : Update error, ", "no data effected")
+		if updatedData.Stock != 0 {
+			return assignProductStock(tx, updatedData.Id, updatedData.Stock)
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.Error("Model : update error, ", err.Error())
 		return nil
 	}
 
@@ -155,6 +179,8 @@ func (cpm *ProductsModel) Update(updatedData Product) *Product {
 		return nil
 	}
 
+	cpm.notify(updatedProduct, false)
+
 	return &updatedProduct
 }
 
@@ -172,5 +198,7 @@ func (cpm *ProductsModel) Delete(ProductId int) bool {
 		return false
 	}
 
+	cpm.notify(data, true)
+
 	return true
 }
@@ -0,0 +1,39 @@
+package model
+
+import "testing"
+
+func TestUpdateSetsBranchStock(t *testing.T) {
+	var db = newTestDB(t)
+	var products = NewProductsModel(db)
+	var product = newTestProduct(t, db, 3, 100)
+
+	var res = products.Update(Product{Id: product.Id, Stock: 7})
+	if res == nil || res.Stock != 7 {
+		t.Fatalf("Update = %+v, want stock 7", res)
+	}
+	var stock = BranchStock{}
+	if err := db.Where("branch_id = ? AND product_id = ?", mainBranch, product.Id).First(&stock).Error; err != nil || stock.Stock != 7 {
+		t.Fatalf("main branch stock = %d, %v, want 7", stock.Stock, err)
+	}
+}
+
+func TestUpdateRollsBackWhenBranchStockFails(t *testing.T) {
+	var db = newTestDB(t)
+	var products = NewProductsModel(db)
+	var product = newTestProduct(t, db, 3, 100)
+
+	if err := db.Migrator().DropTable(&BranchStock{}); err != nil {
+		t.Fatal(err)
+	}
+	if res := products.Update(Product{Id: product.Id, Name: "Tenda Tunnel", Stock: 7}); res != nil {
+		t.Fatalf("Update = %+v, want nil when branch stock cannot be saved", res)
+	}
+
+	var saved = Product{}
+	if err := db.First(&saved, product.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.Name != product.Name || saved.Stock != 3 {
+		t.Fatalf("product = %q with stock %d, want the update rolled back", saved.Name, saved.Stock)
+	}
+}
@@ -0,0 +1,100 @@
+package model
+
+import (
+	"rentcamp/pagination"
+	"rentcamp/search"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ProductListener is called after a product is created, updated or deleted,
+// so indexes built from the catalogue can stay in sync.
+type ProductListener func(product Product, deleted bool)
+
+type ProductSearchHit struct {
+	Product
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+func (cpm *ProductsModel) Listen(listener ProductListener) {
+	cpm.listeners = append(cpm.listeners, listener)
+}
+
+func (cpm *ProductsModel) notify(product Product, deleted bool) {
+	for _, listener := range cpm.listeners {
+		listener(product, deleted)
+	}
+}
+
+func productDocument(product Product) search.Document {
+	return search.Document{
+		ID:          product.Id,
+		Name:        product.Name,
+		Description: product.Description,
+		Category:    product.Category,
+		Tags:        search.SplitTags(product.Tags),
+	}
+}
+
+// UseSearch indexes the whole catalogue in engine and keeps it up to date
+// as products change.
+func (cpm *ProductsModel) UseSearch(engine search.Engine) {
+	cpm.search = engine
+
+	var docs = []search.Document{}
+	for _, product := range cpm.SelectAll("") {
+		docs = append(docs, productDocument(product))
+	}
+	if err := engine.Index(docs...); err != nil {
+		logrus.Error("Model : Cannot index catalogue, ", err.Error())
+	}
+
+	cpm.Listen(func(product Product, deleted bool) {
+		var err error
+		if deleted {
+			err = engine.Remove(product.Id)
+		} else {
+			err = engine.Index(productDocument(product))
+		}
+		if err != nil {
+			logrus.Error("Model : Cannot update search index, ", err.Error())
+		}
+	})
+}
+
+// Search runs a catalogue search and returns the matching products in
+// relevance order, together with the total number of matches.
+func (cpm *ProductsModel) Search(text string, page pagination.Page) ([]ProductSearchHit, int, error) {
+	limit, offset := page.Window()
+
+	res, err := cpm.search.Search(search.Query{Text: text, Limit: limit, Offset: offset})
+	if err != nil {
+		logrus.Error("Model : Search error, ", err.Error())
+		return nil, 0, err
+	}
+
+	var ids = []int{}
+	for _, hit := range res.Hits {
+		ids = append(ids, hit.ID)
+	}
+
+	var products = []Product{}
+	if err := cpm.db.Where("id IN ?", ids).Find(&products).Error; err != nil {
+		logrus.Error("Model : Search error, ", err.Error())
+		return nil, 0, err
+	}
+	var byID = map[int]Product{}
+	for _, product := range products {
+		byID[product.Id] = product
+	}
+
+	var hits = []ProductSearchHit{}
+	for _, hit := range res.Hits {
+		if product, ok := byID[hit.ID]; ok {
+			hits = append(hits, ProductSearchHit{Product: product, Score: hit.Score, Highlights: hit.Highlights})
+		}
+	}
+
+	return hits, res.Total, nil
+}
@@ -0,0 +1,169 @@
+package model
+
+import (
+	"errors"
+	"rentcamp/pagination"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrQuestionNotFound = errors.New("question not found")
+	ErrEmptyQuestion    = errors.New("question cannot be empty")
+	ErrAlreadyAnswered  = errors.New("question has already been answered, edit the answer instead")
+	ErrNotAnswered      = errors.New("question has not been answered yet")
+)
+
+// ProductQuestion is a customer question about a product. It is shown
+// publicly once staff have answered it, unless moderation hid it.
+type ProductQuestion struct {
+	Id         int          `gorm:"primaryKey" json:"id"`
+	ProductId  int          `gorm:"index;not null" json:"product_id"`
+	UserId     int          `gorm:"index;not null" json:"user_id"`
+	Body       string       `gorm:"type:text;not null" json:"body" form:"body"`
+	Answer     string       `gorm:"type:text" json:"answer,omitempty"`
+	AnsweredBy int          `json:"answered_by,omitempty"`
+	AnsweredAt *time.Time   `gorm:"index" json:"answered_at,omitempty"`
+	Hidden     bool         `gorm:"not null;default:false" json:"hidden"`
+	CreatedAt  time.Time    `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"created_at"`
+	Asker      ReviewAuthor `gorm:"foreignKey:UserId" json:"asker"`
+}
+
+type QuestionModelInterface interface {
+	Ask(question ProductQuestion) (*ProductQuestion, error)
+	SelectAnswered(productID int, page pagination.Page) []ProductQuestion
+	SelectForStaff(unansweredOnly bool, page pagination.Page) []ProductQuestion
+	Answer(questionID int, adminID int, answer string) (*ProductQuestion, error)
+	EditAnswer(questionID int, adminID int, answer string) (*ProductQuestion, error)
+	SetHidden(questionID int, hidden bool) (*ProductQuestion, error)
+	Delete(questionID int) bool
+}
+
+type QuestionModel struct {
+	db *gorm.DB
+}
+
+func NewQuestionModel(db *gorm.DB) QuestionModelInterface {
+	return &QuestionModel{
+		db: db,
+	}
+}
+
+func (qm *QuestionModel) Ask(question ProductQuestion) (*ProductQuestion, error) {
+	question.Body = strings.TrimSpace(question.Body)
+	if question.Body == "" {
+		return nil, ErrEmptyQuestion
+	}
+
+	if err := qm.db.Where("id = ?", question.ProductId).First(&Product{}).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+
+	question.Id = 0
+	question.Answer = ""
+	question.AnsweredAt = nil
+	question.Hidden = false
+	if err := qm.db.Omit("Asker").Create(&question).Error; err != nil {
+		logrus.Error("Question Model: Error asking question, ", err.Error())
+		return nil, err
+	}
+
+	return qm.selectById(question.Id)
+}
+
+func (qm *QuestionModel) selectById(questionID int) (*ProductQuestion, error) {
+	var res = ProductQuestion{}
+	if err := qm.db.Preload("Asker").Where("id = ?", questionID).First(&res).Error; err != nil {
+		return nil, ErrQuestionNotFound
+	}
+	return &res, nil
+}
+
+func (qm *QuestionModel) SelectAnswered(productID int, page pagination.Page) []ProductQuestion {
+	var res = []ProductQuestion{}
+	var qry = qm.db.Preload("Asker").
+		Where("product_id = ? AND answered_at IS NOT NULL AND hidden = ?", productID, false)
+	if err := page.Keyset(qry, "id", true).Find(&res).Error; err != nil {
+		logrus.Error("Question Model: Error listing questions, ", err.Error())
+		return nil
+	}
+	return res
+}
+
+func (qm *QuestionModel) SelectForStaff(unansweredOnly bool, page pagination.Page) []ProductQuestion {
+	var res = []ProductQuestion{}
+	var qry = qm.db.Preload("Asker")
+	if unansweredOnly {
+		qry = qry.Where("answered_at IS NULL AND hidden = ?", false)
+	}
+	if err := page.Keyset(qry, "id", false).Find(&res).Error; err != nil {
+		logrus.Error("Question Model: Error listing questions, ", err.Error())
+		return nil
+	}
+	return res
+}
+
+// Answer stores the first staff answer. A question is only answered once,
+// so the customer is only told once; later changes go through EditAnswer.
+func (qm *QuestionModel) Answer(questionID int, adminID int, answer string) (*ProductQuestion, error) {
+	return qm.setAnswer(questionID, adminID, answer, false)
+}
+
+// EditAnswer replaces the answer of an answered question.
+func (qm *QuestionModel) EditAnswer(questionID int, adminID int, answer string) (*ProductQuestion, error) {
+	return qm.setAnswer(questionID, adminID, answer, true)
+}
+
+func (qm *QuestionModel) setAnswer(questionID int, adminID int, answer string, answered bool) (*ProductQuestion, error) {
+	if _, err := qm.selectById(questionID); err != nil {
+		return nil, err
+	}
+
+	var qry = qm.db.Model(&ProductQuestion{}).Where("id = ?", questionID)
+	if answered {
+		qry = qry.Where("answered_at IS NOT NULL")
+	} else {
+		qry = qry.Where("answered_at IS NULL")
+	}
+	qry = qry.Updates(map[string]any{"answer": answer, "answered_by": adminID, "answered_at": time.Now()})
+	if qry.Error != nil {
+		logrus.Error("Question Model: Error answering question, ", qry.Error.Error())
+		return nil, qry.Error
+	}
+	if qry.RowsAffected == 0 {
+		if answered {
+			return nil, ErrNotAnswered
+		}
+		return nil, ErrAlreadyAnswered
+	}
+
+	return qm.selectById(questionID)
+}
+
+func (qm *QuestionModel) SetHidden(questionID int, hidden bool) (*ProductQuestion, error) {
+	if _, err := qm.selectById(questionID); err != nil {
+		return nil, err
+	}
+
+	if err := qm.db.Model(&ProductQuestion{}).Where("id = ?", questionID).Update("hidden", hidden).Error; err != nil {
+		logrus.Error("Question Model: Error hiding question, ", err.Error())
+		return nil, err
+	}
+
+	return qm.selectById(questionID)
+}
+
+func (qm *QuestionModel) Delete(questionID int) bool {
+	var qry = qm.db.Where("id = ?", questionID).Delete(&ProductQuestion{})
+	if qry.Error != nil {
+		logrus.Error("Question Model: Error deleting question, ", qry.Error.Error())
+		return false
+	}
+	return qry.RowsAffected > 0
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAnswerQuestionOnce(t *testing.T) {
+	var db = newTestDB(t)
+	var questions = NewQuestionModel(db)
+	var product = newTestProduct(t, db, 1, 100)
+	mustCreate(t, db, &User{Id: 10, Name: "Camper", Email: "camper@example.com"})
+
+	var ask = func(body string) int {
+		t.Helper()
+		question, err := questions.Ask(ProductQuestion{ProductId: product.Id, UserId: 10, Body: body})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return question.Id
+	}
+	var answered = ask("Is the tent waterproof?")
+	var open = ask("Does it come with pegs?")
+
+	if _, err := questions.Answer(answered, 1, "Yes"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := questions.Answer(answered, 2, "No"); !errors.Is(err, ErrAlreadyAnswered) {
+		t.Fatalf("answering twice: err = %v, want ErrAlreadyAnswered", err)
+	}
+
+	res, err := questions.EditAnswer(answered, 2, "Yes, up to 3000 mm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Answer != "Yes, up to 3000 mm" || res.AnsweredBy != 2 {
+		t.Fatalf("edited question = %+v, want the new answer by admin 2", res)
+	}
+
+	if _, err := questions.EditAnswer(open, 1, "Yes"); !errors.Is(err, ErrNotAnswered) {
+		t.Fatalf("editing an open question: err = %v, want ErrNotAnswered", err)
+	}
+	if _, err := questions.Answer(404, 1, "Yes"); !errors.Is(err, ErrQuestionNotFound) {
+		t.Fatalf("answering a missing question: err = %v, want ErrQuestionNotFound", err)
+	}
+}
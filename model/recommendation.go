@@ -0,0 +1,183 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	RecommendReasonRentedTogether = "rented_together"
+	RecommendReasonPopular        = "popular"
+)
+
+// rentedStatuses are the order statuses that count as a real rental.
+var rentedStatuses = []string{OrderStatusConfirmed, OrderStatusPickedUp, OrderStatusReturned}
+
+// ProductAffinity counts the orders in which two products were rented
+// together. It is rebuilt from order history by a background job.
+type ProductAffinity struct {
+	ProductId int       `gorm:"primaryKey;autoIncrement:false" json:"product_id"`
+	RelatedId int       `gorm:"primaryKey;autoIncrement:false" json:"related_id"`
+	Rentals   int       `gorm:"not null" json:"rentals"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type RecommendedProduct struct {
+	Product
+	Score  int    `json:"score"`
+	Reason string `json:"reason"`
+}
+
+type RecommendationModelInterface interface {
+	RebuildAffinity() error
+	Related(productID int, limit int) ([]RecommendedProduct, error)
+	ForUser(userID int, limit int) ([]RecommendedProduct, error)
+}
+
+type RecommendationModel struct {
+	db *gorm.DB
+}
+
+func NewRecommendationModel(db *gorm.DB) RecommendationModelInterface {
+	return &RecommendationModel{
+		db: db,
+	}
+}
+
+// RebuildAffinity recounts which products were rented in the same order.
+func (rm *RecommendationModel) RebuildAffinity() error {
+	return rm.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&ProductAffinity{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Exec("INSERT INTO product_affinities (product_id, related_id, rentals, updated_at) "+
+			"SELECT a.product_id, b.product_id, COUNT(DISTINCT a.order_id), ? "+
+			"FROM order_items a "+
+			"JOIN order_items b ON b.order_id = a.order_id AND b.product_id <> a.product_id "+
+			"JOIN orders ON orders.id = a.order_id AND orders.status IN ? "+
+			"GROUP BY a.product_id, b.product_id", time.Now(), rentedStatuses).Error
+	})
+}
+
+// Related lists the products most often rented together with productID,
+// topped up with popular products from the same category.
+func (rm *RecommendationModel) Related(productID int, limit int) ([]RecommendedProduct, error) {
+	var product = Product{}
+	if err := rm.db.Where("id = ?", productID).First(&product).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
+		logrus.Error("Recommendation Model: Error finding product, ", err.Error())
+		return nil, err
+	}
+
+	var res = []RecommendedProduct{}
+	if err := rm.db.Model(&Product{}).
+		Select("products.*, product_affinities.rentals AS score").
+		Joins("JOIN product_affinities ON product_affinities.related_id = products.id").
+		Where("product_affinities.product_id = ?", productID).
+		Order("score desc, products.id").Limit(limit).
+		Scan(&res).Error; err != nil {
+		logrus.Error("Recommendation Model: Error reading related products, ", err.Error())
+		return nil, err
+	}
+	for i := range res {
+		res[i].Reason = RecommendReasonRentedTogether
+	}
+
+	var exclude = []int{productID}
+	for _, item := range res {
+		exclude = append(exclude, item.Id)
+	}
+
+	var categories = []string{}
+	if product.Category != "" {
+		categories = append(categories, product.Category)
+	}
+
+	popular, err := rm.popular(categories, exclude, limit-len(res))
+	return append(res, popular...), err
+}
+
+// ForUser recommends products that are often rented together with what the
+// user rented before, then popular products from the categories they rent.
+func (rm *RecommendationModel) ForUser(userID int, limit int) ([]RecommendedProduct, error) {
+	var rented = []int{}
+	if err := rm.db.Model(&OrderItem{}).Distinct("order_items.product_id").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("orders.user_id = ? AND orders.status IN ?", userID, rentedStatuses).
+		Pluck("order_items.product_id", &rented).Error; err != nil {
+		logrus.Error("Recommendation Model: Error reading rental history, ", err.Error())
+		return nil, err
+	}
+
+	var res = []RecommendedProduct{}
+	if len(rented) > 0 {
+		if err := rm.db.Model(&Product{}).
+			Select("products.*, SUM(product_affinities.rentals) AS score").
+			Joins("JOIN product_affinities ON product_affinities.related_id = products.id").
+			Where("product_affinities.product_id IN ? AND products.id NOT IN ?", rented, rented).
+			Group("products.id").
+			Order("score desc, products.id").Limit(limit).
+			Scan(&res).Error; err != nil {
+			logrus.Error("Recommendation Model: Error reading recommendations, ", err.Error())
+			return nil, err
+		}
+	}
+	for i := range res {
+		res[i].Reason = RecommendReasonRentedTogether
+	}
+
+	var categories = []string{}
+	if len(rented) > 0 {
+		if err := rm.db.Model(&Product{}).Unscoped().Distinct("category").
+			Where("id IN ? AND category <> ''", rented).
+			Pluck("category", &categories).Error; err != nil {
+			logrus.Error("Recommendation Model: Error reading categories, ", err.Error())
+		}
+	}
+
+	var exclude = append([]int{}, rented...)
+	for _, item := range res {
+		exclude = append(exclude, item.Id)
+	}
+
+	popular, err := rm.popular(categories, exclude, limit-len(res))
+	return append(res, popular...), err
+}
+
+// popular lists the most rented products in the given categories, or in the
+// whole catalogue when no category is known.
+func (rm *RecommendationModel) popular(categories []string, exclude []int, limit int) ([]RecommendedProduct, error) {
+	var res = []RecommendedProduct{}
+	if limit <= 0 {
+		return res, nil
+	}
+
+	var qry = rm.db.Model(&Product{}).
+		Select("products.*, COUNT(DISTINCT orders.id) AS score").
+		Joins("LEFT JOIN order_items ON order_items.product_id = products.id").
+		Joins("LEFT JOIN orders ON orders.id = order_items.order_id AND orders.status IN ?", rentedStatuses)
+	if len(exclude) > 0 {
+		qry = qry.Where("products.id NOT IN ?", exclude)
+	}
+	if len(categories) > 0 {
+		qry = qry.Where("products.category IN ?", categories)
+	}
+
+	if err := qry.Group("products.id").
+		Order("score desc, products.avg_rating desc, products.id").Limit(limit).
+		Scan(&res).Error; err != nil {
+		logrus.Error("Recommendation Model: Error reading popular products, ", err.Error())
+		return nil, err
+	}
+	for i := range res {
+		res[i].Reason = RecommendReasonPopular
+	}
+
+	return res, nil
+}
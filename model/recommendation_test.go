@@ -0,0 +1,79 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRecommendations(t *testing.T) {
+	var db = newTestDB(t)
+	var recommendations = NewRecommendationModel(db)
+
+	var newProduct = func(name string, category string) int {
+		var product = Product{Name: name, Description: name, Price: 100, Stock: 5, Category: category, AdminId: catalogAdmin(t, db)}
+		mustCreate(t, db, &product)
+		return product.Id
+	}
+	var tent = newProduct("Tenda Dome", "Tenda")
+	var bag = newProduct("Sleeping Bag", "Tenda")
+	var stove = newProduct("Kompor", "Masak")
+	var lamp = newProduct("Lampu Tenda", "Tenda")
+	var pegs = newProduct("Pasak", "Tenda")
+
+	var newOrder = func(userID int, status string, products ...int) {
+		var order = Order{UserId: userID, Status: status}
+		for _, productID := range products {
+			order.Items = append(order.Items, OrderItem{ProductId: productID, Quantity: 1, StartDate: days(1), EndDate: days(2)})
+		}
+		mustCreate(t, db, &order)
+	}
+	newOrder(10, OrderStatusConfirmed, tent, stove)
+	newOrder(11, OrderStatusReturned, tent, stove, bag)
+	newOrder(12, OrderStatusCancelled, tent, lamp)
+	newOrder(13, OrderStatusPickedUp, lamp)
+
+	// Rebuilding replaces the counts instead of adding to them.
+	for i := 0; i < 2; i++ {
+		if err := recommendations.RebuildAffinity(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var summary = func(res []RecommendedProduct) string {
+		var parts = []string{}
+		for _, item := range res {
+			parts = append(parts, fmt.Sprintf("%d:%d:%s", item.Id, item.Score, item.Reason))
+		}
+		return fmt.Sprint(parts)
+	}
+
+	related, err := recommendations.Related(tent, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = fmt.Sprint([]string{
+		fmt.Sprintf("%d:2:%s", stove, RecommendReasonRentedTogether),
+		fmt.Sprintf("%d:1:%s", bag, RecommendReasonRentedTogether),
+		fmt.Sprintf("%d:1:%s", lamp, RecommendReasonPopular),
+	})
+	if got := summary(related); got != want {
+		t.Fatalf("related = %s, want %s (the cancelled order must not count)", got, want)
+	}
+
+	forUser, err := recommendations.ForUser(10, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = fmt.Sprint([]string{
+		fmt.Sprintf("%d:2:%s", bag, RecommendReasonRentedTogether),
+		fmt.Sprintf("%d:1:%s", lamp, RecommendReasonPopular),
+		fmt.Sprintf("%d:0:%s", pegs, RecommendReasonPopular),
+	})
+	if got := summary(forUser); got != want {
+		t.Fatalf("for user = %s, want %s (nothing already rented)", got, want)
+	}
+
+	if _, err := recommendations.Related(404, 3); err != ErrProductNotFound {
+		t.Fatalf("related for a missing product: err = %v, want ErrProductNotFound", err)
+	}
+}
@@ -0,0 +1,235 @@
+package model
+
+import (
+	"errors"
+	"rentcamp/pagination"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrReviewNotAllowed = errors.New("only customers who returned this product on that order can review it")
+	ErrAlreadyReviewed  = errors.New("this rental has already been reviewed")
+	ErrInvalidRating    = errors.New("rating must be between 1 and 5")
+	ErrReviewNotFound   = errors.New("review not found")
+)
+
+// Review is left by a customer for one line of a returned order, which
+// makes every review a verified rental.
+type Review struct {
+	Id          int           `gorm:"primaryKey" json:"id"`
+	ProductId   int           `gorm:"index;not null" json:"product_id"`
+	OrderItemId int           `gorm:"uniqueIndex;not null" json:"order_item_id" form:"order_item_id"`
+	UserId      int           `gorm:"index;not null" json:"user_id"`
+	Rating      int           `gorm:"type:tinyint;not null" json:"rating" form:"rating"`
+	Body        string        `gorm:"type:text" json:"body" form:"body"`
+	Hidden      bool          `gorm:"not null;default:false;index" json:"hidden"`
+	Flagged     bool          `gorm:"not null;default:false;index" json:"flagged"`
+	FlagReason  string        `gorm:"type:varchar(255)" json:"flag_reason,omitempty"`
+	Reply       string        `gorm:"type:text" json:"reply,omitempty"`
+	RepliedBy   int           `json:"replied_by,omitempty"`
+	RepliedAt   *time.Time    `json:"replied_at,omitempty"`
+	CreatedAt   time.Time     `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"created_at"`
+	Photos      []ReviewPhoto `json:"photos"`
+	User        ReviewAuthor  `gorm:"foreignKey:UserId" json:"author"`
+}
+
+type ReviewPhoto struct {
+	Id       int    `gorm:"primaryKey" json:"id"`
+	ReviewId int    `gorm:"index;not null" json:"review_id"`
+	Url      string `gorm:"type:text;not null" json:"url"`
+}
+
+// ReviewAuthor is the public part of the reviewer's account.
+type ReviewAuthor struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func (ReviewAuthor) TableName() string {
+	return "users"
+}
+
+type ReviewModelInterface interface {
+	CanReview(review Review) error
+	Insert(review Review, photos []string) (*Review, error)
+	SelectByProduct(productID int, page pagination.Page) []Review
+	SelectForModeration(flaggedOnly bool, page pagination.Page) []Review
+	SetHidden(reviewID int, hidden bool) (*Review, error)
+	Reply(reviewID int, adminID int, reply string) (*Review, error)
+	Flag(reviewID int, reason string) (*Review, error)
+}
+
+type ReviewModel struct {
+	db *gorm.DB
+}
+
+func NewReviewModel(db *gorm.DB) ReviewModelInterface {
+	return &ReviewModel{
+		db: db,
+	}
+}
+
+// CanReview reports whether the review would be accepted, so photos are
+// only uploaded for reviews that can be saved.
+func (rm *ReviewModel) CanReview(review Review) error {
+	return checkReviewable(rm.db, review)
+}
+
+// checkReviewable accepts a valid rating from the customer who returned
+// the order line, once.
+func checkReviewable(tx *gorm.DB, review Review) error {
+	if review.Rating < 1 || review.Rating > 5 {
+		return ErrInvalidRating
+	}
+
+	var count int64
+	if err := tx.Model(&OrderItem{}).
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("order_items.id = ? AND order_items.product_id = ?", review.OrderItemId, review.ProductId).
+		Where("orders.user_id = ? AND orders.status = ?", review.UserId, OrderStatusReturned).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrReviewNotAllowed
+	}
+
+	if err := tx.Model(&Review{}).Where("order_item_id = ?", review.OrderItemId).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrAlreadyReviewed
+	}
+
+	return nil
+}
+
+// Insert checks that the reviewer rented the product on that order line
+// and returned it, then stores the review and refreshes the product rating.
+func (rm *ReviewModel) Insert(review Review, photos []string) (*Review, error) {
+	err := rm.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkReviewable(tx, review); err != nil {
+			return err
+		}
+
+		review.Id = 0
+		review.Hidden = false
+		review.Flagged = false
+		review.Reply = ""
+		review.Photos = nil
+		for _, url := range photos {
+			review.Photos = append(review.Photos, ReviewPhoto{Url: url})
+		}
+		if err := tx.Omit("User").Create(&review).Error; err != nil {
+			return err
+		}
+
+		return refreshRating(tx, review.ProductId)
+	})
+	if err != nil {
+		logrus.Error("Review Model: Error inserting review, ", err.Error())
+		return nil, err
+	}
+
+	return rm.selectById(review.Id)
+}
+
+func (rm *ReviewModel) selectById(reviewID int) (*Review, error) {
+	var res = Review{}
+	if err := rm.db.Preload("Photos").Preload("User").Where("id = ?", reviewID).First(&res).Error; err != nil {
+		return nil, ErrReviewNotFound
+	}
+	return &res, nil
+}
+
+func (rm *ReviewModel) SelectByProduct(productID int, page pagination.Page) []Review {
+	var res = []Review{}
+	var qry = rm.db.Preload("Photos").Preload("User").
+		Where("product_id = ? AND hidden = ?", productID, false)
+	if err := page.Keyset(qry, "id", true).Find(&res).Error; err != nil {
+		logrus.Error("Review Model: Error listing reviews, ", err.Error())
+		return nil
+	}
+	return res
+}
+
+func (rm *ReviewModel) SelectForModeration(flaggedOnly bool, page pagination.Page) []Review {
+	var res = []Review{}
+	var qry = rm.db.Preload("Photos").Preload("User")
+	if flaggedOnly {
+		qry = qry.Where("flagged = ?", true)
+	}
+	if err := page.Keyset(qry, "id", true).Find(&res).Error; err != nil {
+		logrus.Error("Review Model: Error listing reviews, ", err.Error())
+		return nil
+	}
+	return res
+}
+
+// SetHidden hides a review from the catalogue, or shows it again. Hidden
+// reviews do not count towards the product rating.
+func (rm *ReviewModel) SetHidden(reviewID int, hidden bool) (*Review, error) {
+	review, err := rm.selectById(reviewID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = rm.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Review{}).Where("id = ?", reviewID).Update("hidden", hidden).Error; err != nil {
+			return err
+		}
+		return refreshRating(tx, review.ProductId)
+	})
+	if err != nil {
+		logrus.Error("Review Model: Error hiding review, ", err.Error())
+		return nil, err
+	}
+
+	return rm.selectById(reviewID)
+}
+
+func (rm *ReviewModel) Reply(reviewID int, adminID int, reply string) (*Review, error) {
+	if _, err := rm.selectById(reviewID); err != nil {
+		return nil, err
+	}
+
+	if err := rm.db.Model(&Review{}).Where("id = ?", reviewID).
+		Updates(map[string]any{"reply": reply, "replied_by": adminID, "replied_at": time.Now()}).Error; err != nil {
+		logrus.Error("Review Model: Error replying to review, ", err.Error())
+		return nil, err
+	}
+
+	return rm.selectById(reviewID)
+}
+
+// Flag marks a review for a closer look without hiding it.
+func (rm *ReviewModel) Flag(reviewID int, reason string) (*Review, error) {
+	if _, err := rm.selectById(reviewID); err != nil {
+		return nil, err
+	}
+
+	if err := rm.db.Model(&Review{}).Where("id = ?", reviewID).
+		Updates(map[string]any{"flagged": true, "flag_reason": reason}).Error; err != nil {
+		logrus.Error("Review Model: Error flagging review, ", err.Error())
+		return nil, err
+	}
+
+	return rm.selectById(reviewID)
+}
+
+func refreshRating(tx *gorm.DB, productID int) error {
+	var stats = struct {
+		Avg   float64
+		Count int
+	}{}
+	if err := tx.Model(&Review{}).Where("product_id = ? AND hidden = ?", productID, false).
+		Select("COALESCE(AVG(rating), 0) AS avg, COUNT(*) AS count").Scan(&stats).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&Product{}).Where("id = ?", productID).
+		UpdateColumns(map[string]any{"avg_rating": stats.Avg, "review_count": stats.Count}).Error
+}
@@ -0,0 +1,131 @@
+package model
+
+import (
+	"errors"
+	"rentcamp/pagination"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// returnedLine checks out one unit of product for the user and marks the
+// order returned, returning the order line the user may review.
+func returnedLine(t *testing.T, db *gorm.DB, userID int, product Product) OrderItem {
+	t.Helper()
+	if err := db.Where("id = ?", userID).FirstOrCreate(&User{Id: userID, Name: "Camper", Email: "camper@example.com"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	var order = checkout(t, db, userID, product, 1, days(3), days(5))
+	if err := db.Model(&Order{}).Where("id = ?", order.Id).Update("status", OrderStatusReturned).Error; err != nil {
+		t.Fatal(err)
+	}
+	return order.Items[0]
+}
+
+func TestCheckReviewable(t *testing.T) {
+	var db = newTestDB(t)
+	var reviews = NewReviewModel(db)
+	var product = newTestProduct(t, db, 5, 100)
+	var other = newTestProduct(t, db, 5, 100)
+
+	var line = returnedLine(t, db, 10, product)
+	var pending = checkout(t, db, 11, product, 1, days(3), days(5)).Items[0]
+
+	var tests = []struct {
+		name   string
+		review Review
+		want   error
+	}{
+		{"rating too low", Review{ProductId: product.Id, OrderItemId: line.Id, UserId: 10, Rating: 0}, ErrInvalidRating},
+		{"rating too high", Review{ProductId: product.Id, OrderItemId: line.Id, UserId: 10, Rating: 6}, ErrInvalidRating},
+		{"another customer's line", Review{ProductId: product.Id, OrderItemId: line.Id, UserId: 11, Rating: 4}, ErrReviewNotAllowed},
+		{"another product on the line", Review{ProductId: other.Id, OrderItemId: line.Id, UserId: 10, Rating: 4}, ErrReviewNotAllowed},
+		{"order not returned yet", Review{ProductId: product.Id, OrderItemId: pending.Id, UserId: 11, Rating: 4}, ErrReviewNotAllowed},
+		{"the customer who returned the line", Review{ProductId: product.Id, OrderItemId: line.Id, UserId: 10, Rating: 4}, nil},
+	}
+	for _, tt := range tests {
+		if err := reviews.CanReview(tt.review); !errors.Is(err, tt.want) {
+			t.Errorf("%s: CanReview = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	var review = Review{ProductId: product.Id, OrderItemId: line.Id, UserId: 10, Rating: 4}
+	if _, err := reviews.Insert(review, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reviews.Insert(review, nil); !errors.Is(err, ErrAlreadyReviewed) {
+		t.Fatalf("reviewing the same line twice = %v, want ErrAlreadyReviewed", err)
+	}
+}
+
+func TestHidingReviewRefreshesRating(t *testing.T) {
+	var db = newTestDB(t)
+	var reviews = NewReviewModel(db)
+	var products = NewProductsModel(db)
+	var product = newTestProduct(t, db, 5, 100)
+
+	var ids = []int{}
+	for userID, rating := range map[int]int{10: 5, 11: 2} {
+		var line = returnedLine(t, db, userID, product)
+		res, err := reviews.Insert(Review{ProductId: product.Id, OrderItemId: line.Id, UserId: userID, Rating: rating}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rating == 2 {
+			ids = append(ids, res.Id)
+		}
+	}
+
+	var check = func(when string, avg float64, count int) {
+		t.Helper()
+		var got = products.SelectById(product.Id)
+		if got.AvgRating != avg || got.ReviewCount != count {
+			t.Fatalf("%s: rating = %v from %d reviews, want %v from %d", when, got.AvgRating, got.ReviewCount, avg, count)
+		}
+	}
+
+	check("both reviews shown", 3.5, 2)
+	if _, err := reviews.SetHidden(ids[0], true); err != nil {
+		t.Fatal(err)
+	}
+	check("low review hidden", 5, 1)
+	if _, err := reviews.SetHidden(ids[0], false); err != nil {
+		t.Fatal(err)
+	}
+	check("low review shown again", 3.5, 2)
+}
+
+func TestSelectPageSortsByRating(t *testing.T) {
+	var db = newTestDB(t)
+	var products = NewProductsModel(db)
+
+	var unrated = newTestProduct(t, db, 1, 100)
+	var good = newTestProduct(t, db, 1, 100)
+	var best = newTestProduct(t, db, 1, 100)
+	var popular = newTestProduct(t, db, 1, 100)
+	for _, rating := range []struct {
+		id    int
+		avg   float64
+		count int
+	}{{good.Id, 4, 1}, {best.Id, 4.5, 2}, {popular.Id, 4, 6}} {
+		db.Model(&Product{}).Where("id = ?", rating.id).UpdateColumns(map[string]any{"avg_rating": rating.avg, "review_count": rating.count})
+	}
+
+	res, total, err := products.SelectPage(pagination.Page{Limit: 10}, ProductSortRating)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got = []int{}
+	for _, product := range res {
+		got = append(got, product.Id)
+	}
+	var want = []int{best.Id, popular.Id, good.Id, unrated.Id}
+	if total != 4 || len(got) != len(want) {
+		t.Fatalf("sorted ids = %v of %d, want %v", got, total, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sorted ids = %v, want %v", got, want)
+		}
+	}
+}
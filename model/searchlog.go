@@ -0,0 +1,106 @@
+package model
+
+import (
+	"rentcamp/helper"
+	"rentcamp/pagination"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// SearchQuery records a catalogue search and how many products it found.
+type SearchQuery struct {
+	Id        int       `gorm:"primaryKey" json:"id"`
+	Query     string    `gorm:"type:varchar(255);index;not null" json:"query"`
+	Results   int       `json:"results"`
+	Searcher  string    `gorm:"type:varchar(64);index" json:"-"`
+	CreatedAt time.Time `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP;index" json:"created_at"`
+}
+
+type SearchQueryStat struct {
+	Query        string    `json:"query"`
+	Searches     int       `json:"searches"`
+	LastSearched time.Time `json:"last_searched"`
+}
+
+type SearchLogModelInterface interface {
+	Record(query string, results int, searcher string)
+	ZeroResults(since time.Time, page pagination.Page) []SearchQueryStat
+	Popular(since time.Time, limit int, minSearchers int) []SearchQueryStat
+	Prune(before time.Time) int64
+}
+
+type SearchLogModel struct {
+	db *gorm.DB
+}
+
+func NewSearchLogModel(db *gorm.DB) SearchLogModelInterface {
+	return &SearchLogModel{
+		db: db,
+	}
+}
+
+// maxQueryLength is the size of the query column, in characters.
+const maxQueryLength = 255
+
+// Record logs a search. searcher identifies who searched (a user or a
+// client address) and is only stored as a hash, to count distinct
+// searchers.
+func (sm *SearchLogModel) Record(query string, results int, searcher string) {
+	query = strings.ToLower(strings.Join(strings.Fields(query), " "))
+	if query == "" {
+		return
+	}
+	query = truncateRunes(query, maxQueryLength)
+
+	if err := sm.db.Create(&SearchQuery{Query: query, Results: results, Searcher: helper.HashToken(searcher)}).Error; err != nil {
+		logrus.Error("Search Log Model: Error recording query, ", err.Error())
+	}
+}
+
+// stats groups the logged searches by query. The time of the latest search
+// is read from that search's own row rather than from MAX(created_at), so
+// it scans as a time on every driver.
+func (sm *SearchLogModel) stats(since time.Time, limit int, offset int, where string, minSearchers int) []SearchQueryStat {
+	var grouped = sm.db.Model(&SearchQuery{}).
+		Select("query, COUNT(*) AS searches, MAX(id) AS last_id").
+		Where("created_at >= ?", since).
+		Where(where).
+		Group("query").Having("COUNT(DISTINCT searcher) >= ?", minSearchers)
+
+	var res = []SearchQueryStat{}
+	if err := sm.db.Table("(?) AS stats", grouped).
+		Select("stats.query, stats.searches, search_queries.created_at AS last_searched").
+		Joins("JOIN search_queries ON search_queries.id = stats.last_id").
+		Order("stats.searches DESC, stats.last_id DESC, stats.query").Limit(limit).Offset(offset).
+		Scan(&res).Error; err != nil {
+		logrus.Error("Search Log Model: Error reading query stats, ", err.Error())
+		return nil
+	}
+	return res
+}
+
+// ZeroResults lists the queries that found nothing, most frequent first.
+func (sm *SearchLogModel) ZeroResults(since time.Time, page pagination.Page) []SearchQueryStat {
+	limit, offset := page.Window()
+	return sm.stats(since, limit, offset, "results = 0", 1)
+}
+
+// Popular lists the most frequent queries that found products and were
+// searched by at least minSearchers different searchers, so a single
+// visitor cannot push a query into everyone's suggestions.
+func (sm *SearchLogModel) Popular(since time.Time, limit int, minSearchers int) []SearchQueryStat {
+	return sm.stats(since, limit, 0, "results > 0", max(minSearchers, 1))
+}
+
+// Prune deletes searches logged before the given time.
+func (sm *SearchLogModel) Prune(before time.Time) int64 {
+	var qry = sm.db.Where("created_at < ?", before).Delete(&SearchQuery{})
+	if qry.Error != nil {
+		logrus.Error("Search Log Model: Error pruning search log, ", qry.Error.Error())
+		return 0
+	}
+	return qry.RowsAffected
+}
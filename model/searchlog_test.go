@@ -0,0 +1,100 @@
+package model
+
+import (
+	"rentcamp/pagination"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestSearchLogStats(t *testing.T) {
+	var db = newTestDB(t)
+	var searchLog = NewSearchLogModel(db)
+
+	var before = time.Now().Add(-time.Second)
+	searchLog.Record("Tenda  Dome", 2, "customer:1")
+	searchLog.Record("tenda dome", 2, "customer:2")
+	searchLog.Record("kompor", 1, "customer:1")
+	searchLog.Record("senter", 0, "customer:1")
+	searchLog.Record("matras", 0, "customer:1")
+	searchLog.Record("senter", 0, "customer:1")
+
+	var since = time.Now().Add(-time.Hour)
+	var popular = searchLog.Popular(since, 10, 1)
+	if len(popular) != 2 || popular[0].Query != "tenda dome" || popular[0].Searches != 2 || popular[1].Query != "kompor" {
+		t.Fatalf("popular = %+v, want tenda dome twice then kompor", popular)
+	}
+
+	var zero = searchLog.ZeroResults(since, pagination.Page{Limit: 10})
+	if len(zero) != 2 || zero[0].Query != "senter" || zero[0].Searches != 2 || zero[1].Query != "matras" {
+		t.Fatalf("zero results = %+v, want senter twice then matras", zero)
+	}
+	if zero[0].LastSearched.Before(before) {
+		t.Fatalf("senter last searched at %v, want the time of its latest search", zero[0].LastSearched)
+	}
+}
+
+func TestSearchLogPopularNeedsDistinctSearchers(t *testing.T) {
+	var db = newTestDB(t)
+	var searchLog = NewSearchLogModel(db)
+
+	for i := 0; i < 10; i++ {
+		searchLog.Record("spam spam", 3, "ip:10.0.0.1")
+	}
+	for _, searcher := range []string{"customer:1", "customer:2", "ip:10.0.0.2"} {
+		searchLog.Record("tenda dome", 2, searcher)
+	}
+
+	var queries = func(stats []SearchQueryStat) []string {
+		var res = []string{}
+		for _, stat := range stats {
+			res = append(res, stat.Query)
+		}
+		return res
+	}
+
+	var since = time.Now().Add(-time.Hour)
+	if got := queries(searchLog.Popular(since, 10, 3)); len(got) != 1 || got[0] != "tenda dome" {
+		t.Fatalf("popular with 3 searchers = %v, want only the query searched by 3 visitors", got)
+	}
+	if got := queries(searchLog.Popular(since, 10, 1)); len(got) != 2 || got[0] != "spam spam" {
+		t.Fatalf("popular with 1 searcher = %v, want both queries", got)
+	}
+
+	var logged = SearchQuery{}
+	db.First(&logged)
+	if logged.Searcher == "ip:10.0.0.1" || logged.Searcher == "" {
+		t.Fatalf("searcher stored as %q, want a hash", logged.Searcher)
+	}
+}
+
+func TestSearchLogPrune(t *testing.T) {
+	var db = newTestDB(t)
+	var searchLog = NewSearchLogModel(db)
+
+	mustCreate(t, db, &SearchQuery{Query: "old", Results: 1, CreatedAt: time.Now().AddDate(0, 0, -100)})
+	searchLog.Record("new", 1, "customer:1")
+
+	if pruned := searchLog.Prune(time.Now().AddDate(0, 0, -90)); pruned != 1 {
+		t.Fatalf("pruned %d searches, want 1", pruned)
+	}
+	var left int64
+	db.Model(&SearchQuery{}).Count(&left)
+	if left != 1 {
+		t.Fatalf("%d searches left, want 1", left)
+	}
+}
+
+func TestSearchLogRecordTruncatesByRunes(t *testing.T) {
+	var db = newTestDB(t)
+	var searchLog = NewSearchLogModel(db)
+
+	searchLog.Record("a"+strings.Repeat("é", 300), 1, "customer:1")
+
+	var logged = SearchQuery{}
+	db.First(&logged)
+	if !utf8.ValidString(logged.Query) || utf8.RuneCountInString(logged.Query) != maxQueryLength {
+		t.Fatalf("logged query has %d runes (valid UTF-8: %v), want %d", utf8.RuneCountInString(logged.Query), utf8.ValidString(logged.Query), maxQueryLength)
+	}
+}
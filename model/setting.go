@@ -0,0 +1,57 @@
+package model
+
+import (
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	SettingAdmin2FARequired    = "admin_2fa_required"
+	SettingActiveCartsMigrated = "active_carts_migrated"
+)
+
+type Setting struct {
+	Key   string `gorm:"primaryKey;type:varchar(50)" json:"key"`
+	Value string `gorm:"type:varchar(255);not null" json:"value"`
+}
+
+type SettingModelInterface interface {
+	Get(key string) string
+	Set(key string, value string) bool
+	Bool(key string) bool
+}
+
+type SettingsModel struct {
+	db *gorm.DB
+}
+
+func NewSettingsModel(db *gorm.DB) SettingModelInterface {
+	return &SettingsModel{
+		db: db,
+	}
+}
+
+func (sm *SettingsModel) Get(key string) string {
+	var data = Setting{}
+	if err := sm.db.Where("`key` = ?", key).Limit(1).Find(&data).Error; err != nil {
+		logrus.Error("Model : Cannot get setting, ", err.Error())
+		return ""
+	}
+
+	return data.Value
+}
+
+func (sm *SettingsModel) Set(key string, value string) bool {
+	var data = Setting{Key: key, Value: value}
+	if err := sm.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&data).Error; err != nil {
+		logrus.Error("Model : Cannot save setting, ", err.Error())
+		return false
+	}
+
+	return true
+}
+
+func (sm *SettingsModel) Bool(key string) bool {
+	return sm.Get(key) == "true"
+}
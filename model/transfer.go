@@ -0,0 +1,228 @@
+package model
+
+import (
+	"errors"
+	"rentcamp/pagination"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	TransferStatusRequested = "requested"
+	TransferStatusInTransit = "in_transit"
+	TransferStatusReceived  = "received"
+	TransferStatusCancelled = "cancelled"
+)
+
+var (
+	ErrTransferNotFound    = errors.New("transfer not found")
+	ErrInvalidTransfer     = errors.New("a transfer needs a product, two different branches and a quantity of at least 1")
+	ErrTransferNotAllowed  = errors.New("transfer cannot change to that status")
+	ErrTransferUnavailable = errors.New("the source branch cannot spare that many units")
+)
+
+// StockTransfer moves units of a product from one branch to another. Units
+// leave the source branch when dispatched and join the destination when
+// received, so while in transit they count towards neither.
+type StockTransfer struct {
+	Id           int                  `gorm:"primaryKey" json:"id"`
+	ProductId    int                  `gorm:"index;not null" json:"product_id"`
+	FromBranchId int                  `gorm:"index;not null" json:"from_branch_id"`
+	ToBranchId   int                  `gorm:"index;not null" json:"to_branch_id"`
+	Quantity     int                  `gorm:"not null" json:"quantity"`
+	Status       string               `gorm:"type:varchar(20);index;not null" json:"status"`
+	Note         string               `gorm:"type:varchar(255)" json:"note"`
+	RequestedBy  int                  `json:"requested_by"`
+	DispatchedAt *time.Time           `json:"dispatched_at"`
+	ReceivedAt   *time.Time           `json:"received_at"`
+	CancelledAt  *time.Time           `json:"cancelled_at"`
+	CreatedAt    time.Time            `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt    time.Time            `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"updated_at"`
+	Product      ProductResponse      `gorm:"foreignKey:ProductId" json:"product"`
+	History      []StockTransferEvent `gorm:"foreignKey:TransferId" json:"history,omitempty"`
+}
+
+// StockTransferEvent records who moved a transfer to a status, and when.
+type StockTransferEvent struct {
+	Id         int       `gorm:"primaryKey" json:"id"`
+	TransferId int       `gorm:"index;not null" json:"transfer_id"`
+	Status     string    `gorm:"type:varchar(20);not null" json:"status"`
+	AdminId    int       `json:"admin_id"`
+	Note       string    `gorm:"type:varchar(255)" json:"note"`
+	CreatedAt  time.Time `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+type TransferModelInterface interface {
+	Request(transfer StockTransfer) (*StockTransfer, error)
+	Dispatch(transferID int, adminID int, note string) (*StockTransfer, error)
+	Receive(transferID int, adminID int, note string) (*StockTransfer, error)
+	Cancel(transferID int, adminID int, note string) (*StockTransfer, error)
+	SelectById(transferID int) (*StockTransfer, error)
+	SelectAll(status string, branchID int, page pagination.Page) []StockTransfer
+}
+
+type TransferModel struct {
+	db *gorm.DB
+}
+
+func NewTransferModel(db *gorm.DB) TransferModelInterface {
+	return &TransferModel{
+		db: db,
+	}
+}
+
+func (tm *TransferModel) Request(transfer StockTransfer) (*StockTransfer, error) {
+	if transfer.ProductId == 0 || transfer.Quantity < 1 || transfer.FromBranchId == transfer.ToBranchId {
+		return nil, ErrInvalidTransfer
+	}
+	if err := tm.db.Where("id = ?", transfer.ProductId).First(&Product{}).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+	var branches int64
+	if err := tm.db.Model(&Branch{}).Where("id IN ?", []int{transfer.FromBranchId, transfer.ToBranchId}).Count(&branches).Error; err != nil {
+		return nil, err
+	}
+	if branches != 2 {
+		return nil, ErrBranchNotFound
+	}
+
+	transfer.Id = 0
+	transfer.Status = TransferStatusRequested
+	transfer.DispatchedAt, transfer.ReceivedAt, transfer.CancelledAt = nil, nil, nil
+
+	err := tm.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Product", "History").Create(&transfer).Error; err != nil {
+			return err
+		}
+		return tx.Create(&StockTransferEvent{TransferId: transfer.Id, Status: TransferStatusRequested, AdminId: transfer.RequestedBy, Note: transfer.Note}).Error
+	})
+	if err != nil {
+		logrus.Error("Transfer Model: Error requesting transfer, ", err.Error())
+		return nil, err
+	}
+
+	return tm.SelectById(transfer.Id)
+}
+
+// Dispatch takes the units out of the source branch. It is refused when the
+// branch needs them for rentals that are booked or held there.
+func (tm *TransferModel) Dispatch(transferID int, adminID int, note string) (*StockTransfer, error) {
+	return tm.move(transferID, TransferStatusRequested, TransferStatusInTransit, "dispatched_at", adminID, note,
+		func(tx *gorm.DB, transfer StockTransfer) error {
+			// The branch must keep enough units for its busiest day from today
+			// on, and so must the product as a whole, since units in transit
+			// are in no branch.
+			var stock int
+			if err := tx.Model(&BranchStock{}).Where("branch_id = ? AND product_id = ?", transfer.FromBranchId, transfer.ProductId).
+				Select("COALESCE(SUM(stock), 0)").Row().Scan(&stock); err != nil {
+				return err
+			}
+			var product = Product{}
+			if err := tx.Select("id", "stock").Where("id = ?", transfer.ProductId).First(&product).Error; err != nil {
+				return err
+			}
+
+			busiest, err := peakInUse(tx, transfer.ProductId, transfer.FromBranchId, Today())
+			if err != nil {
+				return err
+			}
+			busiestOverall, err := peakInUse(tx, transfer.ProductId, 0, Today())
+			if err != nil {
+				return err
+			}
+			if stock-busiest < transfer.Quantity || product.Stock-busiestOverall < transfer.Quantity {
+				return ErrTransferUnavailable
+			}
+
+			return adjustBranchStock(tx, transfer.FromBranchId, transfer.ProductId, -transfer.Quantity)
+		})
+}
+
+// Receive adds the units to the destination branch.
+func (tm *TransferModel) Receive(transferID int, adminID int, note string) (*StockTransfer, error) {
+	return tm.move(transferID, TransferStatusInTransit, TransferStatusReceived, "received_at", adminID, note,
+		func(tx *gorm.DB, transfer StockTransfer) error {
+			return adjustBranchStock(tx, transfer.ToBranchId, transfer.ProductId, transfer.Quantity)
+		})
+}
+
+// Cancel drops a transfer that has not been dispatched yet.
+func (tm *TransferModel) Cancel(transferID int, adminID int, note string) (*StockTransfer, error) {
+	return tm.move(transferID, TransferStatusRequested, TransferStatusCancelled, "cancelled_at", adminID, note, nil)
+}
+
+// move changes the status of a transfer from one state to the next, runs
+// apply for the stock side of the change and records the event.
+func (tm *TransferModel) move(transferID int, from string, to string, stampColumn string, adminID int, note string,
+	apply func(tx *gorm.DB, transfer StockTransfer) error) (*StockTransfer, error) {
+	err := tm.db.Transaction(func(tx *gorm.DB) error {
+		var transfer = StockTransfer{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", transferID).First(&transfer).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTransferNotFound
+			}
+			return err
+		}
+		if transfer.Status != from {
+			return ErrTransferNotAllowed
+		}
+
+		if apply != nil {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", transfer.ProductId).First(&Product{}).Error; err != nil {
+				return err
+			}
+			if err := apply(tx, transfer); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&StockTransfer{}).Where("id = ?", transferID).
+			Updates(map[string]any{"status": to, stampColumn: time.Now()}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&StockTransferEvent{TransferId: transferID, Status: to, AdminId: adminID, Note: note}).Error
+	})
+	if err != nil {
+		logrus.Error("Transfer Model: Error moving transfer to ", to, ", ", err.Error())
+		return nil, err
+	}
+
+	return tm.SelectById(transferID)
+}
+
+func (tm *TransferModel) SelectById(transferID int) (*StockTransfer, error) {
+	var res = StockTransfer{}
+	if err := tm.db.Preload("Product").Preload("History", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Where("id = ?", transferID).First(&res).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTransferNotFound
+		}
+		logrus.Error("Transfer Model: Error finding transfer, ", err.Error())
+		return nil, err
+	}
+	return &res, nil
+}
+
+// SelectAll lists transfers, newest first. A branchID matches transfers
+// leaving or arriving at that branch.
+func (tm *TransferModel) SelectAll(status string, branchID int, page pagination.Page) []StockTransfer {
+	var res = []StockTransfer{}
+	var qry = tm.db.Preload("Product")
+	if status != "" {
+		qry = qry.Where("status = ?", status)
+	}
+	if branchID != 0 {
+		qry = qry.Where("from_branch_id = ? OR to_branch_id = ?", branchID, branchID)
+	}
+	if err := page.Keyset(qry, "id", true).Find(&res).Error; err != nil {
+		logrus.Error("Transfer Model: Error listing transfers, ", err.Error())
+		return nil
+	}
+	return res
+}
@@ -0,0 +1,155 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func branchStock(t *testing.T, tm *TransferModel, branchID int, productID int) int {
+	t.Helper()
+	var stock int
+	if err := tm.db.Model(&BranchStock{}).Where("branch_id = ? AND product_id = ?", branchID, productID).
+		Select("COALESCE(SUM(stock), 0)").Row().Scan(&stock); err != nil {
+		t.Fatal(err)
+	}
+	return stock
+}
+
+func TestTransferLifecycle(t *testing.T) {
+	var db = newTestDB(t)
+	var transfers = NewTransferModel(db).(*TransferModel)
+	var product = newTestProduct(t, db, 3, 100)
+	var north = Branch{Name: "North", Address: "Jl. Utara 1", Latitude: -6.1, Longitude: 106.8}
+	mustCreate(t, db, &north)
+
+	transfer, err := transfers.Request(StockTransfer{ProductId: product.Id, FromBranchId: mainBranch, ToBranchId: north.Id, Quantity: 2, RequestedBy: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transfer.Status != TransferStatusRequested || branchStock(t, transfers, mainBranch, product.Id) != 3 {
+		t.Fatalf("requested transfer = %+v, want status requested and no stock moved", transfer)
+	}
+
+	if _, err := transfers.Receive(transfer.Id, 1, ""); !errors.Is(err, ErrTransferNotAllowed) {
+		t.Fatalf("receive before dispatch: err = %v, want ErrTransferNotAllowed", err)
+	}
+
+	if _, err := transfers.Dispatch(transfer.Id, 1, "on the van"); err != nil {
+		t.Fatal(err)
+	}
+	var inTransit = Product{}
+	db.First(&inTransit, product.Id)
+	if from, to := branchStock(t, transfers, mainBranch, product.Id), branchStock(t, transfers, north.Id, product.Id); from != 1 || to != 0 || inTransit.Stock != 1 {
+		t.Fatalf("in transit: main %d, north %d, total %d, want 1, 0, 1", from, to, inTransit.Stock)
+	}
+	if _, err := transfers.Cancel(transfer.Id, 1, ""); !errors.Is(err, ErrTransferNotAllowed) {
+		t.Fatalf("cancel after dispatch: err = %v, want ErrTransferNotAllowed", err)
+	}
+
+	received, err := transfers.Receive(transfer.Id, 2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total = Product{}
+	db.First(&total, product.Id)
+	if to := branchStock(t, transfers, north.Id, product.Id); to != 2 || total.Stock != 3 {
+		t.Fatalf("received: north %d, total %d, want 2, 3", to, total.Stock)
+	}
+
+	var statuses = []string{}
+	for _, event := range received.History {
+		statuses = append(statuses, event.Status)
+	}
+	if len(statuses) != 3 || statuses[0] != TransferStatusRequested || statuses[1] != TransferStatusInTransit || statuses[2] != TransferStatusReceived {
+		t.Fatalf("history = %v, want requested, in transit, received", statuses)
+	}
+	if received.ReceivedAt == nil || received.History[2].AdminId != 2 {
+		t.Fatalf("received transfer = %+v, want received_at and the receiving admin recorded", received)
+	}
+}
+
+func TestTransferDispatchKeepsBookedUnits(t *testing.T) {
+	var db = newTestDB(t)
+	var transfers = NewTransferModel(db).(*TransferModel)
+	var product = newTestProduct(t, db, 3, 100)
+	var north = Branch{Name: "North", Address: "Jl. Utara 1", Latitude: -6.1, Longitude: 106.8}
+	mustCreate(t, db, &north)
+
+	checkout(t, db, 10, product, 2, days(3), days(5))
+
+	transfer, err := transfers.Request(StockTransfer{ProductId: product.Id, FromBranchId: mainBranch, ToBranchId: north.Id, Quantity: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transfers.Dispatch(transfer.Id, 1, ""); !errors.Is(err, ErrTransferUnavailable) {
+		t.Fatalf("dispatching held units: err = %v, want ErrTransferUnavailable", err)
+	}
+	if stock := branchStock(t, transfers, mainBranch, product.Id); stock != 3 {
+		t.Fatalf("main branch stock = %d after a refused dispatch, want 3", stock)
+	}
+
+	cancelled, err := transfers.Cancel(transfer.Id, 1, "not needed")
+	if err != nil || cancelled.Status != TransferStatusCancelled {
+		t.Fatalf("Cancel = %+v, %v, want a cancelled transfer", cancelled, err)
+	}
+}
+
+func TestTransferDispatchCountsBusiestDay(t *testing.T) {
+	var db = newTestDB(t)
+	var orders = NewOrdersModel(db)
+	var transfers = NewTransferModel(db).(*TransferModel)
+	var product = newTestProduct(t, db, 4, 100)
+	var north = Branch{Name: "North", Address: "Jl. Utara 1", Latitude: -6.1, Longitude: 106.8}
+	mustCreate(t, db, &north)
+
+	// Two bookings that never overlap need 2 units on any one day, not 4.
+	for userID, start := range map[int]int{10: 3, 11: 10} {
+		var order = checkout(t, db, userID, product, 2, days(start), days(start+2))
+		if _, err := orders.ConfirmPayment(order.Id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tooMany, err := transfers.Request(StockTransfer{ProductId: product.Id, FromBranchId: mainBranch, ToBranchId: north.Id, Quantity: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transfers.Dispatch(tooMany.Id, 1, ""); !errors.Is(err, ErrTransferUnavailable) {
+		t.Fatalf("dispatching 3 of the 2 free units: err = %v, want ErrTransferUnavailable", err)
+	}
+
+	transfer, err := transfers.Request(StockTransfer{ProductId: product.Id, FromBranchId: mainBranch, ToBranchId: north.Id, Quantity: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transfers.Dispatch(transfer.Id, 1, ""); err != nil {
+		t.Fatalf("dispatching the 2 units free on every day: %v", err)
+	}
+	if stock := branchStock(t, transfers, mainBranch, product.Id); stock != 2 {
+		t.Fatalf("main branch stock = %d after dispatch, want 2", stock)
+	}
+}
+
+func TestTransferRequestValidates(t *testing.T) {
+	var db = newTestDB(t)
+	var transfers = NewTransferModel(db)
+	var product = newTestProduct(t, db, 3, 100)
+
+	var tests = []struct {
+		name     string
+		transfer StockTransfer
+		wantErr  error
+	}{
+		{"same branch", StockTransfer{ProductId: product.Id, FromBranchId: mainBranch, ToBranchId: mainBranch, Quantity: 1}, ErrInvalidTransfer},
+		{"no quantity", StockTransfer{ProductId: product.Id, FromBranchId: mainBranch, ToBranchId: 2}, ErrInvalidTransfer},
+		{"unknown branch", StockTransfer{ProductId: product.Id, FromBranchId: mainBranch, ToBranchId: 9, Quantity: 1}, ErrBranchNotFound},
+		{"unknown product", StockTransfer{ProductId: 99, FromBranchId: mainBranch, ToBranchId: 9, Quantity: 1}, ErrProductNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := transfers.Request(tt.transfer); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
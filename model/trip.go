@@ -0,0 +1,294 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"rentcamp/pagination"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTripTemplateNotFound = errors.New("trip template not found")
+	ErrInvalidTripTemplate  = errors.New("a trip template needs a name and at least one item with a category and a quantity per person above 0")
+	ErrInvalidTripPeople    = errors.New("people must be between 1 and 50")
+)
+
+// maxTripPeople bounds a plan's group size, which multiplies every line.
+const maxTripPeople = 50
+
+// TripTemplate describes a kind of trip, for example a 2-night mountain
+// hike, and the gear it needs.
+type TripTemplate struct {
+	Id          int                `gorm:"primaryKey" json:"id"`
+	Name        string             `gorm:"type:varchar(100);not null" json:"name"`
+	Description string             `gorm:"type:text" json:"description"`
+	Nights      int                `gorm:"not null;default:0" json:"nights"`
+	CreatedAt   time.Time          `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt   time.Time          `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"updated_at"`
+	Items       []TripTemplateItem `gorm:"foreignKey:TemplateId;constraint:OnDelete:CASCADE" json:"items"`
+}
+
+// TripTemplateItem asks for QuantityPerPerson units of a product from
+// Category for every traveller, rounded up. A two person tent is 0.5.
+type TripTemplateItem struct {
+	Id                int     `gorm:"primaryKey" json:"id"`
+	TemplateId        int     `gorm:"index;not null" json:"template_id"`
+	Category          string  `gorm:"type:varchar(50);not null" json:"category"`
+	QuantityPerPerson float64 `gorm:"type:decimal(5,2);not null" json:"quantity_per_person"`
+	Required          bool    `gorm:"not null" json:"required"`
+}
+
+type TripPlanRequest struct {
+	TemplateId      int  `json:"template_id" form:"template_id"`
+	People          int  `json:"people" form:"people"`
+	StartDate       Date `json:"start_date" form:"start_date"`
+	EndDate         Date `json:"end_date" form:"end_date"`
+	IncludeOptional bool `json:"include_optional" form:"include_optional"`
+}
+
+// TripPlanLine is the product suggested for one template item. Product is
+// nil when the catalogue has nothing in the category.
+type TripPlanLine struct {
+	Category   string   `json:"category"`
+	Required   bool     `json:"required"`
+	Quantity   int      `json:"quantity"`
+	Product    *Product `json:"product"`
+	Available  int      `json:"available"`
+	Sufficient bool     `json:"sufficient"`
+	Subtotal   int      `json:"subtotal"`
+}
+
+// TripPlan is a suggested cart for a trip. Complete is true when every
+// required line can be rented for the dates.
+type TripPlan struct {
+	TemplateId int            `json:"template_id"`
+	Name       string         `json:"name"`
+	People     int            `json:"people"`
+	StartDate  Date           `json:"start_date"`
+	EndDate    Date           `json:"end_date"`
+	Lines      []TripPlanLine `json:"lines"`
+	Total      int            `json:"total"`
+	Complete   bool           `json:"complete"`
+}
+
+type TripModelInterface interface {
+	InsertTemplate(template TripTemplate) (*TripTemplate, error)
+	UpdateTemplate(template TripTemplate) (*TripTemplate, error)
+	DeleteTemplate(templateID int) bool
+	SelectTemplates(page pagination.Page) []TripTemplate
+	SelectTemplate(templateID int) (*TripTemplate, error)
+	Plan(req TripPlanRequest, cartID int) (*TripPlan, error)
+}
+
+type TripModel struct {
+	db *gorm.DB
+}
+
+func NewTripModel(db *gorm.DB) TripModelInterface {
+	return &TripModel{
+		db: db,
+	}
+}
+
+func templateItems(db *gorm.DB) *gorm.DB {
+	return db.Order("id")
+}
+
+func validTemplate(template TripTemplate) bool {
+	if strings.TrimSpace(template.Name) == "" || len(template.Items) == 0 {
+		return false
+	}
+	for _, item := range template.Items {
+		if strings.TrimSpace(item.Category) == "" || item.QuantityPerPerson <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (tm *TripModel) InsertTemplate(template TripTemplate) (*TripTemplate, error) {
+	if !validTemplate(template) {
+		return nil, ErrInvalidTripTemplate
+	}
+
+	template.Id = 0
+	for i := range template.Items {
+		template.Items[i].Id = 0
+	}
+	if err := tm.db.Create(&template).Error; err != nil {
+		logrus.Error("Trip Model: Error creating template, ", err.Error())
+		return nil, err
+	}
+
+	return tm.SelectTemplate(template.Id)
+}
+
+// UpdateTemplate replaces the template and its whole item list.
+func (tm *TripModel) UpdateTemplate(template TripTemplate) (*TripTemplate, error) {
+	if !validTemplate(template) {
+		return nil, ErrInvalidTripTemplate
+	}
+	if _, err := tm.SelectTemplate(template.Id); err != nil {
+		return nil, err
+	}
+
+	err := tm.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&TripTemplate{}).Where("id = ?", template.Id).
+			Updates(map[string]any{"name": template.Name, "description": template.Description, "nights": template.Nights}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("template_id = ?", template.Id).Delete(&TripTemplateItem{}).Error; err != nil {
+			return err
+		}
+		for i := range template.Items {
+			template.Items[i].Id = 0
+			template.Items[i].TemplateId = template.Id
+		}
+		return tx.Create(&template.Items).Error
+	})
+	if err != nil {
+		logrus.Error("Trip Model: Error updating template, ", err.Error())
+		return nil, err
+	}
+
+	return tm.SelectTemplate(template.Id)
+}
+
+func (tm *TripModel) DeleteTemplate(templateID int) bool {
+	var deleted int64
+	err := tm.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("template_id = ?", templateID).Delete(&TripTemplateItem{}).Error; err != nil {
+			return err
+		}
+		var qry = tx.Where("id = ?", templateID).Delete(&TripTemplate{})
+		deleted = qry.RowsAffected
+		return qry.Error
+	})
+	if err != nil {
+		logrus.Error("Trip Model: Error deleting template, ", err.Error())
+		return false
+	}
+	return deleted > 0
+}
+
+func (tm *TripModel) SelectTemplates(page pagination.Page) []TripTemplate {
+	var res = []TripTemplate{}
+	if err := page.Keyset(tm.db.Preload("Items", templateItems), "id", false).Find(&res).Error; err != nil {
+		logrus.Error("Trip Model: Error listing templates, ", err.Error())
+		return nil
+	}
+	return res
+}
+
+func (tm *TripModel) SelectTemplate(templateID int) (*TripTemplate, error) {
+	var res = TripTemplate{}
+	if err := tm.db.Preload("Items", templateItems).Where("id = ?", templateID).First(&res).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTripTemplateNotFound
+		}
+		logrus.Error("Trip Model: Error finding template, ", err.Error())
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Plan picks a product for every item of the template, preferring the
+// best rated product that is free for the whole trip. Without an end date
+// the trip lasts the template's number of nights. With a cartID stock is
+// checked the way AddItemToCart checks it for that cart: at the cart's
+// branch, and without the units the cart already holds for the dates.
+func (tm *TripModel) Plan(req TripPlanRequest, cartID int) (*TripPlan, error) {
+	template, err := tm.SelectTemplate(req.TemplateId)
+	if err != nil {
+		return nil, err
+	}
+	if req.People < 1 || req.People > maxTripPeople {
+		return nil, ErrInvalidTripPeople
+	}
+	if req.EndDate.IsZero() && !req.StartDate.IsZero() {
+		req.EndDate = NewDate(req.StartDate.AddDate(0, 0, template.Nights))
+	}
+	if req.StartDate.IsZero() || req.StartDate.Before(Today().Time) || req.EndDate.Before(req.StartDate.Time) {
+		return nil, ErrInvalidDates
+	}
+
+	var plan = TripPlan{
+		TemplateId: template.Id,
+		Name:       template.Name,
+		People:     req.People,
+		StartDate:  req.StartDate,
+		EndDate:    req.EndDate,
+		Lines:      []TripPlanLine{},
+		Complete:   true,
+	}
+
+	var branchID int
+	if cartID != 0 {
+		if branchID, err = cartBranch(tm.db, cartID); err != nil {
+			logrus.Error("Trip Model: Error reading cart branch, ", err.Error())
+			return nil, err
+		}
+	}
+
+	// planned keeps two items of the same category from counting the same
+	// free units twice.
+	var planned = map[int]int{}
+
+	for _, item := range template.Items {
+		if !item.Required && !req.IncludeOptional {
+			continue
+		}
+
+		var line = TripPlanLine{
+			Category: item.Category,
+			Required: item.Required,
+			Quantity: int(math.Ceil(item.QuantityPerPerson*float64(req.People) - 1e-9)),
+		}
+
+		var products = []Product{}
+		if err := tm.db.Where("category = ?", item.Category).Order("avg_rating desc, id").Find(&products).Error; err != nil {
+			logrus.Error("Trip Model: Error reading products, ", err.Error())
+			return nil, err
+		}
+
+		for i := range products {
+			var available int
+			if cartID != 0 {
+				available, err = lineRoom(tm.db, cartID, CartItem{ProductID: products[i].Id, StartDate: req.StartDate, EndDate: req.EndDate}, 0, branchID)
+			} else {
+				available, err = availableStock(tm.db, products[i].Id, 0, req.StartDate, req.EndDate, 0)
+			}
+			if err != nil {
+				logrus.Error("Trip Model: Error checking availability, ", err.Error())
+				return nil, err
+			}
+			available -= planned[products[i].Id]
+
+			if line.Product == nil || available > line.Available {
+				line.Product = &products[i]
+				line.Available = max(available, 0)
+			}
+			if available >= line.Quantity {
+				break
+			}
+		}
+
+		if line.Product != nil {
+			line.Sufficient = line.Available >= line.Quantity
+			line.Subtotal = line.Product.Price * line.Quantity
+			planned[line.Product.Id] += line.Quantity
+			plan.Total += line.Subtotal
+		}
+		if item.Required && !line.Sufficient {
+			plan.Complete = false
+		}
+
+		plan.Lines = append(plan.Lines, line)
+	}
+
+	return &plan, nil
+}
@@ -0,0 +1,129 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTripPlan(t *testing.T) {
+	var db = newTestDB(t)
+	var trips = NewTripModel(db)
+
+	var newProduct = func(name string, category string, stock int, rating float64) Product {
+		var product = Product{Name: name, Description: name, Price: 100, Stock: stock, Category: category, AvgRating: rating, AdminId: catalogAdmin(t, db)}
+		mustCreate(t, db, &product)
+		mustCreate(t, db, &BranchStock{BranchId: mainBranch, ProductId: product.Id, Stock: stock})
+		return product
+	}
+	var bestTent = newProduct("Tenda Ultralight", "Tenda", 1, 4.8)
+	var tent = newProduct("Tenda Dome", "Tenda", 3, 4.0)
+	var bag = newProduct("Sleeping Bag", "Tidur", 2, 4.5)
+	newProduct("Lampu", "Lampu", 5, 4.0)
+
+	template, err := trips.InsertTemplate(TripTemplate{Name: "Pendakian 2 malam", Nights: 2, Items: []TripTemplateItem{
+		{Category: "Tenda", QuantityPerPerson: 0.5, Required: true},
+		{Category: "Tidur", QuantityPerPerson: 1, Required: true},
+		{Category: "Lampu", QuantityPerPerson: 1, Required: false},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type line struct {
+		product    int
+		quantity   int
+		available  int
+		sufficient bool
+	}
+	var check = func(plan *TripPlan, complete bool, want []line) {
+		t.Helper()
+		if plan.Complete != complete || len(plan.Lines) != len(want) {
+			t.Fatalf("plan complete %v with %d lines, want %v with %d", plan.Complete, len(plan.Lines), complete, len(want))
+		}
+		for i, w := range want {
+			var got = plan.Lines[i]
+			if got.Product == nil || got.Product.Id != w.product || got.Quantity != w.quantity ||
+				got.Available != w.available || got.Sufficient != w.sufficient {
+				t.Fatalf("line %d = %+v, want %+v", i, got, w)
+			}
+		}
+	}
+
+	// Two people share one tent, so the best rated tent is enough.
+	plan, err := trips.Plan(TripPlanRequest{TemplateId: template.Id, People: 2, StartDate: days(3)}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.EndDate.Equal(days(5).Time) {
+		t.Fatalf("end date = %s, want the start plus the template's nights", plan.EndDate)
+	}
+	check(plan, true, []line{{bestTent.Id, 1, 1, true}, {bag.Id, 2, 2, true}})
+	if plan.Total != 300 {
+		t.Fatalf("total = %d, want 300", plan.Total)
+	}
+
+	// Three people need two tents, which only the lower rated one has, and
+	// three sleeping bags, which makes the plan incomplete.
+	plan, _ = trips.Plan(TripPlanRequest{TemplateId: template.Id, People: 3, StartDate: days(3), IncludeOptional: true}, 0)
+	check(plan, false, []line{{tent.Id, 2, 3, true}, {bag.Id, 3, 2, false}, {plan.Lines[2].Product.Id, 3, 5, true}})
+
+	// Units booked by another order for overlapping dates are not free.
+	mustCreate(t, db, &Order{UserId: 11, Status: OrderStatusConfirmed, Items: []OrderItem{
+		{ProductId: tent.Id, Quantity: 2, StartDate: days(4), EndDate: days(6)},
+	}})
+	plan, _ = trips.Plan(TripPlanRequest{TemplateId: template.Id, People: 3, StartDate: days(3)}, 0)
+	check(plan, false, []line{{bestTent.Id, 2, 1, false}, {bag.Id, 3, 2, false}})
+
+	plan, _ = trips.Plan(TripPlanRequest{TemplateId: template.Id, People: 3, StartDate: days(7), EndDate: days(8)}, 0)
+	check(plan, false, []line{{tent.Id, 2, 3, true}, {bag.Id, 3, 2, false}})
+
+	// Two items of the same category do not count the same free units twice.
+	doubled, err := trips.InsertTemplate(TripTemplate{Name: "Dua lapis", Items: []TripTemplateItem{
+		{Category: "Tidur", QuantityPerPerson: 1, Required: true},
+		{Category: "Tidur", QuantityPerPerson: 1, Required: true},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, _ = trips.Plan(TripPlanRequest{TemplateId: doubled.Id, People: 1, StartDate: days(3)}, 0)
+	check(plan, true, []line{{bag.Id, 1, 2, true}, {bag.Id, 1, 1, true}})
+
+	// The lines a cart already holds for the dates are not free for its plan.
+	cart, _, err := NewCartModel(db).CreateCart(Cart{UserID: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewCartModel(db).AddItemToCart(cart.ID, CartItem{ProductID: bag.Id, Quantity: 1, StartDate: days(3), EndDate: days(5)}); err != nil {
+		t.Fatal(err)
+	}
+	plan, _ = trips.Plan(TripPlanRequest{TemplateId: template.Id, People: 2, StartDate: days(3)}, cart.ID)
+	check(plan, false, []line{{bestTent.Id, 1, 1, true}, {bag.Id, 2, 1, false}})
+	plan, _ = trips.Plan(TripPlanRequest{TemplateId: template.Id, People: 2, StartDate: days(3)}, 0)
+	check(plan, true, []line{{bestTent.Id, 1, 1, true}, {bag.Id, 2, 2, true}})
+
+	// A cart that chose a branch is planned against that branch's stock.
+	var north = Branch{Name: "North store", Address: "Bogor"}
+	mustCreate(t, db, &north)
+	if _, err := NewCartModel(db).SetCartBranch(cart.ID, north.Id); err != nil {
+		t.Fatal(err)
+	}
+	plan, _ = trips.Plan(TripPlanRequest{TemplateId: template.Id, People: 2, StartDate: days(3)}, cart.ID)
+	check(plan, false, []line{{bestTent.Id, 1, 0, false}, {bag.Id, 2, 0, false}})
+
+	var invalid = []struct {
+		name string
+		req  TripPlanRequest
+		want error
+	}{
+		{"no people", TripPlanRequest{TemplateId: template.Id, StartDate: days(3)}, ErrInvalidTripPeople},
+		{"too many people", TripPlanRequest{TemplateId: template.Id, People: maxTripPeople + 1, StartDate: days(3)}, ErrInvalidTripPeople},
+		{"start in the past", TripPlanRequest{TemplateId: template.Id, People: 1, StartDate: days(-1)}, ErrInvalidDates},
+		{"end before start", TripPlanRequest{TemplateId: template.Id, People: 1, StartDate: days(3), EndDate: days(2)}, ErrInvalidDates},
+		{"missing template", TripPlanRequest{TemplateId: 404, People: 1, StartDate: days(3)}, ErrTripTemplateNotFound},
+	}
+	for _, tt := range invalid {
+		if _, err := trips.Plan(tt.req, 0); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
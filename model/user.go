@@ -2,7 +2,11 @@ package model
 
 import (
 	"errors"
+	"rentcamp/helper"
+	"rentcamp/pagination"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
@@ -17,13 +21,35 @@ type User struct {
 	Email     string         `gorm:"type:varchar(50);not null" json:"email" form:"email"`
 	Phone     string         `gorm:"type:varchar(15);not null" json:"phone" form:"phone"`
 	Address   string         `gorm:"type:varchar(255);not null" json:"address" form:"address"`
-	Gender    string         `gorm:"type:ENUM('m','f');not null" json:"gender" form:"gender"`
+	Gender    string         `gorm:"type:ENUM('m','f','u');not null" json:"gender" form:"gender"`
 	CreatedAt time.Time      `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"created_at" form:"created_at"`
 	UpdatedAt time.Time      `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"updated_at" form:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at" form:"deleted_at"`
-	Carts     []Cart         `json:"cart"`
+	Carts     []Cart         `gorm:"constraint:-" json:"cart"`
+
+	// EmailOptOut stops reminder and marketing emails to the user.
+	EmailOptOut bool `gorm:"not null;default:false" json:"email_opt_out" form:"-"`
+}
+
+// UserIdentity links a customer to an account at an external OpenID
+// Connect provider.
+type UserIdentity struct {
+	Id        int       `gorm:"primaryKey" json:"id"`
+	UserId    int       `gorm:"index;not null" json:"user_id"`
+	Issuer    string    `gorm:"type:varchar(255);uniqueIndex:idx_identity_subject;not null" json:"issuer"`
+	Subject   string    `gorm:"type:varchar(255);uniqueIndex:idx_identity_subject;not null" json:"subject"`
+	CreatedAt time.Time `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"created_at"`
 }
 
+// maxEmailLength matches the users.email column.
+const maxEmailLength = 50
+
+var (
+	ErrIdentityUnverified   = errors.New("identity provider did not return a verified email")
+	ErrIdentityEmailTooLong = errors.New("email from the identity provider is longer than 50 characters")
+	ErrAccountDeleted       = errors.New("the account for this identity was deleted")
+)
+
 type LoginUser struct {
 	Username string `json:"username" form:"username"`
 	Password string `json:"password" form:"password"`
@@ -32,11 +58,12 @@ type LoginUser struct {
 type UserModelInterface interface {
 	Login(username string, password string) *User
 	InsertUser(newItem User) *User
-	SelectAll() []User
-	SelectAllWithPagination(page, limit int, search string) ([]User, int64, error)
+	SelectPage(page pagination.Page, search string) ([]User, int64, error)
 	SelectById(userId int) *User
 	Update(updatedData User) (*User, error)
 	Delete(userId int) bool
+	LoginWithIdentity(identity helper.OIDCIdentity) (*User, error)
+	SetEmailOptOut(userId int, optOut bool) bool
 }
 
 type UsersModel struct {
@@ -60,6 +87,23 @@ func (um *UsersModel) Login(username string, password string) *User {
 		return nil
 	}
 
+	valid, needsRehash := helper.CheckCredential(password, data.Password)
+	if !valid {
+		logrus.Error("Model : Login data error, ", "invalid credential")
+		return nil
+	}
+
+	if needsRehash {
+		hashed, err := helper.HashPassword(password)
+		if err != nil {
+			logrus.Error("Model : Cannot hash legacy password, ", err.Error())
+			return &data
+		}
+		if err := um.db.Model(&data).Update("password", hashed).Error; err != nil {
+			logrus.Error("Model : Cannot migrate legacy password, ", err.Error())
+		}
+	}
+
 	return &data
 }
 
@@ -72,68 +116,33 @@ func (um *UsersModel) InsertUser(newUser User) *User {
 	return &newUser
 }
 
-func (um *UsersModel) SelectAll() []User {
-	var data = []User{}
-	if err := um.db.Find(&data).Error; err != nil {
-		logrus.Error("Model : Cannot get all users, ", err.Error())
-		return nil
-	}
-
-	return data
-}
-
-func (um *UsersModel) SelectAllWithPagination(page, limit int, search string) ([]User, int64, error) {
-	var Users []User
+// SelectPage lists customers in id order, optionally filtered by name,
+// together with the number of matching customers.
+func (um *UsersModel) SelectPage(page pagination.Page, search string) ([]User, int64, error) {
+	var users = []User{}
 	var totalCount int64
 
-	if page < 1 {
-		page = 1
-	}
-
-	if limit < 1 {
-		limit = 10
-	}
-
+	var qry = um.db.Model(&User{})
 	if search != "" {
-		if err := um.db.Model(&User{}).
-			Where("name LIKE ?", "%"+search+"%").
-			Count(&totalCount).Error; err != nil {
-			return nil, 0, err
-		}
-	} else {
-		if err := um.db.Model(&User{}).
-			Count(&totalCount).Error; err != nil {
-			return nil, 0, err
-		}
+		qry = qry.Where("name LIKE ?", "%"+search+"%")
 	}
 
-	offset := (page - 1) * limit
-
-	if offset < 0 {
-		offset = 0
+	if err := qry.Session(&gorm.Session{}).Count(&totalCount).Error; err != nil {
+		logrus.Error("Model : Cannot count users, ", err.Error())
+		return nil, 0, err
 	}
 
-	if search != "" {
-		if err := um.db.Where("name LIKE ?", "%"+search+"%").
-			Offset(offset).
-			Limit(limit).
-			Find(&Users).Error; err != nil {
-			return nil, 0, err
-		}
-	} else {
-		if err := um.db.Offset(offset).
-			Limit(limit).
-			Find(&Users).Error; err != nil {
-			return nil, 0, err
-		}
+	if err := page.Keyset(qry, "id", false).Find(&users).Error; err != nil {
+		logrus.Error("Model : Cannot get users, ", err.Error())
+		return nil, 0, err
 	}
 
-	return Users, totalCount, nil
+	return users, totalCount, nil
 }
 
 func (um *UsersModel) SelectById(userId int) *User {
 	var data = User{}
-	if err := um.db.Preload("cartz").Where("id = ?", userId).First(&data).Error; err != nil {
+	if err := um.db.Preload("Carts").Where("id = ?", userId).First(&data).Error; err != nil {
 		logrus.Error("Model : Data with that ID was not found, ", err.Error())
 		return nil
 	}
@@ -203,3 +212,118 @@ func (um *UsersModel) Delete(userId int) bool {
 
 	return true
 }
+
+// LoginWithIdentity returns the customer behind an external identity. The
+// customer is found by a previously linked identity, then by verified email,
+// and is created when neither exists. Lookup, create and link run in one
+// transaction so a failed link leaves no account behind.
+func (um *UsersModel) LoginWithIdentity(identity helper.OIDCIdentity) (*User, error) {
+	var data = User{}
+
+	err := um.db.Transaction(func(tx *gorm.DB) error {
+		var link = UserIdentity{}
+		if err := tx.Where("issuer = ? AND subject = ?", identity.Issuer, identity.Subject).Limit(1).Find(&link).Error; err != nil {
+			return err
+		}
+		if link.Id != 0 {
+			return linkedUser(tx, link.UserId, &data)
+		}
+
+		if identity.Email == "" || !identity.EmailVerified {
+			return ErrIdentityUnverified
+		}
+		if utf8.RuneCountInString(identity.Email) > maxEmailLength {
+			return ErrIdentityEmailTooLong
+		}
+
+		if err := tx.Where("email = ?", identity.Email).Order("id").Limit(1).Find(&data).Error; err != nil {
+			return err
+		}
+		if data.Id == 0 {
+			var deleted int64
+			if err := tx.Unscoped().Model(&User{}).Where("email = ? AND deleted_at IS NOT NULL", identity.Email).Count(&deleted).Error; err != nil {
+				return err
+			}
+			if deleted > 0 {
+				return ErrAccountDeleted
+			}
+
+			newUser, err := newIdentityUser(identity)
+			if err != nil {
+				return err
+			}
+			if err := tx.Create(&newUser).Error; err != nil {
+				return err
+			}
+			data = newUser
+		}
+
+		link = UserIdentity{UserId: data.Id, Issuer: identity.Issuer, Subject: identity.Subject}
+		return tx.Create(&link).Error
+	})
+	if err != nil {
+		if !errors.Is(err, ErrIdentityUnverified) && !errors.Is(err, ErrIdentityEmailTooLong) && !errors.Is(err, ErrAccountDeleted) {
+			logrus.Error("Model : Cannot login with identity, ", err.Error())
+		}
+		return nil, err
+	}
+
+	return &data, nil
+}
+
+func linkedUser(tx *gorm.DB, userId int, data *User) error {
+	if err := tx.Unscoped().Where("id = ?", userId).First(data).Error; err != nil {
+		return err
+	}
+	if data.DeletedAt.Valid {
+		return ErrAccountDeleted
+	}
+	return nil
+}
+
+func newIdentityUser(identity helper.OIDCIdentity) (User, error) {
+	suffix, err := helper.GenerateRandomToken(3)
+	if err != nil {
+		return User{}, err
+	}
+	password, err := helper.GenerateRandomToken(32)
+	if err != nil {
+		return User{}, err
+	}
+	hashpwd, err := helper.HashPassword(password)
+	if err != nil {
+		return User{}, err
+	}
+
+	var local = truncateRunes(strings.Split(identity.Email, "@")[0], 18)
+	var name = truncateRunes(identity.Name, 100)
+	if name == "" {
+		name = local
+	}
+
+	return User{
+		Name:     name,
+		Username: local + "_" + suffix,
+		Password: hashpwd,
+		Email:    identity.Email,
+		Gender:   "u",
+	}, nil
+}
+
+func truncateRunes(value string, limit int) string {
+	var runes = []rune(value)
+	if len(runes) > limit {
+		return string(runes[:limit])
+	}
+	return value
+}
+
+func (um *UsersModel) SetEmailOptOut(userId int, optOut bool) bool {
+	var qry = um.db.Model(&User{}).Where("id = ?", userId).Update("email_opt_out", optOut)
+	if qry.Error != nil {
+		logrus.Error("Model : Cannot update email preference, ", qry.Error.Error())
+		return false
+	}
+
+	return qry.RowsAffected > 0
+}
@@ -0,0 +1,130 @@
+package model
+
+import (
+	"errors"
+	"rentcamp/helper"
+	"strings"
+	"testing"
+)
+
+func TestUserLoginChecksPassword(t *testing.T) {
+	var db = newTestDB(t)
+	var users = NewUsersModel(db)
+
+	hashed, _ := helper.HashPassword("pass")
+	mustCreate(t, db, &User{Name: "Budi", Username: "budi", Password: hashed, Email: "budi@example.com", Gender: "m"})
+	mustCreate(t, db, &User{Name: "Sari", Username: "sari", Password: "legacy", Email: "sari@example.com", Gender: "f"})
+
+	var tests = []struct {
+		username string
+		password string
+		wantOK   bool
+	}{
+		{"budi", "pass", true},
+		{"budi", "wrong", false},
+		{"budi", "", false},
+		{"nobody", "pass", false},
+		{"sari", "legacy", true},
+		{"sari", "legacy", true},
+	}
+
+	for _, tt := range tests {
+		if got := users.Login(tt.username, tt.password); (got != nil) != tt.wantOK {
+			t.Errorf("Login(%q, %q) = %v, want ok %v", tt.username, tt.password, got, tt.wantOK)
+		}
+	}
+
+	var sari = User{}
+	db.Where("username = ?", "sari").First(&sari)
+	if !helper.IsHashedPassword(sari.Password) {
+		t.Fatalf("legacy password should be rehashed, got %q", sari.Password)
+	}
+}
+
+func TestLoginWithIdentity(t *testing.T) {
+	var db = newTestDB(t)
+	var users = NewUsersModel(db)
+
+	var existing = User{Name: "Budi", Username: "budi", Password: "x", Email: "budi@example.com", Gender: "m"}
+	mustCreate(t, db, &existing)
+	var removed = User{Name: "Sari", Username: "sari", Password: "x", Email: "sari@example.com", Gender: "f"}
+	mustCreate(t, db, &removed)
+	if err := db.Delete(&removed).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var identity = func(subject string, email string, verified bool) helper.OIDCIdentity {
+		return helper.OIDCIdentity{Issuer: "https://id.example.com", Subject: subject, Email: email, EmailVerified: verified, Name: "Someone"}
+	}
+
+	var tests = []struct {
+		name     string
+		identity helper.OIDCIdentity
+		wantId   int
+		wantErr  error
+	}{
+		{"links an account with the same email", identity("sub-budi", "budi@example.com", true), existing.Id, nil},
+		{"finds the linked identity again", identity("sub-budi", "", false), existing.Id, nil},
+		{"unverified email", identity("sub-new", "new@example.com", false), 0, ErrIdentityUnverified},
+		{"email longer than the column", identity("sub-long", strings.Repeat("a", 40)+"@example.com", true), 0, ErrIdentityEmailTooLong},
+		{"soft-deleted account with the same email", identity("sub-sari", "sari@example.com", true), 0, ErrAccountDeleted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := users.LoginWithIdentity(tt.identity)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && got.Id != tt.wantId {
+				t.Fatalf("user id = %d, want %d", got.Id, tt.wantId)
+			}
+		})
+	}
+
+	var count int64
+	db.Unscoped().Model(&User{}).Where("email = ?", "sari@example.com").Count(&count)
+	if count != 1 {
+		t.Fatalf("a deleted account must not get a duplicate, found %d rows", count)
+	}
+}
+
+func TestLoginWithIdentityCreatesCustomer(t *testing.T) {
+	var db = newTestDB(t)
+	var users = NewUsersModel(db)
+
+	var identity = helper.OIDCIdentity{Issuer: "https://id.example.com", Subject: "sub-1", Email: "dewi.lestari.panjang.sekali@example.com", EmailVerified: true}
+	got, err := users.LoginWithIdentity(identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "dewi.lestari.panja" {
+		t.Errorf("name should fall back to the email local part, got %q", got.Name)
+	}
+	if !strings.HasPrefix(got.Username, "dewi.lestari.panja_") || len(got.Username) > 25 {
+		t.Errorf("username %q does not fit the column", got.Username)
+	}
+
+	again, err := users.LoginWithIdentity(identity)
+	if err != nil || again.Id != got.Id {
+		t.Fatalf("second login should return the same customer, got %+v, %v", again, err)
+	}
+}
+
+func TestLoginWithIdentityDeletedLinkedAccount(t *testing.T) {
+	var db = newTestDB(t)
+	var users = NewUsersModel(db)
+
+	var identity = helper.OIDCIdentity{Issuer: "https://id.example.com", Subject: "sub-1", Email: "dewi@example.com", EmailVerified: true}
+	got, err := users.LoginWithIdentity(identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !users.Delete(got.Id) {
+		t.Fatal("Delete failed")
+	}
+
+	if _, err := users.LoginWithIdentity(identity); !errors.Is(err, ErrAccountDeleted) {
+		t.Fatalf("err = %v, want ErrAccountDeleted", err)
+	}
+}
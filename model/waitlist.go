@@ -0,0 +1,312 @@
+package model
+
+import (
+	"errors"
+	"rentcamp/pagination"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	WaitlistStatusWaiting   = "waiting"
+	WaitlistStatusOffered   = "offered"
+	WaitlistStatusFulfilled = "fulfilled"
+	WaitlistStatusExpired   = "expired"
+	WaitlistStatusCancelled = "cancelled"
+)
+
+var (
+	ErrStillAvailable       = errors.New("product is still available for those dates, add it to your cart instead")
+	ErrWaitlistNotFound     = errors.New("waitlist entry not found")
+	ErrAlreadyOnWaitlist    = errors.New("you are already on the waitlist for those dates")
+	ErrWaitlistInvalidInput = errors.New("quantity must be at least 1 and dates must be valid")
+)
+
+// WaitlistEntry queues a customer for a product that is fully booked for
+// the requested dates. Entries are served first come, first served.
+type WaitlistEntry struct {
+	Id             int             `gorm:"primaryKey" json:"id"`
+	ProductId      int             `gorm:"index;not null" json:"product_id"`
+	UserId         int             `gorm:"index;not null" json:"user_id"`
+	Quantity       int             `json:"quantity"`
+	StartDate      Date            `json:"start_date"`
+	EndDate        Date            `json:"end_date"`
+	Status         string          `gorm:"type:varchar(20);index;not null" json:"status"`
+	HoldId         *int            `json:"-"`
+	OfferExpiresAt *time.Time      `json:"offer_expires_at"`
+	CreatedAt      time.Time       `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"created_at"`
+	Product        ProductResponse `gorm:"foreignKey:ProductId" json:"product"`
+}
+
+// WaitlistOffer is an entry that was just given an exclusive hold, with
+// the details needed to tell the customer.
+type WaitlistOffer struct {
+	Entry       WaitlistEntry
+	Name        string
+	Email       string
+	ProductName string
+}
+
+// FreedStock is a number of units of a product that became available again
+// for a rental period, after a cancellation, a return or an expired hold.
+type FreedStock struct {
+	ProductId int
+	Quantity  int
+	StartDate Date
+	EndDate   Date
+}
+
+type WaitlistModelInterface interface {
+	Join(entry WaitlistEntry) (*WaitlistEntry, error)
+	Leave(userID int, entryID int) bool
+	SelectByUser(userID int, page pagination.Page) []WaitlistEntry
+	OfferFreedStock(freed FreedStock, holdFor time.Duration) []WaitlistOffer
+	ExpireOffers() ([]FreedStock, error)
+}
+
+type WaitlistModel struct {
+	db *gorm.DB
+}
+
+func NewWaitlistModel(db *gorm.DB) WaitlistModelInterface {
+	return &WaitlistModel{
+		db: db,
+	}
+}
+
+// Join puts the customer on the waitlist. Joining is refused while the
+// product can still be rented for those dates.
+func (wm *WaitlistModel) Join(entry WaitlistEntry) (*WaitlistEntry, error) {
+	if entry.Quantity < 1 || entry.StartDate.IsZero() || entry.EndDate.IsZero() ||
+		entry.StartDate.Before(Today().Time) || entry.EndDate.Before(entry.StartDate.Time) {
+		return nil, ErrWaitlistInvalidInput
+	}
+
+	if err := wm.db.Where("id = ?", entry.ProductId).First(&Product{}).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+
+	available, err := availableStock(wm.db, entry.ProductId, 0, entry.StartDate, entry.EndDate, entry.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if available >= entry.Quantity {
+		return nil, ErrStillAvailable
+	}
+
+	var count int64
+	if err := wm.db.Model(&WaitlistEntry{}).
+		Where("user_id = ? AND product_id = ? AND start_date = ? AND end_date = ?", entry.UserId, entry.ProductId, entry.StartDate, entry.EndDate).
+		Where("status IN ?", []string{WaitlistStatusWaiting, WaitlistStatusOffered}).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrAlreadyOnWaitlist
+	}
+
+	entry.Id = 0
+	entry.Status = WaitlistStatusWaiting
+	entry.HoldId = nil
+	entry.OfferExpiresAt = nil
+	if err := wm.db.Create(&entry).Error; err != nil {
+		logrus.Error("Waitlist Model: Error joining waitlist, ", err.Error())
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// Leave cancels a waiting entry, giving back any units held for it.
+func (wm *WaitlistModel) Leave(userID int, entryID int) bool {
+	var entry = WaitlistEntry{}
+	if err := wm.db.Where("id = ? AND user_id = ? AND status IN ?", entryID, userID, []string{WaitlistStatusWaiting, WaitlistStatusOffered}).
+		First(&entry).Error; err != nil {
+		return false
+	}
+
+	err := wm.db.Transaction(func(tx *gorm.DB) error {
+		if entry.HoldId != nil {
+			if err := tx.Model(&InventoryHold{}).Where("id = ? AND released_at IS NULL", *entry.HoldId).Update("released_at", time.Now()).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&entry).Update("status", WaitlistStatusCancelled).Error
+	})
+	if err != nil {
+		logrus.Error("Waitlist Model: Error leaving waitlist, ", err.Error())
+		return false
+	}
+
+	return true
+}
+
+func (wm *WaitlistModel) SelectByUser(userID int, page pagination.Page) []WaitlistEntry {
+	var res = []WaitlistEntry{}
+	var qry = wm.db.Preload("Product").Where("user_id = ? AND status IN ?", userID, []string{WaitlistStatusWaiting, WaitlistStatusOffered})
+	if err := page.Keyset(qry, "id", false).Find(&res).Error; err != nil {
+		logrus.Error("Waitlist Model: Error listing waitlist, ", err.Error())
+		return nil
+	}
+	return res
+}
+
+// OfferFreedStock walks the waitlist entries overlapping the freed period
+// in the order they joined and gives each an exclusive hold for holdFor.
+// Entries are served strictly first come, first served: when the entry at
+// the front does not fit the available stock, the entries behind it keep
+// waiting too. Offering stops once the freed units are handed out.
+func (wm *WaitlistModel) OfferFreedStock(freed FreedStock, holdFor time.Duration) []WaitlistOffer {
+	var offers = []WaitlistOffer{}
+
+	err := wm.db.Transaction(func(tx *gorm.DB) error {
+		var product = Product{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", freed.ProductId).First(&product).Error; err != nil {
+			return err
+		}
+
+		var entries = []WaitlistEntry{}
+		if err := tx.Where("product_id = ? AND status = ? AND end_date >= ?", freed.ProductId, WaitlistStatusWaiting, Today()).
+			Where("start_date <= ? AND end_date >= ?", freed.EndDate, freed.StartDate).
+			Order("id").Find(&entries).Error; err != nil {
+			return err
+		}
+
+		var offered = 0
+		for _, entry := range entries {
+			if offered >= freed.Quantity {
+				break
+			}
+
+			available, err := availableStock(tx, freed.ProductId, 0, entry.StartDate, entry.EndDate, 0)
+			if err != nil {
+				return err
+			}
+			if available < entry.Quantity {
+				break
+			}
+
+			var expiresAt = time.Now().Add(holdFor)
+			var hold = InventoryHold{
+				UserId:    entry.UserId,
+				ProductId: freed.ProductId,
+				Quantity:  entry.Quantity,
+				StartDate: entry.StartDate,
+				EndDate:   entry.EndDate,
+				ExpiresAt: expiresAt,
+			}
+			if err := tx.Create(&hold).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&entry).Updates(map[string]any{
+				"status":           WaitlistStatusOffered,
+				"hold_id":          hold.Id,
+				"offer_expires_at": expiresAt,
+			}).Error; err != nil {
+				return err
+			}
+
+			var user = User{}
+			if err := tx.Select("id", "name", "email").Where("id = ?", entry.UserId).First(&user).Error; err != nil {
+				return err
+			}
+
+			entry.Status = WaitlistStatusOffered
+			entry.HoldId = &hold.Id
+			entry.OfferExpiresAt = &expiresAt
+			offers = append(offers, WaitlistOffer{Entry: entry, Name: user.Name, Email: user.Email, ProductName: product.Name})
+			offered += entry.Quantity
+		}
+
+		return nil
+	})
+	if err != nil {
+		logrus.Error("Waitlist Model: Error offering freed stock, ", err.Error())
+		return nil
+	}
+
+	return offers
+}
+
+// ExpireOffers ends offers whose hold ran out and returns the units they
+// held so they can be offered to the next customer in line. Every offer is
+// expired together with its hold, or not at all.
+func (wm *WaitlistModel) ExpireOffers() ([]FreedStock, error) {
+	var freed = []FreedStock{}
+
+	err := wm.db.Transaction(func(tx *gorm.DB) error {
+		var now = time.Now()
+
+		var entries = []WaitlistEntry{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("status = ? AND offer_expires_at <= ?", WaitlistStatusOffered, now).Find(&entries).Error; err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			if err := tx.Model(&entry).Update("status", WaitlistStatusExpired).Error; err != nil {
+				return err
+			}
+			if entry.HoldId != nil {
+				if err := tx.Model(&InventoryHold{}).Where("id = ? AND released_at IS NULL", *entry.HoldId).Update("released_at", now).Error; err != nil {
+					return err
+				}
+			}
+			freed = append(freed, FreedStock{ProductId: entry.ProductId, Quantity: entry.Quantity, StartDate: entry.StartDate, EndDate: entry.EndDate})
+		}
+
+		return nil
+	})
+	if err != nil {
+		logrus.Error("Waitlist Model: Error expiring offers, ", err.Error())
+		return nil, err
+	}
+
+	return freed, nil
+}
+
+// fulfilWaitlist closes the offers a customer just checked out. An offer is
+// fulfilled only when an order line covers its dates and quantity; its
+// waitlist hold is then released because the order holds cover the units.
+// Offers the order does not cover keep their hold until they expire.
+func fulfilWaitlist(tx *gorm.DB, userID int, lines []OrderItem) error {
+	var productIDs = []int{}
+	var left = make([]int, len(lines))
+	for i, line := range lines {
+		productIDs = append(productIDs, line.ProductId)
+		left[i] = line.Quantity
+	}
+
+	var entries = []WaitlistEntry{}
+	if err := tx.Where("user_id = ? AND product_id IN ? AND status = ?", userID, productIDs, WaitlistStatusOffered).
+		Order("id").Find(&entries).Error; err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		for i, line := range lines {
+			if line.ProductId != entry.ProductId || left[i] < entry.Quantity ||
+				line.StartDate.After(entry.StartDate.Time) || line.EndDate.Before(entry.EndDate.Time) {
+				continue
+			}
+			left[i] -= entry.Quantity
+
+			if entry.HoldId != nil {
+				if err := tx.Model(&InventoryHold{}).Where("id = ? AND released_at IS NULL", *entry.HoldId).Update("released_at", time.Now()).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&entry).Update("status", WaitlistStatusFulfilled).Error; err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return nil
+}
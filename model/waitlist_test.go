@@ -0,0 +1,109 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOfferFreedStockServesTheLineInOrder(t *testing.T) {
+	var db = newTestDB(t)
+	var orders = NewOrdersModel(db)
+	var waitlist = NewWaitlistModel(db)
+	var product = newTestProduct(t, db, 2, 100)
+
+	var first = checkout(t, db, 10, product, 1, days(3), days(5))
+	var second = checkout(t, db, 11, product, 1, days(3), days(5))
+
+	var entries = []WaitlistEntry{
+		{UserId: 20, Quantity: 1, StartDate: days(3), EndDate: days(5)},
+		{UserId: 21, Quantity: 2, StartDate: days(4), EndDate: days(4)},
+		{UserId: 22, Quantity: 1, StartDate: days(3), EndDate: days(5)},
+		{UserId: 23, Quantity: 1, StartDate: days(10), EndDate: days(12)},
+	}
+	for i := range entries {
+		mustCreate(t, db, &User{Id: entries[i].UserId, Name: "Camper", Email: "camper@example.com"})
+		entries[i].ProductId = product.Id
+		entries[i].Status = WaitlistStatusWaiting
+		mustCreate(t, db, &entries[i])
+	}
+
+	var offeredTo = func(offers []WaitlistOffer) []int {
+		var users = []int{}
+		for _, offer := range offers {
+			users = append(users, offer.Entry.UserId)
+		}
+		return users
+	}
+	var freedBy = func(order *Order) FreedStock {
+		var item = order.Items[0]
+		return FreedStock{ProductId: item.ProductId, Quantity: item.Quantity, StartDate: item.StartDate, EndDate: item.EndDate}
+	}
+
+	if _, err := orders.Cancel(first.Id, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := offeredTo(waitlist.OfferFreedStock(freedBy(first), time.Hour)); len(got) != 1 || got[0] != 20 {
+		t.Fatalf("one freed unit offered to %v, want only the first in line", got)
+	}
+
+	if _, err := orders.Cancel(second.Id, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := offeredTo(waitlist.OfferFreedStock(freedBy(second), time.Hour)); len(got) != 0 {
+		t.Fatalf("offered to %v, want nobody to skip the customer at the front", got)
+	}
+
+	db.Model(&WaitlistEntry{}).Where("id = ?", entries[0].Id).Update("offer_expires_at", time.Now().Add(-time.Minute))
+	freed, err := waitlist.ExpireOffers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(freed) != 1 || freed[0].ProductId != product.Id || freed[0].Quantity != 1 {
+		t.Fatalf("freed = %+v, want the unit of the expired offer", freed)
+	}
+
+	var expired = WaitlistEntry{}
+	db.Where("id = ?", entries[0].Id).First(&expired)
+	var hold = InventoryHold{}
+	db.Where("id = ?", *expired.HoldId).First(&hold)
+	if expired.Status != WaitlistStatusExpired || hold.ReleasedAt == nil {
+		t.Fatal("an expired offer must be closed and its hold released")
+	}
+
+	if got := offeredTo(waitlist.OfferFreedStock(freed[0], time.Hour)); len(got) != 1 || got[0] != 21 {
+		t.Fatalf("offered to %v, want the next customer in line", got)
+	}
+}
+
+func TestCheckoutFulfilsOnlyCoveredOffers(t *testing.T) {
+	var db = newTestDB(t)
+	var product = newTestProduct(t, db, 5, 100)
+
+	var entries = []WaitlistEntry{
+		{Quantity: 1, StartDate: days(3), EndDate: days(5)},
+		{Quantity: 1, StartDate: days(10), EndDate: days(12)},
+		{Quantity: 3, StartDate: days(3), EndDate: days(4)},
+	}
+	for i := range entries {
+		var hold = InventoryHold{UserId: 30, ProductId: product.Id, Quantity: entries[i].Quantity,
+			StartDate: entries[i].StartDate, EndDate: entries[i].EndDate, ExpiresAt: time.Now().Add(time.Hour)}
+		mustCreate(t, db, &hold)
+		entries[i].UserId = 30
+		entries[i].ProductId = product.Id
+		entries[i].Status = WaitlistStatusOffered
+		entries[i].HoldId = &hold.Id
+		mustCreate(t, db, &entries[i])
+	}
+
+	checkout(t, db, 30, product, 2, days(3), days(5))
+
+	for i, want := range []string{WaitlistStatusFulfilled, WaitlistStatusOffered, WaitlistStatusOffered} {
+		var entry = WaitlistEntry{}
+		db.Where("id = ?", entries[i].Id).First(&entry)
+		var hold = InventoryHold{}
+		db.Where("id = ?", *entry.HoldId).First(&hold)
+		if entry.Status != want || (hold.ReleasedAt != nil) != (want == WaitlistStatusFulfilled) {
+			t.Errorf("entry %d: status %q with hold released %v, want %q", i, entry.Status, hold.ReleasedAt != nil, want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package model
+
+import (
+	"errors"
+	"rentcamp/pagination"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WishlistItem saves a product for later without committing to dates.
+// LastPrice and LastInStock remember what the customer was last told, so
+// the notifier only emails about real changes.
+type WishlistItem struct {
+	Id          int             `gorm:"primaryKey" json:"id"`
+	UserId      int             `gorm:"uniqueIndex:idx_wishlist_product;not null" json:"user_id"`
+	ProductId   int             `gorm:"uniqueIndex:idx_wishlist_product;not null" json:"product_id"`
+	LastPrice   int             `json:"price_when_saved"`
+	LastInStock bool            `json:"-"`
+	CreatedAt   time.Time       `gorm:"type:timestamp DEFAULT CURRENT_TIMESTAMP" json:"created_at"`
+	Product     ProductResponse `gorm:"foreignKey:ProductId" json:"product"`
+}
+
+// WishlistWatch is a wishlist entry joined with its owner and the current
+// state of the product, as read by the notifier.
+type WishlistWatch struct {
+	Id          int
+	UserId      int
+	Name        string
+	Email       string
+	EmailOptOut bool
+	ProductId   int
+	ProductName string
+	Price       int
+	Stock       int
+	LastPrice   int
+	LastInStock bool
+}
+
+type WishlistModelInterface interface {
+	Add(userID int, productID int) (*WishlistItem, error)
+	Remove(userID int, productID int) bool
+	List(userID int, page pagination.Page) []WishlistItem
+	Has(userID int, productID int) bool
+	Watches() []WishlistWatch
+	UpdateWatch(id int, price int, inStock bool) bool
+}
+
+type WishlistModel struct {
+	db *gorm.DB
+}
+
+func NewWishlistModel(db *gorm.DB) WishlistModelInterface {
+	return &WishlistModel{
+		db: db,
+	}
+}
+
+// Add saves the product to the user's wishlist. Saving a product twice
+// keeps the original entry.
+func (wm *WishlistModel) Add(userID int, productID int) (*WishlistItem, error) {
+	var product = Product{}
+	if err := wm.db.Where("id = ?", productID).First(&product).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+
+	var item = WishlistItem{
+		UserId:      userID,
+		ProductId:   productID,
+		LastPrice:   product.Price,
+		LastInStock: product.Stock > 0,
+	}
+	if err := wm.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&item).Error; err != nil {
+		logrus.Error("Wishlist Model: Error adding item, ", err.Error())
+		return nil, err
+	}
+
+	var res = WishlistItem{}
+	if err := wm.db.Preload("Product").Where("user_id = ? AND product_id = ?", userID, productID).First(&res).Error; err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (wm *WishlistModel) Remove(userID int, productID int) bool {
+	var qry = wm.db.Where("user_id = ? AND product_id = ?", userID, productID).Delete(&WishlistItem{})
+	if qry.Error != nil {
+		logrus.Error("Wishlist Model: Error removing item, ", qry.Error.Error())
+		return false
+	}
+	return qry.RowsAffected > 0
+}
+
+func (wm *WishlistModel) List(userID int, page pagination.Page) []WishlistItem {
+	var res = []WishlistItem{}
+	if err := page.Keyset(wm.db.Preload("Product").Where("user_id = ?", userID), "id", true).Find(&res).Error; err != nil {
+		logrus.Error("Wishlist Model: Error listing items, ", err.Error())
+		return nil
+	}
+	return res
+}
+
+func (wm *WishlistModel) Has(userID int, productID int) bool {
+	var count int64
+	if err := wm.db.Model(&WishlistItem{}).Where("user_id = ? AND product_id = ?", userID, productID).Count(&count).Error; err != nil {
+		logrus.Error("Wishlist Model: Error checking item, ", err.Error())
+		return false
+	}
+	return count > 0
+}
+
+func (wm *WishlistModel) Watches() []WishlistWatch {
+	var res = []WishlistWatch{}
+	if err := wm.db.Model(&WishlistItem{}).
+		Select("wishlist_items.id, wishlist_items.user_id, users.name, users.email, users.email_opt_out, wishlist_items.product_id, " +
+			"products.name AS product_name, products.price, products.stock, wishlist_items.last_price, wishlist_items.last_in_stock").
+		Joins("JOIN users ON users.id = wishlist_items.user_id AND users.deleted_at IS NULL").
+		Joins("JOIN products ON products.id = wishlist_items.product_id AND products.deleted_at IS NULL").
+		Scan(&res).Error; err != nil {
+		logrus.Error("Wishlist Model: Error reading watches, ", err.Error())
+		return nil
+	}
+	return res
+}
+
+func (wm *WishlistModel) UpdateWatch(id int, price int, inStock bool) bool {
+	if err := wm.db.Model(&WishlistItem{}).Where("id = ?", id).
+		Updates(map[string]any{"last_price": price, "last_in_stock": inStock}).Error; err != nil {
+		logrus.Error("Wishlist Model: Error updating watch, ", err.Error())
+		return false
+	}
+	return true
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"errors"
+	"rentcamp/pagination"
+	"testing"
+)
+
+func TestWishlist(t *testing.T) {
+	var db = newTestDB(t)
+	var wishlist = NewWishlistModel(db)
+	var product = newTestProduct(t, db, 0, 100)
+
+	first, err := wishlist.Add(10, product.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.LastPrice != 100 || first.LastInStock || first.Product.Name != product.Name {
+		t.Fatalf("saved item = %+v, want price 100, out of stock and the product", first)
+	}
+
+	db.Model(&Product{}).Where("id = ?", product.Id).Update("price", 80)
+	again, err := wishlist.Add(10, product.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Id != first.Id || again.LastPrice != 100 {
+		t.Fatalf("saving twice should keep the original entry, got %+v", again)
+	}
+
+	if _, err := wishlist.Add(10, 999); !errors.Is(err, ErrProductNotFound) {
+		t.Fatalf("unknown product = %v, want ErrProductNotFound", err)
+	}
+
+	if items := wishlist.List(10, pagination.Page{Limit: 10}); len(items) != 1 {
+		t.Fatalf("wishlist has %d items, want 1", len(items))
+	}
+	if items := wishlist.List(11, pagination.Page{Limit: 10}); len(items) != 0 {
+		t.Fatalf("another customer's wishlist has %d items, want 0", len(items))
+	}
+
+	if !wishlist.Has(10, product.Id) || wishlist.Has(11, product.Id) {
+		t.Fatal("Has should only report the customer's own entries")
+	}
+	if !wishlist.Remove(10, product.Id) || wishlist.Remove(10, product.Id) {
+		t.Fatal("Remove should succeed once")
+	}
+}
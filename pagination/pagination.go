@@ -0,0 +1,235 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var (
+	ErrInvalidLimit  = errors.New("limit must be a positive number")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// cursor is what an opaque cursor string decodes to. Lists ordered by id
+// use After/Before (keyset), ranked lists such as search results use
+// Offset.
+type cursor struct {
+	After  int `json:"a,omitempty"`
+	Before int `json:"b,omitempty"`
+	Offset int `json:"o,omitempty"`
+}
+
+func (cur cursor) encode() string {
+	raw, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decode(raw string) (cursor, error) {
+	var cur = cursor{}
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return cur, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &cur); err != nil {
+		return cur, ErrInvalidCursor
+	}
+	if cur.After < 0 || cur.Before < 0 || cur.Offset < 0 || (cur.After > 0 && cur.Before > 0) {
+		return cur, ErrInvalidCursor
+	}
+	return cur, nil
+}
+
+// Page is one requested page of a list, read from ?limit and ?cursor.
+type Page struct {
+	Limit  int
+	cursor cursor
+	url    url.URL
+}
+
+// Parse reads the page from the request. A missing limit means
+// DefaultLimit and anything above MaxLimit is capped.
+func Parse(c echo.Context) (Page, error) {
+	var page = Page{Limit: DefaultLimit, url: *c.Request().URL}
+
+	if raw := c.QueryParam("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return page, ErrInvalidLimit
+		}
+		page.Limit = min(limit, MaxLimit)
+	}
+
+	if raw := c.QueryParam("cursor"); raw != "" {
+		cur, err := decode(raw)
+		if err != nil {
+			return page, err
+		}
+		page.cursor = cur
+	}
+
+	return page, nil
+}
+
+// First reports whether this is the first page of the list.
+func (p Page) First() bool {
+	return p.cursor == cursor{}
+}
+
+func (p Page) backward() bool {
+	return p.cursor.Before > 0
+}
+
+// Keyset orders qry by the integer column and restricts it to the rows
+// after (or before) the cursor. One extra row is fetched so KeysetResult
+// can tell whether there is another page.
+func (p Page) Keyset(qry *gorm.DB, column string, desc bool) *gorm.DB {
+	var ascending = desc == p.backward()
+
+	switch {
+	case p.cursor.After > 0 && desc:
+		qry = qry.Where(column+" < ?", p.cursor.After)
+	case p.cursor.After > 0:
+		qry = qry.Where(column+" > ?", p.cursor.After)
+	case p.cursor.Before > 0 && desc:
+		qry = qry.Where(column+" > ?", p.cursor.Before)
+	case p.cursor.Before > 0:
+		qry = qry.Where(column+" < ?", p.cursor.Before)
+	}
+
+	if ascending {
+		qry = qry.Order(column)
+	} else {
+		qry = qry.Order(column + " desc")
+	}
+
+	return qry.Limit(p.Limit + 1)
+}
+
+// Window returns the limit and offset for lists that cannot be walked by
+// id. The limit includes one extra row for OffsetResult.
+func (p Page) Window() (limit int, offset int) {
+	return p.Limit + 1, p.cursor.Offset
+}
+
+// Meta describes the returned page. Next and Prev are links to the
+// neighbouring pages and are null at either end of the list.
+type Meta struct {
+	Limit      int     `json:"limit"`
+	Count      int     `json:"count"`
+	Total      *int64  `json:"total,omitempty"`
+	NextCursor *string `json:"next_cursor"`
+	PrevCursor *string `json:"prev_cursor"`
+	Next       *string `json:"next"`
+	Prev       *string `json:"prev"`
+}
+
+// WithTotal adds the size of the whole list.
+func (m Meta) WithTotal(total int64) Meta {
+	m.Total = &total
+	return m
+}
+
+func (p Page) link(cur cursor) (*string, *string) {
+	var token = cur.encode()
+
+	var query = p.url.Query()
+	query.Set("cursor", token)
+	query.Set("limit", strconv.Itoa(p.Limit))
+
+	var target = url.URL{Path: p.url.Path, RawQuery: query.Encode()}
+	var link = target.String()
+
+	return &token, &link
+}
+
+func (p Page) meta(count int, next *cursor, prev *cursor) Meta {
+	var meta = Meta{Limit: p.Limit, Count: count}
+	if next != nil {
+		meta.NextCursor, meta.Next = p.link(*next)
+	}
+	if prev != nil {
+		meta.PrevCursor, meta.Prev = p.link(*prev)
+	}
+	return meta
+}
+
+// KeysetResult trims the extra row fetched by Keyset, puts the rows back in
+// list order and builds the meta for them. key returns the value of the
+// column the list was ordered by.
+func KeysetResult[T any](p Page, items []T, key func(T) int) ([]T, Meta) {
+	var more = len(items) > p.Limit
+	if more {
+		items = items[:p.Limit]
+	}
+	if items == nil {
+		items = []T{}
+	}
+
+	if p.backward() {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	if len(items) == 0 {
+		return items, p.meta(0, nil, nil)
+	}
+
+	var next, prev *cursor
+	if more || p.backward() {
+		next = &cursor{After: key(items[len(items)-1])}
+	}
+	if p.cursor.After > 0 || (p.backward() && more) {
+		prev = &cursor{Before: key(items[0])}
+	}
+
+	return items, p.meta(len(items), next, prev)
+}
+
+// OffsetResult trims the extra row fetched through Window and builds the
+// meta for the page.
+func OffsetResult[T any](p Page, items []T) ([]T, Meta) {
+	var more = len(items) > p.Limit
+	if more {
+		items = items[:p.Limit]
+	}
+	if items == nil {
+		items = []T{}
+	}
+
+	var next, prev *cursor
+	if more {
+		next = &cursor{Offset: p.cursor.Offset + p.Limit}
+	}
+	if p.cursor.Offset > 0 {
+		prev = &cursor{Offset: max(p.cursor.Offset-p.Limit, 0)}
+	}
+
+	return items, p.meta(len(items), next, prev)
+}
+
+// Slice pages through a list built in memory. all holds the list from its
+// start up to the end of the window returned by Window.
+func Slice[T any](p Page, all []T) ([]T, Meta) {
+	return OffsetResult(p, all[min(p.cursor.Offset, len(all)):])
+}
+
+// Response is the envelope every list endpoint returns.
+func Response(message string, data any, meta Meta) map[string]any {
+	return map[string]any{
+		"message": message,
+		"data":    data,
+		"meta":    meta,
+	}
+}
@@ -0,0 +1,234 @@
+package pagination
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type row struct {
+	Id int
+}
+
+func newContext(target string) echo.Context {
+	return echo.New().NewContext(httptest.NewRequest("GET", target, nil), httptest.NewRecorder())
+}
+
+func mustParse(t *testing.T, target string) Page {
+	t.Helper()
+	page, err := Parse(newContext(target))
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", target, err)
+	}
+	return page
+}
+
+func TestParse(t *testing.T) {
+	var tests = []struct {
+		name      string
+		target    string
+		wantLimit int
+		wantErr   error
+	}{
+		{"default limit", "/products", DefaultLimit, nil},
+		{"limit", "/products?limit=5", 5, nil},
+		{"limit above the maximum", "/products?limit=1000", MaxLimit, nil},
+		{"zero limit", "/products?limit=0", 0, ErrInvalidLimit},
+		{"limit that is not a number", "/products?limit=ten", 0, ErrInvalidLimit},
+		{"cursor that is not base64", "/products?cursor=***", 0, ErrInvalidCursor},
+		{"cursor that is not json", "/products?cursor=" + "bm90IGpzb24", 0, ErrInvalidCursor},
+		{"negative cursor", "/products?cursor=" + cursor{After: -1}.encode(), 0, ErrInvalidCursor},
+		{"cursor in both directions", "/products?cursor=" + cursor{After: 3, Before: 5}.encode(), 0, ErrInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := Parse(newContext(tt.target))
+			if err != tt.wantErr {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && page.Limit != tt.wantLimit {
+				t.Fatalf("limit = %d, want %d", page.Limit, tt.wantLimit)
+			}
+		})
+	}
+
+	if !mustParse(t, "/products").First() {
+		t.Fatal("a page without cursor must be the first")
+	}
+	if mustParse(t, "/products?cursor="+cursor{Offset: 20}.encode()).First() {
+		t.Fatal("a page with a cursor must not be the first")
+	}
+}
+
+func newTestDB(t *testing.T, rows int) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(&row{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= rows; i++ {
+		if err := db.Create(&row{Id: i}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// keysetPage loads the page of rows the target asks for.
+func keysetPage(t *testing.T, db *gorm.DB, target string, desc bool) ([]int, Meta) {
+	t.Helper()
+	var page = mustParse(t, target)
+	var rows = []row{}
+	if err := page.Keyset(db.Model(&row{}), "id", desc).Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	data, meta := KeysetResult(page, rows, func(r row) int { return r.Id })
+	var ids = []int{}
+	for _, r := range data {
+		ids = append(ids, r.Id)
+	}
+	return ids, meta
+}
+
+func deref(link *string) string {
+	if link == nil {
+		return ""
+	}
+	return *link
+}
+
+func TestKeysetWalksBothWays(t *testing.T) {
+	var db = newTestDB(t, 7)
+
+	var tests = []struct {
+		name string
+		desc bool
+		// steps follows the "next" or "prev" link of the previous page.
+		steps []string
+		want  []string
+	}{
+		{"ascending", false,
+			[]string{"", "next", "next", "prev", "prev"},
+			[]string{"[1 2 3]", "[4 5 6]", "[7]", "[4 5 6]", "[1 2 3]"}},
+		{"descending", true,
+			[]string{"", "next", "next", "prev", "prev"},
+			[]string{"[7 6 5]", "[4 3 2]", "[1]", "[4 3 2]", "[7 6 5]"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var target = "/rows?limit=3"
+			var meta Meta
+			for i, step := range tt.steps {
+				switch step {
+				case "next":
+					target = deref(meta.Next)
+				case "prev":
+					target = deref(meta.Prev)
+				}
+				if target == "" {
+					t.Fatalf("step %d: no %s link", i, step)
+				}
+
+				var ids []int
+				ids, meta = keysetPage(t, db, target, tt.desc)
+				if got := fmt.Sprint(ids); got != tt.want[i] {
+					t.Fatalf("step %d (%s): ids = %s, want %s", i, step, got, tt.want[i])
+				}
+			}
+		})
+	}
+
+	// The ends of the list have no link past them.
+	_, first := keysetPage(t, db, "/rows?limit=3", false)
+	if first.Prev != nil || first.Next == nil {
+		t.Fatalf("first page meta = %+v, want only a next link", first)
+	}
+	_, last := keysetPage(t, db, "/rows?limit=3&cursor="+cursor{After: 6}.encode(), false)
+	if last.Next != nil || last.Prev == nil {
+		t.Fatalf("last page meta = %+v, want only a prev link", last)
+	}
+	empty, meta := keysetPage(t, db, "/rows?limit=3&cursor="+cursor{After: 7}.encode(), false)
+	if len(empty) != 0 || meta.Next != nil || meta.Prev != nil {
+		t.Fatalf("past the end = %v %+v, want an empty page without links", empty, meta)
+	}
+}
+
+func TestLinksKeepTheQuery(t *testing.T) {
+	var page = mustParse(t, "/products?q=tenda&sort=price&limit=2")
+	_, meta := OffsetResult(page, []int{1, 2, 3})
+
+	next, err := url.Parse(deref(meta.Next))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var query = next.Query()
+	if next.Path != "/products" || query.Get("q") != "tenda" || query.Get("sort") != "price" || query.Get("limit") != "2" {
+		t.Fatalf("next link = %s, want the same list with a cursor", next)
+	}
+	if query.Get("cursor") != deref(meta.NextCursor) {
+		t.Fatalf("next link cursor = %q, want %q", query.Get("cursor"), deref(meta.NextCursor))
+	}
+}
+
+func TestOffsetResultAndSlice(t *testing.T) {
+	var all = []int{1, 2, 3, 4, 5}
+
+	var tests = []struct {
+		name     string
+		offset   int
+		want     string
+		wantNext bool
+		wantPrev bool
+	}{
+		{"first page", 0, "[1 2]", true, false},
+		{"middle page", 2, "[3 4]", true, true},
+		{"last page", 4, "[5]", false, true},
+		{"past the end", 10, "[]", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var target = "/rows?limit=2"
+			if tt.offset > 0 {
+				target += "&cursor=" + cursor{Offset: tt.offset}.encode()
+			}
+			var page = mustParse(t, target)
+
+			limit, offset := page.Window()
+			if limit != 3 || offset != tt.offset {
+				t.Fatalf("window = %d, %d, want 3, %d", limit, offset, tt.offset)
+			}
+
+			data, meta := Slice(page, all)
+			if got := fmt.Sprint(data); got != tt.want || meta.Count != len(data) {
+				t.Fatalf("data = %s (count %d), want %s", got, meta.Count, tt.want)
+			}
+			if (meta.Next != nil) != tt.wantNext || (meta.Prev != nil) != tt.wantPrev {
+				t.Fatalf("next %v prev %v, want next %v prev %v", meta.Next != nil, meta.Prev != nil, tt.wantNext, tt.wantPrev)
+			}
+		})
+	}
+
+	var page = mustParse(t, "/rows?limit=2&cursor="+cursor{Offset: 1}.encode())
+	_, meta := OffsetResult(page, []int{2, 3, 4})
+	prev, _ := decode(deref(meta.PrevCursor))
+	if prev.Offset != 0 {
+		t.Fatalf("prev offset = %d, want it clamped to 0", prev.Offset)
+	}
+}
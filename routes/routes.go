@@ -12,41 +12,226 @@ func RouteAdmin(e *echo.Echo, uc controller.AdminControllerInterface, cfg config
 	var user = e.Group("/admins")
 	user.POST("", uc.CreateUser())
 	user.POST("/login", uc.Login())
+	user.POST("/login/mfa", uc.LoginMFA())
+	user.POST("/login/mfa/enroll", uc.EnrollMFAChallenge())
+	user.POST("/login/mfa/activate", uc.ActivateMFAChallenge())
+
+	var admin = e.Group("/admins")
+	admin.Use(helper.Middleware(cfg))
+	admin.GET("", uc.GetAllAdmins(), helper.RequirePermission(helper.PermAdminManage))
+	admin.POST("/invites", uc.CreateInvite(), helper.RequirePermission(helper.PermAdminManage))
+	admin.PUT("/:id/disable", uc.DisableAdmin(), helper.RequirePermission(helper.PermAdminManage))
+	admin.PUT("/:id/enable", uc.EnableAdmin(), helper.RequirePermission(helper.PermAdminManage))
+	admin.DELETE("/:id", uc.DeleteAdmin(), helper.RequirePermission(helper.PermAdminManage))
+	admin.POST("/:id/unlock", uc.UnlockAdmin(), helper.RequirePermission(helper.PermAdminManage))
+	admin.PUT("/settings/2fa", uc.SetMFARequired(), helper.RequirePermission(helper.PermAdminManage))
+	admin.POST("/2fa/enroll", uc.EnrollTOTP(), helper.RequireStaff())
+	admin.POST("/2fa/verify", uc.VerifyTOTP(), helper.RequireStaff())
+	admin.DELETE("/2fa", uc.DisableTOTP(), helper.RequireStaff())
 }
 func RouteProduct(e *echo.Echo, cpc controller.ProductControllerInterface, cfg config.Config) {
 	var product = e.Group("/admins")
-	product.Use(helper.Middleware())
-	product.POST("/products", cpc.CreateProduct())
-	product.PUT("/products/:id", cpc.UpdateProduct())
-	product.DELETE("/products/:id", cpc.DeleteProduct())
+	product.Use(helper.Middleware(cfg))
+	product.POST("/products", cpc.CreateProduct(), helper.RequirePermission(helper.PermProductWrite))
+	product.PUT("/products/:id", cpc.UpdateProduct(), helper.RequirePermission(helper.PermProductWrite))
+	product.DELETE("/products/:id", cpc.DeleteProduct(), helper.RequirePermission(helper.PermProductWrite))
+	product.GET("/reports/search/zero-results", cpc.GetZeroResultQueries(), helper.RequirePermission(helper.PermReportRead))
+	product.POST("/transfers", cpc.CreateTransfer(), helper.RequirePermission(helper.PermInventoryWrite))
+	product.GET("/transfers", cpc.GetTransfers(), helper.RequirePermission(helper.PermInventoryWrite))
+	product.GET("/transfers/:id", cpc.GetTransferById(), helper.RequirePermission(helper.PermInventoryWrite))
+	product.POST("/transfers/:id/dispatch", cpc.DispatchTransfer(), helper.RequirePermission(helper.PermInventoryWrite))
+	product.POST("/transfers/:id/receive", cpc.ReceiveTransfer(), helper.RequirePermission(helper.PermInventoryWrite))
+	product.POST("/transfers/:id/cancel", cpc.CancelTransfer(), helper.RequirePermission(helper.PermInventoryWrite))
 
 	var admin = e.Group("/products")
-	admin.GET("", cpc.GetAllProduct())
-	admin.GET("/:id", cpc.GetProductById())
+	admin.GET("", cpc.GetAllProduct(), helper.RequireScope(helper.ScopeCatalogRead))
+	admin.GET("/suggest", cpc.Suggest(), helper.RequireScope(helper.ScopeCatalogRead))
+	admin.GET("/:id", cpc.GetProductById(), helper.RequireScope(helper.ScopeCatalogRead))
+	admin.POST("/:id/waitlist", cpc.JoinWaitlist(), helper.Middleware(cfg))
+
+	var waitlist = e.Group("/me/waitlist")
+	waitlist.Use(helper.Middleware(cfg))
+	waitlist.GET("", cpc.GetMyWaitlist())
+	waitlist.DELETE("/:id", cpc.LeaveWaitlist())
 }
 
 func RouteUser(e *echo.Echo, uc controller.UserControllerInterface, cfg config.Config) {
 	var user = e.Group("/customer")
-	user.Use(helper.Middleware())
-	user.GET("/:id", uc.GetUserById())
-	user.PUT("/:id", uc.UpdateUser())
-	user.DELETE("/:id", uc.DeleteUser())
+	user.Use(helper.Middleware(cfg))
+	user.GET("", uc.GetAllUsers(), helper.RequirePermission(helper.PermUserRead))
+	user.GET("/:id", uc.GetUserById(), helper.RequireSelfOrPermission("id", helper.PermUserRead))
+	user.PUT("/:id", uc.UpdateUser(), helper.RequireSelfOrPermission("id", helper.PermUserWrite))
+	user.DELETE("/:id", uc.DeleteUser(), helper.RequireSelfOrPermission("id", helper.PermUserWrite))
+	user.POST("/:id/unlock", uc.UnlockUser(), helper.RequirePermission(helper.PermUserWrite))
 
 	var customer = e.Group("/customer")
 	customer.POST("/login", uc.Login())
-	customer.GET("", uc.GetAllUsers())
+	customer.GET("/oidc/login", uc.OIDCLogin())
+	customer.GET("/oidc/callback", uc.OIDCCallback())
+	customer.GET("/unsubscribe", uc.Unsubscribe())
 	customer.POST("", uc.CreateUser())
 }
 
 func RouteCart(e *echo.Echo, cc controller.CartControllerInterface, cfg config.Config) {
 	var cart = e.Group("/carts")
-	cart.Use(helper.Middleware())
-	cart.POST("/:user_id", cc.CreateCart())
-	cart.GET("/:cart_id", cc.GetCartByCartId())
-	cart.POST("/:cart_id/items", cc.AddItemToCart())
-	cart.PUT("/:cart_id/items/:item_id", cc.UpdateCartItem())
-	cart.DELETE("/:cart_id/items/:item_id", cc.RemoveCartItem())
-	cart.GET("/:cart_id/items", cc.GetItemsInCart())
-	cart.DELETE("/:cart_id/items", cc.RemoveAllItemsFromCart())
-	cart.GET("/:cart_id/total", cc.GetTotalCartPrice())
+	cart.Use(helper.Middleware(cfg))
+	cart.POST("/:user_id", cc.CreateCart(), helper.RequireScope(helper.ScopeCartWrite))
+	cart.GET("/:cart_id", cc.GetCartByCartId(), helper.RequireScope(helper.ScopeCartRead), cc.RequireCartOwner())
+	cart.POST("/:cart_id/items", cc.AddItemToCart(), helper.RequireScope(helper.ScopeCartWrite), cc.RequireCartOwner())
+	cart.PUT("/:cart_id/items/:item_id", cc.UpdateCartItem(), helper.RequireScope(helper.ScopeCartWrite), cc.RequireCartOwner())
+	cart.DELETE("/:cart_id/items/:item_id", cc.RemoveCartItem(), helper.RequireScope(helper.ScopeCartWrite), cc.RequireCartOwner())
+	cart.PUT("/:cart_id/branch", cc.SetCartBranch(), helper.RequireScope(helper.ScopeCartWrite), cc.RequireCartOwner())
+	cart.GET("/:cart_id/items", cc.GetItemsInCart(), helper.RequireScope(helper.ScopeCartRead), cc.RequireCartOwner())
+	cart.DELETE("/:cart_id/items", cc.RemoveAllItemsFromCart(), helper.RequireScope(helper.ScopeCartWrite), cc.RequireCartOwner())
+	cart.GET("/:cart_id/total", cc.GetTotalCartPrice(), helper.RequireScope(helper.ScopeCartRead), cc.RequireCartOwner())
+
+	var me = e.Group("/me")
+	me.Use(helper.Middleware(cfg))
+	me.GET("/cart", cc.GetMyCart(), helper.RequireScope(helper.ScopeCartRead))
+	me.POST("/cart/merge", cc.MergeGuestCart())
+
+	var guest = e.Group("/guest/cart")
+	guest.POST("", cc.CreateGuestCart())
+	guest.GET("", cc.GetCartByCartId(), cc.RequireGuestCart())
+	guest.GET("/items", cc.GetItemsInCart(), cc.RequireGuestCart())
+	guest.POST("/items", cc.AddItemToCart(), cc.RequireGuestCart())
+	guest.PUT("/items/:item_id", cc.UpdateCartItem(), cc.RequireGuestCart())
+	guest.DELETE("/items/:item_id", cc.RemoveCartItem(), cc.RequireGuestCart())
+	guest.PUT("/branch", cc.SetCartBranch(), cc.RequireGuestCart())
+	guest.GET("/total", cc.GetTotalCartPrice(), cc.RequireGuestCart())
+
+	var report = e.Group("/admins/reports")
+	report.Use(helper.Middleware(cfg))
+	report.GET("/carts", cc.GetAbandonmentReport(), helper.RequirePermission(helper.PermReportRead))
+}
+
+func RouteApiKey(e *echo.Echo, akc controller.ApiKeyControllerInterface, cfg config.Config) {
+	var apiKey = e.Group("/admins/api-keys")
+	apiKey.Use(helper.Middleware(cfg))
+	apiKey.Use(helper.RequirePermission(helper.PermAPIKeyManage))
+	apiKey.POST("", akc.CreateApiKey())
+	apiKey.GET("", akc.GetAllApiKeys())
+	apiKey.DELETE("/:id", akc.RevokeApiKey())
+}
+
+func RouteOrder(e *echo.Echo, oc controller.OrderControllerInterface, cfg config.Config) {
+	var me = e.Group("/me")
+	me.Use(helper.Middleware(cfg))
+	me.POST("/cart/checkout", oc.Checkout())
+	me.GET("/orders", oc.GetMyOrders())
+	me.GET("/orders/:id", oc.GetMyOrderById())
+	me.POST("/orders/:id/cancel", oc.CancelMyOrder())
+
+	var admin = e.Group("/admins/orders")
+	admin.Use(helper.Middleware(cfg))
+	admin.GET("", oc.GetAllOrders(), helper.RequirePermission(helper.PermOrderRead))
+	admin.GET("/:id", oc.GetOrderById(), helper.RequirePermission(helper.PermOrderRead))
+	admin.POST("/:id/payment", oc.SetPaymentResult(), helper.RequirePermission(helper.PermOrderWrite))
+	admin.POST("/:id/cancel", oc.CancelOrder(), helper.RequirePermission(helper.PermOrderWrite))
+	admin.POST("/:id/pickup", oc.MarkPickedUp(), helper.RequirePermission(helper.PermOrderHandover))
+	admin.POST("/:id/return", oc.MarkReturned(), helper.RequirePermission(helper.PermOrderHandover))
+}
+
+func RouteWishlist(e *echo.Echo, wc controller.WishlistControllerInterface, cfg config.Config) {
+	var wishlist = e.Group("/me/wishlist")
+	wishlist.Use(helper.Middleware(cfg))
+	wishlist.Use(wc.RequireCustomer())
+	wishlist.GET("", wc.GetWishlist())
+	wishlist.POST("", wc.AddToWishlist())
+	wishlist.DELETE("/:product_id", wc.RemoveFromWishlist())
+	wishlist.POST("/:product_id/move-to-cart", wc.MoveToCart())
+}
+
+func RouteReview(e *echo.Echo, rc controller.ReviewControllerInterface, cfg config.Config) {
+	var product = e.Group("/products")
+	product.GET("/:id/reviews", rc.GetProductReviews(), helper.RequireScope(helper.ScopeCatalogRead))
+	product.POST("/:id/reviews", rc.CreateReview(), helper.Middleware(cfg))
+
+	var admin = e.Group("/admins/reviews")
+	admin.Use(helper.Middleware(cfg))
+	admin.Use(helper.RequirePermission(helper.PermReviewModerate))
+	admin.GET("", rc.GetReviewsForModeration())
+	admin.PUT("/:id/hide", rc.HideReview())
+	admin.PUT("/:id/show", rc.ShowReview())
+	admin.POST("/:id/reply", rc.ReplyReview())
+	admin.POST("/:id/flag", rc.FlagReview())
+}
+
+func RouteQuestion(e *echo.Echo, qc controller.QuestionControllerInterface, cfg config.Config) {
+	var product = e.Group("/products")
+	product.GET("/:id/questions", qc.GetProductQuestions(), helper.RequireScope(helper.ScopeCatalogRead))
+	product.POST("/:id/questions", qc.AskQuestion(), helper.Middleware(cfg))
+
+	var admin = e.Group("/admins/questions")
+	admin.Use(helper.Middleware(cfg))
+	admin.Use(helper.RequirePermission(helper.PermQuestionAnswer))
+	admin.GET("", qc.GetQuestionsForStaff())
+	admin.POST("/:id/answer", qc.AnswerQuestion())
+	admin.PUT("/:id/answer", qc.EditAnswer())
+	admin.PUT("/:id/hide", qc.HideQuestion())
+	admin.PUT("/:id/show", qc.ShowQuestion())
+	admin.DELETE("/:id", qc.DeleteQuestion())
+}
+
+func RouteRecommendation(e *echo.Echo, rc controller.RecommendationControllerInterface, cfg config.Config) {
+	var product = e.Group("/products")
+	product.GET("/:id/related", rc.GetRelatedProducts(), helper.RequireScope(helper.ScopeCatalogRead))
+
+	var me = e.Group("/me/recommendations")
+	me.Use(helper.Middleware(cfg))
+	me.GET("", rc.GetMyRecommendations())
+}
+
+func RouteTrip(e *echo.Echo, tc controller.TripControllerInterface, cfg config.Config) {
+	var template = e.Group("/trip-templates")
+	template.GET("", tc.GetTemplates(), helper.RequireScope(helper.ScopeCatalogRead))
+	template.GET("/:id", tc.GetTemplateById(), helper.RequireScope(helper.ScopeCatalogRead))
+
+	var plan = e.Group("/trip-plans")
+	plan.POST("", tc.PlanTrip(), helper.RequireScope(helper.ScopeCatalogRead))
+	plan.POST("/add-to-cart", tc.AddPlanToCart(), helper.Middleware(cfg))
+
+	var admin = e.Group("/admins/trip-templates")
+	admin.Use(helper.Middleware(cfg))
+	admin.Use(helper.RequirePermission(helper.PermProductWrite))
+	admin.POST("", tc.CreateTemplate())
+	admin.PUT("/:id", tc.UpdateTemplate())
+	admin.DELETE("/:id", tc.DeleteTemplate())
+}
+
+func RouteBranch(e *echo.Echo, bc controller.BranchControllerInterface, cfg config.Config) {
+	var branch = e.Group("/branches")
+	branch.GET("", bc.GetBranches(), helper.RequireScope(helper.ScopeCatalogRead))
+	branch.GET("/:id", bc.GetBranchById(), helper.RequireScope(helper.ScopeCatalogRead))
+
+	var product = e.Group("/products")
+	product.GET("/:id/availability", bc.GetProductAvailability(), helper.RequireScope(helper.ScopeCatalogRead))
+
+	var admin = e.Group("/admins/branches")
+	admin.Use(helper.Middleware(cfg))
+	admin.POST("", bc.CreateBranch(), helper.RequirePermission(helper.PermBranchManage))
+	admin.PUT("/:id", bc.UpdateBranch(), helper.RequirePermission(helper.PermBranchManage))
+	admin.DELETE("/:id", bc.DeleteBranch(), helper.RequirePermission(helper.PermBranchManage))
+	admin.GET("/:id/stock", bc.GetBranchStock(), helper.RequirePermission(helper.PermInventoryWrite))
+	admin.PUT("/:id/stock/:product_id", bc.SetBranchStock(), helper.RequirePermission(helper.PermInventoryWrite))
+}
+
+func RouteDelivery(e *echo.Echo, dc controller.DeliveryControllerInterface, cfg config.Config) {
+	var zone = e.Group("/delivery-zones")
+	zone.GET("", dc.GetZones())
+	zone.GET("/quote", dc.QuoteDelivery())
+
+	var slot = e.Group("/delivery-slots")
+	slot.GET("", dc.GetSlots())
+
+	var admin = e.Group("/admins")
+	admin.Use(helper.Middleware(cfg))
+	admin.POST("/delivery-zones", dc.CreateZone(), helper.RequirePermission(helper.PermDeliveryManage))
+	admin.PUT("/delivery-zones/:id", dc.UpdateZone(), helper.RequirePermission(helper.PermDeliveryManage))
+	admin.DELETE("/delivery-zones/:id", dc.DeleteZone(), helper.RequirePermission(helper.PermDeliveryManage))
+	admin.POST("/delivery-slots", dc.CreateSlot(), helper.RequirePermission(helper.PermDeliveryManage))
+	admin.PUT("/delivery-slots/:id", dc.UpdateSlot(), helper.RequirePermission(helper.PermDeliveryManage))
+	admin.DELETE("/delivery-slots/:id", dc.DeleteSlot(), helper.RequirePermission(helper.PermDeliveryManage))
+	admin.GET("/deliveries", dc.GetRuns(), helper.RequirePermission(helper.PermDeliveryRead))
+	admin.PUT("/deliveries/:order_id/driver", dc.AssignDriver(), helper.RequirePermission(helper.PermDeliveryManage))
 }
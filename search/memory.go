@@ -0,0 +1,139 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Field weights: a word in the name counts three times as much as the same
+// word in the description.
+var fieldWeights = []float64{3, 2, 2, 1}
+
+// MemoryIndex is an in-process inverted index. It needs no database, which
+// makes it handy for tests and small deployments.
+type MemoryIndex struct {
+	mu       sync.RWMutex
+	docs     map[int]Document
+	postings map[string]map[int][]int
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		docs:     map[int]Document{},
+		postings: map[string]map[int][]int{},
+	}
+}
+
+func fieldTexts(doc Document) []string {
+	return []string{doc.Name, doc.Category, strings.Join(doc.Tags, " "), doc.Description}
+}
+
+func (mi *MemoryIndex) Index(docs ...Document) error {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	for _, doc := range docs {
+		mi.remove(doc.ID)
+		mi.docs[doc.ID] = doc
+
+		for field, text := range fieldTexts(doc) {
+			for _, term := range Tokenize(text) {
+				if mi.postings[term] == nil {
+					mi.postings[term] = map[int][]int{}
+				}
+				if mi.postings[term][doc.ID] == nil {
+					mi.postings[term][doc.ID] = make([]int, len(fieldWeights))
+				}
+				mi.postings[term][doc.ID][field]++
+			}
+		}
+	}
+
+	return nil
+}
+
+func (mi *MemoryIndex) Remove(id int) error {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	mi.remove(id)
+	return nil
+}
+
+func (mi *MemoryIndex) remove(id int) {
+	var doc, ok = mi.docs[id]
+	if !ok {
+		return
+	}
+
+	for _, text := range fieldTexts(doc) {
+		for _, term := range Tokenize(text) {
+			delete(mi.postings[term], id)
+			if len(mi.postings[term]) == 0 {
+				delete(mi.postings, term)
+			}
+		}
+	}
+	delete(mi.docs, id)
+}
+
+// Search scores each document with a tf-idf sum over the indexed terms that
+// match a query word, weighted by field and by how close the match is.
+// Documents matching more of the query words rank higher.
+func (mi *MemoryIndex) Search(q Query) (*Result, error) {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+
+	var words = Tokenize(q.Text)
+	var scores = map[int]float64{}
+	var covered = map[int]int{}
+	var matched = map[int]map[string]bool{}
+
+	for _, word := range words {
+		var seen = map[int]bool{}
+		for term, docs := range mi.postings {
+			var closeness = Match(word, term)
+			if closeness == 0 {
+				continue
+			}
+
+			var idf = math.Log(1 + float64(len(mi.docs))/float64(len(docs)))
+			for id, freqs := range docs {
+				for field, freq := range freqs {
+					scores[id] += closeness * fieldWeights[field] * float64(freq) * idf
+				}
+				if matched[id] == nil {
+					matched[id] = map[string]bool{}
+				}
+				matched[id][term] = true
+				if !seen[id] {
+					seen[id] = true
+					covered[id]++
+				}
+			}
+		}
+	}
+
+	var hits = []Hit{}
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score * float64(covered[id]) / float64(len(words))})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+
+	var limit, offset = q.window()
+	var res = Result{Total: len(hits)}
+	for i := offset; i < len(hits) && i < offset+limit; i++ {
+		var hit = hits[i]
+		hit.Highlights = highlights(mi.docs[hit.ID], matched[hit.ID])
+		res.Hits = append(res.Hits, hit)
+	}
+
+	return &res, nil
+}
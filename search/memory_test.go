@@ -0,0 +1,103 @@
+package search
+
+import (
+	"testing"
+)
+
+var catalogue = []Document{
+	{ID: 1, Name: "Tenda Dome", Description: "Tenda ringan untuk 4 orang", Category: "Tenda", Tags: []string{"camping", "waterproof"}},
+	{ID: 2, Name: "Sleeping Bag", Description: "Hangat untuk tidur di dalam tenda", Category: "Tidur", Tags: []string{"camping"}},
+	{ID: 3, Name: "Kompor Portable", Description: "Kompor gas lipat", Category: "Masak", Tags: []string{"dapur"}},
+	{ID: 4, Name: "Carrier 60L", Description: "Tas gunung dengan rain cover", Category: "Tas", Tags: []string{"hiking", "waterproof"}},
+}
+
+func hitIDs(res *Result) []int {
+	var ids = []int{}
+	for _, hit := range res.Hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+func sameIDs(a []int, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMemoryIndexSearch(t *testing.T) {
+	var index = NewMemoryIndex()
+	if err := index.Index(catalogue...); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name  string
+		query Query
+		want  []int
+		total int
+	}{
+		{"name outranks description", Query{Text: "tenda"}, []int{1, 2}, 2},
+		{"category", Query{Text: "masak"}, []int{3}, 1},
+		{"tag", Query{Text: "waterproof"}, []int{1, 4}, 2},
+		{"tag shared by several products", Query{Text: "camping"}, []int{1, 2}, 2},
+		{"more query words matched ranks higher", Query{Text: "waterproof hiking"}, []int{4, 1}, 2},
+		{"typo", Query{Text: "kompr"}, []int{3}, 1},
+		{"prefix", Query{Text: "sleep"}, []int{2}, 1},
+		{"no match", Query{Text: "senter"}, []int{}, 0},
+		{"limit", Query{Text: "tenda", Limit: 1}, []int{1}, 2},
+		{"offset", Query{Text: "tenda", Limit: 1, Offset: 1}, []int{2}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := index.Search(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hitIDs(res); !sameIDs(got, tt.want) || res.Total != tt.total {
+				t.Fatalf("hits = %v (total %d), want %v (total %d)", got, res.Total, tt.want, tt.total)
+			}
+		})
+	}
+}
+
+func TestMemoryIndexHighlightsMatchingFields(t *testing.T) {
+	var index = NewMemoryIndex()
+	index.Index(catalogue...)
+
+	res, _ := index.Search(Query{Text: "tenda"})
+	var highlights = res.Hits[0].Highlights
+	for _, field := range []string{"name", "description", "category"} {
+		if highlights[field] == "" {
+			t.Errorf("missing %s highlight in %v", field, highlights)
+		}
+	}
+	if _, ok := highlights["tags"]; ok {
+		t.Errorf("tags do not match but were highlighted: %v", highlights)
+	}
+}
+
+func TestMemoryIndexReindexAndRemove(t *testing.T) {
+	var index = NewMemoryIndex()
+	index.Index(catalogue...)
+
+	index.Index(Document{ID: 3, Name: "Kompor Lipat", Category: "Masak"})
+	if res, _ := index.Search(Query{Text: "portable"}); res.Total != 0 {
+		t.Fatalf("old name still found after reindexing: %v", hitIDs(res))
+	}
+	if res, _ := index.Search(Query{Text: "lipat"}); !sameIDs(hitIDs(res), []int{3}) {
+		t.Fatalf("new name not found after reindexing: %v", hitIDs(res))
+	}
+
+	index.Remove(1)
+	if res, _ := index.Search(Query{Text: "tenda"}); !sameIDs(hitIDs(res), []int{2}) {
+		t.Fatalf("removed product still found: %v", hitIDs(res))
+	}
+}
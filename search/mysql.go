@@ -0,0 +1,220 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+const (
+	fullTextAll  = "ft_products_search"
+	fullTextName = "ft_products_name"
+
+	// maxExpansions caps how many indexed terms a misspelt word expands to.
+	maxExpansions = 5
+)
+
+// MySQLEngine searches the products table with FULLTEXT indexes. MySQL has
+// no typo tolerance of its own, so the engine keeps the catalogue
+// vocabulary in memory and rewrites each query word into the known terms
+// it is close to before asking MySQL.
+type MySQLEngine struct {
+	db *gorm.DB
+
+	mu       sync.RWMutex
+	vocab    map[string]int
+	docTerms map[int][]string
+}
+
+func NewMySQLEngine(db *gorm.DB) *MySQLEngine {
+	return &MySQLEngine{
+		db:       db,
+		vocab:    map[string]int{},
+		docTerms: map[int][]string{},
+	}
+}
+
+// EnsureIndex creates the FULLTEXT indexes the engine relies on.
+func (me *MySQLEngine) EnsureIndex() error {
+	var indexes = map[string]string{
+		fullTextAll:  "name, description, category, tags",
+		fullTextName: "name",
+	}
+	for name, columns := range indexes {
+		var count int64
+		if err := me.db.Raw("SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = 'products' AND index_name = ?", name).
+			Scan(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		if err := me.db.Exec("ALTER TABLE products ADD FULLTEXT INDEX " + name + " (" + columns + ")").Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (me *MySQLEngine) Index(docs ...Document) error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+
+	for _, doc := range docs {
+		me.remove(doc.ID)
+
+		var terms = []string{}
+		var seen = map[string]bool{}
+		for _, text := range fieldTexts(doc) {
+			for _, term := range Tokenize(text) {
+				if !seen[term] {
+					seen[term] = true
+					terms = append(terms, term)
+					me.vocab[term]++
+				}
+			}
+		}
+		me.docTerms[doc.ID] = terms
+	}
+
+	return nil
+}
+
+func (me *MySQLEngine) Remove(id int) error {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+
+	me.remove(id)
+	return nil
+}
+
+func (me *MySQLEngine) remove(id int) {
+	for _, term := range me.docTerms[id] {
+		me.vocab[term]--
+		if me.vocab[term] <= 0 {
+			delete(me.vocab, term)
+		}
+	}
+	delete(me.docTerms, id)
+}
+
+// expand rewrites every query word into a boolean-mode group of the indexed
+// terms it matches, best matches first.
+func (me *MySQLEngine) expand(words []string) string {
+	me.mu.RLock()
+	defer me.mu.RUnlock()
+
+	var groups = []string{}
+	for _, word := range words {
+		type candidate struct {
+			term      string
+			closeness float64
+		}
+		var candidates = []candidate{}
+		for term := range me.vocab {
+			if closeness := Match(word, term); closeness > 0 {
+				candidates = append(candidates, candidate{term, closeness})
+			}
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			return candidates[i].closeness > candidates[j].closeness
+		})
+
+		var group = []string{word}
+		if len(word) >= 3 {
+			group = append(group, word+"*")
+		}
+		for i := 0; i < len(candidates) && i < maxExpansions; i++ {
+			if candidates[i].term != word {
+				group = append(group, candidates[i].term)
+			}
+		}
+		groups = append(groups, "("+strings.Join(group, " ")+")")
+	}
+
+	return strings.Join(groups, " ")
+}
+
+func (me *MySQLEngine) Search(q Query) (*Result, error) {
+	var words = Tokenize(q.Text)
+	if len(words) == 0 {
+		return &Result{}, nil
+	}
+
+	var expr = me.expand(words)
+	var limit, offset = q.window()
+
+	var base = func() *gorm.DB {
+		return me.db.Table("products").
+			Where("deleted_at IS NULL").
+			Where("MATCH(name, description, category, tags) AGAINST(? IN BOOLEAN MODE)", expr)
+	}
+
+	var total int64
+	if err := base().Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var rows = []struct {
+		Id          int
+		Name        string
+		Description string
+		Category    string
+		Tags        string
+		Score       float64
+	}{}
+	if err := base().
+		Select("id, name, description, category, tags, "+
+			"MATCH(name) AGAINST(? IN BOOLEAN MODE) * 3 + MATCH(name, description, category, tags) AGAINST(? IN BOOLEAN MODE) AS score", expr, expr).
+		Order("score DESC, id").Limit(limit).Offset(offset).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	var res = Result{Total: int(total)}
+	for _, row := range rows {
+		var doc = Document{
+			ID:          row.Id,
+			Name:        row.Name,
+			Description: row.Description,
+			Category:    row.Category,
+			Tags:        SplitTags(row.Tags),
+		}
+		res.Hits = append(res.Hits, Hit{
+			ID:         row.Id,
+			Score:      row.Score,
+			Highlights: highlights(doc, matchedTerms(doc, words)),
+		})
+	}
+
+	return &res, nil
+}
+
+// matchedTerms returns the words of doc that match one of the query words.
+func matchedTerms(doc Document, words []string) map[string]bool {
+	var matched = map[string]bool{}
+	for _, text := range fieldTexts(doc) {
+		for _, term := range Tokenize(text) {
+			for _, word := range words {
+				if Match(word, term) > 0 {
+					matched[term] = true
+					break
+				}
+			}
+		}
+	}
+	return matched
+}
+
+// SplitTags parses the comma separated tag list stored on a product.
+func SplitTags(tags string) []string {
+	var res = []string{}
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			res = append(res, tag)
+		}
+	}
+	return res
+}
@@ -0,0 +1,93 @@
+package search
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	EngineMySQL  = "mysql"
+	EngineMemory = "memory"
+)
+
+// Document is the searchable view of a catalogue product.
+type Document struct {
+	ID          int
+	Name        string
+	Description string
+	Category    string
+	Tags        []string
+}
+
+type Query struct {
+	Text   string
+	Limit  int
+	Offset int
+}
+
+// Hit is a matching document. Highlights holds a snippet per matching field
+// with the matched words wrapped in <em> tags.
+type Hit struct {
+	ID         int               `json:"id"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+type Result struct {
+	Hits  []Hit
+	Total int
+}
+
+// Engine indexes documents and answers ranked, typo tolerant queries.
+type Engine interface {
+	Index(docs ...Document) error
+	Remove(id int) error
+	Search(q Query) (*Result, error)
+}
+
+// New returns the engine named by kind. The MySQL engine falls back to the
+// in-memory index when its FULLTEXT indexes cannot be created.
+func New(kind string, db *gorm.DB) Engine {
+	if strings.ToLower(kind) == EngineMemory {
+		return NewMemoryIndex()
+	}
+
+	var engine = NewMySQLEngine(db)
+	if err := engine.EnsureIndex(); err != nil {
+		logrus.Warn("Search : cannot prepare FULLTEXT index, using in-memory index instead, ", err.Error())
+		return NewMemoryIndex()
+	}
+
+	return engine
+}
+
+func (q Query) window() (int, int) {
+	var limit, offset = q.Limit, q.Offset
+	if limit < 1 {
+		limit = 10
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+// highlights builds the snippet of every field of doc that contains one of
+// the matched terms.
+func highlights(doc Document, matched map[string]bool) map[string]string {
+	var res = map[string]string{}
+	var fields = map[string]string{
+		"name":        doc.Name,
+		"description": doc.Description,
+		"category":    doc.Category,
+		"tags":        strings.Join(doc.Tags, ", "),
+	}
+	for field, text := range fields {
+		if snippet, ok := Highlight(text, matched); ok {
+			res[field] = snippet
+		}
+	}
+	return res
+}
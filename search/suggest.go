@@ -0,0 +1,141 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	SuggestProduct  = "product"
+	SuggestCategory = "category"
+	SuggestQuery    = "query"
+)
+
+type Suggestion struct {
+	Text      string `json:"text"`
+	Type      string `json:"type"`
+	ProductID int    `json:"product_id,omitempty"`
+	weight    int
+}
+
+type Suggestions struct {
+	Products   []Suggestion `json:"products"`
+	Categories []Suggestion `json:"categories"`
+	Queries    []Suggestion `json:"queries"`
+}
+
+// PopularQuery is a past search that found results, with how often it was
+// searched.
+type PopularQuery struct {
+	Query    string
+	Searches int
+}
+
+type suggestKey struct {
+	key  string
+	item int
+}
+
+// Suggester is an in-memory prefix index over product names, categories and
+// popular queries. Every word of a suggestion is indexed, so "dome" also
+// suggests "Tenda Dome 4 Orang". Rebuild swaps the whole index at once.
+type Suggester struct {
+	mu    sync.RWMutex
+	keys  []suggestKey
+	items []Suggestion
+}
+
+func NewSuggester() *Suggester {
+	return &Suggester{}
+}
+
+func normalize(text string) string {
+	return strings.Join(Tokenize(text), " ")
+}
+
+func (s *Suggester) Rebuild(products []Document, queries []PopularQuery) {
+	var items = []Suggestion{}
+	var categories = map[string]int{}
+	var categoryNames = map[string]string{}
+
+	for _, product := range products {
+		items = append(items, Suggestion{Text: product.Name, Type: SuggestProduct, ProductID: product.ID, weight: 1})
+		if key := normalize(product.Category); key != "" {
+			categories[key]++
+			categoryNames[key] = product.Category
+		}
+	}
+	for key, count := range categories {
+		items = append(items, Suggestion{Text: categoryNames[key], Type: SuggestCategory, weight: count})
+	}
+	for _, query := range queries {
+		items = append(items, Suggestion{Text: query.Query, Type: SuggestQuery, weight: query.Searches})
+	}
+
+	var keys = []suggestKey{}
+	for i, item := range items {
+		var words = Tokenize(item.Text)
+		for w := range words {
+			keys = append(keys, suggestKey{key: strings.Join(words[w:], " "), item: i})
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].key < keys[j].key
+	})
+
+	s.mu.Lock()
+	s.keys = keys
+	s.items = items
+	s.mu.Unlock()
+}
+
+// Suggest returns up to limit suggestions of each type for the prefix,
+// the most popular first.
+func (s *Suggester) Suggest(prefix string, limit int) Suggestions {
+	var res = Suggestions{Products: []Suggestion{}, Categories: []Suggestion{}, Queries: []Suggestion{}}
+
+	prefix = normalize(prefix)
+	if prefix == "" {
+		return res
+	}
+
+	s.mu.RLock()
+	var found = []Suggestion{}
+	var seen = map[int]bool{}
+	for i := sort.Search(len(s.keys), func(i int) bool { return s.keys[i].key >= prefix }); i < len(s.keys); i++ {
+		if !strings.HasPrefix(s.keys[i].key, prefix) {
+			break
+		}
+		if !seen[s.keys[i].item] {
+			seen[s.keys[i].item] = true
+			found = append(found, s.items[s.keys[i].item])
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(found, func(i, j int) bool {
+		var pi = strings.HasPrefix(normalize(found[i].Text), prefix)
+		var pj = strings.HasPrefix(normalize(found[j].Text), prefix)
+		if pi != pj {
+			return pi
+		}
+		if found[i].weight != found[j].weight {
+			return found[i].weight > found[j].weight
+		}
+		return found[i].Text < found[j].Text
+	})
+
+	for _, item := range found {
+		switch {
+		case item.Type == SuggestProduct && len(res.Products) < limit:
+			res.Products = append(res.Products, item)
+		case item.Type == SuggestCategory && len(res.Categories) < limit:
+			res.Categories = append(res.Categories, item)
+		case item.Type == SuggestQuery && len(res.Queries) < limit:
+			res.Queries = append(res.Queries, item)
+		}
+	}
+
+	return res
+}
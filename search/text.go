@@ -0,0 +1,166 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	snippetBefore = 60
+	snippetLength = 200
+)
+
+// Tokenize lower-cases text and splits it into words of letters and digits.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// maxEdits is how many typos a query word of that length may contain.
+func maxEdits(term string) int {
+	switch n := len([]rune(term)); {
+	case n <= 3:
+		return 0
+	case n <= 6:
+		return 1
+	}
+	return 2
+}
+
+// Match reports how well an indexed term matches a query word: 1 for an
+// exact match, less for a prefix or a match within the allowed typos, and
+// 0 when they do not match.
+func Match(query string, term string) float64 {
+	if query == term {
+		return 1
+	}
+	if len(query) >= 3 && strings.HasPrefix(term, query) {
+		return 0.8
+	}
+
+	var limit = maxEdits(query)
+	if limit == 0 {
+		return 0
+	}
+	if d := distance(query, term, limit); d <= limit {
+		return 0.7 - 0.15*float64(d-1)
+	}
+	return 0
+}
+
+// distance is the Levenshtein distance between a and b, giving up with
+// limit+1 as soon as it is known to exceed limit.
+func distance(a string, b string, limit int) int {
+	var ra, rb = []rune(a), []rune(b)
+	if abs(len(ra)-len(rb)) > limit {
+		return limit + 1
+	}
+
+	var prev = make([]int, len(rb)+1)
+	var curr = make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		var best = curr[0]
+		for j := 1; j <= len(rb); j++ {
+			var cost = 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			best = min(best, curr[j])
+		}
+		if best > limit {
+			return limit + 1
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Highlight returns a snippet of text around the first word found in
+// matched, with every matched word wrapped in <em> tags. The text is HTML
+// escaped. ok is false when no word of text matched.
+func Highlight(text string, matched map[string]bool) (string, bool) {
+	type span struct{ start, end int }
+
+	var spans = []span{}
+	var start = -1
+	for i, r := range text + " " {
+		var isWord = i < len(text) && (unicode.IsLetter(r) || unicode.IsDigit(r))
+		if isWord && start < 0 {
+			start = i
+		}
+		if !isWord && start >= 0 {
+			if matched[strings.ToLower(text[start:i])] {
+				spans = append(spans, span{start, i})
+			}
+			start = -1
+		}
+	}
+	if len(spans) == 0 {
+		return "", false
+	}
+
+	// Snippet bounds are byte offsets; move them to rune and then word
+	// boundaries so multi-byte characters are never cut in half.
+	var from = max(0, spans[0].start-snippetBefore)
+	for from < spans[0].start && !utf8.RuneStart(text[from]) {
+		from++
+	}
+	for from > 0 && from < spans[0].start {
+		if r, _ := utf8.DecodeLastRuneInString(text[:from]); unicode.IsSpace(r) {
+			break
+		}
+		_, size := utf8.DecodeRuneInString(text[from:])
+		from += size
+	}
+
+	var to = min(len(text), from+snippetLength)
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to++
+	}
+	for to < len(text) {
+		r, size := utf8.DecodeRuneInString(text[to:])
+		if unicode.IsSpace(r) {
+			break
+		}
+		to += size
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	var pos = from
+	for _, s := range spans {
+		if s.start < from || s.end > to {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:s.start]))
+		b.WriteString("<em>")
+		b.WriteString(html.EscapeString(text[s.start:s.end]))
+		b.WriteString("</em>")
+		pos = s.end
+	}
+	b.WriteString(html.EscapeString(text[pos:to]))
+	if to < len(text) {
+		b.WriteString("…")
+	}
+
+	return b.String(), true
+}
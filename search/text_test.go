@@ -0,0 +1,83 @@
+package search
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestMatch(t *testing.T) {
+	var tests = []struct {
+		query string
+		term  string
+		want  float64
+	}{
+		{"tenda", "tenda", 1},
+		{"ten", "tenda", 0.8},
+		{"te", "tenda", 0},
+		{"tedna", "tenda", 0},
+		{"tnda", "tenda", 0.7},
+		{"sleepng", "sleeping", 0.7},
+		{"slepeng", "sleeping", 0.55},
+		{"kompor", "carrier", 0},
+	}
+
+	for _, tt := range tests {
+		if got := Match(tt.query, tt.term); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.query, tt.term, got, tt.want)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	var tests = []struct {
+		name    string
+		text    string
+		matched []string
+		want    string
+		wantOk  bool
+	}{
+		{"matched word", "Tenda Dome 4 orang", []string{"dome"}, "Tenda <em>Dome</em> 4 orang", true},
+		{"every match", "Tenda tenda TENDA", []string{"tenda"}, "<em>Tenda</em> <em>tenda</em> <em>TENDA</em>", true},
+		{"no match", "Tenda Dome", []string{"kompor"}, "", false},
+		{"escapes html", "<b>Tenda</b> & pasak", []string{"tenda"}, "&lt;b&gt;<em>Tenda</em>&lt;/b&gt; &amp; pasak", true},
+		{"multi-byte word", "Kompor café très chaud", []string{"café"}, "Kompor <em>café</em> très chaud", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var matched = map[string]bool{}
+			for _, word := range tt.matched {
+				matched[word] = true
+			}
+			got, ok := Highlight(tt.text, matched)
+			if got != tt.want || ok != tt.wantOk {
+				t.Fatalf("Highlight = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestHighlightCutsLongTextOnRuneBoundaries(t *testing.T) {
+	var matched = map[string]bool{"tenda": true}
+
+	// Runs of multi-byte letters without spaces put the snippet bounds in
+	// the middle of a rune unless Highlight moves them. The second bytes of
+	// "Š" and "Å" read as spaces when taken for runes on their own.
+	for _, filler := range []string{"é", "Š", "Å", "日本", "🏕"} {
+		for shift := 0; shift < 4; shift++ {
+			var text = strings.Repeat("a", shift) + strings.Repeat(filler, 80) + " tenda " + strings.Repeat(filler, 150)
+			got, ok := Highlight(text, matched)
+			if !ok {
+				t.Fatalf("no snippet for %q", filler)
+			}
+			if !utf8.ValidString(got) {
+				t.Fatalf("snippet for %q shifted %d is not valid UTF-8: %q", filler, shift, got)
+			}
+			if !strings.Contains(got, "<em>tenda</em>") {
+				t.Fatalf("snippet %q lost the match", got)
+			}
+		}
+	}
+}
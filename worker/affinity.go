@@ -0,0 +1,25 @@
+package worker
+
+import (
+	"rentcamp/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// AffinityBuilder recounts which products are rented together, so related
+// products and recommendations follow the order history.
+type AffinityBuilder struct {
+	recommendations model.RecommendationModelInterface
+}
+
+func NewAffinityBuilder(recommendations model.RecommendationModelInterface) *AffinityBuilder {
+	return &AffinityBuilder{
+		recommendations: recommendations,
+	}
+}
+
+func (ab *AffinityBuilder) Run() {
+	if err := ab.recommendations.RebuildAffinity(); err != nil {
+		logrus.Error("Worker : cannot rebuild product affinity, ", err.Error())
+	}
+}
@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"fmt"
+	"net/url"
+	"rentcamp/config"
+	"rentcamp/helper"
+	"rentcamp/model"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CartReminder emails customers once about carts they left behind and
+// archives carts that stayed untouched for much longer.
+type CartReminder struct {
+	config config.Config
+	carts  model.CartModelInterface
+	mailer helper.Mailer
+}
+
+func NewCartReminder(carts model.CartModelInterface, mailer helper.Mailer, cfg config.Config) *CartReminder {
+	return &CartReminder{
+		config: cfg,
+		carts:  carts,
+		mailer: mailer,
+	}
+}
+
+func (cr *CartReminder) Run() {
+	var now = time.Now()
+
+	var abandoned = cr.carts.FindAbandonedCarts(now.Add(-time.Duration(cr.config.CartReminderHours) * time.Hour))
+	for _, cart := range abandoned {
+		if err := cr.mailer.Send(cart.Email, "You left something in your cart", cr.reminderBody(cart)); err != nil {
+			continue
+		}
+		cr.carts.MarkReminderSent(cart.CartID)
+	}
+
+	var archived = cr.carts.ArchiveStaleCarts(now.AddDate(0, 0, -cr.config.CartArchiveDays))
+
+	if len(abandoned) > 0 || archived > 0 {
+		logrus.Info("Worker : sent ", len(abandoned), " cart reminders and archived ", archived, " carts")
+	}
+}
+
+func (cr *CartReminder) reminderBody(cart model.AbandonedCart) string {
+	var unsubscribe = cr.config.AppBaseURL + "/customer/unsubscribe?token=" +
+		url.QueryEscape(helper.GenerateUnsubscribeToken(cr.config.Secret, cart.UserID))
+
+	return fmt.Sprintf("Hi %s,\n\n"+
+		"You still have %d item(s) waiting in your RentCamp cart. "+
+		"Gear is booked quickly, so finish your rental while it is still available:\n%s\n\n"+
+		"Don't want these emails? Unsubscribe here:\n%s\n",
+		cart.Name, cart.Items, cr.config.AppBaseURL+"/me/cart", unsubscribe)
+}
@@ -0,0 +1,92 @@
+package worker
+
+import (
+	"rentcamp/config"
+	"rentcamp/model"
+	"rentcamp/search"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	popularQueryDays  = 30
+	popularQueryLimit = 500
+)
+
+// SuggestIndexer rebuilds the autocomplete index from the catalogue and the
+// search log.
+type SuggestIndexer struct {
+	config    config.Config
+	products  model.ProductModelInterface
+	searchLog model.SearchLogModelInterface
+	suggester *search.Suggester
+	changed   chan struct{}
+}
+
+func NewSuggestIndexer(products model.ProductModelInterface, searchLog model.SearchLogModelInterface, suggester *search.Suggester, cfg config.Config) *SuggestIndexer {
+	return &SuggestIndexer{
+		config:    cfg,
+		products:  products,
+		searchLog: searchLog,
+		suggester: suggester,
+		changed:   make(chan struct{}, 1),
+	}
+}
+
+func (si *SuggestIndexer) Run() {
+	var docs = []search.Document{}
+	for _, product := range si.products.SelectAll("") {
+		docs = append(docs, search.Document{ID: product.Id, Name: product.Name, Category: product.Category})
+	}
+
+	var queries = []search.PopularQuery{}
+	for _, stat := range si.searchLog.Popular(time.Now().AddDate(0, 0, -popularQueryDays), popularQueryLimit, si.config.SuggestMinSearchers) {
+		if si.blocked(stat.Query) {
+			continue
+		}
+		queries = append(queries, search.PopularQuery{Query: stat.Query, Searches: stat.Searches})
+	}
+
+	si.suggester.Rebuild(docs, queries)
+}
+
+// blocked reports whether query contains a word of the suggestion
+// blocklist.
+func (si *SuggestIndexer) blocked(query string) bool {
+	for _, word := range search.Tokenize(query) {
+		for _, blocked := range si.config.SuggestBlocklist {
+			if word == blocked {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ProductChanged is registered with the product model so suggestions never
+// show deleted or renamed products. It only queues a rebuild for Watch, so
+// the request that changed the product does not wait for it, and a burst
+// of changes is rebuilt once.
+func (si *SuggestIndexer) ProductChanged(product model.Product, deleted bool) {
+	select {
+	case si.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Watch rebuilds the index whenever products changed. It runs until the
+// process exits.
+func (si *SuggestIndexer) Watch() {
+	for range si.changed {
+		si.Run()
+	}
+}
+
+// PruneLog deletes searches older than the configured retention.
+func (si *SuggestIndexer) PruneLog() {
+	var pruned = si.searchLog.Prune(time.Now().AddDate(0, 0, -si.config.SearchLogRetentionDays))
+	if pruned > 0 {
+		logrus.Info("Worker : pruned ", pruned, " logged searches")
+	}
+}
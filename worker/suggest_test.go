@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"rentcamp/config"
+	"rentcamp/model"
+	"rentcamp/search"
+	"testing"
+	"time"
+)
+
+type stubProducts struct {
+	model.ProductModelInterface
+	loads int
+}
+
+func (s *stubProducts) SelectAll(sort string) []model.Product {
+	s.loads++
+	return []model.Product{{Id: 1, Name: "Tenda Dome", Category: "Tenda"}}
+}
+
+type stubSearchLog struct {
+	model.SearchLogModelInterface
+	minSearchers int
+}
+
+func (s *stubSearchLog) Popular(since time.Time, limit int, minSearchers int) []model.SearchQueryStat {
+	s.minSearchers = minSearchers
+	return []model.SearchQueryStat{{Query: "tenda murah", Searches: 5}, {Query: "tenda judi online", Searches: 50}}
+}
+
+func TestSuggestIndexerFiltersQueries(t *testing.T) {
+	var searchLog = &stubSearchLog{}
+	var suggester = search.NewSuggester()
+	var cfg = config.Config{SuggestMinSearchers: 3, SuggestBlocklist: []string{"judi"}}
+
+	NewSuggestIndexer(&stubProducts{}, searchLog, suggester, cfg).Run()
+
+	if searchLog.minSearchers != 3 {
+		t.Fatalf("popular queries read with %d minimum searchers, want 3", searchLog.minSearchers)
+	}
+	if queries := suggester.Suggest("tenda", 10).Queries; len(queries) != 1 || queries[0].Text != "tenda murah" {
+		t.Fatalf("suggested queries = %+v, want only the query without a blocked word", queries)
+	}
+}
+
+func TestSuggestIndexerQueuesProductChanges(t *testing.T) {
+	var products = &stubProducts{}
+	var indexer = NewSuggestIndexer(products, &stubSearchLog{}, search.NewSuggester(), config.Config{})
+
+	// Changes only queue a rebuild, and a burst is coalesced into one.
+	for i := 0; i < 5; i++ {
+		indexer.ProductChanged(model.Product{Id: 1}, false)
+	}
+	if products.loads != 0 {
+		t.Fatalf("product change rebuilt the index %d times in the caller", products.loads)
+	}
+	if len(indexer.changed) != 1 {
+		t.Fatalf("%d rebuilds queued, want 1", len(indexer.changed))
+	}
+
+	close(indexer.changed)
+	indexer.Watch()
+	if products.loads != 1 {
+		t.Fatalf("Watch rebuilt the index %d times, want 1", products.loads)
+	}
+}
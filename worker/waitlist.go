@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"fmt"
+	"rentcamp/config"
+	"rentcamp/helper"
+	"rentcamp/model"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WaitlistOfferer hands units freed by cancellations and returns to the
+// next customers on a product's waitlist.
+type WaitlistOfferer struct {
+	config     config.Config
+	waitlist   model.WaitlistModelInterface
+	dispatcher *helper.Dispatcher
+}
+
+func NewWaitlistOfferer(waitlist model.WaitlistModelInterface, dispatcher *helper.Dispatcher, cfg config.Config) *WaitlistOfferer {
+	return &WaitlistOfferer{
+		config:     cfg,
+		waitlist:   waitlist,
+		dispatcher: dispatcher,
+	}
+}
+
+// StockFreed offers the freed units to the product's waitlist and emails
+// every customer who received an exclusive hold.
+func (wo *WaitlistOfferer) StockFreed(freed model.FreedStock) {
+	var holdFor = time.Duration(wo.config.WaitlistHoldMinutes) * time.Minute
+
+	for _, offer := range wo.waitlist.OfferFreedStock(freed, holdFor) {
+		wo.dispatcher.Dispatch(helper.Notification{
+			To:      offer.Email,
+			Subject: offer.ProductName + " is available for your dates",
+			Body: fmt.Sprintf("Hi %s,\n\n"+
+				"A unit of %s just became available for %s to %s. "+
+				"We are holding %d for you until %s; add it to your cart and checkout before then to keep it.\n",
+				offer.Name, offer.ProductName, offer.Entry.StartDate, offer.Entry.EndDate,
+				offer.Entry.Quantity, offer.Entry.OfferExpiresAt.Format("2006-01-02 15:04")),
+		})
+	}
+}
+
+// Run expires offers nobody took up and passes their units down the line.
+func (wo *WaitlistOfferer) Run() {
+	freed, err := wo.waitlist.ExpireOffers()
+	if err != nil {
+		return
+	}
+	for _, units := range freed {
+		wo.StockFreed(units)
+	}
+
+	if len(freed) > 0 {
+		logrus.Info("Worker : re-offered ", len(freed), " expired waitlist holds")
+	}
+}
+
+// ReleaseHolds runs the checkout hold sweeper and offers the released
+// units to the waitlist.
+func (wo *WaitlistOfferer) ReleaseHolds(release func() []model.FreedStock) func() {
+	return func() {
+		for _, units := range release() {
+			wo.StockFreed(units)
+		}
+	}
+}
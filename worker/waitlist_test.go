@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"errors"
+	"rentcamp/config"
+	"rentcamp/model"
+	"testing"
+	"time"
+)
+
+type stubWaitlist struct {
+	model.WaitlistModelInterface
+	expired   []model.FreedStock
+	expireErr error
+	offered   []model.FreedStock
+}
+
+func (s *stubWaitlist) ExpireOffers() ([]model.FreedStock, error) {
+	return s.expired, s.expireErr
+}
+
+func (s *stubWaitlist) OfferFreedStock(freed model.FreedStock, holdFor time.Duration) []model.WaitlistOffer {
+	s.offered = append(s.offered, freed)
+	return nil
+}
+
+func TestWaitlistOffererPassesFreedStockOn(t *testing.T) {
+	var freed = []model.FreedStock{{ProductId: 7, Quantity: 1}, {ProductId: 7, Quantity: 2}}
+
+	var waitlist = &stubWaitlist{expired: freed}
+	var offerer = NewWaitlistOfferer(waitlist, nil, config.Config{WaitlistHoldMinutes: 30})
+	offerer.Run()
+	if len(waitlist.offered) != 2 {
+		t.Fatalf("expired offers re-offered %d times, want 2", len(waitlist.offered))
+	}
+
+	waitlist = &stubWaitlist{}
+	offerer = NewWaitlistOfferer(waitlist, nil, config.Config{WaitlistHoldMinutes: 30})
+	offerer.ReleaseHolds(func() []model.FreedStock { return freed })()
+	if len(waitlist.offered) != 2 || waitlist.offered[1].Quantity != 2 {
+		t.Fatalf("released holds offered as %+v, want every released hold", waitlist.offered)
+	}
+
+	waitlist = &stubWaitlist{expired: freed, expireErr: errors.New("deadlock")}
+	NewWaitlistOfferer(waitlist, nil, config.Config{WaitlistHoldMinutes: 30}).Run()
+	if len(waitlist.offered) != 0 {
+		t.Fatalf("offered %+v after ExpireOffers failed, want nothing", waitlist.offered)
+	}
+}
@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"fmt"
+	"net/url"
+	"rentcamp/config"
+	"rentcamp/helper"
+	"rentcamp/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WishlistNotifier tells customers when a product on their wishlist gets
+// cheaper or comes back in stock.
+type WishlistNotifier struct {
+	config   config.Config
+	wishlist model.WishlistModelInterface
+	mailer   helper.Mailer
+}
+
+func NewWishlistNotifier(wishlist model.WishlistModelInterface, mailer helper.Mailer, cfg config.Config) *WishlistNotifier {
+	return &WishlistNotifier{
+		config:   cfg,
+		wishlist: wishlist,
+		mailer:   mailer,
+	}
+}
+
+func (wn *WishlistNotifier) Run() {
+	var sent int
+	for _, watch := range wn.wishlist.Watches() {
+		var inStock = watch.Stock > 0
+		if watch.Price == watch.LastPrice && inStock == watch.LastInStock {
+			continue
+		}
+
+		var subject string
+		switch {
+		case inStock && !watch.LastInStock:
+			subject = watch.ProductName + " is available again"
+		case watch.Price < watch.LastPrice:
+			subject = fmt.Sprintf("%s dropped in price to %d", watch.ProductName, watch.Price)
+		}
+
+		if subject != "" && watch.Email != "" && !watch.EmailOptOut {
+			if err := wn.mailer.Send(watch.Email, subject, wn.body(watch, subject)); err != nil {
+				continue
+			}
+			sent++
+		}
+
+		wn.wishlist.UpdateWatch(watch.Id, watch.Price, inStock)
+	}
+
+	if sent > 0 {
+		logrus.Info("Worker : sent ", sent, " wishlist notifications")
+	}
+}
+
+func (wn *WishlistNotifier) body(watch model.WishlistWatch, subject string) string {
+	var unsubscribe = wn.config.AppBaseURL + "/customer/unsubscribe?token=" +
+		url.QueryEscape(helper.GenerateUnsubscribeToken(wn.config.Secret, watch.UserId))
+
+	return fmt.Sprintf("Hi %s,\n\n"+
+		"Good news from your RentCamp wishlist: %s.\n%s\n\n"+
+		"Don't want these emails? Unsubscribe here:\n%s\n",
+		watch.Name, subject, fmt.Sprintf("%s/products/%d", wn.config.AppBaseURL, watch.ProductId), unsubscribe)
+}
@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"rentcamp/config"
+	"rentcamp/model"
+	"strings"
+	"testing"
+)
+
+type stubWishlist struct {
+	model.WishlistModelInterface
+	watches []model.WishlistWatch
+	updated map[int]model.WishlistWatch
+}
+
+func (s *stubWishlist) Watches() []model.WishlistWatch {
+	return s.watches
+}
+
+func (s *stubWishlist) UpdateWatch(id int, price int, inStock bool) bool {
+	s.updated[id] = model.WishlistWatch{Price: price, LastInStock: inStock}
+	return true
+}
+
+type sentMail struct {
+	to      string
+	subject string
+	body    string
+}
+
+type recordMailer struct {
+	sent []sentMail
+}
+
+func (r *recordMailer) Send(to string, subject string, body string) error {
+	r.sent = append(r.sent, sentMail{to, subject, body})
+	return nil
+}
+
+func TestWishlistNotifier(t *testing.T) {
+	var watch = func(id int, price int, stock int, lastPrice int, lastInStock bool, optOut bool) model.WishlistWatch {
+		return model.WishlistWatch{Id: id, UserId: id, Name: "Budi", Email: "budi@example.com", EmailOptOut: optOut,
+			ProductId: 7, ProductName: "Tenda Dome", Price: price, Stock: stock, LastPrice: lastPrice, LastInStock: lastInStock}
+	}
+
+	var wishlist = &stubWishlist{updated: map[int]model.WishlistWatch{}, watches: []model.WishlistWatch{
+		watch(1, 100, 2, 100, true, false),
+		watch(2, 80, 2, 100, true, false),
+		watch(3, 100, 2, 100, false, false),
+		watch(4, 120, 2, 100, true, false),
+		watch(5, 80, 2, 100, true, true),
+		watch(6, 100, 0, 100, true, false),
+	}}
+	var mailer = &recordMailer{}
+
+	NewWishlistNotifier(wishlist, mailer, config.Config{AppBaseURL: "https://rentcamp.test", Secret: "secret"}).Run()
+
+	var subjects = []string{}
+	for _, mail := range mailer.sent {
+		subjects = append(subjects, mail.subject)
+		if !strings.Contains(mail.body, "https://rentcamp.test/customer/unsubscribe?token=") {
+			t.Errorf("mail %q has no unsubscribe link", mail.subject)
+		}
+	}
+	var want = []string{"Tenda Dome dropped in price to 80", "Tenda Dome is available again"}
+	if strings.Join(subjects, "|") != strings.Join(want, "|") {
+		t.Fatalf("sent %q, want %q", subjects, want)
+	}
+
+	// Every change is remembered, mailed or not, so it is not reported twice.
+	for _, id := range []int{2, 3, 4, 5, 6} {
+		if _, found := wishlist.updated[id]; !found {
+			t.Errorf("watch %d was not updated", id)
+		}
+	}
+	if _, found := wishlist.updated[1]; found {
+		t.Error("an unchanged watch should not be updated")
+	}
+}
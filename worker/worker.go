@@ -0,0 +1,32 @@
+package worker
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Every runs job in the background once per interval until the process
+// exits. A panicking run is logged and does not stop later runs.
+func Every(interval time.Duration, name string, job func()) {
+	go func() {
+		var ticker = time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			run(name, job)
+		}
+	}()
+
+	logrus.Info("Worker : ", name, " scheduled every ", interval)
+}
+
+func run(name string, job func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			logrus.Error("Worker : ", name, " panicked, ", r)
+		}
+	}()
+
+	job()
+}